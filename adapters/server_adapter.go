@@ -2,23 +2,26 @@
 package adapters
 
 import (
-	"cid_retranslator_walk/cidparser"
 	"cid_retranslator_walk/constants"
+	"cid_retranslator_walk/eventdict"
 	"cid_retranslator_walk/models"
 	"cid_retranslator_walk/server"// Додаємо для Stats
+	"cid_retranslator_walk/state"
 	"fmt"
 	"log/slog"
-	"strings"
 
 )
 
 type Adapter struct {
-	EventMap cidparser.EventMap
+	Events *eventdict.Dictionary
+	// Стан охорони розділів ППК за міткою конвеєра (порожній, якщо відстеження вимкнене)
+	Partitions func(pipeline string, deviceID int) []state.Partition
 }
 
-func NewAdapter(eventMap cidparser.EventMap) *Adapter {
+func NewAdapter(events *eventdict.Dictionary, partitions func(pipeline string, deviceID int) []state.Partition) *Adapter {
 	return &Adapter{
-		EventMap: eventMap,
+		Events:     events,
+		Partitions: partitions,
 	}
 }
 
@@ -26,13 +29,13 @@ func NewAdapter(eventMap cidparser.EventMap) *Adapter {
 func (ad Adapter) StreamDevicesToUI(serverDeviceChan <-chan server.Device, uiPPKChan chan<- *models.PPKItem) {
 	slog.Info("Device adapter started")
 	for device := range serverDeviceChan {
-		//status := determineDeviceStatus(device.LastEvent)
-
 		uiItem := &models.PPKItem{
+			Pipeline: device.Pipeline,
 			Number: device.ID,
-			Name:   fmt.Sprintf("%03d", device.ID),
+			Name:   deviceName(device.Pipeline, device.ID),
 			Event:  device.LastEvent,
 			Date:   device.LastEventTime,
+			Status: ad.deviceStatus(device.Pipeline, device.ID),
 		}
 
 		// Non-blocking send
@@ -53,14 +56,11 @@ func (ad Adapter) StreamEventsToUI(serverEventChan <-chan server.GlobalEvent, ui
 		code := event.Data[11:15]
 		group := event.Data[15:17]
 		zone := event.Data[17:20]
-		eventType, desc, found := ad.EventMap.GetEventDescriptions(code)
-		if !found {
-			continue
-		}
+		eventType, desc, _ := ad.Events.Describe(code)
 		priority, eventType := ad.DetermineEventPriority(code, eventType)
 		uiEvent := &models.EventItem{
 			Time:     event.Time,
-			Device:   fmt.Sprint(event.DeviceID),
+			Device:   eventDevice(event.Pipeline, event.DeviceID),
 			Code:     code,
 			Type:     eventType,
 			Desc:     desc,
@@ -94,10 +94,7 @@ func (ad Adapter) LoadDeviceEvents(server *server.Server, deviceID int, uiDetail
 		group := ev.Data[15:17]
 		zone := ev.Data[17:20]
 
-		eventType, desc, found := ad.EventMap.GetEventDescriptions(code)
-		if !found {
-			continue
-		}
+		eventType, desc, _ := ad.Events.Describe(code)
 
 		priority, eventType := ad.DetermineEventPriority(code, eventType)
 
@@ -152,10 +149,7 @@ func (ad Adapter) StreamDeviceEventsToUI(
 			group := ev.Data[15:17]
 			zone := ev.Data[17:20]
 
-			eventType, desc, found := ad.EventMap.GetEventDescriptions(code)
-			if !found {
-				continue
-			}
+			eventType, desc, _ := ad.Events.Describe(code)
 
 			priority, eventType := ad.DetermineEventPriority(code, eventType)
 
@@ -182,13 +176,13 @@ func (ad Adapter) StreamDeviceEventsToUI(
 func (ad Adapter) LoadInitialDevices(devices []server.Device, uiPPKChan chan<- *models.PPKItem) {
 	slog.Info("Loading initial devices", "count", len(devices))
 	for _, device := range devices {
-		// status := ad.determineDeviceStatus(device.LastEvent)
-
 		uiItem := &models.PPKItem{
+			Pipeline: device.Pipeline,
 			Number: device.ID,
-			Name:   fmt.Sprintf("%03d", device.ID),
+			Name:   deviceName(device.Pipeline, device.ID),
 			Event: device.LastEvent,
 			Date:   device.LastEventTime,
+			Status: ad.deviceStatus(device.Pipeline, device.ID),
 		}
 
 		select {
@@ -208,16 +202,13 @@ func (ad Adapter) LoadInitialEvents(events []server.GlobalEvent, uiEventChan cha
 		group := ev.Data[15:17]
 		zone := ev.Data[17:20]
 
-		eventType, desc, found := ad.EventMap.GetEventDescriptions(code)
-		if !found {
-			continue
-		}
+		eventType, desc, _ := ad.Events.Describe(code)
 
 		priority, eventType := ad.DetermineEventPriority(code, eventType)
 
 		uiEvent := &models.EventItem{
 			Time:     ev.Time,
-			Device:   fmt.Sprint(ev.DeviceID),
+			Device:   eventDevice(ev.Pipeline, ev.DeviceID),
 			Code:     code,
 			Type:     eventType,
 			Desc:     desc,
@@ -234,54 +225,74 @@ func (ad Adapter) LoadInitialEvents(events []server.GlobalEvent, uiEventChan cha
 	slog.Info("Initial events loaded")
 }
 
-// determineDeviceStatus визначає статус пристрою на основі останньої події
-func (ad Adapter) determineDeviceStatus(lastEvent string) string {
-	// Логіка визначення статусу на основі вмісту події
-	// Це приклад - адаптуйте під вашу логіку
+// deviceName формує назву ППК; для кількох конвеєрів додає мітку конвеєра
+func deviceName(pipeline string, id int) string {
+	if pipeline == "" {
+		return fmt.Sprintf("%03d", id)
+	}
+	return fmt.Sprintf("%s/%03d", pipeline, id)
+}
 
-	if strings.Contains(lastEvent, "error") || strings.Contains(lastEvent, "fail") {
-		return "Помилка"
+// eventDevice формує поле "пристрій" події з міткою конвеєра
+func eventDevice(pipeline string, id int) string {
+	if pipeline == "" {
+		return fmt.Sprint(id)
 	}
+	return fmt.Sprintf("%s/%d", pipeline, id)
+}
 
-	if strings.Contains(lastEvent, "warning") || strings.Contains(lastEvent, "warn") {
-		return "Попередження"
+// deviceStatus визначає стан охорони ППК за його розділами; відхилення від розкладу важливіше
+func (ad Adapter) deviceStatus(pipeline string, id int) string {
+	if ad.Partitions == nil {
+		return ""
+	}
+	parts := ad.Partitions(pipeline, id)
+	armed, status := 0, ""
+	for _, p := range parts {
+		switch p.Exception {
+		case state.ExceptionLateClose:
+			return models.StatusLateClose
+		case state.ExceptionEarlyOpen:
+			status = models.StatusEarlyOpen
+		}
+		if p.Armed {
+			armed++
+		}
 	}
 
-	// За замовчуванням - активний
-	return "Активний"
+	switch {
+	case status != "" || len(parts) == 0:
+		return status
+	case armed == len(parts):
+		return models.StatusArmed
+	case armed == 0:
+		return models.StatusDisarmed
+	default:
+		return models.StatusPartial
+	}
 }
 
-// DetermineEventPriority визначає пріоритет і тип події
+// DetermineEventPriority визначає пріоритет і тип події за категорією коду у словнику
 func (ad Adapter) DetermineEventPriority(code, event string) (int, string) {
-	// Приклад логіки визначення пріоритету
-	// Адаптуйте під ваш протокол CID
-
-	//eventLower := strings.ToLower(eventData)
-	eventType := cidparser.GetColorByEvent(code)
-
-	if strings.Contains(eventType, "unknown") {
+	class, ok := ad.Events.Classify(code)
+	if !ok {
 		return constants.UnknownEvent, event
 	}
 
-	if strings.Contains(eventType, "guard") {
+	switch class.Category {
+	case eventdict.CategoryArm:
 		return constants.GuardEvent, event
-	}
-
-	if strings.Contains(eventType, "disguard") {
+	case eventdict.CategoryDisarm:
 		return constants.DisguardEvent, event
-	}
-
-	// Успіх
-	if strings.Contains(eventType, "ok") {
+	case eventdict.CategoryRestore:
 		return constants.OkEvent, event
-	}
-
-	if strings.Contains(eventType, "alarm") {
+	case eventdict.CategoryAlarm:
 		return constants.AlarmEvent, event
+	case eventdict.CategoryOther:
+		return constants.OtherEvent, event
+	default:
+		return constants.UnknownEvent, event
 	}
-
-	// За замовчуванням - інформація
-	return constants.UnknownEvent, event
 }
 
 // formatEventDescription форматує опис події для UI
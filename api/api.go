@@ -0,0 +1,677 @@
+package api
+
+import (
+	"cid_retranslator_walk/audit"
+	"cid_retranslator_walk/bus"
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/deadletter"
+	"cid_retranslator_walk/eventdict"
+	"cid_retranslator_walk/incident"
+	"cid_retranslator_walk/logging"
+	"cid_retranslator_walk/metrics"
+	"cid_retranslator_walk/queue"
+	"cid_retranslator_walk/state"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	shutdownTimeout = 5 * time.Second
+	// defaultLogLines - скільки записів журналу повертає GET /api/logs без параметра lines
+	defaultLogLines = 100
+)
+
+// Backend - операції застосунку, доступні через API
+type Backend interface {
+	Stats() metrics.Snapshot
+	Pipelines() []PipelineStatus
+	Config() *config.Config
+	ReloadConfig(actor audit.Actor) (*config.ReloadReport, error)
+
+	DeadLetters() *deadletter.Store     // nil, якщо сховище вимкнене
+	ResubmitDeadLetter(id string) error // Повертає запис у чергу його приймача
+
+	Events() *eventdict.Dictionary // Словник кодів подій
+	State() *state.Tracker         // nil, якщо відстеження стану вимкнене
+	Incidents() *incident.Store    // nil, якщо інциденти вимкнені
+
+	Backlog() []Backlog                                         // Повідомлення, що чекають на доставку, по приймачах
+	PauseUpstream(pipeline, upstream string, paused bool) error // ErrUnknownUpstream, якщо приймача немає
+
+	Logs() *logging.Ring // Останні записи журналу
+	Bus() []bus.Stats    // Лічильники шин повідомлень; порожній, якщо шин немає
+	Audit() *audit.Log   // nil, якщо журнал аудиту вимкнений
+}
+
+// PipelineStatus - стан одного конвеєра ретрансляції
+type PipelineStatus struct {
+	Name     string           `json:"name"`
+	Listen   string           `json:"listen"`
+	Upstream string           `json:"upstream"`
+	Devices  int              `json:"devices"`
+	Stats    metrics.Snapshot `json:"stats"`
+
+	Upstreams map[string]metrics.Snapshot `json:"upstreams,omitempty"` // Додаткові приймачі маршрутизації
+}
+
+// ErrUnknownUpstream повертається, якщо конвеєра або приймача з таким іменем немає
+var ErrUnknownUpstream = errors.New("unknown pipeline or upstream")
+
+// Backlog - черга одного приймача конвеєра
+type Backlog struct {
+	Pipeline string `json:"pipeline"`
+	Upstream string `json:"upstream"`
+	queue.Backlog
+	OldestAge  time.Duration `json:"oldestAge"` // Скільки чекає найстаріше повідомлення
+	Connected  bool          `json:"connected"`
+	Paused     bool          `json:"paused"`
+	ReplayRate float64       `json:"replayRate"` // Повідомлень за секунду; 0 - без обмеження
+}
+
+// EventList - словник кодів подій з версією
+type EventList struct {
+	Version  string            `json:"version,omitempty"` // Версія постаченого events.json
+	Revision int               `json:"revision"`          // Кількість змін файлу користувача
+	Language string            `json:"language"`          // Мова описів у подіях
+	Events   []eventdict.Entry `json:"events"`
+}
+
+// Server - HTTP API для керування ретранслятором. Кожен запит має бути
+// автентифікований; доступ до обробників обмежується ролями.
+type Server struct {
+	addr     string
+	backend  Backend
+	mux      *http.ServeMux
+	auth     []Authenticator
+	failures *failedAuth
+}
+
+// New створює API сервер на адресі addr. Клієнти автентифікуються токенами
+// з поточної конфігурації backend.
+func New(addr string, backend Backend) *Server {
+	s := &Server{
+		addr:     addr,
+		backend:  backend,
+		mux:      http.NewServeMux(),
+		failures: newFailedAuth(),
+	}
+	s.auth = []Authenticator{TokenAuth{Tokens: func() []config.APIToken {
+		return backend.Config().API.Tokens
+	}}}
+	s.routes()
+	return s
+}
+
+// routes реєструє обробники з мінімальною роллю для кожного
+func (s *Server) routes() {
+	s.handle("GET /api/status", RoleViewer, s.handleStatus)
+	s.handle("GET /api/pipelines", RoleViewer, s.handlePipelines)
+	s.handle("GET /api/backlog", RoleViewer, s.handleBacklog)
+	s.handle("GET /api/logs", RoleViewer, s.handleLogs)
+	s.handle("GET /api/bus", RoleViewer, s.handleBus)
+	s.handle("GET /api/events", RoleViewer, s.handleEvents)
+	s.handle("GET /api/events/{code}", RoleViewer, s.handleEvent)
+	s.handle("GET /api/conditions", RoleViewer, s.handleConditions)
+	s.handle("GET /api/partitions", RoleViewer, s.handlePartitions)
+	s.handle("GET /api/objects/{account}/history", RoleViewer, s.handleObjectHistory)
+	s.handle("GET /api/incidents", RoleViewer, s.handleIncidents)
+	s.handle("GET /api/incidents/{id}", RoleViewer, s.handleIncident)
+	s.handle("GET /api/deadletters", RoleViewer, s.handleDeadLetters)
+	s.handle("GET /api/deadletters/{id}", RoleViewer, s.handleDeadLetter)
+
+	s.handle("PUT /api/deadletters/{id}", RoleOperator, s.handleDeadLetterUpdate)
+	s.handle("DELETE /api/deadletters/{id}", RoleOperator, s.handleDeadLetterDelete)
+	s.handle("DELETE /api/deadletters", RoleOperator, s.handleDeadLettersPurge)
+	s.handle("POST /api/deadletters/{id}/resubmit", RoleOperator, s.handleDeadLetterResubmit)
+	s.handle("DELETE /api/conditions/{id}", RoleOperator, s.handleConditionClear)
+	s.handle("POST /api/incidents/{id}/ack", RoleOperator, s.handleIncidentAck)
+	s.handle("PUT /api/events/{code}", RoleOperator, s.handleEventUpdate)
+	s.handle("DELETE /api/events/{code}", RoleOperator, s.handleEventDelete)
+
+	s.handle("GET /api/config", RoleAdmin, s.handleConfig)
+	s.handle("POST /api/config/reload", RoleAdmin, s.handleConfigReload)
+	s.handle("POST /api/events/reload", RoleAdmin, s.handleEventsReload)
+	s.handle("GET /api/audit", RoleAdmin, s.handleAudit)
+	s.handle("POST /api/pipelines/{name}/pause", RoleAdmin, s.handlePause(true))
+	s.handle("POST /api/pipelines/{name}/resume", RoleAdmin, s.handlePause(false))
+}
+
+// Handler повертає HTTP обробник API (для тестів і вбудовування)
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Run запускає HTTP сервер і блокується до скасування ctx
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("API server started", "addr", listener.Addr())
+	if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("API server stopped")
+	return nil
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.backend.Stats())
+}
+
+func (s *Server) handlePipelines(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.backend.Pipelines())
+}
+
+func (s *Server) handleBacklog(w http.ResponseWriter, r *http.Request) {
+	backlog := s.backend.Backlog()
+	if backlog == nil {
+		backlog = []Backlog{}
+	}
+	writeJSON(w, http.StatusOK, backlog)
+}
+
+// handlePause призупиняє або відновлює видачу повідомлень приймачу конвеєра;
+// параметр upstream вибирає приймач маршрутизації (за замовчуванням основний)
+func (s *Server) handlePause(paused bool) http.HandlerFunc {
+	action := audit.ActionClientResume
+	if paused {
+		action = audit.ActionClientPause
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		pipeline, upstream := r.PathValue("name"), r.URL.Query().Get("upstream")
+		if upstream == "" {
+			upstream = config.PrimaryUpstream
+		}
+		if err := s.backend.PauseUpstream(pipeline, upstream, paused); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrUnknownUpstream) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		s.record(r, audit.Entry{Action: action, Target: pipeline + "/" + upstream})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) handleBus(w http.ResponseWriter, r *http.Request) {
+	stats := s.backend.Bus()
+	if stats == nil {
+		stats = []bus.Stats{}
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleConfig повертає ефективну конфігурацію у YAML із прихованими секретами
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	data, err := yaml.Marshal(s.backend.Config().Redacted())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(data)
+}
+
+func (s *Server) handleConfigReload(w http.ResponseWriter, r *http.Request) {
+	report, err := s.backend.ReloadConfig(s.actor(r))
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleLogs повертає останні записи журналу. Параметри: lines (кількість,
+// 0 - усі збережені) і level (мінімальний рівень, за замовчуванням DEBUG).
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	lines := defaultLogLines
+	if v := r.URL.Query().Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, errors.New("lines must be a non-negative number"))
+			return
+		}
+		lines = n
+	}
+	level := slog.LevelDebug
+	if v := r.URL.Query().Get("level"); v != "" {
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	entries := []logging.Entry{}
+	if ring := s.backend.Logs(); ring != nil {
+		entries = append(entries, ring.Last(lines, level)...)
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// deadLetters повертає сховище недоставлених повідомлень або відповідає 503, якщо воно вимкнене
+func (s *Server) deadLetters(w http.ResponseWriter) *deadletter.Store {
+	store := s.backend.DeadLetters()
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, deadletter.ErrDisabled)
+	}
+	return store
+}
+
+func (s *Server) handleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if store := s.deadLetters(w); store != nil {
+		writeJSON(w, http.StatusOK, store.List())
+	}
+}
+
+func (s *Server) handleDeadLetter(w http.ResponseWriter, r *http.Request) {
+	store := s.deadLetters(w)
+	if store == nil {
+		return
+	}
+	entry, err := store.Get(r.PathValue("id"))
+	if err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// handleDeadLetterUpdate замінює кадр запису. Тіло: {"text": "..."} або {"payload": "<base64>"}
+func (s *Server) handleDeadLetterUpdate(w http.ResponseWriter, r *http.Request) {
+	store := s.deadLetters(w)
+	if store == nil {
+		return
+	}
+
+	var body struct {
+		Text    *string `json:"text"`
+		Payload []byte  `json:"payload"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	payload := body.Payload
+	if body.Text != nil {
+		payload = []byte(*body.Text)
+	}
+	if len(payload) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("text or payload is required"))
+		return
+	}
+
+	old, err := store.Get(r.PathValue("id"))
+	if err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	entry, err := store.Update(old.ID, payload)
+	if err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	s.record(r, audit.Entry{Action: audit.ActionDeadLetterEdit, Target: entry.ID, Old: old.Text, New: entry.Text})
+	writeJSON(w, http.StatusOK, entry)
+}
+
+func (s *Server) handleDeadLetterDelete(w http.ResponseWriter, r *http.Request) {
+	store := s.deadLetters(w)
+	if store == nil {
+		return
+	}
+	if err := store.Delete(r.PathValue("id")); err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	s.record(r, audit.Entry{Action: audit.ActionDeadLetterDelete, Target: r.PathValue("id")})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeadLettersPurge(w http.ResponseWriter, r *http.Request) {
+	store := s.deadLetters(w)
+	if store == nil {
+		return
+	}
+	n, err := store.Purge()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.record(r, audit.Entry{Action: audit.ActionDeadLetterPurge, Detail: fmt.Sprintf("%d entries", n)})
+	writeJSON(w, http.StatusOK, map[string]int{"purged": n})
+}
+
+// handleDeadLetterResubmit ставить повідомлення в чергу повторно; результат доставки асинхронний
+func (s *Server) handleDeadLetterResubmit(w http.ResponseWriter, r *http.Request) {
+	if err := s.backend.ResubmitDeadLetter(r.PathValue("id")); err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	s.record(r, audit.Entry{Action: audit.ActionDeadLetterResubmit, Target: r.PathValue("id")})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	events := s.backend.Events()
+	list := EventList{Language: events.Language(), Events: events.Entries()}
+	list.Version, list.Revision = events.Version()
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) handleEvent(w http.ResponseWriter, r *http.Request) {
+	entry, ok := s.backend.Events().Entry(r.PathValue("code"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("unknown event code"))
+		return
+	}
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// handleEventUpdate замінює запис користувача для коду. Поля, не передані
+// в запиті, беруться з постаченого словника.
+func (s *Server) handleEventUpdate(w http.ResponseWriter, r *http.Request) {
+	var body eventdict.Entry
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	body.Code, body.Source = r.PathValue("code"), ""
+
+	events := s.backend.Events()
+	old, _ := events.Entry(body.Code)
+	entry, err := events.Set(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.record(r, audit.Entry{Action: audit.ActionEventEdit, Target: body.Code, Old: eventFields(old), New: eventFields(entry)})
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// handleEventDelete видаляє запис користувача; знову діє постачений запис
+func (s *Server) handleEventDelete(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+	if err := s.backend.Events().Delete(code); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, eventdict.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err)
+		return
+	}
+	s.record(r, audit.Entry{Action: audit.ActionEventDelete, Target: code})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleEventsReload перечитує файли словника, змінені поза API
+func (s *Server) handleEventsReload(w http.ResponseWriter, r *http.Request) {
+	events := s.backend.Events()
+	if err := events.Reload(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	version, revision := events.Version()
+	s.record(r, audit.Entry{Action: audit.ActionEventReload, Detail: fmt.Sprintf("%d codes, version %q, revision %d", events.Len(), version, revision)})
+	writeJSON(w, http.StatusOK, map[string]any{"codes": events.Len(), "version": version, "revision": revision})
+}
+
+// eventFields подає поля запису словника для журналу аудиту
+func eventFields(e eventdict.Entry) string {
+	if e.Code == "" {
+		return ""
+	}
+	data, _ := json.Marshal(struct {
+		Type        map[string]string `json:"type"`
+		Description map[string]string `json:"description"`
+		eventdict.Class
+	}{e.Type, e.Description, e.Class})
+	return string(data)
+}
+
+// actor визначає, від чийого імені виконується запит
+func (s *Server) actor(r *http.Request) audit.Actor {
+	if id, ok := IdentityFrom(r.Context()); ok {
+		return id.Actor()
+	}
+	return audit.Actor{Name: "anonymous@" + remoteHost(r), Source: audit.SourceAPI}
+}
+
+// record додає запис у журнал аудиту (якщо він увімкнений)
+func (s *Server) record(r *http.Request, e audit.Entry) {
+	log := s.backend.Audit()
+	if log == nil {
+		return
+	}
+	if err := log.Record(s.actor(r), e); err != nil {
+		slog.Error("Failed to write audit entry", "action", e.Action, "error", err)
+	}
+}
+
+// handleAudit повертає записи журналу аудиту. Фільтри: since, until (RFC 3339),
+// actor, source, action, limit; format=csv - експорт у CSV.
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	log := s.backend.Audit()
+	if log == nil {
+		writeError(w, http.StatusServiceUnavailable, audit.ErrDisabled)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := audit.Filter{Actor: q.Get("actor"), Source: q.Get("source"), Action: q.Get("action")}
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := q.Get(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			*t = parsed
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, errors.New("limit must be a non-negative number"))
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, err := log.Query(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	switch q.Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, entries)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+		if err := audit.WriteCSV(w, entries); err != nil {
+			slog.Error("Failed to export audit log", "error", err)
+		}
+	default:
+		writeError(w, http.StatusBadRequest, errors.New("format must be json or csv"))
+	}
+}
+
+// state повертає відстеження стану або відповідає 503, якщо воно вимкнене
+func (s *Server) state(w http.ResponseWriter) *state.Tracker {
+	tracker := s.backend.State()
+	if tracker == nil {
+		writeError(w, http.StatusServiceUnavailable, state.ErrDisabled)
+	}
+	return tracker
+}
+
+func (s *Server) handleConditions(w http.ResponseWriter, r *http.Request) {
+	if tracker := s.state(w); tracker != nil {
+		writeJSON(w, http.StatusOK, tracker.Conditions())
+	}
+}
+
+func (s *Server) handlePartitions(w http.ResponseWriter, r *http.Request) {
+	if tracker := s.state(w); tracker != nil {
+		writeJSON(w, http.StatusOK, tracker.Partitions())
+	}
+}
+
+// handleObjectHistory повертає постановки і зняття об'єкта; фільтри: pipeline, partition
+func (s *Server) handleObjectHistory(w http.ResponseWriter, r *http.Request) {
+	tracker := s.state(w)
+	if tracker == nil {
+		return
+	}
+	account, err := strconv.Atoi(r.PathValue("account"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid account %q", r.PathValue("account")))
+		return
+	}
+	partition := -1
+	if v := r.URL.Query().Get("partition"); v != "" {
+		if partition, err = strconv.Atoi(v); err != nil || partition < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid partition %q", v))
+			return
+		}
+	}
+	pipeline := r.URL.Query().Get("pipeline")
+
+	list := []state.Transition{}
+	for _, tr := range tracker.History(account) {
+		if (pipeline == "" || tr.Pipeline == pipeline) && (partition < 0 || tr.Partition == partition) {
+			list = append(list, tr)
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleConditionClear закриває стан, відновлення якого не надійде
+func (s *Server) handleConditionClear(w http.ResponseWriter, r *http.Request) {
+	tracker := s.state(w)
+	if tracker == nil {
+		return
+	}
+	c, err := tracker.Clear(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	s.record(r, audit.Entry{Action: audit.ActionConditionClear, Target: c.ID,
+		Detail: fmt.Sprintf("open since %s", c.Since.Format(time.RFC3339))})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// incidents повертає сховище інцидентів або відповідає 503, якщо вони вимкнені
+func (s *Server) incidents(w http.ResponseWriter) *incident.Store {
+	store := s.backend.Incidents()
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, incident.ErrDisabled)
+	}
+	return store
+}
+
+// handleIncidents повертає інциденти від найновішого; фільтр status: open, acknowledged
+func (s *Server) handleIncidents(w http.ResponseWriter, r *http.Request) {
+	if store := s.incidents(w); store != nil {
+		writeJSON(w, http.StatusOK, store.List(r.URL.Query().Get("status")))
+	}
+}
+
+func (s *Server) handleIncident(w http.ResponseWriter, r *http.Request) {
+	store := s.incidents(w)
+	if store == nil {
+		return
+	}
+	inc, err := store.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, inc)
+}
+
+// handleIncidentAck підтверджує інцидент. Тіло: {"resolution": "...", "comment": "..."}
+func (s *Server) handleIncidentAck(w http.ResponseWriter, r *http.Request) {
+	store := s.incidents(w)
+	if store == nil {
+		return
+	}
+	var body struct {
+		Resolution string `json:"resolution"`
+		Comment    string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	inc, err := store.Acknowledge(r.PathValue("id"), s.actor(r).Name, body.Resolution, body.Comment)
+	switch {
+	case errors.Is(err, incident.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+		return
+	case errors.Is(err, incident.ErrAcknowledged):
+		writeError(w, http.StatusConflict, err)
+		return
+	case errors.Is(err, incident.ErrInvalid):
+		writeError(w, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.record(r, audit.Entry{Action: audit.ActionIncidentAck, Target: inc.ID, New: inc.Resolution, Detail: inc.Comment})
+	writeJSON(w, http.StatusOK, inc)
+}
+
+// writeDeadLetterError перетворює помилку сховища на HTTP статус
+func writeDeadLetterError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, deadletter.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, deadletter.ErrDisabled):
+		writeError(w, http.StatusServiceUnavailable, err)
+	default:
+		writeError(w, http.StatusConflict, err)
+	}
+}
+
+// writeJSON серіалізує відповідь у JSON
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to encode API response", "error", err)
+	}
+}
+
+// writeError повертає помилку у форматі {"error": "..."}
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
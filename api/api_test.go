@@ -0,0 +1,637 @@
+package api
+
+import (
+	"cid_retranslator_walk/audit"
+	"cid_retranslator_walk/bus"
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/deadletter"
+	"cid_retranslator_walk/eventdict"
+	"cid_retranslator_walk/incident"
+	"cid_retranslator_walk/logging"
+	"cid_retranslator_walk/metrics"
+	"cid_retranslator_walk/queue"
+	"cid_retranslator_walk/server"
+	"cid_retranslator_walk/state"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeBackend struct {
+	snapshot  metrics.Snapshot
+	cfg       *config.Config
+	pipelines []PipelineStatus
+	bus       []bus.Stats
+	report    *config.ReloadReport
+	reloadErr error
+	reloads   int
+
+	reloadActor audit.Actor
+	audit       *audit.Log
+
+	deadLetters *deadletter.Store
+	resubmitted []string
+
+	events    *eventdict.Dictionary
+	state     *state.Tracker
+	incidents *incident.Store
+
+	backlog []Backlog
+	paused  map[string]bool
+
+	logs *logging.Ring
+}
+
+func (f *fakeBackend) Stats() metrics.Snapshot { return f.snapshot }
+
+func (f *fakeBackend) Pipelines() []PipelineStatus { return f.pipelines }
+
+// Config повертає конфігурацію з токенами тестових клієнтів
+func (f *fakeBackend) Config() *config.Config {
+	if f.cfg == nil {
+		f.cfg = &config.Config{}
+	}
+	f.cfg.API.Tokens = []config.APIToken{
+		{Name: "noc", Hash: HashToken(viewerToken), Role: "viewer"},
+		{Name: "duty", Hash: HashToken(operatorToken), Role: "operator"},
+		{Name: "admin", Hash: HashToken(adminToken), Role: "admin"},
+	}
+	return f.cfg
+}
+
+const (
+	viewerToken   = "viewer-secret"
+	operatorToken = "operator-secret"
+	adminToken    = "admin-secret"
+)
+
+// newRequest створює запит від імені клієнта з роллю admin
+func newRequest(method, target string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	r.Header.Set("Authorization", "Bearer "+adminToken)
+	return r
+}
+
+func (f *fakeBackend) ReloadConfig(actor audit.Actor) (*config.ReloadReport, error) {
+	f.reloads++
+	f.reloadActor = actor
+	return f.report, f.reloadErr
+}
+
+func (f *fakeBackend) DeadLetters() *deadletter.Store { return f.deadLetters }
+
+func (f *fakeBackend) Events() *eventdict.Dictionary { return f.events }
+
+func (f *fakeBackend) State() *state.Tracker { return f.state }
+
+func (f *fakeBackend) Incidents() *incident.Store { return f.incidents }
+
+func (f *fakeBackend) Backlog() []Backlog { return f.backlog }
+
+func (f *fakeBackend) PauseUpstream(pipeline, upstream string, paused bool) error {
+	if pipeline != "default" || upstream != config.PrimaryUpstream && upstream != "backup" {
+		return ErrUnknownUpstream
+	}
+	if f.paused == nil {
+		f.paused = make(map[string]bool)
+	}
+	f.paused[pipeline+"/"+upstream] = paused
+	return nil
+}
+
+func (f *fakeBackend) Logs() *logging.Ring { return f.logs }
+
+func (f *fakeBackend) Audit() *audit.Log { return f.audit }
+
+func (f *fakeBackend) Bus() []bus.Stats { return f.bus }
+
+func (f *fakeBackend) ResubmitDeadLetter(id string) error {
+	if _, err := f.deadLetters.Take(id); err != nil {
+		return err
+	}
+	f.resubmitted = append(f.resubmitted, id)
+	return nil
+}
+
+func TestServer_Status(t *testing.T) {
+	backend := &fakeBackend{snapshot: metrics.Snapshot{Accepted: 7, Connected: true}}
+	s := New("", backend)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, newRequest(http.MethodGet, "/api/status", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var got metrics.Snapshot
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Accepted != 7 || !got.Connected {
+		t.Errorf("unexpected snapshot: %+v", got)
+	}
+}
+
+func TestServer_Bus(t *testing.T) {
+	backend := &fakeBackend{}
+	s := New("", backend)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, newRequest(http.MethodGet, "/api/bus", nil))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Fatalf("without sinks: %d %q, want 200 []", rec.Code, rec.Body.String())
+	}
+
+	backend.bus = []bus.Stats{{Name: "analytics", Type: "nats", Published: 5, Spilled: 2, Dropped: 1}}
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, newRequest(http.MethodGet, "/api/bus", nil))
+	var got []bus.Stats
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "analytics" || got[0].Published != 5 || got[0].Spilled != 2 || got[0].Dropped != 1 {
+		t.Errorf("unexpected stats: %+v", got)
+	}
+}
+
+func TestServer_ConfigReload(t *testing.T) {
+	backend := &fakeBackend{report: &config.ReloadReport{
+		Applied:         []string{"client.host"},
+		RestartRequired: []string{"queue.buffersize"},
+	}}
+	s := New("", backend)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, newRequest(http.MethodPost, "/api/config/reload", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var got config.ReloadReport
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.RestartRequired) != 1 || got.RestartRequired[0] != "queue.buffersize" {
+		t.Errorf("unexpected report: %+v", got)
+	}
+
+	backend.reloadErr = errors.New("bad config")
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, newRequest(http.MethodPost, "/api/config/reload", nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 on reload error, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, newRequest(http.MethodGet, "/api/config/reload", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rec.Code)
+	}
+}
+
+func TestServer_Config(t *testing.T) {
+	cfg, err := config.Sources{}.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Client.Port = "30004"
+	s := New("", &fakeBackend{cfg: cfg})
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, newRequest(http.MethodGet, "/api/config", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "port: \"30004\"") {
+		t.Errorf("effective config not returned:\n%s", rec.Body.String())
+	}
+}
+
+func TestServer_Pipelines(t *testing.T) {
+	backend := &fakeBackend{pipelines: []PipelineStatus{
+		{Name: "north", Listen: "0.0.0.0:20010", Stats: metrics.Snapshot{Accepted: 3}},
+		{Name: "south", Listen: "0.0.0.0:20011", Devices: 2},
+	}}
+	s := New("", backend)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, newRequest(http.MethodGet, "/api/pipelines", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var got []PipelineStatus
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "north" || got[0].Stats.Accepted != 3 || got[1].Devices != 2 {
+		t.Errorf("unexpected pipelines: %+v", got)
+	}
+}
+
+func TestServer_Logs(t *testing.T) {
+	ring := logging.NewRing(10)
+	logger := slog.New(ring.Handler(slog.LevelDebug))
+	logger.Debug("dialing")
+	logger.Warn("queue full", "corrID", "3-1")
+	logger.Info("connected")
+	s := New("", &fakeBackend{logs: ring})
+
+	get := func(path string) (*httptest.ResponseRecorder, []logging.Entry) {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, newRequest(http.MethodGet, path, nil))
+		var entries []logging.Entry
+		json.NewDecoder(rec.Body).Decode(&entries)
+		return rec, entries
+	}
+
+	if _, entries := get("/api/logs?lines=2"); len(entries) != 2 || entries[0].Message != "queue full" || entries[1].Message != "connected" {
+		t.Errorf("lines=2: %+v", entries)
+	}
+	_, entries := get("/api/logs?level=warn")
+	if len(entries) != 1 || entries[0].Attrs["corrID"] != "3-1" {
+		t.Errorf("level=warn: %+v", entries)
+	}
+	if rec, _ := get("/api/logs?level=loud"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid level: code %d, want 400", rec.Code)
+	}
+}
+
+func TestServer_DeadLetters(t *testing.T) {
+	store, _ := deadletter.Open("", 10)
+	first, _ := store.Add("default", "primary", []byte("5010 181234E13001005\x14"), "NACK after 3 attempt(s)", 3)
+	second, _ := store.Add("default", "primary", []byte("5010 181234E11001005\x14"), "NACK after 3 attempt(s)", 3)
+	backend := &fakeBackend{deadLetters: store}
+	s := New("", backend)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, newRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/deadletters", "")
+	var list []deadletter.Entry
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil || len(list) != 2 {
+		t.Fatalf("list = %+v, %v", list, err)
+	}
+
+	rec = do(http.MethodPut, "/api/deadletters/"+first.ID, `{"text": "5010 189999E13001005\u0014"}`)
+	var edited deadletter.Entry
+	json.NewDecoder(rec.Body).Decode(&edited)
+	if rec.Code != http.StatusOK || edited.Text != "5010 189999E13001005\x14" {
+		t.Errorf("update = %d %+v", rec.Code, edited)
+	}
+
+	if rec := do(http.MethodPost, "/api/deadletters/"+first.ID+"/resubmit", ""); rec.Code != http.StatusAccepted {
+		t.Errorf("resubmit = %d", rec.Code)
+	}
+	if len(backend.resubmitted) != 1 || store.Len() != 1 {
+		t.Errorf("resubmitted %v, %d left", backend.resubmitted, store.Len())
+	}
+	if rec := do(http.MethodGet, "/api/deadletters/"+first.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get resubmitted = %d, want 404", rec.Code)
+	}
+
+	if rec := do(http.MethodDelete, "/api/deadletters/"+second.ID, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete = %d", rec.Code)
+	}
+	store.Add("default", "primary", []byte("x"), "NACK", 1)
+	rec = do(http.MethodDelete, "/api/deadletters", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"purged":1`) {
+		t.Errorf("purge = %d %s", rec.Code, rec.Body.String())
+	}
+
+	backend.deadLetters = nil
+	if rec := do(http.MethodGet, "/api/deadletters", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("disabled store = %d, want 503", rec.Code)
+	}
+}
+
+func TestServer_Events(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.json")
+	shipped := `{"version": "1.0", "events": [{"contactId_code": "E130", "TypeCodeMes_UK": "Тривога", "CodeMes_UK": "Проникнення"}]}`
+	if err := os.WriteFile(path, []byte(shipped), 0644); err != nil {
+		t.Fatal(err)
+	}
+	events := eventdict.New(path, filepath.Join(dir, "events.local.json"), eventdict.LangUK)
+	if err := events.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	log, err := audit.Open(filepath.Join(dir, "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	s := New("", &fakeBackend{events: events, audit: log})
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/events", viewerToken, "")
+	var list EventList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil || len(list.Events) != 1 || list.Events[0].Source != eventdict.SourceShipped {
+		t.Fatalf("list = %+v, %v", list, err)
+	}
+	if list.Version != "1.0" || list.Revision != 0 || list.Language != eventdict.LangUK {
+		t.Errorf("list version = %q revision %d language %q", list.Version, list.Revision, list.Language)
+	}
+
+	if rec := do(http.MethodPut, "/api/events/E130", viewerToken, `{"type": {"en": "Alarm"}}`); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer update = %d, want 403", rec.Code)
+	}
+	rec = do(http.MethodPut, "/api/events/E130", operatorToken, `{"type": {"en": "Alarm"}, "severity": "major"}`)
+	var entry eventdict.Entry
+	json.NewDecoder(rec.Body).Decode(&entry)
+	if rec.Code != http.StatusOK || entry.Type["en"] != "Alarm" || entry.Type["uk"] != "Тривога" || entry.Severity != "major" {
+		t.Errorf("update = %d %+v", rec.Code, entry)
+	}
+	if rec := do(http.MethodPut, "/api/events/X1", operatorToken, `{"type": {"en": "Alarm"}}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid code = %d, want 400", rec.Code)
+	}
+	json.NewDecoder(do(http.MethodGet, "/api/events", viewerToken, "").Body).Decode(&list)
+	if list.Version != "1.0" || list.Revision != 1 {
+		t.Errorf("after update version = %q revision %d, want 1.0 and 1", list.Version, list.Revision)
+	}
+
+	if rec := do(http.MethodDelete, "/api/events/E130", operatorToken, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete = %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/api/events/E130", operatorToken, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("delete without user entry = %d, want 404", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/events/E999", viewerToken, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown code = %d, want 404", rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/api/events/reload", operatorToken, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("operator reload = %d, want 403", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/events/reload", adminToken, ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"codes":1`) {
+		t.Errorf("reload = %d %s", rec.Code, rec.Body.String())
+	}
+
+	entries, err := log.Query(audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	if strings.Join(actions, ",") != "event.edit,event.delete,event.reload" {
+		t.Errorf("audit actions = %v", actions)
+	}
+}
+
+func TestServer_State(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.json")
+	shipped := `[{"contactId_code": "E130", "Category": "alarm", "Severity": "critical", "Restore": "R130"},
+		{"contactId_code": "R401", "Category": "arm", "Severity": "info"}]`
+	if err := os.WriteFile(path, []byte(shipped), 0644); err != nil {
+		t.Fatal(err)
+	}
+	events := eventdict.New(path, filepath.Join(dir, "events.local.json"), eventdict.LangUK)
+	if err := events.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	tracker, _ := state.Open("", 100, events)
+	tracker.Submit("default", server.Frame{Payload: []byte("5010 181234E13001005\x14"), Received: time.Now(), Outcome: server.OutcomeAck})
+	tracker.Submit("default", server.Frame{Payload: []byte("5010 181234R40102007\x14"), Received: time.Now(), Outcome: server.OutcomeAck})
+	log, err := audit.Open(filepath.Join(dir, "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	backend := &fakeBackend{audit: log}
+	s := New("", backend)
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := do(http.MethodGet, "/api/conditions", viewerToken); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("disabled = %d, want 503", rec.Code)
+	}
+	backend.state = tracker
+
+	var conds []state.Condition
+	if err := json.NewDecoder(do(http.MethodGet, "/api/conditions", viewerToken).Body).Decode(&conds); err != nil ||
+		len(conds) != 1 || conds[0].ID != "default:1234:1:5:E130" {
+		t.Fatalf("conditions = %+v, %v", conds, err)
+	}
+	var parts []state.Partition
+	if err := json.NewDecoder(do(http.MethodGet, "/api/partitions", viewerToken).Body).Decode(&parts); err != nil ||
+		len(parts) != 1 || !parts[0].Armed || parts[0].Partition != 2 {
+		t.Fatalf("partitions = %+v, %v", parts, err)
+	}
+
+	var history []state.Transition
+	if err := json.NewDecoder(do(http.MethodGet, "/api/objects/1234/history?partition=2", viewerToken).Body).Decode(&history); err != nil ||
+		len(history) != 1 || history[0].User != 7 {
+		t.Errorf("history = %+v, %v", history, err)
+	}
+	if rec := do(http.MethodGet, "/api/objects/1234/history?partition=1", viewerToken); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("history of partition 1 = %s", rec.Body.String())
+	}
+	if rec := do(http.MethodGet, "/api/objects/abc/history", viewerToken); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid account = %d, want 400", rec.Code)
+	}
+
+	const id = "/api/conditions/default:1234:1:5:E130"
+	if rec := do(http.MethodDelete, id, viewerToken); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer clear = %d, want 403", rec.Code)
+	}
+	if rec := do(http.MethodDelete, id, operatorToken); rec.Code != http.StatusNoContent {
+		t.Errorf("clear = %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, id, operatorToken); rec.Code != http.StatusNotFound {
+		t.Errorf("second clear = %d, want 404", rec.Code)
+	}
+	if entries, _ := log.Query(audit.Filter{}); len(entries) != 1 || entries[0].Action != audit.ActionConditionClear {
+		t.Errorf("audit = %+v", entries)
+	}
+}
+
+func TestServer_Incidents(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.json")
+	shipped := `[{"contactId_code": "E130", "Category": "alarm", "Severity": "critical", "Restore": "R130"}]`
+	if err := os.WriteFile(path, []byte(shipped), 0644); err != nil {
+		t.Fatal(err)
+	}
+	events := eventdict.New(path, filepath.Join(dir, "events.local.json"), eventdict.LangUK)
+	if err := events.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	store, _ := incident.Open("", 10, events)
+	store.SetPolicy(time.Minute, []string{"false_alarm", "alarm_confirmed"})
+	store.Submit("default", server.Frame{Payload: []byte("5010 181234E13001005\x14"), Received: time.Now(), Outcome: server.OutcomeAck})
+	log, err := audit.Open(filepath.Join(dir, "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	backend := &fakeBackend{audit: log}
+	s := New("", backend)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := do(http.MethodGet, "/api/incidents", viewerToken, ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("disabled = %d, want 503", rec.Code)
+	}
+	backend.incidents = store
+
+	var list []incident.Incident
+	if err := json.NewDecoder(do(http.MethodGet, "/api/incidents?status=open", viewerToken, "").Body).Decode(&list); err != nil ||
+		len(list) != 1 || list[0].ID != "1" || list[0].Code != "E130" {
+		t.Fatalf("incidents = %+v, %v", list, err)
+	}
+
+	const ack = "/api/incidents/1/ack"
+	for _, tt := range []struct {
+		token, body string
+		want        int
+	}{
+		{viewerToken, `{"resolution": "false_alarm", "comment": "Cat"}`, http.StatusForbidden},
+		{operatorToken, `{"resolution": "unknown", "comment": "Cat"}`, http.StatusBadRequest},
+		{operatorToken, `{"resolution": "false_alarm"}`, http.StatusBadRequest},
+		{operatorToken, `{"resolution": "false_alarm", "comment": "Cat"}`, http.StatusOK},
+		{operatorToken, `{"resolution": "false_alarm", "comment": "Cat"}`, http.StatusConflict},
+	} {
+		if rec := do(http.MethodPost, ack, tt.token, tt.body); rec.Code != tt.want {
+			t.Errorf("ack %s = %d, want %d: %s", tt.body, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+	if rec := do(http.MethodPost, "/api/incidents/9/ack", operatorToken, `{"resolution": "alarm_confirmed", "comment": "x"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown incident = %d, want 404", rec.Code)
+	}
+
+	var inc incident.Incident
+	if err := json.NewDecoder(do(http.MethodGet, "/api/incidents/1", viewerToken, "").Body).Decode(&inc); err != nil ||
+		inc.Status != incident.StatusAcknowledged || inc.AckBy != "token:duty" || inc.Comment != "Cat" {
+		t.Errorf("acknowledged incident = %+v, %v", inc, err)
+	}
+	if entries, _ := log.Query(audit.Filter{}); len(entries) != 1 || entries[0].Action != audit.ActionIncidentAck || entries[0].New != "false_alarm" {
+		t.Errorf("audit = %+v", entries)
+	}
+}
+
+func TestServer_Backlog(t *testing.T) {
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	backend := &fakeBackend{audit: log}
+	s := New("", backend)
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/api/backlog", viewerToken); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("empty backlog = %d %s", rec.Code, rec.Body.String())
+	}
+	backend.backlog = []Backlog{{
+		Pipeline: "default", Upstream: "primary", OldestAge: 90 * time.Second, Paused: true, ReplayRate: 5,
+		Backlog: queue.Backlog{Count: 3, Classes: map[string]int{"alarm": 1, "test": 2}},
+	}}
+	var got []map[string]any
+	if err := json.NewDecoder(do(http.MethodGet, "/api/backlog", viewerToken).Body).Decode(&got); err != nil || len(got) != 1 ||
+		got[0]["count"] != 3.0 || got[0]["paused"] != true || got[0]["oldestAge"] != float64(90*time.Second) {
+		t.Errorf("backlog = %+v, %v", got, err)
+	}
+
+	for _, tt := range []struct {
+		path, token string
+		want        int
+	}{
+		{"/api/pipelines/default/pause", operatorToken, http.StatusForbidden},
+		{"/api/pipelines/default/pause", adminToken, http.StatusNoContent},
+		{"/api/pipelines/default/pause?upstream=backup", adminToken, http.StatusNoContent},
+		{"/api/pipelines/default/resume?upstream=backup", adminToken, http.StatusNoContent},
+		{"/api/pipelines/default/pause?upstream=gone", adminToken, http.StatusNotFound},
+		{"/api/pipelines/other/pause", adminToken, http.StatusNotFound},
+	} {
+		if rec := do(http.MethodPost, tt.path, tt.token); rec.Code != tt.want {
+			t.Errorf("POST %s = %d, want %d", tt.path, rec.Code, tt.want)
+		}
+	}
+	if !backend.paused["default/primary"] || backend.paused["default/backup"] {
+		t.Errorf("paused = %v", backend.paused)
+	}
+	entries, _ := log.Query(audit.Filter{})
+	if len(entries) != 3 || entries[0].Action != audit.ActionClientPause || entries[0].Target != "default/primary" ||
+		entries[2].Action != audit.ActionClientResume {
+		t.Errorf("audit = %+v", entries)
+	}
+}
+
+func TestServer_Audit(t *testing.T) {
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	store, _ := deadletter.Open("", 10)
+	entry, _ := store.Add("default", "primary", []byte("5010 181234E13001005\x14"), "NACK after 3 attempt(s)", 3)
+	backend := &fakeBackend{deadLetters: store, audit: log, report: &config.ReloadReport{}}
+	s := New("", backend)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := newRequest(method, path, nil)
+		req.RemoteAddr = "10.1.2.3:40000"
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	do(http.MethodPost, "/api/config/reload")
+	if backend.reloadActor != (audit.Actor{Name: "token:admin", Source: audit.SourceAPI}) {
+		t.Errorf("reload actor = %+v", backend.reloadActor)
+	}
+	do(http.MethodDelete, "/api/deadletters/"+entry.ID)
+
+	rec := do(http.MethodGet, "/api/audit?action=deadletter.delete")
+	var entries []audit.Entry
+	json.NewDecoder(rec.Body).Decode(&entries)
+	if len(entries) != 1 || entries[0].Target != entry.ID || entries[0].Actor != "token:admin" || entries[0].Source != audit.SourceAPI {
+		t.Errorf("audit entries = %+v", entries)
+	}
+
+	rec = do(http.MethodGet, "/api/audit?format=csv")
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv" || !strings.Contains(rec.Body.String(), "deadletter.delete") {
+		t.Errorf("csv export: %s %q", ct, rec.Body.String())
+	}
+	if rec := do(http.MethodGet, "/api/audit?since=yesterday"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid since: code %d, want 400", rec.Code)
+	}
+}
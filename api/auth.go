@@ -0,0 +1,247 @@
+package api
+
+import (
+	"cid_retranslator_walk/audit"
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/ratelimiter"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Після authFailureBurst невдалих спроб з однієї адреси дозволяється
+	// одна спроба на хвилину; успішний вхід скидає лічильник
+	authFailureRate  = 1.0 / 60
+	authFailureBurst = 5
+	// authFailureForget - через скільки часу без спроб адреса забувається
+	authFailureForget = 15 * time.Minute
+)
+
+var (
+	// ErrNoCredentials - запит не містить облікових даних, які розуміє автентифікатор
+	ErrNoCredentials = errors.New("authentication required")
+	// ErrInvalidCredentials - облікові дані не відповідають жодному клієнту
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	errTooManyAttempts = errors.New("too many failed authentication attempts, try again later")
+)
+
+// Role - рівень доступу клієнта API. Кожна роль має всі права попередніх.
+type Role int
+
+const (
+	RoleViewer   Role = iota // Стан, конвеєри, журнал, недоставлені повідомлення
+	RoleOperator             // Редагування, видалення і повторна відправка недоставлених
+	RoleAdmin                // Конфігурація і журнал аудиту
+)
+
+// ParseRole повертає роль за назвою з конфігурації (viewer, operator, admin)
+func ParseRole(name string) (Role, error) {
+	i := slices.Index(config.APIRoles, name)
+	if i < 0 {
+		return 0, fmt.Errorf("unknown role %q", name)
+	}
+	return Role(i), nil
+}
+
+func (r Role) String() string {
+	if r < 0 || int(r) >= len(config.APIRoles) {
+		return fmt.Sprintf("Role(%d)", int(r))
+	}
+	return config.APIRoles[r]
+}
+
+// Способи автентифікації
+const (
+	MethodToken = "token"
+	// MethodCert - клієнтський сертифікат mTLS (для майбутнього автентифікатора)
+	MethodCert = "cert"
+)
+
+// Identity - автентифікований клієнт API
+type Identity struct {
+	Name   string // Ім'я токена або CN сертифіката
+	Method string // MethodToken, MethodCert
+	Role   Role
+}
+
+// Actor повертає учасника для журналу аудиту, наприклад "token:noc"
+func (id *Identity) Actor() audit.Actor {
+	return audit.Actor{Name: id.Method + ":" + id.Name, Source: audit.SourceAPI}
+}
+
+// Authenticator визначає клієнта за запитом. Якщо запит не містить облікових
+// даних цього виду, повертається ErrNoCredentials і перевіряється наступний
+// автентифікатор. Так до токенів можна додати, наприклад, перевірку
+// r.TLS.PeerCertificates для mTLS.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// TokenAuth перевіряє токен із заголовка "Authorization: Bearer <token>"
+// за хешами з конфігурації. Tokens викликається для кожного запиту, тому
+// зміни токенів у конфігурації діють без перезапуску.
+type TokenAuth struct {
+	Tokens func() []config.APIToken
+}
+
+func (a TokenAuth) Authenticate(r *http.Request) (*Identity, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrNoCredentials
+	}
+
+	hash := []byte(HashToken(strings.TrimSpace(token)))
+	for _, t := range a.Tokens() {
+		if subtle.ConstantTimeCompare(hash, []byte(t.Hash)) != 1 {
+			continue
+		}
+		role, err := ParseRole(t.Role)
+		if err != nil {
+			return nil, fmt.Errorf("token %s: %w", t.Name, err)
+		}
+		return &Identity{Name: t.Name, Method: MethodToken, Role: role}, nil
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// HashToken повертає хеш токена у форматі поля api.tokens[].hash
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// NewToken генерує випадковий токен (256 біт)
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type identityKey struct{}
+
+// IdentityFrom повертає клієнта, автентифікованого для запиту з контекстом ctx
+func IdentityFrom(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// handle реєструє обробник, доступний клієнтам із роллю не нижче role
+func (s *Server) handle(pattern string, role Role, handler http.HandlerFunc) {
+	s.mux.Handle(pattern, s.authorize(role, handler))
+}
+
+// authorize автентифікує запит і перевіряє роль клієнта
+func (s *Server) authorize(role Role, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := remoteHost(r)
+		if !s.failures.allow(host) {
+			w.Header().Set("Retry-After", "60")
+			writeError(w, http.StatusTooManyRequests, errTooManyAttempts)
+			return
+		}
+
+		id, err := s.authenticate(r)
+		if err != nil {
+			s.failures.fail(host)
+			slog.Warn("API authentication failed", "remote", host, "path", r.URL.Path, "error", err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="cid-retranslator"`)
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		s.failures.reset(host)
+
+		if id.Role < role {
+			writeError(w, http.StatusForbidden, fmt.Errorf("%s role required", role))
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	})
+}
+
+// authenticate опитує автентифікатори по черзі
+func (s *Server) authenticate(r *http.Request) (*Identity, error) {
+	for _, a := range s.auth {
+		id, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return id, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// remoteHost повертає адресу клієнта без порту
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// failedAuth обмежує невдалі спроби автентифікації з кожної адреси
+type failedAuth struct {
+	mu      sync.Mutex
+	clients map[string]*failedClient
+}
+
+type failedClient struct {
+	limiter *ratelimiter.RateLimiter
+	last    time.Time
+}
+
+func newFailedAuth() *failedAuth {
+	return &failedAuth{clients: make(map[string]*failedClient)}
+}
+
+// allow дозволяє спробу; для адреси з невдалими спробами вона витрачає ліміт
+func (f *failedAuth) allow(host string) bool {
+	f.mu.Lock()
+	c := f.clients[host]
+	f.mu.Unlock()
+	return c == nil || c.limiter.Allow()
+}
+
+// fail запам'ятовує невдалу спробу
+func (f *failedAuth) fail(host string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	for h, c := range f.clients {
+		if now.Sub(c.last) > authFailureForget {
+			delete(f.clients, h)
+		}
+	}
+	c := f.clients[host]
+	if c == nil {
+		// Перша невдала спроба витрачає ліміт тут, наступні - в allow
+		c = &failedClient{limiter: ratelimiter.NewRateLimiter(authFailureRate, authFailureBurst)}
+		c.limiter.Allow()
+		f.clients[host] = c
+	}
+	c.last = now
+}
+
+// reset забуває невдалі спроби після успішної автентифікації
+func (f *failedAuth) reset(host string) {
+	f.mu.Lock()
+	delete(f.clients, host)
+	f.mu.Unlock()
+}
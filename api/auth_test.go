@@ -0,0 +1,115 @@
+package api
+
+import (
+	"cid_retranslator_walk/config"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_Roles(t *testing.T) {
+	s := New("", &fakeBackend{})
+
+	tests := []struct {
+		token  string
+		method string
+		path   string
+		want   int
+	}{
+		{"", http.MethodGet, "/api/status", http.StatusUnauthorized},
+		{"wrong", http.MethodGet, "/api/status", http.StatusUnauthorized},
+		{viewerToken, http.MethodGet, "/api/status", http.StatusOK},
+		{viewerToken, http.MethodDelete, "/api/deadletters/1", http.StatusForbidden},
+		{operatorToken, http.MethodDelete, "/api/deadletters/1", http.StatusServiceUnavailable},
+		{operatorToken, http.MethodGet, "/api/audit", http.StatusForbidden},
+		{adminToken, http.MethodGet, "/api/audit", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s with %q = %d, want %d", tt.method, tt.path, tt.token, rec.Code, tt.want)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s %s: 401 without WWW-Authenticate", tt.method, tt.path)
+		}
+	}
+}
+
+func TestServer_FailedAttempts(t *testing.T) {
+	s := New("", &fakeBackend{})
+	do := func(token, remote string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := range authFailureBurst {
+		if code := do("guess", "10.0.0.9:5000"); code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d = %d, want 401", i+1, code)
+		}
+	}
+	if code := do(viewerToken, "10.0.0.9:5001"); code != http.StatusTooManyRequests {
+		t.Errorf("attempt after the limit = %d, want 429 even with a valid token", code)
+	}
+	if code := do(viewerToken, "10.0.0.10:5000"); code != http.StatusOK {
+		t.Errorf("other address = %d, want 200", code)
+	}
+
+	// Успішний вхід скидає лічильник
+	s.failures.reset("10.0.0.9")
+	do("guess", "10.0.0.9:5002")
+	if code := do(viewerToken, "10.0.0.9:5003"); code != http.StatusOK {
+		t.Fatalf("valid token after one failure = %d, want 200", code)
+	}
+	for range authFailureBurst - 1 {
+		do("guess", "10.0.0.9:5004")
+	}
+	if code := do("guess", "10.0.0.9:5005"); code != http.StatusUnauthorized {
+		t.Errorf("counter was not reset by a successful login: %d", code)
+	}
+}
+
+func TestTokenAuth(t *testing.T) {
+	backend := &fakeBackend{}
+	auth := TokenAuth{Tokens: func() []config.APIToken { return backend.Config().API.Tokens }}
+
+	tests := []struct {
+		header string
+		name   string
+		role   Role
+		err    error
+	}{
+		{"", "", 0, ErrNoCredentials},
+		{"Basic dXNlcjpwYXNz", "", 0, ErrNoCredentials},
+		{"Bearer nope", "", 0, ErrInvalidCredentials},
+		{"bearer " + operatorToken, "duty", RoleOperator, nil},
+		{"Bearer " + adminToken, "admin", RoleAdmin, nil},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		id, err := auth.Authenticate(req)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%q: error %v, want %v", tt.header, err, tt.err)
+			continue
+		}
+		if err == nil && (id.Name != tt.name || id.Role != tt.role || id.Method != MethodToken) {
+			t.Errorf("%q: identity %+v", tt.header, id)
+		}
+	}
+
+	if got := HashToken("abc"); got != "sha256:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("HashToken() = %s", got)
+	}
+}
@@ -0,0 +1,232 @@
+// Package audit веде журнал дій операторів і змін конфігурації: лише
+// дописування, один JSON-запис на рядок.
+package audit
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrDisabled повертається, якщо журнал аудиту вимкнений у конфігурації
+var ErrDisabled = errors.New("audit log is disabled")
+
+// Джерела дій
+const (
+	SourceUI   = "ui"   // Вікно програми на цій машині
+	SourceAPI  = "api"  // HTTP API
+	SourceFile = "file" // Зміна файлу конфігурації
+)
+
+// Дії
+const (
+	ActionConfigChange       = "config.change"
+	ActionDeadLetterEdit     = "deadletter.edit"
+	ActionDeadLetterDelete   = "deadletter.delete"
+	ActionDeadLetterPurge    = "deadletter.purge"
+	ActionDeadLetterResubmit = "deadletter.resubmit"
+	ActionEventEdit          = "event.edit"
+	ActionEventDelete        = "event.delete"
+	ActionEventReload        = "event.reload"
+	ActionConditionClear     = "condition.clear"
+	ActionIncidentAck        = "incident.ack"
+	ActionClientPause        = "client.pause"
+	ActionClientResume       = "client.resume"
+)
+
+// Actor - хто виконав дію і звідки
+type Actor struct {
+	Name   string `json:"name"`   // Користувач ОС, токен API, шлях до файлу
+	Source string `json:"source"` // SourceUI, SourceAPI, ...
+}
+
+// LocalUser повертає користувача ОС, що працює з вікном програми
+func LocalUser() Actor {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return Actor{Name: name, Source: SourceUI}
+}
+
+// Entry - запис журналу аудиту
+type Entry struct {
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Source string    `json:"source"`
+	Action string    `json:"action"`
+	Target string    `json:"target,omitempty"` // Поле конфігурації, ID запису тощо
+	Old    string    `json:"old,omitempty"`
+	New    string    `json:"new,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Filter відбирає записи для запиту; порожні поля не обмежують
+type Filter struct {
+	Since, Until time.Time
+	Actor        string
+	Source       string
+	Action       string
+	Limit        int // Найновіші Limit записів; 0 - усі
+}
+
+func (f Filter) match(e *Entry) bool {
+	switch {
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	case f.Actor != "" && e.Actor != f.Actor:
+		return false
+	case f.Source != "" && e.Source != f.Source:
+		return false
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	}
+	return true
+}
+
+// Log - журнал аудиту у файлі JSON Lines. Записи лише дописуються в кінець.
+type Log struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	seq  uint64
+	now  func() time.Time
+}
+
+// Open відкриває (або створює) журнал і продовжує нумерацію записів
+func Open(path string) (*Log, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	l := &Log{path: path, now: time.Now}
+	err := l.scan(func(e *Entry) {
+		l.seq = max(l.seq, e.Seq)
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	l.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	// Запис, обірваний аварійним завершенням, не повинен склеїтися з наступним
+	if torn, err := endsMidLine(path); err == nil && torn {
+		l.file.Write([]byte{'\n'})
+	}
+	return l, nil
+}
+
+// endsMidLine перевіряє, чи файл не закінчується переведенням рядка
+func endsMidLine(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return false, err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return false, err
+	}
+	return last[0] != '\n', nil
+}
+
+// Close закриває файл журналу
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// Record дописує запис від імені actor. Seq, Time, Actor і Source
+// заповнюються журналом.
+func (l *Log) Record(actor Actor, e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	e.Seq = l.seq
+	e.Time = l.now()
+	e.Actor, e.Source = actor.Name, actor.Source
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("audit %s: %w", l.path, err)
+	}
+	return l.file.Sync()
+}
+
+// Query повертає записи, що відповідають фільтру, від старих до нових
+func (l *Log) Query(f Filter) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := []Entry{}
+	err := l.scan(func(e *Entry) {
+		if f.match(e) {
+			entries = append(entries, *e)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if f.Limit > 0 && len(entries) > f.Limit {
+		entries = entries[len(entries)-f.Limit:]
+	}
+	return entries, nil
+}
+
+// scan читає файл журналу; пошкоджені рядки (наприклад, обірваний запис) пропускаються
+func (l *Log) scan(fn func(e *Entry)) error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			fn(&e)
+		}
+	}
+	return scanner.Err()
+}
+
+// WriteCSV експортує записи в CSV із заголовком
+func WriteCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"seq", "time", "actor", "source", "action", "target", "old", "new", "detail"})
+	for _, e := range entries {
+		cw.Write([]string{
+			strconv.FormatUint(e.Seq, 10), e.Time.Format(time.RFC3339Nano),
+			e.Actor, e.Source, e.Action, e.Target, e.Old, e.New, e.Detail,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
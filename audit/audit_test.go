@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLog_RecordAndQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "audit.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { now = now.Add(time.Minute); return now }
+
+	ui := Actor{Name: "operator", Source: SourceUI}
+	api := Actor{Name: "anonymous@10.0.0.7", Source: SourceAPI}
+	l.Record(ui, Entry{Action: ActionConfigChange, Target: "client.host", Old: "10.0.0.1", New: "10.0.0.2"})
+	l.Record(api, Entry{Action: ActionDeadLetterResubmit, Target: "4"})
+	l.Record(ui, Entry{Action: ActionConfigChange, Target: "logging.level", Old: "INFO", New: "DEBUG"})
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		targets []string
+	}{
+		{"all", Filter{}, []string{"client.host", "4", "logging.level"}},
+		{"by actor", Filter{Actor: "operator"}, []string{"client.host", "logging.level"}},
+		{"by source", Filter{Source: SourceAPI}, []string{"4"}},
+		{"by action", Filter{Action: ActionDeadLetterResubmit}, []string{"4"}},
+		{"since", Filter{Since: time.Date(2026, 3, 1, 12, 2, 0, 0, time.UTC)}, []string{"4", "logging.level"}},
+		{"until", Filter{Until: time.Date(2026, 3, 1, 12, 2, 0, 0, time.UTC)}, []string{"client.host"}},
+		{"latest", Filter{Limit: 1}, []string{"logging.level"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var targets []string
+			for _, e := range entries {
+				targets = append(targets, e.Target)
+			}
+			if strings.Join(targets, ",") != strings.Join(tt.targets, ",") {
+				t.Errorf("targets = %v, want %v", targets, tt.targets)
+			}
+		})
+	}
+	l.Close()
+
+	// Обірваний запис не заважає, нумерація продовжується після повторного відкриття
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"seq":4,"act`)
+	f.Close()
+
+	l, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.Record(api, Entry{Action: ActionDeadLetterPurge})
+	entries, _ := l.Query(Filter{Action: ActionDeadLetterPurge})
+	if len(entries) != 1 || entries[0].Seq != 4 {
+		t.Errorf("entry after reopen = %+v, want seq 4", entries)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, []Entry{{
+		Seq: 1, Time: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Actor: "operator", Source: SourceUI,
+		Action: ActionConfigChange, Target: "cidrules.testcodemap", Old: "map[]", New: "map[E601:E602, x]",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "seq,time,actor,source,action,target,old,new,detail\n" +
+		`1,2026-03-01T12:00:00Z,operator,ui,config.change,cidrules.testcodemap,map[],"map[E601:E602, x]",` + "\n"
+	if buf.String() != want {
+		t.Errorf("csv = %q, want %q", buf.String(), want)
+	}
+}
@@ -15,4 +15,7 @@ rsrc -ico icon.ico -manifest multiplepages.exe.manifest -o rsrc.syso
 echo Building executable...
 go build -o cid_retranslator.exe .
 
+echo Building service executable...
+go build -o cidrelayd.exe ./cmd/cidrelayd
+
 echo Build complete!
@@ -0,0 +1,338 @@
+// Package bus публікує кожне ретрансльоване повідомлення разом з відповіддю
+// приймача в шину повідомлень для аналітики. Публікація відокремлена від
+// ретрансляції обмеженим буфером: поки шина недоступна, повідомлення
+// зберігаються у файлі переповнення, а коли й він заповнений - відкидаються.
+package bus
+
+import (
+	"cid_retranslator_walk/cidparser"
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/ratelimiter"
+	"cid_retranslator_walk/server"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Значення за замовчуванням для нульових полів config.BusSink
+const (
+	defaultSubject    = "cid.messages"
+	defaultBufferSize = 10000
+	defaultSpillMax   = 100000
+)
+
+// replayInterval - як часто перевіряється, чи можна повторно відправити файл переповнення
+const replayInterval = 5 * time.Second
+
+// Sink - шина повідомлень. Publish викликається з однієї горутини і повертає
+// помилку, якщо шина не прийняла повідомлення (наприклад, немає з'єднання).
+type Sink interface {
+	Publish(subject string, data []byte) error
+	Close() error
+}
+
+// sinkTypes - способи відкрити шину за типом з конфігурації (config.BusSinkTypes)
+var sinkTypes = map[string]func(config.BusSink) (Sink, error){
+	"nats": openNATS,
+}
+
+// Record - повідомлення, що публікується в шину
+type Record struct {
+	ID         string    `json:"id"` // Ідентифікатор кореляції, унікальний у межах запуску
+	Pipeline   string    `json:"pipeline"`
+	Account    int       `json:"account"`
+	Code       string    `json:"code,omitempty"` // Порожні, якщо кадр не розібрано
+	Qualifier  string    `json:"qualifier,omitempty"`
+	Group      int       `json:"group"`
+	Zone       int       `json:"zone"`
+	Category   string    `json:"category,omitempty"`
+	Raw        string    `json:"raw"`     // Кадр без термінатора
+	Outcome    string    `json:"outcome"` // ack, nack, timeout, rejected (server.Outcome*)
+	ReceivedAt time.Time `json:"receivedAt"`
+	RepliedAt  time.Time `json:"repliedAt"`
+}
+
+// NewRecord формує запис з кадру
+func NewRecord(pipeline string, fr server.Frame) Record {
+	rec := Record{
+		ID:         fr.ID,
+		Pipeline:   pipeline,
+		Account:    fr.DeviceID,
+		Raw:        strings.TrimRight(string(fr.Payload), "\x14"),
+		Outcome:    fr.Outcome,
+		ReceivedAt: fr.Received,
+		RepliedAt:  fr.Replied,
+	}
+	if m, err := cidparser.Parse(fr.Payload); err == nil {
+		rec.Account = m.Account
+		rec.Code = m.Code
+		rec.Qualifier = string(m.Qualifier)
+		rec.Group = m.Group
+		rec.Zone = m.Zone
+		rec.Category = m.Category()
+	}
+	return rec
+}
+
+// Stats - лічильники однієї шини
+type Stats struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Published    int64  `json:"published"`
+	Spilled      int64  `json:"spilled"` // Записано у файл переповнення
+	Dropped      int64  `json:"dropped"` // Відкинуто: буфер і файл переповнення заповнені
+	Buffered     int    `json:"buffered"`
+	SpillPending int64  `json:"spillPending"` // Чекають у файлі переповнення
+}
+
+// Bus розсилає записи всім шинам з конфігурації
+type Bus struct {
+	sinks []*runner
+}
+
+// runner - одна шина зі своїм буфером і файлом переповнення
+type runner struct {
+	name    string
+	kind    string
+	subject string
+	sink    Sink
+	buffer  chan Record
+	spill   *spill // nil - без файлу переповнення
+
+	// down - остання спроба публікації була невдалою; лише для горутини run
+	down bool
+
+	published atomic.Int64
+	spilled   atomic.Int64
+	dropped   atomic.Int64
+	dropLog   *ratelimiter.RateLimiter
+}
+
+// New відкриває шини з конфігурації. Відносний spillpath має бути вже
+// перетворений викликачем.
+func New(cfg *config.BusConfig) (*Bus, error) {
+	b := &Bus{}
+	for _, sc := range cfg.Sinks {
+		open, ok := sinkTypes[sc.Type]
+		if !ok {
+			b.Close()
+			return nil, fmt.Errorf("sink %s: unknown type %q", sc.Name, sc.Type)
+		}
+		sink, err := open(sc)
+		if err != nil {
+			b.Close()
+			return nil, fmt.Errorf("sink %s: %w", sc.Name, err)
+		}
+		r, err := newRunner(sc, sink)
+		if err != nil {
+			sink.Close()
+			b.Close()
+			return nil, fmt.Errorf("sink %s: %w", sc.Name, err)
+		}
+		b.sinks = append(b.sinks, r)
+	}
+	return b, nil
+}
+
+func newRunner(sc config.BusSink, sink Sink) (*runner, error) {
+	r := &runner{
+		name:    sc.Name,
+		kind:    sc.Type,
+		subject: sc.Subject,
+		sink:    sink,
+		dropLog: ratelimiter.NewRateLimiter(0.1, 1),
+	}
+	if r.subject == "" {
+		r.subject = defaultSubject
+	}
+	size := sc.BufferSize
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+	r.buffer = make(chan Record, size)
+	if sc.SpillPath != "" {
+		limit := sc.SpillMax
+		if limit <= 0 {
+			limit = defaultSpillMax
+		}
+		s, err := openSpill(sc.SpillPath, limit)
+		if err != nil {
+			return nil, err
+		}
+		r.spill = s
+	}
+	return r, nil
+}
+
+// Submit ставить кадр у буфери шин. Не блокується: якщо буфер повний,
+// запис відкидається. Підходить як server.Observer.
+func (b *Bus) Submit(pipeline string, fr server.Frame) {
+	if len(b.sinks) == 0 {
+		return
+	}
+	rec := NewRecord(pipeline, fr)
+	for _, r := range b.sinks {
+		select {
+		case r.buffer <- rec:
+		default:
+			r.drop("buffer full")
+		}
+	}
+}
+
+// Run публікує записи до скасування ctx, після чого зберігає або публікує
+// залишок буферів і закриває шини
+func (b *Bus) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range b.sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.run(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// Stats повертає лічильники шин
+func (b *Bus) Stats() []Stats {
+	stats := make([]Stats, 0, len(b.sinks))
+	for _, r := range b.sinks {
+		st := Stats{
+			Name:      r.name,
+			Type:      r.kind,
+			Published: r.published.Load(),
+			Spilled:   r.spilled.Load(),
+			Dropped:   r.dropped.Load(),
+			Buffered:  len(r.buffer),
+		}
+		if r.spill != nil {
+			st.SpillPending = r.spill.len()
+		}
+		stats = append(stats, st)
+	}
+	return stats
+}
+
+// Close закриває шини без публікації буферів (для помилок у New)
+func (b *Bus) Close() {
+	for _, r := range b.sinks {
+		r.sink.Close()
+	}
+}
+
+// subjectOf повертає тему запису: <subject>.<pipeline>.<outcome>
+func (r *runner) subjectOf(rec Record) string {
+	return r.subject + "." + rec.Pipeline + "." + rec.Outcome
+}
+
+func (r *runner) run(ctx context.Context) {
+	ticker := time.NewTicker(replayInterval)
+	defer ticker.Stop()
+
+	r.replay()
+	for {
+		select {
+		case <-ctx.Done():
+			r.stop()
+			return
+		case rec := <-r.buffer:
+			r.handle(rec)
+		case <-ticker.C:
+			r.replay()
+		}
+	}
+}
+
+// handle публікує запис або, поки шина недоступна чи файл переповнення
+// не порожній (щоб зберегти порядок), дописує його у файл
+func (r *runner) handle(rec Record) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		slog.Error("Cannot encode bus record", "sink", r.name, "error", err)
+		return
+	}
+	if !r.down && (r.spill == nil || r.spill.len() == 0) {
+		err := r.sink.Publish(r.subjectOf(rec), data)
+		if err == nil {
+			r.published.Add(1)
+			return
+		}
+		r.down = true
+		slog.Warn("Message bus unavailable", "sink", r.name, "spill", r.spill != nil, "error", err)
+	}
+	r.toSpill(data)
+}
+
+func (r *runner) toSpill(data []byte) {
+	if r.spill == nil {
+		r.drop("bus unavailable")
+		return
+	}
+	if err := r.spill.append(data); err != nil {
+		r.drop(err.Error())
+		return
+	}
+	r.spilled.Add(1)
+}
+
+func (r *runner) drop(reason string) {
+	r.dropped.Add(1)
+	if r.dropLog.Allow() {
+		slog.Warn("Bus record dropped", "sink", r.name, "reason", reason, "dropped", r.dropped.Load())
+	}
+}
+
+// replay повторно публікує файл переповнення; якщо файлу немає, лише
+// скидає ознаку недоступності, щоб наступний запис спробував шину знову
+func (r *runner) replay() {
+	if r.spill == nil || r.spill.len() == 0 {
+		r.down = false
+		return
+	}
+	lines, err := r.spill.read()
+	if err != nil {
+		slog.Error("Cannot read bus spill file", "sink", r.name, "path", r.spill.path, "error", err)
+		return
+	}
+	sent := 0
+	for _, line := range lines {
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			slog.Warn("Skipping corrupt bus spill record", "sink", r.name, "error", err)
+			sent++
+			continue
+		}
+		if err := r.sink.Publish(r.subjectOf(rec), line); err != nil {
+			r.down = true
+			break
+		}
+		r.published.Add(1)
+		sent++
+	}
+	if err := r.spill.rewrite(lines[sent:]); err != nil {
+		slog.Error("Cannot update bus spill file", "sink", r.name, "path", r.spill.path, "error", err)
+		return
+	}
+	if sent == len(lines) {
+		r.down = false
+		slog.Info("Spilled bus records published", "sink", r.name, "count", sent)
+	}
+}
+
+// stop обробляє залишок буфера (у файл переповнення, якщо шина недоступна) і закриває шину
+func (r *runner) stop() {
+	for len(r.buffer) > 0 {
+		r.handle(<-r.buffer)
+	}
+	if err := r.sink.Close(); err != nil {
+		slog.Warn("Error closing message bus", "sink", r.name, "error", err)
+	}
+	slog.Info("Message bus stopped", "sink", r.name, "published", r.published.Load(),
+		"spilled", r.spilled.Load(), "dropped", r.dropped.Load())
+}
@@ -0,0 +1,197 @@
+package bus
+
+import (
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/server"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSink записує опубліковані повідомлення; поки down, відмовляє
+type fakeSink struct {
+	mu       sync.Mutex
+	down     bool
+	subjects []string
+	records  []Record
+}
+
+func (s *fakeSink) Publish(subject string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("not connected")
+	}
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return err
+	}
+	s.subjects = append(s.subjects, subject)
+	s.records = append(s.records, rec)
+	return nil
+}
+
+func (s *fakeSink) Close() error { return nil }
+
+func (s *fakeSink) setDown(down bool) {
+	s.mu.Lock()
+	s.down = down
+	s.mu.Unlock()
+}
+
+func (s *fakeSink) ids() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, len(s.records))
+	for i, r := range s.records {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func frame(id string, outcome string) server.Frame {
+	now := time.Now()
+	return server.Frame{
+		ID:       id,
+		Payload:  []byte("5010 181234E13001015\x14"),
+		DeviceID: 1234,
+		Received: now,
+		Replied:  now,
+		Outcome:  outcome,
+	}
+}
+
+func newTestBus(t *testing.T, sc config.BusSink, sink Sink) *Bus {
+	t.Helper()
+	r, err := newRunner(sc, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Bus{sinks: []*runner{r}}
+}
+
+func TestNewRecord(t *testing.T) {
+	rec := NewRecord("default", frame("1-1", server.OutcomeTimeout))
+	if rec.Account != 1234 || rec.Code != "E130" || rec.Qualifier != "E" || rec.Group != 1 || rec.Zone != 15 ||
+		rec.Category != "burglary" || rec.Raw != "5010 181234E13001015" || rec.Outcome != server.OutcomeTimeout {
+		t.Errorf("record = %+v", rec)
+	}
+
+	// Нерозібраний кадр публікується як є
+	fr := frame("1-2", server.OutcomeNack)
+	fr.Payload = []byte("garbage\x14")
+	if rec := NewRecord("default", fr); rec.Raw != "garbage" || rec.Account != 1234 || rec.Code != "" {
+		t.Errorf("record = %+v", rec)
+	}
+}
+
+func TestBus_Publish(t *testing.T) {
+	sink := &fakeSink{}
+	b := newTestBus(t, config.BusSink{Name: "test", Subject: "analytics"}, sink)
+	b.Submit("default", frame("1-1", server.OutcomeAck))
+	b.Submit("default", frame("1-2", server.OutcomeNack))
+
+	r := b.sinks[0]
+	for len(r.buffer) > 0 {
+		r.handle(<-r.buffer)
+	}
+	want := []string{"analytics.default.ack", "analytics.default.nack"}
+	if fmt.Sprint(sink.subjects) != fmt.Sprint(want) {
+		t.Errorf("subjects = %v, want %v", sink.subjects, want)
+	}
+	if st := b.Stats()[0]; st.Published != 2 || st.Dropped != 0 || st.Spilled != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestBus_Spill(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spill", "bus.jsonl")
+	sink := &fakeSink{down: true}
+	b := newTestBus(t, config.BusSink{Name: "test", SpillPath: path, SpillMax: 3}, sink)
+	r := b.sinks[0]
+
+	for i := range 4 {
+		b.Submit("default", frame(fmt.Sprint("1-", i), server.OutcomeAck))
+		r.handle(<-r.buffer)
+	}
+	if st := b.Stats()[0]; st.Spilled != 3 || st.SpillPending != 3 || st.Dropped != 1 {
+		t.Fatalf("stats while down = %+v", st)
+	}
+
+	// Шина ще недоступна: файл залишається
+	r.replay()
+	if st := b.Stats()[0]; st.SpillPending != 3 {
+		t.Fatalf("spill pending = %d after a failed replay, want 3", st.SpillPending)
+	}
+
+	// Файл переживає перезапуск
+	b = newTestBus(t, config.BusSink{Name: "test", SpillPath: path, SpillMax: 3}, sink)
+	r = b.sinks[0]
+	if st := b.Stats()[0]; st.SpillPending != 3 {
+		t.Fatalf("spill pending after reopen = %d, want 3", st.SpillPending)
+	}
+
+	sink.setDown(false)
+	r.replay()
+	b.Submit("default", frame("1-9", server.OutcomeAck))
+	r.handle(<-r.buffer)
+
+	want := []string{"1-0", "1-1", "1-2", "1-9"}
+	if got := sink.ids(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("published = %v, want %v", got, want)
+	}
+	if st := b.Stats()[0]; st.SpillPending != 0 || st.Published != 4 {
+		t.Errorf("stats after replay = %+v", st)
+	}
+}
+
+// Поки у файлі є записи, нові йдуть за ними, щоб зберегти порядок
+func TestBus_SpillKeepsOrder(t *testing.T) {
+	sink := &fakeSink{down: true}
+	b := newTestBus(t, config.BusSink{Name: "test", SpillPath: filepath.Join(t.TempDir(), "bus.jsonl")}, sink)
+	r := b.sinks[0]
+
+	b.Submit("default", frame("1-1", server.OutcomeAck))
+	r.handle(<-r.buffer)
+	sink.setDown(false)
+	b.Submit("default", frame("1-2", server.OutcomeAck))
+	r.handle(<-r.buffer)
+	if got := sink.ids(); len(got) != 0 {
+		t.Fatalf("published %v before the spill file was replayed", got)
+	}
+	r.replay()
+	if got := sink.ids(); fmt.Sprint(got) != "[1-1 1-2]" {
+		t.Errorf("published = %v, want [1-1 1-2]", got)
+	}
+}
+
+// Без файлу переповнення недоступна шина означає втрату; буфер не блокує Submit
+func TestBus_Drop(t *testing.T) {
+	sink := &fakeSink{down: true}
+	b := newTestBus(t, config.BusSink{Name: "test", BufferSize: 2}, sink)
+
+	done := make(chan struct{})
+	go func() {
+		for i := range 5 {
+			b.Submit("default", frame(fmt.Sprint("1-", i), server.OutcomeAck))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Submit blocked on a full buffer")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.Run(ctx)
+	if st := b.Stats()[0]; st.Dropped != 5 || st.Buffered != 0 {
+		t.Errorf("stats = %+v, want 5 dropped", st)
+	}
+}
@@ -0,0 +1,66 @@
+package bus
+
+import (
+	"cid_retranslator_walk/config"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// closeTimeout - скільки чекати відправки буферизованих повідомлень при закритті
+const closeTimeout = 2 * time.Second
+
+// natsSink публікує в NATS (core NATS, доставка не частіше одного разу)
+type natsSink struct {
+	conn *nats.Conn
+}
+
+// openNATS підключається до NATS. Якщо сервер недоступний, підключення
+// повторюється у фоні, а Publish до того повертає помилку.
+func openNATS(sc config.BusSink) (Sink, error) {
+	opts := []nats.Option{
+		nats.Name("cid-relay/" + sc.Name),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		// Без власного буфера клієнта: поки з'єднання немає, записи йдуть у файл переповнення
+		nats.ReconnectBufSize(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				slog.Warn("NATS connection lost", "sink", sc.Name, "error", err)
+			}
+		}),
+		nats.ConnectHandler(connected(sc.Name)),
+		nats.ReconnectHandler(connected(sc.Name)),
+	}
+	if sc.Username != "" {
+		opts = append(opts, nats.UserInfo(sc.Username, sc.Password))
+	}
+	if sc.Token != "" {
+		opts = append(opts, nats.Token(sc.Token))
+	}
+	conn, err := nats.Connect(sc.URL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &natsSink{conn: conn}, nil
+}
+
+func connected(name string) nats.ConnHandler {
+	return func(c *nats.Conn) {
+		slog.Info("Connected to NATS", "sink", name, "server", c.ConnectedUrl())
+	}
+}
+
+func (s *natsSink) Publish(subject string, data []byte) error {
+	return s.conn.Publish(subject, data)
+}
+
+func (s *natsSink) Close() error {
+	var err error
+	if s.conn.IsConnected() {
+		err = s.conn.FlushTimeout(closeTimeout)
+	}
+	s.conn.Close()
+	return err
+}
@@ -0,0 +1,88 @@
+package bus
+
+import (
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/server"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func runNATS(t *testing.T) *natsserver.Server {
+	t.Helper()
+	s, err := natsserver.NewServer(&natsserver.Options{Host: "127.0.0.1", Port: -1, NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server is not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func TestNATS(t *testing.T) {
+	ns := runNATS(t)
+
+	sub, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	msgs := make(chan *nats.Msg, 10)
+	if _, err := sub.ChanSubscribe("cid.messages.>", msgs); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := New(&config.BusConfig{Sinks: []config.BusSink{{Name: "analytics", Type: "nats", URL: ns.ClientURL()}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	b.Submit("default", frame("1-1", server.OutcomeRejected))
+	select {
+	case msg := <-msgs:
+		if msg.Subject != "cid.messages.default.rejected" {
+			t.Errorf("subject = %q", msg.Subject)
+		}
+		var rec Record
+		if err := json.Unmarshal(msg.Data, &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.ID != "1-1" || rec.Outcome != server.OutcomeRejected || rec.Code != "E130" {
+			t.Errorf("record = %+v", rec)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for a NATS message")
+	}
+}
+
+// Недоступний сервер не заважає відкрити шину: Publish повертає помилку
+func TestNATS_Unavailable(t *testing.T) {
+	sink, err := openNATS(config.BusSink{Name: "analytics", Type: "nats", URL: "nats://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if err := sink.Publish("cid.messages.default.ack", []byte("{}")); err == nil {
+		t.Error("Publish succeeded without a connection")
+	}
+}
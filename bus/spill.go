@@ -0,0 +1,95 @@
+package bus
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+var errSpillFull = errors.New("spill file full")
+
+// spill - файл переповнення: записи JSON, по одному в рядку. Кількість записів
+// читається з файлу при відкритті, тож незавершена відправка продовжиться після перезапуску.
+type spill struct {
+	path  string
+	limit int
+	n     atomic.Int64 // Для Stats; змінюється лише горутиною шини
+}
+
+func openSpill(path string, limit int) (*spill, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	s := &spill{path: path, limit: limit}
+	lines, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	s.n.Store(int64(len(lines)))
+	return s, nil
+}
+
+func (s *spill) len() int64 {
+	return s.n.Load()
+}
+
+func (s *spill) append(data []byte) error {
+	if s.len() >= int64(s.limit) {
+		return errSpillFull
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s.n.Add(1)
+	return nil
+}
+
+// read повертає записи файлу; відсутній файл - порожній
+func (s *spill) read() ([][]byte, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lines [][]byte
+	for line := range bytes.SplitSeq(data, []byte{'\n'}) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// rewrite замінює вміст файлу записами lines; без записів файл видаляється
+func (s *spill) rewrite(lines [][]byte) error {
+	if len(lines) == 0 {
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		s.n.Store(0)
+		return nil
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(bytes.Join(lines, []byte{'\n'}), '\n'), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.n.Store(int64(len(lines)))
+	return nil
+}
@@ -268,4 +268,74 @@ func BenchmarkIsHeartBeat(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_ = IsHeartBeat(message)
 	}
-}
\ No newline at end of file
+}
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    Message
+		wantErr bool
+	}{
+		{
+			name:    "Restore with terminator",
+			message: "5010 182100R57516331\x14",
+			want:    Message{Receiver: "5010", Account: 2100, Qualifier: 'R', Code: "R575", Group: 16, Zone: 331},
+		},
+		{
+			name:    "Alarm",
+			message: "5010 184321E13001005",
+			want:    Message{Receiver: "5010", Account: 4321, Qualifier: 'E', Code: "E130", Group: 1, Zone: 5},
+		},
+		{"Too short", "5010 18", Message{}, true},
+		{"Bad account", "5010 18ABCDE13001005", Message{}, true},
+		{"Bad zone", "5010 181234E13001XYZ", Message{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.message))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessage_Category(t *testing.T) {
+	tests := []struct {
+		code     string
+		event    int
+		category string
+	}{
+		{"E110", 110, "fire"},
+		{"R130", 130, "burglary"},
+		{"E302", 302, "technical"},
+		{"E401", 401, "openclose"},
+		{"E602", 602, "test"},
+		{"E999", 999, "other"},
+		{"", -1, "other"},
+	}
+	for _, tt := range tests {
+		m := Message{Code: tt.code}
+		if got := m.Event(); got != tt.event {
+			t.Errorf("Event(%q) = %d, want %d", tt.code, got, tt.event)
+		}
+		if got := m.Category(); got != tt.category {
+			t.Errorf("Category(%q) = %s, want %s", tt.code, got, tt.category)
+		}
+	}
+}
+
+func TestIsAlarm(t *testing.T) {
+	for code, want := range map[string]bool{
+		"E100": true, "E130": true, "E139": true,
+		"R130": false, "E140": false, "E401": false, "E13": false, "": false,
+	} {
+		if got := IsAlarm(code); got != want {
+			t.Errorf("IsAlarm(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
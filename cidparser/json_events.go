@@ -2,6 +2,7 @@ package cidparser
 
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 )
@@ -11,6 +12,13 @@ type Event struct {
 	ContactIdCode  string `json:"contactId_code"`
 	TypeCodeMesUK  string `json:"TypeCodeMes_UK"`
 	CodeMesUK      string `json:"CodeMes_UK"`
+	TypeCodeMesEN  string `json:"TypeCodeMes_EN,omitempty"` // Переклади необов'язкові
+	CodeMesEN      string `json:"CodeMes_EN,omitempty"`
+	TypeCodeMesRU  string `json:"TypeCodeMes_RU,omitempty"`
+	CodeMesRU      string `json:"CodeMes_RU,omitempty"`
+	Category       string `json:"Category,omitempty"` // Класифікація (eventdict.Categories)
+	Severity       string `json:"Severity,omitempty"`
+	Restore        string `json:"Restore,omitempty"` // Код, що завершує стан (R130 для E130)
 	Zoneno         int    `json:"Zoneno"`
 	AccessCode     int    `json:"AccessCode"`
 	GroupSent      int    `json:"GroupSent"`
@@ -20,18 +28,40 @@ type Event struct {
 // EventMap — мапа для швидкого пошуку за contactId_code
 type EventMap map[string]*Event
 
-// LoadEvents завантажує JSON-масив подій і повертає мапу для пошуку
+// EventFile - файл подій з версією. Старий формат (лише масив подій)
+// читається як файл без версії.
+type EventFile struct {
+	Version  string  `json:"version,omitempty"`  // Версія постаченого словника
+	Revision int     `json:"revision,omitempty"` // Лічильник змін файлу користувача
+	Events   []Event `json:"events"`
+}
+
+// LoadEvents завантажує файл подій (масив або EventFile) і повертає мапу для пошуку
 func LoadEvents(jsonData []byte) (EventMap, error) {
-	var events []Event
-	if err := json.Unmarshal(jsonData, &events); err != nil {
-		return nil, fmt.Errorf("помилка парсингу JSON: %w", err)
+	eventMap, _, err := LoadEventFile(jsonData)
+	return eventMap, err
+}
+
+// LoadEventFile завантажує файл подій у будь-якому з двох форматів і повертає
+// мапу для пошуку та заголовок файлу (без подій)
+func LoadEventFile(jsonData []byte) (EventMap, EventFile, error) {
+	var file EventFile
+	var err error
+	if data := bytes.TrimLeft(jsonData, " \t\r\n"); len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &file.Events)
+	} else {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, EventFile{}, fmt.Errorf("помилка парсингу JSON: %w", err)
 	}
 
-	eventMap := make(EventMap, len(events))
-	for _, ev := range events {
+	eventMap := make(EventMap, len(file.Events))
+	for _, ev := range file.Events {
 		eventMap[ev.ContactIdCode] = &ev
 	}
-	return eventMap, nil
+	file.Events = nil
+	return eventMap, file, nil
 }
 
 // GetEventDescriptions повертає TypeCodeMes_UK та CodeMes_UK за contactId_code
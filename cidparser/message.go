@@ -0,0 +1,109 @@
+package cidparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	groupStart = 15
+	groupEnd   = 17
+	zoneStart  = 17
+	zoneEnd    = 20
+)
+
+// Message - розібрані поля кадру Contact ID
+type Message struct {
+	Receiver  string // Номер приймача/лінії (перші 4 символи)
+	Account   int    // Номер об'єкта
+	Qualifier byte   // 'E' - нова подія/тривога, 'R' - відновлення
+	Code      string // Код події разом з кваліфікатором, наприклад "E130"
+	Group     int    // Номер групи (розділу)
+	Zone      int    // Номер зони або користувача
+}
+
+// Parse розбирає кадр Contact ID (з термінатором або без нього)
+func Parse(message []byte) (Message, error) {
+	s := strings.TrimSuffix(string(message), terminator)
+	if len(s) < requiredMessageLength {
+		return Message{}, fmt.Errorf("invalid message length: got %d, want at least %d",
+			len(s), requiredMessageLength)
+	}
+
+	account, err := strconv.Atoi(s[accountStart:accountEnd])
+	if err != nil {
+		return Message{}, fmt.Errorf("invalid account number %q: %w", s[accountStart:accountEnd], err)
+	}
+
+	group, err := strconv.Atoi(strings.TrimSpace(s[groupStart:groupEnd]))
+	if err != nil {
+		return Message{}, fmt.Errorf("invalid group %q: %w", s[groupStart:groupEnd], err)
+	}
+
+	zone, err := strconv.Atoi(strings.TrimSpace(s[zoneStart:zoneEnd]))
+	if err != nil {
+		return Message{}, fmt.Errorf("invalid zone %q: %w", s[zoneStart:zoneEnd], err)
+	}
+
+	return Message{
+		Receiver:  s[:4],
+		Account:   account,
+		Qualifier: s[codeStart],
+		Code:      s[codeStart:codeEnd],
+		Group:     group,
+		Zone:      zone,
+	}, nil
+}
+
+// IsAlarm повідомляє, чи є код тривогою за діапазоном Contact ID: подія (E)
+// медичної, пожежної, панічної тривоги чи проникнення (100-139). Точна
+// класифікація кожного коду - у словнику подій (eventdict).
+func IsAlarm(code string) bool {
+	if len(code) != 4 || code[0] != 'E' {
+		return false
+	}
+	n, err := strconv.Atoi(code[1:])
+	return err == nil && n >= 100 && n <= 139
+}
+
+// Категорії подій Contact ID за діапазонами кодів
+var eventCategories = []struct {
+	from, to int
+	name     string
+}{
+	{100, 109, "medical"},
+	{110, 119, "fire"},
+	{120, 129, "panic"},
+	{130, 139, "burglary"},
+	{140, 149, "general"},
+	{150, 169, "auxiliary"},
+	{200, 299, "supervisory"},
+	{300, 399, "technical"},
+	{400, 499, "openclose"},
+	{500, 599, "bypass"},
+	{600, 699, "test"},
+}
+
+// Event повертає числовий код події без кваліфікатора (наприклад 130), або -1
+func (m Message) Event() int {
+	if len(m.Code) != 4 {
+		return -1
+	}
+	n, err := strconv.Atoi(m.Code[1:])
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// Category повертає категорію події (fire, burglary, technical, ...) або "other"
+func (m Message) Category() string {
+	event := m.Event()
+	for _, c := range eventCategories {
+		if event >= c.from && event <= c.to {
+			return c.name
+		}
+	}
+	return "other"
+}
@@ -2,24 +2,37 @@ package client
 
 import (
 	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/logging"
 	"cid_retranslator_walk/metrics"
 	"cid_retranslator_walk/queue"
+	"cid_retranslator_walk/ratelimiter"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	ackByte         = 0x06
 	nackByte        = 0x15
+	terminatorByte  = 0x14
 	replyTimeout    = 10 * time.Second
+	retryReplyWait  = time.Second // Менше часу на відповідь на повтор не має сенсу
 	writeTimeout    = 10 * time.Second
 	shutdownTimeout = 5 * time.Second
 )
 
+// retryBudget - змінна, щоб тести могли скоротити бюджет повторів
+var retryBudget = config.RetryBudget
+
+// DeadLetterFunc зберігає повідомлення, яке приймач відхилив після всіх спроб.
+// Повертає помилку, якщо зберегти не вдалося.
+type DeadLetterFunc func(payload []byte, reason string, attempts int) error
+
 // MessageProvider defines the interface for consuming messages
 type MessageProvider interface {
 	Events() <-chan queue.SharedData
@@ -27,15 +40,35 @@ type MessageProvider interface {
 }
 
 type Client struct {
+	conn     net.Conn
+	queue    MessageProvider
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+	metrics  *metrics.Stats
+
+	// Параметри, що змінюються на льоту (hot-reload)
+	mu               sync.Mutex
 	host             string
 	port             string
-	conn             net.Conn
-	queue            MessageProvider
 	reconnectInitial time.Duration
 	reconnectMax     time.Duration
-	cancel           context.CancelFunc
-	stopOnce         sync.Once
-	metrics          *metrics.Stats
+	retryAttempts    int
+	retryBackoff     time.Duration
+	deadLetter       DeadLetterFunc
+	ackDeadLettered  bool
+	retarget         chan struct{}
+
+	heartbeatInterval time.Duration
+	heartbeatFrame    []byte
+	heartbeatReset    chan struct{}
+
+	replay *ratelimiter.RateLimiter // Обмеження відправки; nil - без обмеження
+	paused atomic.Bool              // Повідомлення не беруться з черги
+	wake   chan struct{}            // Змінилися пауза або обмеження відправки
+
+	replies *replyReader // Відповіді поточного з'єднання
+	busy    atomic.Bool  // Повідомлення взято з черги, але відповідь ще не передана
+	dropped atomic.Int64 // Повідомлення, доставку яких перервали обрив з'єднання або зупинка
 }
 
 func New(cfg *config.ClientConfig, q MessageProvider) *Client {
@@ -45,8 +78,158 @@ func New(cfg *config.ClientConfig, q MessageProvider) *Client {
 		queue:            q,
 		reconnectInitial: cfg.ReconnectInitial,
 		reconnectMax:     cfg.ReconnectMax,
+		retryAttempts:    cfg.RetryAttempts,
+		retryBackoff:     cfg.RetryBackoff,
 		metrics:          q.GetMetrics(),
+		retarget:         make(chan struct{}, 1),
+
+		heartbeatInterval: cfg.HeartbeatInterval,
+		heartbeatFrame:    heartbeatFrame(cfg.HeartbeatFrame),
+		heartbeatReset:    make(chan struct{}, 1),
+
+		replay: replayLimiter(cfg.ReplayRate),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// replayLimiter створює обмежувач на rate повідомлень за секунду (nil, якщо rate 0)
+func replayLimiter(rate float64) *ratelimiter.RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return ratelimiter.NewRateLimiter(rate, max(int(rate), 1))
+}
+
+// heartbeatFrame додає термінатор до кадру тесту зв'язку, якщо його немає
+func heartbeatFrame(frame string) []byte {
+	if frame == "" || frame[len(frame)-1] == terminatorByte {
+		return []byte(frame)
+	}
+	return append([]byte(frame), terminatorByte)
+}
+
+// SetTarget змінює адресу приймача. Поточне повідомлення доставляється до кінця,
+// після чого з'єднання закривається і клієнт підключається до нової адреси.
+func (c *Client) SetTarget(host, port string) {
+	c.mu.Lock()
+	c.host, c.port = host, port
+	c.mu.Unlock()
+
+	select {
+	case c.retarget <- struct{}{}:
+	default:
 	}
+	slog.Info("Client target changed, reconnecting", "target", c.target())
+}
+
+// SetBackoff змінює параметри затримки перепідключення
+func (c *Client) SetBackoff(initial, max time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reconnectInitial, c.reconnectMax = initial, max
+}
+
+// SetRetry змінює кількість спроб доставки при NACK і початкову затримку між ними
+func (c *Client) SetRetry(attempts int, backoff time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retryAttempts, c.retryBackoff = attempts, backoff
+}
+
+// SetDeadLetter встановлює обробник повідомлень, відхилених після всіх спроб.
+// Якщо ackPanel true, панелі відповідається ACK, щойно повідомлення збережено.
+func (c *Client) SetDeadLetter(fn DeadLetterFunc, ackPanel bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadLetter, c.ackDeadLettered = fn, ackPanel
+}
+
+// SetHeartbeat змінює інтервал і кадр тесту зв'язку (інтервал 0 - вимкнути)
+func (c *Client) SetHeartbeat(interval time.Duration, frame string) {
+	c.mu.Lock()
+	c.heartbeatInterval, c.heartbeatFrame = interval, heartbeatFrame(frame)
+	c.mu.Unlock()
+
+	select {
+	case c.heartbeatReset <- struct{}{}:
+	default:
+	}
+}
+
+// SetReplayRate змінює обмеження відправки приймачу (0 - без обмеження)
+func (c *Client) SetReplayRate(rate float64) {
+	c.mu.Lock()
+	c.replay = replayLimiter(rate)
+	c.mu.Unlock()
+	c.wakeUp()
+}
+
+// Pause припиняє видачу повідомлень з черги: поточне повідомлення доставляється
+// до кінця, тест зв'язку продовжується, решта накопичується в черзі
+func (c *Client) Pause() {
+	if !c.paused.Swap(true) {
+		slog.Warn("Client paused, messages stay queued", "target", c.target())
+	}
+	c.wakeUp()
+}
+
+// Resume відновлює видачу повідомлень з черги
+func (c *Client) Resume() {
+	if c.paused.Swap(false) {
+		slog.Info("Client resumed", "target", c.target())
+	}
+	c.wakeUp()
+}
+
+// Paused повідомляє, чи призупинено видачу повідомлень
+func (c *Client) Paused() bool {
+	return c.paused.Load()
+}
+
+// wakeUp змушує цикл з'єднання перечитати паузу і обмеження відправки
+func (c *Client) wakeUp() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// replayLimit повертає поточний обмежувач відправки
+func (c *Client) replayLimit() *ratelimiter.RateLimiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.replay
+}
+
+// heartbeat повертає поточні параметри тесту зв'язку
+func (c *Client) heartbeat() (interval time.Duration, frame []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.heartbeatInterval, c.heartbeatFrame
+}
+
+// retryPolicy повертає поточні параметри повторної доставки (щонайменше одна спроба)
+func (c *Client) retryPolicy() (attempts int, backoff time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return max(c.retryAttempts, 1), c.retryBackoff
+}
+
+// backoff повертає поточні параметри затримки перепідключення
+func (c *Client) backoff() (initial, max time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reconnectInitial, c.reconnectMax
+}
+
+// Busy повідомляє, чи доставляє клієнт зараз повідомлення
+func (c *Client) Busy() bool {
+	return c.busy.Load()
+}
+
+// Dropped повертає кількість повідомлень, доставку яких перервали обрив з'єднання або зупинка
+func (c *Client) Dropped() int64 {
+	return c.dropped.Load()
 }
 
 // GetQueueStats повертає канал зі статистикою
@@ -59,19 +242,22 @@ func (c *Client) GetQueueStats() <-chan metrics.Snapshot {
 	return ch
 }
 
-// Run запускає клієнта з автоматичним перепідключенням
+// Run запускає клієнта з автоматичним перепідключенням. Повертається після
+// скасування ctx, коли поточну доставку завершено або перервано.
 func (c *Client) Run(ctx context.Context) {
 	ctx, cancel := context.WithCancel(ctx)
 	c.cancel = cancel
 
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		defer func() {
 			if r := recover(); r != nil {
 				slog.Error("Panic in client run loop", "panic", r)
 			}
 		}()
 
-		delay := c.reconnectInitial
+		delay, _ := c.backoff()
 		reconnectAttempts := 0
 
 		for {
@@ -82,6 +268,12 @@ func (c *Client) Run(ctx context.Context) {
 			default:
 			}
 
+			// Зміна адреси до підключення вже врахована в dial
+			select {
+			case <-c.retarget:
+			default:
+			}
+
 			conn, err := c.dial(ctx)
 			if err != nil {
 				c.metrics.SetConnected(false)
@@ -101,6 +293,8 @@ func (c *Client) Run(ctx context.Context) {
 				select {
 				case <-time.After(delay):
 					delay = c.calculateNextDelay(delay)
+				case <-c.retarget:
+					delay, _ = c.backoff()
 				case <-ctx.Done():
 					return
 				}
@@ -118,13 +312,14 @@ func (c *Client) Run(ctx context.Context) {
 			conn.Close()
 			c.conn = nil
 			c.metrics.SetConnected(false)
-			delay = c.reconnectInitial
+			delay, _ = c.backoff()
 
 			slog.Info("Connection closed, reconnecting...")
 		}
 	}()
 
 	<-ctx.Done()
+	<-done
 	slog.Info("Client run loop stopped")
 }
 
@@ -138,15 +333,18 @@ func (c *Client) dial(ctx context.Context) (net.Conn, error) {
 
 // calculateNextDelay обчислює наступну затримку з exponential backoff
 func (c *Client) calculateNextDelay(current time.Duration) time.Duration {
+	_, reconnectMax := c.backoff()
 	next := current * 2
-	if next > c.reconnectMax {
-		return c.reconnectMax
+	if next > reconnectMax {
+		return reconnectMax
 	}
 	return next
 }
 
 // target повертає адресу цілі
 func (c *Client) target() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return net.JoinHostPort(c.host, c.port)
 }
 
@@ -166,7 +364,9 @@ func (c *Client) Stop() {
 	})
 }
 
-// handleConnection обробляє одне з'єднання до його закриття
+// handleConnection обробляє одне з'єднання до його закриття. Якщо з'єднання
+// простоює довше за інтервал тесту зв'язку, приймачу надсилається тест зв'язку.
+// Поки клієнт на паузі або вичерпано обмеження відправки, черга не читається.
 func (c *Client) handleConnection(ctx context.Context, conn net.Conn) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -174,18 +374,69 @@ func (c *Client) handleConnection(ctx context.Context, conn net.Conn) {
 		}
 	}()
 
+	idle := time.NewTimer(0)
+	defer idle.Stop()
+	resetIdle := func() {
+		if interval, _ := c.heartbeat(); interval > 0 {
+			idle.Reset(interval)
+		} else {
+			idle.Stop()
+		}
+	}
+	resetIdle()
+	replies := c.readerFor(conn)
+
 	for {
+		events, limit := c.queue.Events(), c.replayLimit()
+		var throttled <-chan time.Time
+		if c.paused.Load() {
+			events = nil
+		} else if limit != nil {
+			if d := limit.Delay(); d > 0 {
+				events, throttled = nil, time.After(d)
+			}
+		}
+
 		select {
-		case data, ok := <-c.queue.Events():
+		case data, ok := <-events:
 			if !ok {
 				slog.Info("DataChannel closed, stopping connection handler")
 				return
 			}
+			if limit != nil {
+				limit.Allow()
+			}
+
+			c.busy.Store(true)
+			err := c.processMessage(ctx, conn, data)
+			c.busy.Store(false)
+			if err != nil {
+				slog.Error("Failed to process message", "error", err, logging.CorrelationKey, data.ID)
+				return
+			}
+			resetIdle()
 
-			if err := c.processMessage(ctx, conn, data); err != nil {
-				slog.Error("Failed to process message", "error", err)
+		case <-idle.C:
+			if err := c.linkTest(conn); err != nil {
+				c.metrics.IncrementLinkDown()
+				slog.Warn("Link test failed, reconnecting", "target", c.target(), "error", err)
 				return
 			}
+			resetIdle()
+
+		case <-c.heartbeatReset:
+			resetIdle()
+
+		case <-throttled:
+		case <-c.wake:
+
+		case <-replies.done:
+			slog.Warn("Connection closed by receiver", "error", replies.err)
+			return
+
+		case <-c.retarget:
+			slog.Info("Closing connection to switch target")
+			return
 
 		case <-ctx.Done():
 			slog.Info("Stopping connection handler due to shutdown")
@@ -194,61 +445,166 @@ func (c *Client) handleConnection(ctx context.Context, conn net.Conn) {
 	}
 }
 
-// processMessage обробляє одне повідомлення
-func (c *Client) processMessage(ctx context.Context, conn net.Conn, data queue.SharedData) error {
-	// Встановлюємо дедлайн на запис
-	if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
-		return fmt.Errorf("failed to set write deadline: %w", err)
+// linkTest надсилає тест зв'язку і записує час відповіді. Помилка означає, що
+// приймач не відповів і з'єднання слід вважати втраченим.
+func (c *Client) linkTest(conn net.Conn) error {
+	_, frame := c.heartbeat()
+	start := time.Now()
+	status, err := c.deliver(conn, frame, replyTimeout)
+	if err != nil {
+		return err
 	}
 
-	// Відправляємо дані
-	if _, err := conn.Write(data.Payload); err != nil {
-		return fmt.Errorf("write failed: %w", err)
+	rtt := time.Since(start)
+	c.metrics.RecordLinkRTT(rtt)
+	if !status {
+		// Приймач живий, але не приймає кадр - ймовірно, неправильний формат
+		slog.Warn("Link test rejected by receiver, check client.heartbeatframe", "rtt", rtt)
+		return nil
 	}
+	slog.Debug("Link test acknowledged", "rtt", rtt)
+	return nil
+}
 
-	slog.Debug("Wrote to server", "length", len(data.Payload))
+// processMessage обробляє одне повідомлення. При NACK повідомлення надсилається
+// повторно із зростаючою затримкою; після останньої спроби воно передається
+// обробнику недоставлених повідомлень.
+func (c *Client) processMessage(ctx context.Context, conn net.Conn, data queue.SharedData) error {
+	attempts, delay := c.retryPolicy()
+	// Всі спроби мають вкластися до того, як сервер відповість панелі за таймаутом
+	deadline := time.Now().Add(retryBudget)
+
+	var status bool
+	attempt := 1
+	for ; ; attempt++ {
+		var err error
+		wait := min(replyTimeout, time.Until(deadline))
+		status, err = c.deliver(conn, data.Payload, wait)
+		if errors.Is(err, errReplyTimeout) && wait < replyTimeout {
+			// Приймач живий, але не встиг у межах бюджету: це остаточний NACK, а не обрив.
+			// З'єднання лишається, запізнілу відповідь відкине наступна доставка.
+			slog.Warn("Reply not received within retry budget, treating as NACK", "attempt", attempt, "wait", wait, logging.CorrelationKey, data.ID)
+			status = false
+			break
+		}
+		if err != nil {
+			c.abandon(data)
+			return err
+		}
+		if status || attempt >= attempts {
+			break
+		}
+		if time.Until(deadline) < delay+retryReplyWait {
+			slog.Warn("Retry budget exhausted", "attempt", attempt, "of", attempts, logging.CorrelationKey, data.ID)
+			break
+		}
 
-	// Чекаємо відповідь
-	reply, err := c.readReply(conn)
-	if err != nil {
-		return fmt.Errorf("read reply failed: %w", err)
+		c.metrics.IncrementRetries()
+		slog.Warn("Received NACK, retrying", "attempt", attempt, "of", attempts, "delay", delay, logging.CorrelationKey, data.ID)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			c.abandon(data)
+			return ctx.Err()
+		}
+		delay = min(delay*2, config.MaxRetryBackoff)
 	}
 
 	// Обробляємо відповідь
-	status := c.parseReply(reply)
-
 	if status {
 		c.metrics.IncrementAccepted()
-		slog.Debug("Received ACK from server")
+		slog.Debug("Received ACK from server", logging.CorrelationKey, data.ID)
 	} else {
 		c.metrics.IncrementRejected()
-		slog.Debug("Received NACK from server")
+		slog.Debug("Received NACK from server", logging.CorrelationKey, data.ID)
+		status = c.storeDeadLetter(data, attempt)
 	}
 
-	// Відправляємо статус назад
+	// Відправляємо статус назад; канал буферизований, тож відправка не блокує
 	select {
 	case data.ReplyCh <- queue.DeliveryData{Status: status}:
 		close(data.ReplyCh)
-	case <-time.After(replyTimeout):
-		slog.Warn("Timeout sending reply to server handler")
+	default:
+		slog.Warn("Reply channel is full, dropping status", logging.CorrelationKey, data.ID)
 	}
 
 	return nil
 }
 
-// readReply читає відповідь від сервера з таймаутом
-func (c *Client) readReply(conn net.Conn) ([]byte, error) {
-	if err := conn.SetReadDeadline(time.Now().Add(replyTimeout)); err != nil {
-		return nil, fmt.Errorf("failed to set read deadline: %w", err)
+// abandon рахує повідомлення, доставку якого перервано, і відповідає на нього NACK:
+// сервер не чекатиме свого таймауту, а запис дедуплікації звільниться
+func (c *Client) abandon(data queue.SharedData) {
+	c.dropped.Add(1)
+	select {
+	case data.ReplyCh <- queue.DeliveryData{Status: false}:
+	default:
+	}
+}
+
+// deliver надсилає повідомлення і чекає відповідь приймача не довше за wait (true - ACK)
+func (c *Client) deliver(conn net.Conn, payload []byte, wait time.Duration) (bool, error) {
+	// Відповіді, що прийшли без запиту, не повинні зарахуватися цьому повідомленню
+	replies := c.readerFor(conn)
+	if n := replies.discardStale(); n > 0 {
+		slog.Warn("Discarded stale replies from receiver", "count", n)
 	}
 
-	reply := make([]byte, 1024)
-	n, err := conn.Read(reply)
+	// Встановлюємо дедлайн на запис
+	if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return false, fmt.Errorf("failed to set write deadline: %w", err)
+	}
+
+	// Відправляємо дані
+	if _, err := conn.Write(payload); err != nil {
+		return false, fmt.Errorf("write failed: %w", err)
+	}
+
+	slog.Debug("Wrote to server", "length", len(payload))
+
+	// Чекаємо відповідь
+	reply, err := c.readReply(conn, wait)
+	if err != nil {
+		return false, fmt.Errorf("read reply failed: %w", err)
+	}
+
+	return c.parseReply(reply), nil
+}
+
+// storeDeadLetter передає відхилене повідомлення обробнику. Повертає статус для
+// панелі: true, якщо повідомлення збережено і налаштовано ACK збережених.
+func (c *Client) storeDeadLetter(data queue.SharedData, attempts int) bool {
+	c.mu.Lock()
+	fn, ackPanel := c.deadLetter, c.ackDeadLettered
+	c.mu.Unlock()
+	if fn == nil {
+		return false
+	}
+
+	reason := fmt.Sprintf("NACK after %d attempt(s)", attempts)
+	if err := fn(data.Payload, reason, attempts); err != nil {
+		slog.Error("Failed to store dead letter", "error", err, logging.CorrelationKey, data.ID)
+		return false
+	}
+	c.metrics.IncrementDeadLettered()
+	slog.Warn("Message moved to dead letters", "reason", reason, logging.CorrelationKey, data.ID)
+	return ackPanel
+}
+
+// readReply чекає відповідь приймача (ACK або NACK) на надіслане повідомлення з таймаутом
+func (c *Client) readReply(conn net.Conn, wait time.Duration) ([]byte, error) {
+	reply, err := c.readerFor(conn).wait(wait)
 	if err != nil {
 		return nil, err
 	}
+	return []byte{reply}, nil
+}
 
-	return reply[:n], nil
+// readerFor повертає читача відповідей з'єднання, створюючи його за потреби
+func (c *Client) readerFor(conn net.Conn) *replyReader {
+	if c.replies == nil || c.replies.conn != conn {
+		c.replies = newReplyReader(conn)
+	}
+	return c.replies
 }
 
 // parseReply визначає статус відповіді (ACK/NACK)
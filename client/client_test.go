@@ -135,6 +135,125 @@ func TestClient_processMessage(t *testing.T) {
 	}
 }
 
+func TestClient_processMessage_RetryBudget(t *testing.T) {
+	orig := retryBudget
+	retryBudget = retryReplyWait + 200*time.Millisecond
+	defer func() { retryBudget = orig }()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	c := &Client{metrics: metrics.New()}
+	c.SetRetry(5, 100*time.Millisecond)
+	var attempts int
+	c.SetDeadLetter(func(payload []byte, reason string, n int) error {
+		attempts = n
+		return nil
+	}, false)
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := serverConn.Read(buf); err != nil {
+				return
+			}
+			serverConn.Write([]byte{nackByte})
+		}
+	}()
+
+	replyCh := make(chan queue.DeliveryData, 1)
+	start := time.Now()
+	if err := c.processMessage(context.Background(), clientConn, queue.SharedData{Payload: []byte("test"), ReplyCh: replyCh}); err != nil {
+		t.Fatalf("processMessage failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > retryBudget {
+		t.Errorf("retries took %s, budget %s", elapsed, retryBudget)
+	}
+	// Третя спроба вже не встигла б отримати відповідь у межах бюджету
+	if attempts != 2 {
+		t.Errorf("dead-lettered after %d attempts, want 2", attempts)
+	}
+	if (<-replyCh).Status {
+		t.Error("expected NACK status for the panel")
+	}
+}
+
+// Приймач відповідає після бюджету, але до replyTimeout: повідомлення відхиляється,
+// а з'єднання лишається для наступних
+func TestClient_processMessage_SlowReceiver(t *testing.T) {
+	orig := retryBudget
+	retryBudget = 200 * time.Millisecond
+	defer func() { retryBudget = orig }()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	c := &Client{metrics: metrics.New()}
+	var dead int
+	c.SetDeadLetter(func(payload []byte, reason string, attempts int) error {
+		dead++
+		return nil
+	}, false)
+
+	go func() {
+		buf := make([]byte, 1024)
+		for _, reply := range []struct {
+			delay time.Duration
+			b     byte
+		}{{400 * time.Millisecond, ackByte}, {0, nackByte}} {
+			if _, err := serverConn.Read(buf); err != nil {
+				return
+			}
+			time.Sleep(reply.delay)
+			serverConn.Write([]byte{reply.b})
+		}
+	}()
+
+	replyCh := make(chan queue.DeliveryData, 1)
+	if err := c.processMessage(context.Background(), clientConn, queue.SharedData{Payload: []byte("slow"), ReplyCh: replyCh}); err != nil {
+		t.Fatalf("processMessage dropped the connection: %v", err)
+	}
+	if (<-replyCh).Status || dead != 1 || c.Dropped() != 0 {
+		t.Errorf("slow reply: dead letters %d, dropped %d", dead, c.Dropped())
+	}
+
+	// Запізнілий ACK не зараховується наступному повідомленню
+	time.Sleep(300 * time.Millisecond)
+	replyCh = make(chan queue.DeliveryData, 1)
+	if err := c.processMessage(context.Background(), clientConn, queue.SharedData{Payload: []byte("next"), ReplyCh: replyCh}); err != nil {
+		t.Fatalf("connection unusable after slow reply: %v", err)
+	}
+	if (<-replyCh).Status {
+		t.Error("late ACK was counted for the next message")
+	}
+}
+
+func TestClient_processMessage_ConnectionError(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	serverConn.Close()
+	defer clientConn.Close()
+
+	c := &Client{metrics: metrics.New()}
+	replyCh := make(chan queue.DeliveryData, 1)
+	if err := c.processMessage(context.Background(), clientConn, queue.SharedData{Payload: []byte("test"), ReplyCh: replyCh}); err == nil {
+		t.Fatal("expected error on a closed connection")
+	}
+
+	select {
+	case reply := <-replyCh:
+		if reply.Status {
+			t.Error("expected NACK status after a connection error")
+		}
+	default:
+		t.Error("server handler got no reply after a connection error")
+	}
+	if c.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", c.Dropped())
+	}
+}
+
 func TestClient_Run_ConnectionLoop(t *testing.T) {
 	// Start a real TCP listener to test connection
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
@@ -189,3 +308,239 @@ func TestClient_Run_ConnectionLoop(t *testing.T) {
 	cancel()
 	wg.Wait()
 }
+
+func TestClient_SetTarget(t *testing.T) {
+	first, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start listener: %v", err)
+	}
+	defer first.Close()
+	second, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start listener: %v", err)
+	}
+	defer second.Close()
+
+	host, port, _ := net.SplitHostPort(first.Addr().String())
+	c := New(&config.ClientConfig{
+		Host:             host,
+		Port:             port,
+		ReconnectInitial: 10 * time.Millisecond,
+		ReconnectMax:     100 * time.Millisecond,
+	}, queue.New(10, metrics.New()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	conn, err := first.Accept()
+	if err != nil {
+		t.Fatalf("failed to accept on first target: %v", err)
+	}
+	defer conn.Close()
+
+	host, port, _ = net.SplitHostPort(second.Addr().String())
+	c.SetTarget(host, port)
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := second.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	select {
+	case conn := <-accepted:
+		conn.Close()
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not reconnect to the new target")
+	}
+
+	// Старе з'єднання має бути закрите клієнтом
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("expected old connection to be closed")
+	}
+}
+
+func TestClient_processMessage_RetryAndDeadLetter(t *testing.T) {
+	tests := []struct {
+		name       string
+		replies    []byte
+		ackPanel   bool
+		wantStatus bool
+		wantWrites int
+		wantDead   int
+	}{
+		{"ACK after retry", []byte{nackByte, ackByte}, true, true, 2, 0},
+		{"dead-lettered, panel ACKed", []byte{nackByte, nackByte, nackByte}, true, true, 3, 1},
+		{"dead-lettered, panel NACKed", []byte{nackByte, nackByte, nackByte}, false, false, 3, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			defer clientConn.Close()
+			defer serverConn.Close()
+
+			stats := metrics.New()
+			c := &Client{metrics: stats, reconnectMax: time.Millisecond}
+			c.SetRetry(3, time.Millisecond)
+
+			var dead [][]byte
+			c.SetDeadLetter(func(payload []byte, reason string, attempts int) error {
+				if attempts != 3 {
+					t.Errorf("attempts = %d, want 3", attempts)
+				}
+				dead = append(dead, payload)
+				return nil
+			}, tt.ackPanel)
+
+			writes := make(chan int, 1)
+			go func() {
+				buf := make([]byte, 1024)
+				n := 0
+				for _, reply := range tt.replies {
+					if _, err := serverConn.Read(buf); err != nil {
+						break
+					}
+					n++
+					serverConn.Write([]byte{reply})
+					if reply == ackByte {
+						break
+					}
+				}
+				writes <- n
+			}()
+
+			replyCh := make(chan queue.DeliveryData, 1)
+			err := c.processMessage(context.Background(), clientConn, queue.SharedData{Payload: []byte("test"), ReplyCh: replyCh})
+			if err != nil {
+				t.Fatalf("processMessage failed: %v", err)
+			}
+
+			if got := (<-replyCh).Status; got != tt.wantStatus {
+				t.Errorf("status = %v, want %v", got, tt.wantStatus)
+			}
+			if got := <-writes; got != tt.wantWrites {
+				t.Errorf("writes = %d, want %d", got, tt.wantWrites)
+			}
+			snap := stats.Snapshot()
+			if len(dead) != tt.wantDead || snap.DeadLetter != int64(tt.wantDead) {
+				t.Errorf("dead letters = %d (metric %d), want %d", len(dead), snap.DeadLetter, tt.wantDead)
+			}
+			if snap.Retries != int64(tt.wantWrites-1) {
+				t.Errorf("retries = %d, want %d", snap.Retries, tt.wantWrites-1)
+			}
+		})
+	}
+}
+
+func TestClient_handleConnection_Heartbeat(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	stats := metrics.New()
+	c := New(&config.ClientConfig{
+		ReconnectInitial:  time.Millisecond,
+		ReconnectMax:      time.Millisecond,
+		HeartbeatInterval: 20 * time.Millisecond,
+		HeartbeatFrame:    "1011           @   ",
+	}, &queue.MockQueue{Stats: stats})
+
+	done := make(chan struct{})
+	go func() {
+		c.handleConnection(context.Background(), clientConn)
+		close(done)
+	}()
+
+	// Перший тест зв'язку підтверджуємо
+	buf := make([]byte, 64)
+	n, err := serverConn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "1011           @   \x14" {
+		t.Errorf("link test frame = %q", got)
+	}
+	time.Sleep(5 * time.Millisecond)
+	serverConn.Write([]byte{ackByte})
+
+	// Другий лишаємо без відповіді: приймач "зник"
+	if _, err := serverConn.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	serverConn.Close()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handleConnection did not return after a missed link test")
+	}
+
+	snap := stats.Snapshot()
+	if snap.LinkRTT < 5*time.Millisecond {
+		t.Errorf("LinkRTT = %s, want at least 5ms", snap.LinkRTT)
+	}
+	if snap.LinkDown != 1 {
+		t.Errorf("LinkDown = %d, want 1", snap.LinkDown)
+	}
+}
+
+// ackAll підтверджує кожен кадр і передає час його отримання в got
+func ackAll(conn net.Conn, got chan<- time.Time) {
+	buf := make([]byte, 64)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			return
+		}
+		got <- time.Now()
+		conn.Write([]byte{ackByte})
+	}
+}
+
+func TestClient_handleConnection_PauseAndReplayRate(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	q := queue.New(50, nil)
+	c := New(&config.ClientConfig{ReplayRate: 20}, q)
+	c.Pause()
+	for i := range 22 {
+		q.Enqueue(queue.SharedData{ID: strconv.Itoa(i), Payload: []byte("5010 181234E13001001\x14"), ReplyCh: make(chan queue.DeliveryData, 1)})
+	}
+
+	got := make(chan time.Time, 22)
+	go ackAll(serverConn, got)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.handleConnection(ctx, clientConn)
+
+	select {
+	case <-got:
+		t.Fatal("paused client sent a message")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if b := q.Backlog(); b.Count != 22 || b.Classes["alarm"] != 22 {
+		t.Errorf("backlog while paused = %+v", b)
+	}
+
+	// 20 повідомлень одразу (запас на секунду), решта - 20 за секунду
+	start := time.Now()
+	c.Resume()
+	var last time.Time
+	for range 22 {
+		select {
+		case last = <-got:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for replayed messages")
+		}
+	}
+	if elapsed := last.Sub(start); elapsed < 90*time.Millisecond {
+		t.Errorf("22 messages replayed in %s, want at least 100ms at 20/s", elapsed)
+	}
+	if c.Paused() {
+		t.Error("client still paused after Resume")
+	}
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// replyBuffer - скільки відповідей може накопичитися до їх розбору
+const replyBuffer = 16
+
+// errReplyTimeout повертається, якщо приймач не відповів вчасно
+var errReplyTimeout = errors.New("reply timeout")
+
+// replyReader читає потік відповідей приймача у фоні. Відповіді можуть прийти
+// частинами або злитими в один сегмент; байти, що не є ACK/NACK, відкидаються.
+// Кожна відповідь зіставляється з повідомленням, що очікує на неї: відповіді,
+// отримані, коли нічого не очікувало (запізнілі, повторні), відкидаються перед
+// відправкою наступного повідомлення.
+type replyReader struct {
+	conn    net.Conn
+	replies chan byte
+	done    chan struct{} // Закривається, коли читання з'єднання завершилося
+	err     error         // Причина завершення; читати лише після done
+}
+
+// newReplyReader запускає читання відповідей з'єднання
+func newReplyReader(conn net.Conn) *replyReader {
+	r := &replyReader{
+		conn:    conn,
+		replies: make(chan byte, replyBuffer),
+		done:    make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+func (r *replyReader) run() {
+	defer close(r.done)
+
+	buf := make([]byte, 1024)
+	for {
+		n, err := r.conn.Read(buf)
+		replies, garbage := parseReplies(buf[:n])
+		if garbage > 0 {
+			slog.Debug("Discarded unexpected bytes from receiver", "count", garbage)
+		}
+		for _, reply := range replies {
+			select {
+			case r.replies <- reply:
+			default:
+				slog.Warn("Too many unsolicited replies from receiver, dropping")
+			}
+		}
+		if err != nil {
+			r.err = err
+			return
+		}
+	}
+}
+
+// parseReplies виділяє з сегмента байти ACK/NACK і рахує решту (сміття)
+func parseReplies(data []byte) (replies []byte, garbage int) {
+	for _, b := range data {
+		switch b {
+		case ackByte, nackByte:
+			replies = append(replies, b)
+		default:
+			garbage++
+		}
+	}
+	return replies, garbage
+}
+
+// discardStale відкидає відповіді, на які ніхто не чекає. Викликається перед
+// відправкою повідомлення.
+func (r *replyReader) discardStale() int {
+	n := 0
+	for {
+		select {
+		case <-r.replies:
+			n++
+		default:
+			return n
+		}
+	}
+}
+
+// wait чекає відповідь на надіслане повідомлення
+func (r *replyReader) wait(timeout time.Duration) (byte, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case reply := <-r.replies:
+		return reply, nil
+	case <-r.done:
+		// Відповідь могла прийти разом із закриттям з'єднання
+		select {
+		case reply := <-r.replies:
+			return reply, nil
+		default:
+		}
+		return 0, r.err
+	case <-timer.C:
+		return 0, errReplyTimeout
+	}
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseReplies(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		wantReplies []byte
+		wantGarbage int
+	}{
+		{"single ACK", []byte{ackByte}, []byte{ackByte}, 0},
+		{"coalesced", []byte{ackByte, nackByte, ackByte}, []byte{ackByte, nackByte, ackByte}, 0},
+		{"garbage around", []byte{'O', 'K', ackByte, '\r', '\n'}, []byte{ackByte}, 4},
+		{"only garbage", []byte{0xFF, 0x00}, nil, 2},
+		{"empty", nil, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replies, garbage := parseReplies(tt.data)
+			if !bytes.Equal(replies, tt.wantReplies) || garbage != tt.wantGarbage {
+				t.Errorf("parseReplies(%q) = %q, %d; want %q, %d", tt.data, replies, garbage, tt.wantReplies, tt.wantGarbage)
+			}
+		})
+	}
+}
+
+// exchange - поведінка приймача для одного повідомлення
+type exchange struct {
+	before   []byte   // Надсилається без запиту перед повідомленням
+	segments [][]byte // Відповідь, що надсилається окремими сегментами
+	want     bool
+}
+
+func TestClient_deliver_Framing(t *testing.T) {
+	tests := []struct {
+		name      string
+		exchanges []exchange
+	}{
+		{"fragmented with leading garbage", []exchange{
+			{segments: [][]byte{{'\r'}, {0xFF}, {ackByte}}, want: true},
+		}},
+		{"garbage then NACK in one segment", []exchange{
+			{segments: [][]byte{[]byte("ERR\x15")}, want: false},
+		}},
+		{"coalesced double ACK is not reused", []exchange{
+			{segments: [][]byte{{ackByte, ackByte}}, want: true},
+			{segments: [][]byte{{nackByte}}, want: false},
+		}},
+		{"late reply before next message is discarded", []exchange{
+			{segments: [][]byte{{nackByte}}, want: false},
+			{before: []byte{ackByte}, segments: [][]byte{{nackByte}}, want: false},
+			{segments: [][]byte{{ackByte}}, want: true},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			defer clientConn.Close()
+			defer serverConn.Close()
+
+			ready := make(chan struct{})
+			go func() {
+				buf := make([]byte, 64)
+				for _, ex := range tt.exchanges {
+					if ex.before != nil {
+						serverConn.Write(ex.before)
+					}
+					ready <- struct{}{}
+					if _, err := serverConn.Read(buf); err != nil {
+						return
+					}
+					for _, seg := range ex.segments {
+						serverConn.Write(seg)
+						time.Sleep(time.Millisecond)
+					}
+				}
+			}()
+
+			c := &Client{}
+			for i, ex := range tt.exchanges {
+				<-ready
+				// Даємо запізнілій відповіді дійти до читача
+				time.Sleep(10 * time.Millisecond)
+				got, err := c.deliver(clientConn, []byte("msg"), replyTimeout)
+				if err != nil {
+					t.Fatalf("message %d: %v", i, err)
+				}
+				if got != ex.want {
+					t.Errorf("message %d: status = %v, want %v", i, got, ex.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReplyReader_ConnectionClosed(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	r := newReplyReader(clientConn)
+	serverConn.Close()
+
+	if _, err := r.wait(time.Second); err == nil || errors.Is(err, errReplyTimeout) {
+		t.Errorf("wait() = %v, want the connection error", err)
+	}
+}
@@ -0,0 +1,154 @@
+// cidrelayd - ретранслятор без UI для роботи як служба Windows або юніт systemd.
+//
+//	cidrelayd [прапорці] [run|install|uninstall|start|stop|token]
+package main
+
+import (
+	"cid_retranslator_walk/api"
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/core"
+	"cid_retranslator_walk/service"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	var overrides stringList
+	configPath := flag.String("config", config.DefaultPath, "path to the configuration file")
+	flag.Var(&overrides, "set", "override a configuration field, e.g. -set server.port=20005 (repeatable)")
+	strictConfig := flag.Bool("strict-config", false, "reject unknown keys in the configuration file")
+	name := flag.String("name", service.DefaultName, "service name")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [run|install|uninstall|start|stop|token]\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Служба запускається не з каталогу програми, тому шлях до конфігурації абсолютний
+	path, err := filepath.Abs(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sources := config.Sources{
+		Path:      path,
+		Environ:   os.Environ(),
+		Overrides: overrides,
+		Strict:    *strictConfig,
+	}
+
+	command := "run"
+	if flag.NArg() > 0 {
+		command = flag.Arg(0)
+	}
+
+	manager := service.NewManager()
+	switch command {
+	case "run":
+		err = run(sources, *name)
+	case "install":
+		err = install(manager, sources, *name)
+	case "uninstall":
+		err = manager.Uninstall(*name)
+	case "start":
+		err = manager.Start(*name)
+	case "stop":
+		err = manager.Stop(*name)
+	case "token":
+		err = printToken()
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s %s: %v", command, *name, err)
+	}
+	if command != "run" && command != "token" {
+		fmt.Printf("%s %s: OK\n", command, *name)
+	}
+}
+
+// run запускає ядро під керуванням менеджера служб
+func run(sources config.Sources, name string) error {
+	cfg, err := sources.Load()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return fmt.Errorf("invalid configuration %s: %w", sources.Path, err)
+	}
+
+	app := core.NewApp(cfg, sources)
+
+	// Оновлення для UI нікому читати, але без читача конвеєри заблокуються
+	go func() {
+		for range app.GetDeviceUpdates() {
+		}
+	}()
+	go func() {
+		for range app.GetEventUpdates() {
+		}
+	}()
+
+	return service.Run(name, app)
+}
+
+// install реєструє службу з поточною конфігурацією: перевіряє її і передає
+// службі ті самі -config, -set і -strict-config
+func install(manager service.Manager, sources config.Sources, name string) error {
+	cfg, err := sources.Load()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return fmt.Errorf("invalid configuration %s: %w", sources.Path, err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-config", sources.Path, "-name", name}
+	for _, o := range sources.Overrides {
+		args = append(args, "-set", o)
+	}
+	if sources.Strict {
+		args = append(args, "-strict-config")
+	}
+	args = append(args, "run")
+
+	return manager.Install(service.Config{
+		Name:        name,
+		DisplayName: "CID Retranslator",
+		Description: "Contact ID relay from panel receivers to the monitoring station",
+		Executable:  exe,
+		Args:        args,
+	})
+}
+
+// printToken генерує токен API і виводить його разом із записом для api.tokens
+func printToken() error {
+	token, err := api.NewToken()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("token: %s\n\n", token)
+	fmt.Printf("api:\n    tokens:\n        - name: <name>\n          hash: %s\n          role: viewer\n", api.HashToken(token))
+	return nil
+}
+
+// stringList - значення прапорця, що може повторюватися
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -15,14 +15,29 @@ type Config struct {
 	Queue      QueueConfig      `yaml:"queue"`
 	Logging    LoggingConfig    `yaml:"logging"`
 	CIDRules   CIDRules         `yaml:"cidrules"`
+	Routing    RoutingConfig    `yaml:"routing"`
 	Monitoring MonitoringConfig `yaml:"monitoring"`
 	UI         UIConfig         `yaml:"ui"`
+	API        APIConfig        `yaml:"api"`
+	DeadLetter DeadLetterConfig `yaml:"deadletter"`
+	Audit      AuditConfig      `yaml:"audit"`
+	Notify     NotifyConfig     `yaml:"notify"`
+	Forward    ForwardConfig    `yaml:"forward"`
+	MQTT       MQTTConfig       `yaml:"mqtt"`
+	Bus        BusConfig        `yaml:"bus"`
+	Events     EventsConfig     `yaml:"events"`
+	State      StateConfig      `yaml:"state"`
+	Incidents  IncidentsConfig  `yaml:"incidents"`
+	Objects    map[int]string   `yaml:"objects"`             // Object names by account number
+	Pipelines  []PipelineConfig `yaml:"pipelines,omitempty"` // Independent relays; empty means one built from the sections above
 }
 
 // ServerConfig holds server-specific configuration.
 type ServerConfig struct {
-	Host string `yaml:"host"`
-	Port string `yaml:"port"`
+	Host            string        `yaml:"host"`
+	Port            string        `yaml:"port"`
+	DedupWindow     time.Duration `yaml:"dedupwindow"`     // Window for suppressing retransmitted frames (0 disables)
+	DedupMaxEntries int           `yaml:"dedupmaxentries"` // Upper bound on remembered frames
 }
 
 // ClientConfig holds client-specific configuration.
@@ -31,11 +46,50 @@ type ClientConfig struct {
 	Port             string        `yaml:"port"`
 	ReconnectInitial time.Duration `yaml:"reconnectinitial"`
 	ReconnectMax     time.Duration `yaml:"reconnectmax"`
+	RetryAttempts    int           `yaml:"retryattempts"` // Deliveries of a NACKed message before it is dead-lettered (0 or 1: no retries)
+	RetryBackoff     time.Duration `yaml:"retrybackoff"`  // Delay before the first retry, doubled for each next one
+
+	// Link test sent upstream when the connection has been idle for HeartbeatInterval
+	// (0, the default, disables it). A missed reply is treated as a dead link and triggers
+	// a reconnect. The frame depends on the receiver, so enabling it requires HeartbeatFrame.
+	HeartbeatInterval time.Duration `yaml:"heartbeatinterval"`
+	HeartbeatFrame    string        `yaml:"heartbeatframe"` // Frame in the receiver's format; the 0x14 terminator is appended
+
+	// Messages per second sent upstream (0 is unlimited). Keeps a backlog that built up
+	// during an outage from flooding the receiver once it is back.
+	ReplayRate float64 `yaml:"replayrate"`
+}
+
+// Limits on retrying a NACKed message. The panel gets its reply after at most the
+// server's 10s reply timeout, so every retry has to fit into a shorter budget or the
+// panel retransmits a message the client is still delivering.
+const (
+	RetryBudget     = 8 * time.Second // Total time the client may spend on one message
+	MaxRetryBackoff = 2 * time.Second // Cap on the doubled delay between retries
+)
+
+// RetryBackoffTotal returns the time spent waiting between attempts deliveries,
+// starting at backoff and doubling up to MaxRetryBackoff.
+func RetryBackoffTotal(attempts int, backoff time.Duration) time.Duration {
+	var total time.Duration
+	for i := 1; i < attempts; i++ {
+		total += backoff
+		backoff = min(backoff*2, MaxRetryBackoff)
+	}
+	return total
 }
 
 // QueueConfig holds queue-specific configuration.
 type QueueConfig struct {
-	BufferSize int `yaml:"buffersize"`
+	BufferSize   int           `yaml:"buffersize"`
+	DrainTimeout time.Duration `yaml:"draintimeout"` // How long to keep delivering queued messages on shutdown
+
+	// Priority queueing. Mode is "fifo" (default) or "priority".
+	Mode                 string         `yaml:"mode"`
+	Dequeue              string         `yaml:"dequeue"`              // "strict" or "weighted"
+	Weights              map[string]int `yaml:"weights"`              // Per-class weights for weighted dequeue
+	ClassCapacity        map[string]int `yaml:"classcapacity"`        // Per-class capacity (defaults to BufferSize)
+	PreserveAccountOrder bool           `yaml:"preserveaccountorder"` // Never reorder messages of the same account
 }
 
 // LoggingConfig holds logging configuration.
@@ -46,6 +100,19 @@ type LoggingConfig struct {
 	MaxAge     int    `yaml:"maxage"`
 	Compress   bool   `yaml:"compress"`
 	Level      string `yaml:"level"`
+
+	Format      string       `yaml:"format"`      // text or json
+	BufferLines int          `yaml:"bufferlines"` // Recent records kept in memory for GET /api/logs
+	Syslog      SyslogConfig `yaml:"syslog"`
+}
+
+// SyslogConfig holds the optional RFC 5424 syslog output.
+type SyslogConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Network  string `yaml:"network"` // udp or tcp
+	Address  string `yaml:"address"` // host:port of the syslog server
+	Facility string `yaml:"facility"`
+	AppName  string `yaml:"appname"`
 }
 
 // CIDRules holds the specific rules for CID message processing.
@@ -57,6 +124,28 @@ type CIDRules struct {
 	AccNumAdd      int               `yaml:"accnumadd"`
 }
 
+// RoutingConfig holds content-based routing of messages to additional upstreams.
+type RoutingConfig struct {
+	AckPolicy string           `yaml:"ackpolicy"` // "primary" (default), "any" or "all"
+	Upstreams []UpstreamConfig `yaml:"upstreams"` // Receivers in addition to the client ("primary")
+	Routes    []RouteConfig    `yaml:"routes"`    // Evaluated in order; all matching routes apply
+}
+
+// UpstreamConfig describes an additional named receiver.
+type UpstreamConfig struct {
+	Name       string `yaml:"name"`
+	Host       string `yaml:"host"`
+	Port       string `yaml:"port"`
+	BufferSize int    `yaml:"buffersize"` // Queue size for this upstream (0 uses queue.buffersize)
+}
+
+// RouteConfig sends messages matching an expression to the listed upstreams.
+type RouteConfig struct {
+	Name  string   `yaml:"name"`
+	Match string   `yaml:"match"` // See package match for the syntax
+	To    []string `yaml:"to"`    // Upstream names; "primary" is the client
+}
+
 // MonitoringConfig holds configuration for UI monitoring.
 type MonitoringConfig struct {
 	PPKTimeout time.Duration `yaml:"ppktimeout"`
@@ -69,21 +158,197 @@ type UIConfig struct {
 	CloseToTray    bool `yaml:"closetotray"`    // Close button minimizes to tray instead of exiting
 }
 
+// APIConfig holds configuration for the HTTP management API.
+type APIConfig struct {
+	Enabled bool       `yaml:"enabled"`
+	Listen  string     `yaml:"listen"`
+	Tokens  []APIToken `yaml:"tokens"` // Every request must present one of these tokens
+}
+
+// APIToken is a static bearer token. Only its hash is stored in the config.
+type APIToken struct {
+	Name string `yaml:"name"`               // Shown in the audit log as "token:<name>"
+	Hash string `yaml:"hash" secret:"true"` // "sha256:<hex>" of the token
+	Role string `yaml:"role"`               // viewer, operator or admin
+}
+
+// DeadLetterConfig holds the store for messages the upstream kept rejecting.
+type DeadLetterConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Path       string `yaml:"path"`       // JSON file, relative to the config file directory
+	MaxEntries int    `yaml:"maxentries"` // Oldest entries are dropped beyond this
+	// ACK the panel once a message is safely dead-lettered. Off by default: the panel
+	// then stops retransmitting and the alarm never reaches the receiver.
+	AckPanel bool `yaml:"ackpanel"`
+}
+
+// AuditConfig holds the append-only log of operator actions and configuration changes.
+type AuditConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"` // JSON Lines file, relative to the config file directory
+}
+
+// NotifyConfig holds alerts to operators about the relay's own health.
+type NotifyConfig struct {
+	Enabled        bool            `yaml:"enabled"`
+	Interval       time.Duration   `yaml:"interval"`       // How often conditions are checked
+	QueueThreshold float64         `yaml:"queuethreshold"` // Fill ratio (0..1] at which a queue counts as nearly full
+	Channels       []NotifyChannel `yaml:"channels"`
+	Rules          []NotifyRule    `yaml:"rules"`
+}
+
+// NotifyChannel is a destination for alerts. Which fields are used depends on Type.
+type NotifyChannel struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"` // smtp, webhook or telegram
+
+	// smtp; STARTTLS is used when the server offers it
+	Host     string   `yaml:"host,omitempty"`
+	Port     string   `yaml:"port,omitempty"`
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty" secret:"true"`
+	From     string   `yaml:"from,omitempty"`
+	To       []string `yaml:"to,omitempty"`
+
+	// webhook: the alert is POSTed as JSON
+	URL string `yaml:"url,omitempty"`
+
+	// telegram
+	BotToken string `yaml:"bottoken,omitempty" secret:"true"`
+	ChatID   string `yaml:"chatid,omitempty"`
+	APIURL   string `yaml:"apiurl,omitempty"` // Defaults to https://api.telegram.org
+}
+
+// NotifyRule says which conditions are sent to which channels and how often.
+type NotifyRule struct {
+	Name       string        `yaml:"name"`
+	Conditions []string      `yaml:"conditions"` // See NotifyConditions
+	Channels   []string      `yaml:"channels"`
+	Delay      time.Duration `yaml:"delay"`      // Condition must hold this long before the first alert
+	Repeat     time.Duration `yaml:"repeat"`     // Re-send while the condition holds (0 sends once)
+	Escalate   time.Duration `yaml:"escalate"`   // Also alert EscalateTo once the condition holds this long (0 disables)
+	EscalateTo []string      `yaml:"escalateto"` // Channel names
+	MaxPerHour int           `yaml:"maxperhour"` // Alerts this rule may send per hour (0 is unlimited)
+	Resolved   bool          `yaml:"resolved"`   // Send a notice when the condition clears
+}
+
+// ForwardConfig holds HTTP consumers that receive every relayed event as JSON.
+type ForwardConfig struct {
+	Destinations []ForwardDestination `yaml:"destinations"`
+}
+
+// ForwardDestination is one HTTP consumer with its own queue and retry policy.
+// Zero values of the tuning fields select the defaults shown in brackets.
+type ForwardDestination struct {
+	Name         string        `yaml:"name"`
+	URL          string        `yaml:"url"`
+	Secret       string        `yaml:"secret" secret:"true"` // HMAC-SHA256 key for request signing (empty disables)
+	Match        string        `yaml:"match"`                // Filter, see package match (empty forwards everything)
+	QueueSize    int           `yaml:"queuesize"`            // Events waiting for delivery, newer are dropped beyond this [1000]
+	BatchSize    int           `yaml:"batchsize"`            // Events per request [1]
+	BatchWait    time.Duration `yaml:"batchwait"`            // How long a partial batch waits for more events [0]
+	Timeout      time.Duration `yaml:"timeout"`              // Per request [10s]
+	RetryInitial time.Duration `yaml:"retryinitial"`         // Delay before the first retry, doubled after each failure [1s]
+	RetryMax     time.Duration `yaml:"retrymax"`             // [1m]
+	MaxAttempts  int           `yaml:"maxattempts"`          // Attempts per batch before it is dropped, 0 retries until delivered [0]
+}
+
+// MQTTConfig holds the optional MQTT publisher of events and device state.
+type MQTTConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	Broker      string        `yaml:"broker"`   // tcp://host:1883, ssl://host:8883 or ws(s)://host/path
+	ClientID    string        `yaml:"clientid"` // Empty uses "cid-relay-<hostname>"
+	Username    string        `yaml:"username"`
+	Password    string        `yaml:"password" secret:"true"`
+	TopicPrefix string        `yaml:"topicprefix"` // Root of all topics
+	EventQoS    int           `yaml:"eventqos"`
+	StateQoS    int           `yaml:"stateqos"` // Also used for the relay status and its last will
+	KeepAlive   time.Duration `yaml:"keepalive"`
+	QueueSize   int           `yaml:"queuesize"` // Messages waiting for the broker; newer are dropped beyond this
+}
+
+// BusConfig holds message bus sinks that receive every relayed message with its upstream outcome.
+type BusConfig struct {
+	Sinks []BusSink `yaml:"sinks"`
+}
+
+// BusSink is one message bus connection with its own buffer.
+// Zero values of the tuning fields select the defaults shown in brackets.
+type BusSink struct {
+	Name       string `yaml:"name"`
+	Type       string `yaml:"type"`    // See BusSinkTypes
+	URL        string `yaml:"url"`     // nats://host:4222, several separated by commas
+	Subject    string `yaml:"subject"` // Prefix; messages go to <subject>.<pipeline>.<outcome> [cid.messages]
+	Username   string `yaml:"username"`
+	Password   string `yaml:"password" secret:"true"`
+	Token      string `yaml:"token" secret:"true"`
+	BufferSize int    `yaml:"buffersize"` // Messages waiting for the bus; newer are spilled or dropped beyond this [10000]
+	SpillPath  string `yaml:"spillpath"`  // File that holds messages while the bus is unreachable (empty drops them)
+	SpillMax   int    `yaml:"spillmax"`   // Messages kept in the spill file, newer are dropped beyond this [100000]
+}
+
+// EventsConfig holds the event code dictionary shown in the UI and added to published events.
+// Paths are relative to the config file directory.
+type EventsConfig struct {
+	Path      string `yaml:"path"`      // Shipped dictionary, replaced on upgrade
+	Overrides string `yaml:"overrides"` // User entries layered over Path; written by the API
+	Language  string `yaml:"language"`  // See EventLanguages; missing translations fall back to uk
+}
+
+// StateConfig holds the tracker of open alarms, troubles and partition arm states.
+type StateConfig struct {
+	Enabled   bool                   `yaml:"enabled"`
+	Path      string                 `yaml:"path"`      // JSON file, relative to the config file directory
+	History   int                    `yaml:"history"`   // Openings and closings kept per partition [100]
+	Schedules map[int]ScheduleConfig `yaml:"schedules"` // Expected opening hours by account number
+}
+
+// ScheduleConfig holds the hours an object is expected to be disarmed.
+type ScheduleConfig struct {
+	Days  []string      `yaml:"days"`  // See ScheduleDays; empty means every day
+	Open  string        `yaml:"open"`  // "08:00"; disarming earlier is an early open
+	Close string        `yaml:"close"` // "20:00"; still disarmed later is a late close
+	Grace time.Duration `yaml:"grace"` // Allowed deviation from both times
+}
+
+// IncidentsConfig holds the alarm acknowledgement workflow.
+type IncidentsConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Path          string        `yaml:"path"`          // JSON file, relative to the config file directory
+	MaxEntries    int           `yaml:"maxentries"`    // Oldest acknowledged incidents are dropped beyond this
+	EscalateAfter time.Duration `yaml:"escalateafter"` // Unacknowledged incidents escalate after this (0 disables)
+	Resolutions   []string      `yaml:"resolutions"`   // Codes an operator picks when acknowledging
+}
+
 // defaultConfig returns a new Config with default values.
 func defaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Host: "0.0.0.0",
-			Port: "20005",
+			Host:            "0.0.0.0",
+			Port:            "20005",
+			DedupWindow:     30 * time.Second,
+			DedupMaxEntries: 10000,
 		},
 		Client: ClientConfig{
 			Host:             "10.32.1.49",
 			Port:             "20004",
 			ReconnectInitial: 1 * time.Second,
 			ReconnectMax:     60 * time.Second,
+			RetryAttempts:    3,
+			RetryBackoff:     500 * time.Millisecond,
 		},
 		Queue: QueueConfig{
-			BufferSize: 100,
+			BufferSize:   100,
+			DrainTimeout: 10 * time.Second,
+			Mode:         "fifo",
+			Dequeue:      "strict",
+			Weights: map[string]int{
+				"alarm":     8,
+				"trouble":   4,
+				"openclose": 2,
+				"test":      1,
+			},
+			PreserveAccountOrder: true,
 		},
 		Logging: LoggingConfig{
 			Filename:   "app.log",
@@ -92,6 +357,14 @@ func defaultConfig() *Config {
 			MaxAge:     28,
 			Compress:   true,
 			Level:      "INFO",
+
+			Format:      "text",
+			BufferLines: 1000,
+			Syslog: SyslogConfig{
+				Network:  "udp",
+				Facility: "local0",
+				AppName:  "cid-retranslator",
+			},
 		},
 		CIDRules: CIDRules{
 			RequiredPrefix: "5",
@@ -100,6 +373,9 @@ func defaultConfig() *Config {
 			AccNumOffset:   2100,
 			AccNumAdd:      2100,
 		},
+		Routing: RoutingConfig{
+			AckPolicy: "primary",
+		},
 		Monitoring: MonitoringConfig{
 			PPKTimeout: 15 * time.Minute,
 		},
@@ -108,22 +384,78 @@ func defaultConfig() *Config {
 			MinimizeToTray: false,
 			CloseToTray:    false,
 		},
+		API: APIConfig{
+			Enabled: false,
+			Listen:  "127.0.0.1:8080",
+		},
+		DeadLetter: DeadLetterConfig{
+			Enabled:    true,
+			Path:       "deadletters.json",
+			MaxEntries: 1000,
+		},
+		Audit: AuditConfig{
+			Enabled: true,
+			Path:    "audit.jsonl",
+		},
+		Notify: NotifyConfig{
+			Enabled:        false,
+			Interval:       10 * time.Second,
+			QueueThreshold: 0.8,
+		},
+		MQTT: MQTTConfig{
+			Enabled:     false,
+			Broker:      "tcp://127.0.0.1:1883",
+			TopicPrefix: "cid",
+			EventQoS:    1,
+			StateQoS:    1,
+			KeepAlive:   30 * time.Second,
+			QueueSize:   1000,
+		},
+		Events: EventsConfig{
+			Path:      "events.json",
+			Overrides: "events.local.json",
+			Language:  "uk",
+		},
+		State: StateConfig{
+			Enabled: true,
+			Path:    "state.json",
+			History: 100,
+		},
+		Incidents: IncidentsConfig{
+			Enabled:       true,
+			Path:          "incidents.json",
+			MaxEntries:    1000,
+			EscalateAfter: 60 * time.Second,
+			Resolutions:   []string{"false_alarm", "alarm_confirmed", "test", "technical", "other"},
+		},
 	}
 }
 
+// DefaultPath is the configuration file used when no other path is given.
+const DefaultPath = "config.yaml"
+
 // New loads the configuration from the default path ("config.yaml").
 // If the file does not exist, it creates a default one.
 // It panics if any other error occurs, as config is critical.
 func New() *Config {
-	path := "config.yaml"
-	cfg, err := load(path)
+	cfg, err := LoadOrCreate(DefaultPath)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+// LoadOrCreate loads the configuration from path. If the file does not exist,
+// it writes and returns the default configuration.
+func LoadOrCreate(path string) (*Config, error) {
+	cfg, err := Load(path)
 	if err == nil {
-		return cfg
+		return cfg, nil
 	}
 
 	if !os.IsNotExist(err) {
 		slog.Error("Failed to load configuration", "path", path, "error", err)
-		panic(err)
+		return nil, err
 	}
 
 	slog.Warn("Configuration file not found, creating a default one.", "path", path)
@@ -132,20 +464,20 @@ func New() *Config {
 	data, err := yaml.Marshal(defaultCfg)
 	if err != nil {
 		slog.Error("Failed to marshal default configuration", "error", err)
-		panic(err)
+		return nil, err
 	}
 
 	if err := os.WriteFile(path, data, 0644); err != nil {
 		slog.Error("Failed to write default configuration file", "path", path, "error", err)
-		panic(err)
+		return nil, err
 	}
 
 	slog.Info("Default configuration file created successfully.", "path", path)
-	return defaultCfg
+	return defaultCfg, nil
 }
 
-// load reads the configuration file from the given path and unmarshals it.
-func load(path string) (*Config, error) {
+// Load reads the configuration file from the given path and unmarshals it.
+func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -155,10 +487,26 @@ func load(path string) (*Config, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, err
 	}
+	if err := inheritPipelines(data, &cfg); err != nil {
+		return nil, err
+	}
 
 	return &cfg, nil
 }
 
+// Clone returns a deep copy of the configuration.
+func (c *Config) Clone() *Config {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		panic(err) // Config always marshals; failure means a programming error
+	}
+	var clone Config
+	if err := yaml.Unmarshal(data, &clone); err != nil {
+		panic(err)
+	}
+	return &clone
+}
+
 // Save writes the current configuration to the file at the given path.
 func (c *Config) Save(path string) error {
 	data, err := yaml.Marshal(c)
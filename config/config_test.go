@@ -1,8 +1,14 @@
 package config
 
 import (
+	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -53,3 +59,476 @@ func TestNew_LoadExistingConfig(t *testing.T) {
 	// Clean up the created file
 	os.Remove(configPath)
 }
+
+func TestDiff(t *testing.T) {
+	old := defaultConfig()
+	updated := old.Clone()
+	updated.Client.Host = "10.0.0.1"
+	updated.CIDRules.TestCodeMap["E601"] = "E602"
+	updated.Monitoring.PPKTimeout = 5 * time.Minute
+
+	changes := Diff(old, updated)
+	paths := make(map[string]FieldChange)
+	for _, ch := range changes {
+		paths[ch.Path] = ch
+	}
+
+	if len(changes) != 3 {
+		t.Errorf("expected 3 changes, got %d: %+v", len(changes), changes)
+	}
+	if ch := paths["client.host"]; ch.Old != old.Client.Host || ch.New != "10.0.0.1" {
+		t.Errorf("unexpected client.host change: %+v", ch)
+	}
+	if _, ok := paths["cidrules.testcodemap"]; !ok {
+		t.Error("expected cidrules.testcodemap change")
+	}
+	if ch := paths["monitoring.ppktimeout"]; ch.New != "5m0s" {
+		t.Errorf("unexpected monitoring.ppktimeout change: %+v", ch)
+	}
+
+	if changes := Diff(old, old.Clone()); len(changes) != 0 {
+		t.Errorf("expected no changes for a clone, got %+v", changes)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := defaultConfig().Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWatcher(path, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan *Config, 1)
+	go w.Run(ctx, func(cfg *Config) { changed <- cfg })
+
+	updated := defaultConfig()
+	updated.Client.Port = "30000"
+	// Гарантуємо інший розмір файлу, навіть якщо mtime не встиг змінитися
+	updated.Client.Host = "192.168.100.100"
+	if err := updated.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case cfg := <-changed:
+		if cfg.Client.Port != "30000" {
+			t.Errorf("expected reloaded port 30000, got %s", cfg.Client.Port)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watcher did not report the change")
+	}
+
+	// Зміна, зафіксована через Sync, не вважається зміною файлу
+	idle := NewWatcher(path, time.Hour)
+	updated.Client.Port = "30001"
+	updated.Save(path)
+	idle.Sync()
+	if idle.changed() {
+		t.Error("change recorded by Sync should not trigger a reload")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := defaultConfig().Validate(); err != nil {
+		t.Fatalf("default config should be valid, got %v", err)
+	}
+
+	cfg := defaultConfig()
+	cfg.Server.Port = "abc"
+	cfg.CIDRules.ValidLength = 0
+	cfg.Queue.BufferSize = -1
+	cfg.Client.ReconnectInitial = 10 * time.Second
+	cfg.Client.ReconnectMax = time.Second
+	cfg.Client.RetryAttempts = -1
+	cfg.Client.HeartbeatInterval = 30 * time.Second
+	cfg.Client.ReplayRate = -5
+	cfg.Logging.Level = "VERBOSE"
+	cfg.Logging.Syslog.Enabled = true
+	cfg.Logging.Syslog.Facility = "local9"
+	cfg.Queue.Weights["urgent"] = 3
+	cfg.DeadLetter.MaxEntries = 0
+
+	err := cfg.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+
+	want := []string{
+		"server.port",
+		"client.reconnectmax",
+		"client.retryattempts",
+		"client.heartbeatframe",
+		"client.replayrate",
+		"queue.buffersize",
+		"queue.weights.urgent",
+		"cidrules.validlength",
+		"logging.level",
+		"logging.syslog.address",
+		"logging.syslog.facility",
+		"deadletter.maxentries",
+	}
+	got := make([]string, len(verr.Errors))
+	for i, fe := range verr.Errors {
+		got[i] = fe.Path
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Validate() paths = %v, want %v", got, want)
+	}
+}
+
+func TestValidate_Retry(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		backoff  time.Duration
+		want     string
+	}{
+		{"default", 3, 500 * time.Millisecond, ""},
+		{"no retries", 1, 2 * time.Second, ""},
+		{"backoff above cap", 2, 3 * time.Second, "client.retrybackoff"},
+		{"delays exceed budget", 6, time.Second, "client.retryattempts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.Client.RetryAttempts = tt.attempts
+			cfg.Client.RetryBackoff = tt.backoff
+
+			var got string
+			var verr *ValidationError
+			if errors.As(cfg.Validate(), &verr) {
+				got = verr.Errors[0].Path
+			}
+			if got != tt.want {
+				t.Errorf("Validate() path = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := RetryBackoffTotal(5, time.Second); got != 7*time.Second {
+		t.Errorf("RetryBackoffTotal(5, 1s) = %s, want 7s", got)
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "server:\n    host: 0.0.0.0\n    port: \"20005\"\n    bogus: 1\nqueue:\n    buffersize: abc\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(path); err == nil {
+		t.Error("Load() should fail on a type error")
+	}
+
+	_, err := LoadStrict(path)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+	if len(verr.Errors) != 2 {
+		t.Errorf("expected unknown key and type errors, got %+v", verr.Errors)
+	}
+
+	os.WriteFile(path, []byte("server:\n    bogus: 1\n"), 0644)
+	if _, err := Load(path); err != nil {
+		t.Errorf("Load() should ignore unknown keys, got %v", err)
+	}
+	if _, err := LoadStrict(path); err == nil {
+		t.Error("LoadStrict() should reject unknown keys")
+	}
+}
+
+func TestValidate_Routing(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Routing = RoutingConfig{
+		AckPolicy: "all",
+		Upstreams: []UpstreamConfig{{Name: "fire", Host: "10.0.0.9", Port: "20004"}},
+		Routes: []RouteConfig{
+			{Name: "fire", Match: "category == fire", To: []string{PrimaryUpstream, "fire"}},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid routing rejected: %v", err)
+	}
+
+	cfg.Routing.AckPolicy = "most"
+	cfg.Routing.Upstreams = append(cfg.Routing.Upstreams, UpstreamConfig{Name: PrimaryUpstream, Host: "h", Port: "1"})
+	cfg.Routing.Routes = append(cfg.Routing.Routes,
+		RouteConfig{Match: "account in 5..1", To: []string{"police"}},
+		RouteConfig{Match: "zone == 1"},
+	)
+
+	var verr *ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatal("expected *ValidationError")
+	}
+	got := make([]string, len(verr.Errors))
+	for i, fe := range verr.Errors {
+		got[i] = fe.Path
+	}
+	want := []string{
+		"routing.ackpolicy",
+		"routing.upstreams[1].name",
+		"routing.routes[1].match",
+		"routing.routes[1].to",
+		"routing.routes[2].to",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Validate() paths = %v, want %v", got, want)
+	}
+}
+
+func TestValidate_APITokens(t *testing.T) {
+	hash := "sha256:" + strings.Repeat("ab", 32)
+	cfg := defaultConfig()
+	cfg.API.Enabled = true
+	cfg.API.Tokens = []APIToken{{Name: "noc", Hash: hash, Role: "viewer"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid tokens rejected: %v", err)
+	}
+
+	cfg.API.Tokens = append(cfg.API.Tokens,
+		APIToken{Name: "noc", Hash: "ab", Role: "root"},
+		APIToken{Hash: strings.ToUpper(hash), Role: "admin"},
+	)
+	var verr *ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatal("expected *ValidationError")
+	}
+	got := make([]string, len(verr.Errors))
+	for i, fe := range verr.Errors {
+		got[i] = fe.Path
+	}
+	want := []string{
+		"api.tokens[1].name",
+		"api.tokens[1].hash",
+		"api.tokens[1].role",
+		"api.tokens[2].name",
+		"api.tokens[2].hash",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Validate() paths = %v, want %v", got, want)
+	}
+
+	cfg.API.Tokens = nil
+	if !errors.As(cfg.Validate(), &verr) || verr.Errors[0].Path != "api.tokens" {
+		t.Errorf("enabled API without tokens accepted: %v", verr)
+	}
+}
+
+func TestValidate_Notify(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Notify.Enabled = true
+	cfg.Notify.Channels = []NotifyChannel{
+		{Name: "ops", Type: "smtp", Host: "mail.local", Port: "25", From: "relay@local", To: []string{"ops@local"}},
+		{Name: "hook", Type: "webhook", URL: "https://incidents.local/hook"},
+		{Name: "duty", Type: "telegram", BotToken: "123:abc", ChatID: "-100"},
+	}
+	cfg.Notify.Rules = []NotifyRule{{
+		Conditions: []string{"link.down", "queue.high"},
+		Channels:   []string{"ops", "hook"},
+		Delay:      30 * time.Second,
+		Escalate:   time.Hour,
+		EscalateTo: []string{"duty"},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid notify config rejected: %v", err)
+	}
+
+	cfg.Notify.QueueThreshold = 1.5
+	cfg.Notify.Channels = append(cfg.Notify.Channels,
+		NotifyChannel{Name: "ops", Type: "webhook", URL: "ftp://x"},
+		NotifyChannel{Name: "pager", Type: "sms"},
+	)
+	cfg.Notify.Rules = append(cfg.Notify.Rules, NotifyRule{
+		Conditions: []string{"power.lost"},
+		Channels:   []string{"nowhere"},
+		Escalate:   time.Minute,
+	})
+
+	var verr *ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatal("expected *ValidationError")
+	}
+	got := make([]string, len(verr.Errors))
+	for i, fe := range verr.Errors {
+		got[i] = fe.Path
+	}
+	want := []string{
+		"notify.queuethreshold",
+		"notify.channels[3].name",
+		"notify.channels[3].url",
+		"notify.channels[4].type",
+		"notify.rules[1].conditions",
+		"notify.rules[1].channels",
+		"notify.rules[1].escalateto",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Validate() paths = %v, want %v", got, want)
+	}
+}
+
+func TestValidate_Forward(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Forward.Destinations = []ForwardDestination{
+		{Name: "incidents", URL: "https://incidents.local/events", Match: "category in [fire, burglary]", BatchSize: 20},
+		{Name: "incidents", URL: "incidents.local", Match: "zone ==", RetryMax: -time.Second},
+	}
+
+	var verr *ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatal("expected *ValidationError")
+	}
+	got := make([]string, len(verr.Errors))
+	for i, fe := range verr.Errors {
+		got[i] = fe.Path
+	}
+	want := []string{
+		"forward.destinations[1].name",
+		"forward.destinations[1].url",
+		"forward.destinations[1].match",
+		"forward.destinations[1].timeout",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Validate() paths = %v, want %v", got, want)
+	}
+}
+
+func TestValidate_MQTT(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.MQTT.Enabled = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("default MQTT settings: %v", err)
+	}
+
+	cfg.MQTT.Broker = "broker.local:1883"
+	cfg.MQTT.TopicPrefix = "cid/#"
+	cfg.MQTT.EventQoS = 3
+	cfg.MQTT.KeepAlive = 0
+
+	var verr *ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatal("expected *ValidationError")
+	}
+	got := make([]string, len(verr.Errors))
+	for i, fe := range verr.Errors {
+		got[i] = fe.Path
+	}
+	want := []string{"mqtt.broker", "mqtt.topicprefix", "mqtt.eventqos", "mqtt.keepalive"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Validate() paths = %v, want %v", got, want)
+	}
+}
+
+func TestValidate_Bus(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Bus.Sinks = []BusSink{
+		{Name: "analytics", Type: "nats", URL: "nats://nats.local:4222", Subject: "cid.messages"},
+		{Name: "analytics", Type: "kafka", Subject: "cid.*", BufferSize: -1},
+	}
+
+	var verr *ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatal("expected *ValidationError")
+	}
+	got := make([]string, len(verr.Errors))
+	for i, fe := range verr.Errors {
+		got[i] = fe.Path
+	}
+	want := []string{
+		"bus.sinks[1].name",
+		"bus.sinks[1].type",
+		"bus.sinks[1].url",
+		"bus.sinks[1].subject",
+		"bus.sinks[1].buffersize",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Validate() paths = %v, want %v", got, want)
+	}
+}
+
+func TestValidate_Events(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Events.Overrides = cfg.Events.Path
+	cfg.Events.Language = "de"
+
+	var verr *ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatal("expected *ValidationError")
+	}
+	got := make([]string, len(verr.Errors))
+	for i, fe := range verr.Errors {
+		got[i] = fe.Path
+	}
+	want := []string{"events.overrides", "events.language"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Validate() paths = %v, want %v", got, want)
+	}
+}
+
+func TestValidate_State(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.State.Path = ""
+	cfg.State.History = 0
+	cfg.State.Schedules = map[int]ScheduleConfig{
+		1234: {Days: []string{"mon", "Fri"}, Open: "08:00", Close: "20:00", Grace: 15 * time.Minute},
+		2000: {Days: []string{"monday"}, Open: "8:00", Close: "7:00", Grace: -time.Minute},
+		3000: {Open: "8am", Close: "20:00"},
+	}
+
+	var verr *ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatal("expected *ValidationError")
+	}
+	got := make([]string, len(verr.Errors))
+	for i, fe := range verr.Errors {
+		got[i] = fe.Path
+	}
+	want := []string{"state.path", "state.history", "state.schedules.2000.days", "state.schedules.2000.close",
+		"state.schedules.2000.grace", "state.schedules.3000.open"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Validate() paths = %v, want %v", got, want)
+	}
+
+	days, _ := cfg.State.Schedules[1234].Weekdays()
+	open, close, err := cfg.State.Schedules[1234].Times()
+	if !slices.Equal(days, []time.Weekday{time.Monday, time.Friday}) || open != 8*time.Hour || close != 20*time.Hour || err != nil {
+		t.Errorf("schedule = %v %v %v %v", days, open, close, err)
+	}
+
+	cfg.State.Enabled = false
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() with state disabled = %v", err)
+	}
+}
+
+func TestValidate_Incidents(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Incidents.MaxEntries = 0
+	cfg.Incidents.EscalateAfter = -time.Second
+	cfg.Incidents.Resolutions = []string{"false_alarm", " ", "false_alarm"}
+
+	var verr *ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatal("expected *ValidationError")
+	}
+	got := make([]string, len(verr.Errors))
+	for i, fe := range verr.Errors {
+		got[i] = fe.Path
+	}
+	want := []string{"incidents.maxentries", "incidents.escalateafter", "incidents.resolutions[1]", "incidents.resolutions[2]"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Validate() paths = %v, want %v", got, want)
+	}
+
+	cfg.Incidents.Enabled = false
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() with incidents disabled = %v", err)
+	}
+}
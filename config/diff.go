@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldChange describes a single changed configuration field.
+type FieldChange struct {
+	Path string `json:"path"` // YAML path, e.g. "client.host"
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// Diff compares two configurations and returns the changed fields, addressed
+// by their YAML paths.
+func Diff(old, new *Config) []FieldChange {
+	var changes []FieldChange
+	diffValue("", reflect.ValueOf(*old), reflect.ValueOf(*new), &changes)
+	return changes
+}
+
+func diffValue(path string, a, b reflect.Value, changes *[]FieldChange) {
+	switch a.Kind() {
+	case reflect.Struct:
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := yamlName(field)
+			if name == "" {
+				continue
+			}
+			if field.Tag.Get("secret") == "true" {
+				diffSecret(joinPath(path, name), a.Field(i), b.Field(i), changes)
+				continue
+			}
+			diffValue(joinPath(path, name), a.Field(i), b.Field(i), changes)
+		}
+
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			appendChange(path, redactedCopy(a), redactedCopy(b), changes)
+			return
+		}
+		for i := 0; i < a.Len(); i++ {
+			diffValue(fmt.Sprintf("%s[%d]", path, i), a.Index(i), b.Index(i), changes)
+		}
+
+	default:
+		appendChange(path, a, b, changes)
+	}
+}
+
+func appendChange(path string, a, b reflect.Value, changes *[]FieldChange) {
+	oldStr, newStr := formatValue(a), formatValue(b)
+	if oldStr != newStr {
+		*changes = append(*changes, FieldChange{Path: path, Old: oldStr, New: newStr})
+	}
+}
+
+// diffSecret reports a changed secret without revealing either value.
+func diffSecret(path string, a, b reflect.Value, changes *[]FieldChange) {
+	if formatValue(a) == formatValue(b) {
+		return
+	}
+	mask := func(v reflect.Value) string {
+		if formatValue(v) == "" {
+			return ""
+		}
+		return redactedValue
+	}
+	*changes = append(*changes, FieldChange{Path: path, Old: mask(a), New: mask(b)})
+}
+
+// redactedCopy returns a copy of a slice with secret fields masked, so that
+// adding or removing an element does not reveal its secrets.
+func redactedCopy(v reflect.Value) reflect.Value {
+	c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(c, v)
+	redact(c)
+	return c
+}
+
+// formatValue renders a value for comparison; nil and empty maps/slices are equal.
+func formatValue(v reflect.Value) string {
+	if (v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.Len() == 0 {
+		return ""
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// yamlName returns the YAML key of a struct field, or "" if it is not serialized.
+func yamlName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if tag == "-" {
+		return ""
+	}
+	if tag == "" {
+		return strings.ToLower(field.Name)
+	}
+	return tag
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// ReloadReport summarizes how a configuration change was applied to a running application.
+type ReloadReport struct {
+	Applied         []string `json:"applied"`          // Fields applied in place
+	RestartRequired []string `json:"restartRequired"`  // Fields that take effect after restart
+	Errors          []string `json:"errors,omitempty"` // Fields that failed to apply
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPipelineName names the implicit pipeline built from the top-level
+// server, client, queue and cidrules sections.
+const DefaultPipelineName = "default"
+
+var pipelineNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// PipelineConfig describes one independent relay: a listening server, its
+// queue and the upstream client, with their own CID rules.
+//
+// Sections omitted from a pipeline in the file are inherited from the
+// top-level server, client, queue, cidrules and routing sections.
+type PipelineConfig struct {
+	Name     string        `yaml:"name"`
+	Server   ServerConfig  `yaml:"server"`
+	Client   ClientConfig  `yaml:"client"`
+	Queue    QueueConfig   `yaml:"queue"`
+	CIDRules CIDRules      `yaml:"cidrules"`
+	Routing  RoutingConfig `yaml:"routing"`
+}
+
+// EffectivePipelines returns the configured pipelines, or a single pipeline
+// named DefaultPipelineName built from the top-level sections if none are configured.
+func (c *Config) EffectivePipelines() []PipelineConfig {
+	if len(c.Pipelines) > 0 {
+		return c.Pipelines
+	}
+	return []PipelineConfig{c.basePipeline()}
+}
+
+// basePipeline builds a pipeline from the top-level sections.
+func (c *Config) basePipeline() PipelineConfig {
+	return PipelineConfig{
+		Name:     DefaultPipelineName,
+		Server:   c.Server,
+		Client:   c.Client,
+		Queue:    c.Queue,
+		CIDRules: c.CIDRules,
+		Routing:  c.Routing,
+	}
+}
+
+// DiffPipeline compares two pipeline configurations and returns the changed
+// fields, addressed by their YAML paths within the pipeline.
+func DiffPipeline(old, new PipelineConfig) []FieldChange {
+	var changes []FieldChange
+	diffValue("", reflect.ValueOf(old), reflect.ValueOf(new), &changes)
+	return changes
+}
+
+// inheritPipelines re-decodes every pipeline from the raw file on top of the
+// top-level sections, so keys missing from a pipeline keep the shared values.
+func inheritPipelines(data []byte, cfg *Config) error {
+	if len(cfg.Pipelines) == 0 {
+		return nil
+	}
+
+	var raw struct {
+		Pipelines []yaml.Node `yaml:"pipelines"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw.Pipelines) != len(cfg.Pipelines) {
+		return fmt.Errorf("pipelines: unexpected layout")
+	}
+
+	for i := range raw.Pipelines {
+		base, err := clonePipeline(cfg.basePipeline())
+		if err != nil {
+			return err
+		}
+		base.Name = ""
+		if err := raw.Pipelines[i].Decode(&base); err != nil {
+			return err
+		}
+
+		var fileOnly PipelineConfig
+		if err := raw.Pipelines[i].Decode(&fileOnly); err != nil {
+			return err
+		}
+		replaceMaps(reflect.ValueOf(&base).Elem(), reflect.ValueOf(&fileOnly).Elem())
+		cfg.Pipelines[i] = base
+	}
+	return nil
+}
+
+func clonePipeline(p PipelineConfig) (PipelineConfig, error) {
+	var clone PipelineConfig
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return clone, err
+	}
+	err = yaml.Unmarshal(data, &clone)
+	return clone, err
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPipelines_Inheritance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `client:
+    host: 10.0.0.1
+cidrules:
+    accnumoffset: 1000
+pipelines:
+    - name: north
+      server:
+          port: "20010"
+    - name: south
+      server:
+          port: "20011"
+      client:
+          host: 10.0.0.2
+          port: "20020"
+      cidrules:
+          accnumadd: 5000
+          testcodemap:
+              E601: E602
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Sources{Path: path}.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	pipelines := cfg.EffectivePipelines()
+	if len(pipelines) != 2 {
+		t.Fatalf("expected 2 pipelines, got %d", len(pipelines))
+	}
+	north, south := pipelines[0], pipelines[1]
+
+	if north.Server.Port != "20010" || north.Server.Host != "0.0.0.0" {
+		t.Errorf("north server = %+v", north.Server)
+	}
+	if north.Client.Host != "10.0.0.1" || north.Client.Port != "20004" {
+		t.Errorf("north should inherit the top-level client, got %+v", north.Client)
+	}
+	if north.CIDRules.AccNumOffset != 1000 || north.CIDRules.TestCodeMap["E603"] != "E602" {
+		t.Errorf("north should inherit the top-level cidrules, got %+v", north.CIDRules)
+	}
+
+	if south.Client.Host != "10.0.0.2" || south.Client.ReconnectMax != cfg.Client.ReconnectMax {
+		t.Errorf("south client = %+v", south.Client)
+	}
+	if south.CIDRules.AccNumAdd != 5000 || south.CIDRules.AccNumOffset != 1000 {
+		t.Errorf("south cidrules = %+v", south.CIDRules)
+	}
+	if len(south.CIDRules.TestCodeMap) != 1 || south.CIDRules.TestCodeMap["E601"] != "E602" {
+		t.Errorf("south testcodemap should replace the inherited map, got %v", south.CIDRules.TestCodeMap)
+	}
+}
+
+func TestPipelines_Default(t *testing.T) {
+	cfg := defaultConfig()
+	pipelines := cfg.EffectivePipelines()
+	if len(pipelines) != 1 || pipelines[0].Name != DefaultPipelineName {
+		t.Fatalf("expected one default pipeline, got %+v", pipelines)
+	}
+	if pipelines[0].Server != cfg.Server {
+		t.Errorf("default pipeline should use the top-level server section")
+	}
+}
+
+func TestPipelines_Validate(t *testing.T) {
+	cfg := defaultConfig()
+	base := cfg.basePipeline()
+	cfg.Pipelines = []PipelineConfig{base, base, base}
+	cfg.Pipelines[0].Name = "a"
+	cfg.Pipelines[1].Name = "a"
+	cfg.Pipelines[1].Server.Port = "20006"
+	cfg.Pipelines[2].Name = "c d"
+	cfg.Pipelines[2].Client.Port = "0"
+
+	var verr *ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatal("expected *ValidationError")
+	}
+
+	got := make(map[string]bool)
+	for _, fe := range verr.Errors {
+		got[fe.Path] = true
+	}
+	for _, want := range []string{"pipelines[1].name", "pipelines[2].name", "pipelines[2].server.port", "pipelines[2].client.port"} {
+		if !got[want] {
+			t.Errorf("missing error for %s, got %+v", want, verr.Errors)
+		}
+	}
+}
+
+func TestDiffPipeline(t *testing.T) {
+	old := defaultConfig().basePipeline()
+	updated := old
+	updated.Client.Port = "1"
+
+	changes := DiffPipeline(old, updated)
+	if len(changes) != 1 || changes[0].Path != "client.port" {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+}
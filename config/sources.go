@@ -0,0 +1,372 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of environment variables that override configuration
+// fields. The rest of the name is the YAML path in upper case with dots replaced
+// by underscores, e.g. CIDR_SERVER_PORT or CIDR_QUEUE_WEIGHTS.
+const EnvPrefix = "CIDR_"
+
+// redactedValue replaces secret values in dumps of the configuration.
+const redactedValue = "***"
+
+// Sources describes where the effective configuration comes from. Layers are
+// applied in order of increasing precedence:
+//
+//  1. built-in defaults
+//  2. the YAML file at Path (missing keys keep their defaults)
+//  3. CIDR_* environment variables from Environ
+//  4. command-line overrides ("server.port=20005") from Overrides
+//
+// Values in layers 3 and 4 are parsed as YAML, so durations look like "30s"
+// and maps use flow syntax: "{alarm: 8, test: 1}".
+type Sources struct {
+	Path      string
+	Environ   []string
+	Overrides []string
+	Strict    bool // Reject unknown keys in the file and require it to exist
+}
+
+// Load builds the effective configuration. If the file does not exist, a
+// default one is written first; in strict mode a missing file is an error.
+func (s Sources) Load() (*Config, error) {
+	cfg, err := s.loadFile()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyLayers(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyLayers applies the environment variables and command-line overrides.
+func (s Sources) applyLayers(cfg *Config) error {
+	if err := cfg.applyEnv(s.Environ); err != nil {
+		return err
+	}
+	for _, override := range s.Overrides {
+		path, value, ok := strings.Cut(override, "=")
+		if !ok {
+			return fmt.Errorf("invalid override %q, want path=value", override)
+		}
+		if err := cfg.Set(strings.TrimSpace(path), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveFields writes the fields at the given YAML paths, with their values
+// taken from cfg, into the configuration file. Other keys and comments in the
+// file are kept, and environment variables and command-line overrides are not
+// written. The effective configuration with the change is validated first; if
+// it is invalid, the file is left untouched.
+func (s Sources) SaveFields(cfg *Config, paths []string) error {
+	data, err := os.ReadFile(s.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: configuration is not a YAML mapping", s.Path)
+	}
+
+	for _, path := range paths {
+		field, err := lookup(reflect.ValueOf(cfg).Elem(), path)
+		if err != nil {
+			return err
+		}
+		value := &yaml.Node{}
+		if err := value.Encode(field.Interface()); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		setNode(root, strings.Split(path, "."), value)
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	effective, err := s.parseFile(out)
+	if err != nil {
+		return err
+	}
+	if err := s.applyLayers(effective); err != nil {
+		return err
+	}
+	if err := effective.Validate(); err != nil {
+		return err
+	}
+	return os.WriteFile(s.Path, out, 0644)
+}
+
+// setNode stores value at the key path of a YAML mapping, adding missing keys.
+func setNode(mapping *yaml.Node, path []string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			value.LineComment = mapping.Content[i+1].LineComment
+			mapping.Content[i+1] = value
+			return
+		}
+		if mapping.Content[i+1].Kind != yaml.MappingNode {
+			mapping.Content[i+1] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		setNode(mapping.Content[i+1], path[1:], value)
+		return
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}
+	if len(path) == 1 {
+		mapping.Content = append(mapping.Content, key, value)
+		return
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	mapping.Content = append(mapping.Content, key, child)
+	setNode(child, path[1:], value)
+}
+
+// Dir returns the directory relative paths in the configuration are resolved against.
+func (s Sources) Dir() string {
+	path := s.Path
+	if path == "" {
+		path = DefaultPath
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "."
+	}
+	return filepath.Dir(abs)
+}
+
+// Resolve makes a path from the configuration absolute, relative to the
+// directory of the configuration file.
+func (s Sources) Resolve(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(s.Dir(), path)
+}
+
+func (s Sources) loadFile() (*Config, error) {
+	if s.Path == "" {
+		return defaultConfig(), nil
+	}
+
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) && s.Strict {
+		return nil, fmt.Errorf("file not found: %w", os.ErrNotExist)
+	}
+	if os.IsNotExist(err) {
+		if _, err := LoadOrCreate(s.Path); err != nil {
+			return nil, err
+		}
+		return defaultConfig(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.parseFile(data)
+}
+
+// parseFile builds the file layer from the defaults and the file contents.
+func (s Sources) parseFile(data []byte) (*Config, error) {
+	cfg := defaultConfig()
+	if err := decode(data, cfg, s.Strict); err != nil {
+		return nil, err
+	}
+
+	// Maps present in the file replace the defaults instead of being merged with them.
+	var fileOnly Config
+	if err := decode(data, &fileOnly, false); err != nil {
+		return nil, err
+	}
+	replaceMaps(reflect.ValueOf(cfg).Elem(), reflect.ValueOf(&fileOnly).Elem())
+
+	if err := inheritPipelines(data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// decode unmarshals YAML into cfg. In strict mode unknown keys are rejected
+// and decoding problems are reported as a *ValidationError.
+func decode(data []byte, cfg *Config, strict bool) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(strict)
+
+	err := dec.Decode(cfg)
+	if err == nil || errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	var typeErr *yaml.TypeError
+	if strict && errors.As(err, &typeErr) {
+		v := &ValidationError{}
+		for _, msg := range typeErr.Errors {
+			v.add("yaml", "%s", msg)
+		}
+		return v
+	}
+	return err
+}
+
+func replaceMaps(dst, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Field(i)
+		switch field.Kind() {
+		case reflect.Struct:
+			replaceMaps(field, src.Field(i))
+		case reflect.Map:
+			if !src.Field(i).IsNil() {
+				field.Set(src.Field(i))
+			}
+		}
+	}
+}
+
+// applyEnv applies CIDR_* variables from environ ("KEY=value" pairs).
+// Unknown variables with the prefix are logged and ignored.
+func (c *Config) applyEnv(environ []string) error {
+	paths := make(map[string]string)
+	for _, path := range Paths() {
+		paths[EnvName(path)] = path
+	}
+
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		path, known := paths[name]
+		if !known {
+			slog.Warn("Ignoring unknown configuration variable", "name", name)
+			continue
+		}
+		if err := c.Set(path, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// EnvName returns the environment variable that overrides the field at path.
+func EnvName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// Paths lists the YAML paths of every configurable field, in declaration order.
+func Paths() []string {
+	var paths []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if yamlName(field) == "" {
+				continue
+			}
+			path := joinPath(prefix, yamlName(field))
+			if field.Type.Kind() == reflect.Struct {
+				walk(field.Type, path)
+				continue
+			}
+			paths = append(paths, path)
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "")
+	return paths
+}
+
+// Set parses value as YAML and stores it in the field at the given YAML path.
+func (c *Config) Set(path, value string) error {
+	field, err := lookup(reflect.ValueOf(c).Elem(), path)
+	if err != nil {
+		return err
+	}
+
+	parsed := reflect.New(field.Type())
+	if err := yaml.Unmarshal([]byte(value), parsed.Interface()); err != nil {
+		return fmt.Errorf("%s: invalid value %q: %w", path, value, err)
+	}
+	field.Set(parsed.Elem())
+	return nil
+}
+
+// lookup finds the struct field addressed by a dotted YAML path.
+func lookup(v reflect.Value, path string) (reflect.Value, error) {
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("unknown configuration field %q", path)
+		}
+		idx := -1
+		for i := 0; i < v.NumField(); i++ {
+			if field := yamlName(v.Type().Field(i)); field != "" && strings.EqualFold(field, name) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return reflect.Value{}, fmt.Errorf("unknown configuration field %q", path)
+		}
+		v = v.Field(idx)
+	}
+	return v, nil
+}
+
+// Redacted returns a copy of the configuration with fields tagged
+// `secret:"true"` masked, suitable for logging and dumps.
+func (c *Config) Redacted() *Config {
+	clone := c.Clone()
+	redact(reflect.ValueOf(clone).Elem())
+	return clone
+}
+
+func redact(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if field.Tag.Get("secret") == "true" && v.Field(i).Kind() == reflect.String {
+				if v.Field(i).String() != "" {
+					v.Field(i).SetString(redactedValue)
+				}
+				continue
+			}
+			redact(v.Field(i))
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			redact(v.Index(i))
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			redact(v.Elem())
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSources_Precedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "server:\n    port: \"30005\"\nclient:\n    host: 10.0.0.1\n    port: \"30004\"\nqueue:\n    weights:\n        alarm: 3\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := Sources{
+		Path: path,
+		Environ: []string{
+			"PATH=/usr/bin",
+			"CIDR_CLIENT_PORT=40004",
+			"CIDR_CLIENT_RECONNECTMAX=2m",
+			"CIDR_NOT_A_FIELD=1",
+		},
+		Overrides: []string{"client.port=50004"},
+	}
+	cfg, err := sources.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Server.Port != "30005" {
+		t.Errorf("file should override defaults, server.port = %s", cfg.Server.Port)
+	}
+	if cfg.Server.Host != "0.0.0.0" || cfg.Logging.MaxSize != 10 {
+		t.Errorf("keys missing from the file should keep defaults, got host %q maxsize %d", cfg.Server.Host, cfg.Logging.MaxSize)
+	}
+	if cfg.Client.Host != "10.0.0.1" {
+		t.Errorf("client.host = %s, want value from file", cfg.Client.Host)
+	}
+	if cfg.Client.ReconnectMax != 2*time.Minute {
+		t.Errorf("environment should override file, reconnectmax = %s", cfg.Client.ReconnectMax)
+	}
+	if cfg.Client.Port != "50004" {
+		t.Errorf("-set should override environment, client.port = %s", cfg.Client.Port)
+	}
+	if want := map[string]int{"alarm": 3}; !reflect.DeepEqual(cfg.Queue.Weights, want) {
+		t.Errorf("maps from the file should replace defaults, weights = %v", cfg.Queue.Weights)
+	}
+}
+
+func TestSources_Errors(t *testing.T) {
+	tests := []Sources{
+		{Overrides: []string{"server.port"}},
+		{Overrides: []string{"server.nosuchfield=1"}},
+		{Overrides: []string{"queue.buffersize=abc"}},
+		{Environ: []string{"CIDR_QUEUE_BUFFERSIZE=abc"}},
+	}
+	for _, sources := range tests {
+		if _, err := sources.Load(); err == nil {
+			t.Errorf("Load(%+v) should fail", sources)
+		}
+	}
+}
+
+func TestSources_CreatesMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	cfg, err := Sources{Path: path}.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("default config file was not created: %v", err)
+	}
+	if cfg.Server.Port != defaultConfig().Server.Port {
+		t.Errorf("expected defaults, got %+v", cfg.Server)
+	}
+
+	dir := filepath.Dir(path)
+	if got := (Sources{Path: path}).Resolve("logs/app.log"); got != filepath.Join(dir, "logs", "app.log") {
+		t.Errorf("Resolve() = %s, want path under %s", got, dir)
+	}
+}
+
+func TestSources_StrictMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if _, err := (Sources{Path: path, Strict: true}).Load(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() error = %v, want file not found", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("strict mode created the config file: %v", err)
+	}
+}
+
+func TestSources_SaveFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "# relay settings\nserver:\n    port: \"30005\" # panels\nclient:\n    host: 10.0.0.1\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sources := Sources{
+		Path:      path,
+		Environ:   []string{"CIDR_CLIENT_PORT=40004"},
+		Overrides: []string{"logging.level=DEBUG"},
+	}
+	cfg, err := sources.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Server.Port = "30006"
+	cfg.Client.ReconnectMax = 2 * time.Minute
+	if err := sources.SaveFields(cfg, []string{"server.port", "client.reconnectmax"}); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# relay settings\nserver:\n    port: \"30006\" # panels\nclient:\n    host: 10.0.0.1\n    reconnectmax: 2m0s\n"
+	if string(saved) != want {
+		t.Errorf("file after SaveFields:\n%s\nwant:\n%s", saved, want)
+	}
+
+	// An invalid value leaves the file untouched
+	cfg.Server.Port = ""
+	if err := sources.SaveFields(cfg, []string{"server.port"}); err == nil {
+		t.Error("SaveFields accepted an invalid configuration")
+	}
+	if got, _ := os.ReadFile(path); string(got) != want {
+		t.Errorf("file changed by a rejected save:\n%s", got)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	paths := Paths()
+	for _, want := range []string{"server.port", "queue.weights", "api.listen"} {
+		if !slices.Contains(paths, want) {
+			t.Errorf("Paths() is missing %s", want)
+		}
+	}
+	if got := EnvName("server.dedupwindow"); got != "CIDR_SERVER_DEDUPWINDOW" {
+		t.Errorf("EnvName() = %s", got)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	type credentials struct {
+		User     string `yaml:"user"`
+		Password string `yaml:"password" secret:"true"`
+	}
+	v := struct {
+		Primary credentials
+		Extra   []credentials
+	}{
+		Primary: credentials{User: "admin", Password: "hunter2"},
+		Extra:   []credentials{{User: "ops", Password: "s3cret"}, {User: "guest"}},
+	}
+
+	redact(reflect.ValueOf(&v).Elem())
+
+	if v.Primary.User != "admin" || v.Primary.Password != redactedValue {
+		t.Errorf("unexpected redaction: %+v", v.Primary)
+	}
+	if v.Extra[0].Password != redactedValue || v.Extra[1].Password != "" {
+		t.Errorf("unexpected redaction in slice: %+v", v.Extra)
+	}
+}
+
+func TestDiffSecret(t *testing.T) {
+	type credentials struct {
+		User     string `yaml:"user"`
+		Password string `yaml:"password" secret:"true"`
+	}
+	old := credentials{User: "admin"}
+	updated := credentials{User: "admin", Password: "hunter2"}
+
+	var changes []FieldChange
+	diffValue("", reflect.ValueOf(old), reflect.ValueOf(updated), &changes)
+	if len(changes) != 1 || changes[0] != (FieldChange{Path: "password", Old: "", New: redactedValue}) {
+		t.Errorf("changes = %+v, want the password change masked", changes)
+	}
+}
+
+func TestDiff_APITokens(t *testing.T) {
+	old := defaultConfig()
+	updated := defaultConfig()
+	updated.API.Tokens = []APIToken{{Name: "noc", Hash: "sha256:00ff", Role: "viewer"}}
+
+	changes := Diff(old, updated)
+	if len(changes) != 1 || changes[0].Path != "api.tokens" {
+		t.Fatalf("changes = %+v, want api.tokens", changes)
+	}
+	if want := "[{noc *** viewer}]"; changes[0].New != want {
+		t.Errorf("new = %q, want %q", changes[0].New, want)
+	}
+	if updated.API.Tokens[0].Hash != "sha256:00ff" {
+		t.Error("Diff() modified the configuration")
+	}
+}
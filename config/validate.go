@@ -0,0 +1,653 @@
+package config
+
+import (
+	"cid_retranslator_walk/logging"
+	"cid_retranslator_walk/match"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueueClassNames lists the priority classes accepted in queue weights and capacities.
+var QueueClassNames = []string{"alarm", "trouble", "openclose", "test"}
+
+// APIRoles lists the API roles from least to most privileged; each role
+// includes the permissions of the ones before it.
+var APIRoles = []string{"viewer", "operator", "admin"}
+
+// BusSinkTypes lists the supported message bus sinks.
+var BusSinkTypes = []string{"nats"}
+
+// EventLanguages lists the languages of the event dictionary; uk is the shipped one.
+var EventLanguages = []string{"uk", "en", "ru"}
+
+// ScheduleDays lists the weekday names accepted in schedules, in time.Weekday order.
+var ScheduleDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// NotifyConditions lists the relay health conditions that notify rules can match.
+var NotifyConditions = []string{"link.down", "queue.high", "device.silent", "incident.escalated"}
+
+var (
+	logLevels   = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+	mqttSchemes = []string{"tcp", "ssl", "tls", "mqtt", "mqtts", "ws", "wss"}
+	eventCodeRe = regexp.MustCompile(`^[ER]\d{3}$`)
+	tokenHashRe = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+)
+
+// minMessageLength is the shortest frame the CID parser can handle.
+const minMessageLength = 20
+
+// FieldError describes a problem with a single configuration field.
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError collects every problem found in a configuration.
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// add records a problem for the given field path.
+func (e *ValidationError) add(path, format string, args ...any) {
+	e.Errors = append(e.Errors, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks the configuration and returns a *ValidationError listing all
+// problems, or nil if the configuration is usable.
+func (c *Config) Validate() error {
+	v := &ValidationError{}
+
+	if len(c.Pipelines) == 0 {
+		c.basePipeline().validate(v, "")
+	} else {
+		c.validatePipelines(v)
+	}
+
+	if c.Logging.Level != "" && !containsFold(logLevels, c.Logging.Level) {
+		v.add("logging.level", "unknown level %q, want one of %s", c.Logging.Level, strings.Join(logLevels, ", "))
+	}
+	if c.Logging.MaxSize <= 0 {
+		v.add("logging.maxsize", "must be positive")
+	}
+	if c.Logging.MaxBackups < 0 {
+		v.add("logging.maxbackups", "must not be negative")
+	}
+	if c.Logging.MaxAge < 0 {
+		v.add("logging.maxage", "must not be negative")
+	}
+	switch strings.ToLower(c.Logging.Format) {
+	case "", logging.FormatText, logging.FormatJSON:
+	default:
+		v.add("logging.format", "unknown format %q, want text or json", c.Logging.Format)
+	}
+	if c.Logging.BufferLines <= 0 {
+		v.add("logging.bufferlines", "must be positive")
+	}
+	if s := c.Logging.Syslog; s.Enabled {
+		switch strings.ToLower(s.Network) {
+		case "udp", "tcp":
+		default:
+			v.add("logging.syslog.network", "unknown network %q, want udp or tcp", s.Network)
+		}
+		if _, port, err := net.SplitHostPort(s.Address); err != nil {
+			v.add("logging.syslog.address", "invalid address %q: %v", s.Address, err)
+		} else {
+			validatePort(v, "logging.syslog.address", port)
+		}
+		if _, err := logging.ParseFacility(s.Facility); err != nil {
+			v.add("logging.syslog.facility", "%v", err)
+		}
+	}
+
+	if c.Monitoring.PPKTimeout <= 0 {
+		v.add("monitoring.ppktimeout", "must be positive")
+	}
+
+	if c.API.Enabled {
+		if _, port, err := net.SplitHostPort(c.API.Listen); err != nil {
+			v.add("api.listen", "invalid address %q: %v", c.API.Listen, err)
+		} else {
+			validatePort(v, "api.listen", port)
+		}
+		if len(c.API.Tokens) == 0 {
+			v.add("api.tokens", "at least one token is required when the API is enabled")
+		}
+	}
+	names := make(map[string]bool)
+	for i, t := range c.API.Tokens {
+		prefix := fmt.Sprintf("api.tokens[%d].", i)
+		switch {
+		case t.Name == "":
+			v.add(prefix+"name", "must not be empty")
+		case names[t.Name]:
+			v.add(prefix+"name", "duplicate token name %q", t.Name)
+		}
+		names[t.Name] = true
+		if !tokenHashRe.MatchString(t.Hash) {
+			v.add(prefix+"hash", "must be sha256:<64 lowercase hex digits>")
+		}
+		if !slices.Contains(APIRoles, t.Role) {
+			v.add(prefix+"role", "unknown role %q, want one of %s", t.Role, strings.Join(APIRoles, ", "))
+		}
+	}
+
+	if c.DeadLetter.Enabled {
+		if c.DeadLetter.Path == "" {
+			v.add("deadletter.path", "must not be empty")
+		}
+		if c.DeadLetter.MaxEntries <= 0 {
+			v.add("deadletter.maxentries", "must be positive")
+		}
+	}
+
+	if c.Audit.Enabled && c.Audit.Path == "" {
+		v.add("audit.path", "must not be empty")
+	}
+
+	if c.Notify.Enabled {
+		c.Notify.validate(v)
+	}
+	c.Forward.validate(v)
+	if c.MQTT.Enabled {
+		c.MQTT.validate(v)
+	}
+	c.Bus.validate(v)
+
+	if c.Events.Path == "" {
+		v.add("events.path", "must not be empty")
+	}
+	if c.Events.Overrides == "" {
+		v.add("events.overrides", "must not be empty")
+	} else if c.Events.Overrides == c.Events.Path {
+		v.add("events.overrides", "must differ from events.path")
+	}
+	if !slices.Contains(EventLanguages, c.Events.Language) {
+		v.add("events.language", "unknown language %q, want one of %s", c.Events.Language, strings.Join(EventLanguages, ", "))
+	}
+
+	if c.State.Enabled {
+		c.State.validate(v)
+	}
+	if c.Incidents.Enabled {
+		c.Incidents.validate(v)
+	}
+
+	if len(v.Errors) == 0 {
+		return nil
+	}
+	return v
+}
+
+// validatePipelines checks every configured pipeline and that names and
+// listen addresses are unique.
+func (c *Config) validatePipelines(v *ValidationError) {
+	names := make(map[string]bool)
+	listens := make(map[string]string)
+	for i, p := range c.Pipelines {
+		prefix := fmt.Sprintf("pipelines[%d].", i)
+		switch {
+		case !pipelineNameRe.MatchString(p.Name):
+			v.add(prefix+"name", "must be non-empty and contain only letters, digits, '-' and '_'")
+		case names[p.Name]:
+			v.add(prefix+"name", "duplicate pipeline name %q", p.Name)
+		}
+		names[p.Name] = true
+
+		listen := net.JoinHostPort(p.Server.Host, p.Server.Port)
+		if other, ok := listens[listen]; ok {
+			v.add(prefix+"server.port", "address %s is already used by pipeline %q", listen, other)
+		}
+		listens[listen] = p.Name
+
+		p.validate(v, prefix)
+	}
+}
+
+// validate checks the sections of a pipeline; prefix is prepended to field paths.
+func (p PipelineConfig) validate(v *ValidationError, prefix string) {
+	validateHost(v, prefix+"server.host", p.Server.Host, true)
+	validatePort(v, prefix+"server.port", p.Server.Port)
+	if p.Server.DedupWindow < 0 {
+		v.add(prefix+"server.dedupwindow", "must not be negative")
+	}
+	if p.Server.DedupWindow > 0 && p.Server.DedupMaxEntries <= 0 {
+		v.add(prefix+"server.dedupmaxentries", "must be positive when dedupwindow is set")
+	}
+
+	validateHost(v, prefix+"client.host", p.Client.Host, false)
+	validatePort(v, prefix+"client.port", p.Client.Port)
+	if p.Client.ReconnectInitial <= 0 {
+		v.add(prefix+"client.reconnectinitial", "must be positive")
+	}
+	if p.Client.ReconnectMax < p.Client.ReconnectInitial {
+		v.add(prefix+"client.reconnectmax", "must not be less than reconnectinitial (%s)", p.Client.ReconnectInitial)
+	}
+	if p.Client.RetryAttempts < 0 {
+		v.add(prefix+"client.retryattempts", "must not be negative")
+	}
+	if p.Client.RetryBackoff < 0 {
+		v.add(prefix+"client.retrybackoff", "must not be negative")
+	} else if p.Client.RetryBackoff > MaxRetryBackoff {
+		v.add(prefix+"client.retrybackoff", "must not exceed %s", MaxRetryBackoff)
+	} else if total := RetryBackoffTotal(p.Client.RetryAttempts, p.Client.RetryBackoff); total >= RetryBudget {
+		v.add(prefix+"client.retryattempts", "%d attempts wait %s between retries, which does not fit the %s retry budget",
+			p.Client.RetryAttempts, total, RetryBudget)
+	}
+	if p.Client.HeartbeatInterval < 0 {
+		v.add(prefix+"client.heartbeatinterval", "must not be negative")
+	}
+	if p.Client.HeartbeatInterval > 0 && p.Client.HeartbeatFrame == "" {
+		v.add(prefix+"client.heartbeatframe", "must not be empty when heartbeatinterval is set")
+	}
+	if p.Client.ReplayRate < 0 {
+		v.add(prefix+"client.replayrate", "must not be negative")
+	}
+
+	p.Queue.validate(v, prefix+"queue.")
+	p.CIDRules.validate(v, prefix+"cidrules.")
+	p.Routing.validate(v, prefix+"routing.")
+}
+
+// PrimaryUpstream is the route destination name of the pipeline client.
+const PrimaryUpstream = "primary"
+
+func (r *RoutingConfig) validate(v *ValidationError, prefix string) {
+	switch strings.ToLower(r.AckPolicy) {
+	case "", "primary", "any", "all":
+	default:
+		v.add(prefix+"ackpolicy", "unknown policy %q, want primary, any or all", r.AckPolicy)
+	}
+
+	names := map[string]bool{PrimaryUpstream: true}
+	for i, u := range r.Upstreams {
+		path := fmt.Sprintf("%supstreams[%d].", prefix, i)
+		switch {
+		case !pipelineNameRe.MatchString(u.Name):
+			v.add(path+"name", "must be non-empty and contain only letters, digits, '-' and '_'")
+		case names[u.Name]:
+			v.add(path+"name", "duplicate upstream name %q", u.Name)
+		}
+		names[u.Name] = true
+		validateHost(v, path+"host", u.Host, false)
+		validatePort(v, path+"port", u.Port)
+		if u.BufferSize < 0 {
+			v.add(path+"buffersize", "must not be negative")
+		}
+	}
+
+	for i, route := range r.Routes {
+		path := fmt.Sprintf("%sroutes[%d].", prefix, i)
+		if _, err := match.Compile(route.Match); err != nil {
+			v.add(path+"match", "%v", err)
+		}
+		if len(route.To) == 0 {
+			v.add(path+"to", "must list at least one upstream")
+		}
+		for _, to := range route.To {
+			if !names[to] {
+				v.add(path+"to", "unknown upstream %q", to)
+			}
+		}
+	}
+}
+
+func (r *CIDRules) validate(v *ValidationError, prefix string) {
+	if len(r.RequiredPrefix) != 1 {
+		v.add(prefix+"requiredprefix", "must be exactly one character")
+	}
+	if r.ValidLength < minMessageLength {
+		v.add(prefix+"validlength", "must be at least %d", minMessageLength)
+	}
+	for _, from := range slices.Sorted(maps.Keys(r.TestCodeMap)) {
+		to := r.TestCodeMap[from]
+		if !eventCodeRe.MatchString(from) || !eventCodeRe.MatchString(to) {
+			v.add(prefix+"testcodemap", "invalid mapping %q -> %q, codes look like E602", from, to)
+		}
+	}
+	if r.AccNumOffset < 0 {
+		v.add(prefix+"accnumoffset", "must not be negative")
+	}
+	if r.AccNumAdd < 0 {
+		v.add(prefix+"accnumadd", "must not be negative")
+	}
+}
+
+func (q *QueueConfig) validate(v *ValidationError, prefix string) {
+	if q.BufferSize <= 0 {
+		v.add(prefix+"buffersize", "must be positive")
+	}
+	if q.DrainTimeout < 0 {
+		v.add(prefix+"draintimeout", "must not be negative")
+	}
+	switch strings.ToLower(q.Mode) {
+	case "", "fifo", "priority":
+	default:
+		v.add(prefix+"mode", "unknown mode %q, want fifo or priority", q.Mode)
+	}
+	switch strings.ToLower(q.Dequeue) {
+	case "", "strict", "weighted":
+	default:
+		v.add(prefix+"dequeue", "unknown strategy %q, want strict or weighted", q.Dequeue)
+	}
+	for _, class := range slices.Sorted(maps.Keys(q.Weights)) {
+		weight := q.Weights[class]
+		if !containsFold(QueueClassNames, class) {
+			v.add(prefix+"weights."+class, "unknown class, want one of %s", strings.Join(QueueClassNames, ", "))
+		} else if weight <= 0 {
+			v.add(prefix+"weights."+class, "must be positive")
+		}
+	}
+	for _, class := range slices.Sorted(maps.Keys(q.ClassCapacity)) {
+		capacity := q.ClassCapacity[class]
+		if !containsFold(QueueClassNames, class) {
+			v.add(prefix+"classcapacity."+class, "unknown class, want one of %s", strings.Join(QueueClassNames, ", "))
+		} else if capacity <= 0 {
+			v.add(prefix+"classcapacity."+class, "must be positive")
+		}
+	}
+}
+
+func (n *NotifyConfig) validate(v *ValidationError) {
+	if n.Interval <= 0 {
+		v.add("notify.interval", "must be positive")
+	}
+	if n.QueueThreshold <= 0 || n.QueueThreshold > 1 {
+		v.add("notify.queuethreshold", "must be in (0, 1]")
+	}
+
+	channels := make(map[string]bool)
+	for i, ch := range n.Channels {
+		prefix := fmt.Sprintf("notify.channels[%d].", i)
+		switch {
+		case ch.Name == "":
+			v.add(prefix+"name", "must not be empty")
+		case channels[ch.Name]:
+			v.add(prefix+"name", "duplicate channel name %q", ch.Name)
+		}
+		channels[ch.Name] = true
+
+		switch ch.Type {
+		case "smtp":
+			validateHost(v, prefix+"host", ch.Host, false)
+			validatePort(v, prefix+"port", ch.Port)
+			if ch.From == "" {
+				v.add(prefix+"from", "must not be empty")
+			}
+			if len(ch.To) == 0 {
+				v.add(prefix+"to", "at least one recipient is required")
+			}
+		case "webhook":
+			if u, err := url.Parse(ch.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				v.add(prefix+"url", "invalid URL %q, want http(s)://host/path", ch.URL)
+			}
+		case "telegram":
+			if ch.BotToken == "" {
+				v.add(prefix+"bottoken", "must not be empty")
+			}
+			if ch.ChatID == "" {
+				v.add(prefix+"chatid", "must not be empty")
+			}
+			if ch.APIURL != "" {
+				if u, err := url.Parse(ch.APIURL); err != nil || u.Scheme == "" || u.Host == "" {
+					v.add(prefix+"apiurl", "invalid URL %q", ch.APIURL)
+				}
+			}
+		default:
+			v.add(prefix+"type", "unknown type %q, want smtp, webhook or telegram", ch.Type)
+		}
+	}
+
+	for i, r := range n.Rules {
+		prefix := fmt.Sprintf("notify.rules[%d].", i)
+		if len(r.Conditions) == 0 {
+			v.add(prefix+"conditions", "at least one condition is required")
+		}
+		for _, cond := range r.Conditions {
+			if !slices.Contains(NotifyConditions, cond) {
+				v.add(prefix+"conditions", "unknown condition %q, want one of %s", cond, strings.Join(NotifyConditions, ", "))
+			}
+		}
+		if len(r.Channels) == 0 {
+			v.add(prefix+"channels", "at least one channel is required")
+		}
+		for _, name := range r.Channels {
+			if !channels[name] {
+				v.add(prefix+"channels", "unknown channel %q", name)
+			}
+		}
+		for _, name := range r.EscalateTo {
+			if !channels[name] {
+				v.add(prefix+"escalateto", "unknown channel %q", name)
+			}
+		}
+		if r.Escalate > 0 && len(r.EscalateTo) == 0 {
+			v.add(prefix+"escalateto", "escalation needs at least one channel")
+		}
+		if r.Delay < 0 || r.Repeat < 0 || r.Escalate < 0 {
+			v.add(prefix+"delay", "delay, repeat and escalate must not be negative")
+		}
+		if r.MaxPerHour < 0 {
+			v.add(prefix+"maxperhour", "must not be negative")
+		}
+	}
+}
+
+func (f *ForwardConfig) validate(v *ValidationError) {
+	names := make(map[string]bool)
+	for i, d := range f.Destinations {
+		prefix := fmt.Sprintf("forward.destinations[%d].", i)
+		switch {
+		case d.Name == "":
+			v.add(prefix+"name", "must not be empty")
+		case names[d.Name]:
+			v.add(prefix+"name", "duplicate destination name %q", d.Name)
+		}
+		names[d.Name] = true
+		if u, err := url.Parse(d.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.add(prefix+"url", "invalid URL %q, want http(s)://host/path", d.URL)
+		}
+		if _, err := match.Compile(d.Match); err != nil {
+			v.add(prefix+"match", "%v", err)
+		}
+		if d.QueueSize < 0 || d.BatchSize < 0 || d.MaxAttempts < 0 {
+			v.add(prefix+"queuesize", "queuesize, batchsize and maxattempts must not be negative")
+		}
+		if d.BatchWait < 0 || d.Timeout < 0 || d.RetryInitial < 0 || d.RetryMax < 0 {
+			v.add(prefix+"timeout", "batchwait, timeout, retryinitial and retrymax must not be negative")
+		}
+	}
+}
+
+func (m *MQTTConfig) validate(v *ValidationError) {
+	u, err := url.Parse(m.Broker)
+	if err != nil || !slices.Contains(mqttSchemes, u.Scheme) || u.Host == "" {
+		v.add("mqtt.broker", "invalid broker %q, want tcp://host:port, ssl://host:port or ws(s)://host/path", m.Broker)
+	}
+	if m.TopicPrefix == "" || strings.ContainsAny(m.TopicPrefix, "+#") {
+		v.add("mqtt.topicprefix", "must be a non-empty topic without wildcards")
+	}
+	if m.EventQoS < 0 || m.EventQoS > 2 {
+		v.add("mqtt.eventqos", "must be 0, 1 or 2")
+	}
+	if m.StateQoS < 0 || m.StateQoS > 2 {
+		v.add("mqtt.stateqos", "must be 0, 1 or 2")
+	}
+	if m.KeepAlive < time.Second {
+		v.add("mqtt.keepalive", "must be at least 1s")
+	}
+	if m.QueueSize <= 0 {
+		v.add("mqtt.queuesize", "must be positive")
+	}
+}
+
+func (b *BusConfig) validate(v *ValidationError) {
+	names := make(map[string]bool)
+	for i, sk := range b.Sinks {
+		prefix := fmt.Sprintf("bus.sinks[%d].", i)
+		switch {
+		case sk.Name == "":
+			v.add(prefix+"name", "must not be empty")
+		case names[sk.Name]:
+			v.add(prefix+"name", "duplicate sink name %q", sk.Name)
+		}
+		names[sk.Name] = true
+		if !slices.Contains(BusSinkTypes, sk.Type) {
+			v.add(prefix+"type", "unknown type %q, want one of %v", sk.Type, BusSinkTypes)
+		}
+		if sk.URL == "" {
+			v.add(prefix+"url", "must not be empty")
+		}
+		if strings.ContainsAny(sk.Subject, " *>") || strings.HasPrefix(sk.Subject, ".") || strings.HasSuffix(sk.Subject, ".") {
+			v.add(prefix+"subject", "invalid subject %q", sk.Subject)
+		}
+		if sk.BufferSize < 0 || sk.SpillMax < 0 {
+			v.add(prefix+"buffersize", "buffersize and spillmax must not be negative")
+		}
+	}
+}
+
+func (st *StateConfig) validate(v *ValidationError) {
+	if st.Path == "" {
+		v.add("state.path", "must not be empty")
+	}
+	if st.History <= 0 {
+		v.add("state.history", "must be positive")
+	}
+	for _, account := range slices.Sorted(maps.Keys(st.Schedules)) {
+		sc := st.Schedules[account]
+		prefix := fmt.Sprintf("state.schedules.%d.", account)
+		if _, err := sc.Weekdays(); err != nil {
+			v.add(prefix+"days", "%v", err)
+		}
+		open, close, err := sc.Times()
+		switch {
+		case err != nil:
+			v.add(prefix+"open", "%v", err)
+		case open >= close:
+			v.add(prefix+"close", "must be later than open (%s)", sc.Open)
+		}
+		if sc.Grace < 0 {
+			v.add(prefix+"grace", "must not be negative")
+		}
+	}
+}
+
+func (ic *IncidentsConfig) validate(v *ValidationError) {
+	if ic.Path == "" {
+		v.add("incidents.path", "must not be empty")
+	}
+	if ic.MaxEntries <= 0 {
+		v.add("incidents.maxentries", "must be positive")
+	}
+	if ic.EscalateAfter < 0 {
+		v.add("incidents.escalateafter", "must not be negative")
+	}
+	if len(ic.Resolutions) == 0 {
+		v.add("incidents.resolutions", "must not be empty")
+	}
+	for i, r := range ic.Resolutions {
+		switch {
+		case strings.TrimSpace(r) == "":
+			v.add(fmt.Sprintf("incidents.resolutions[%d]", i), "must not be empty")
+		case slices.Index(ic.Resolutions, r) != i:
+			v.add(fmt.Sprintf("incidents.resolutions[%d]", i), "duplicate resolution %q", r)
+		}
+	}
+}
+
+// Weekdays returns the scheduled days; nil means every day.
+func (sc ScheduleConfig) Weekdays() ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, name := range sc.Days {
+		i := slices.Index(ScheduleDays, strings.ToLower(name))
+		if i < 0 {
+			return nil, fmt.Errorf("unknown day %q, want one of %s", name, strings.Join(ScheduleDays, ", "))
+		}
+		days = append(days, time.Weekday(i))
+	}
+	return days, nil
+}
+
+// Times returns the open and close times as offsets from midnight.
+func (sc ScheduleConfig) Times() (open, close time.Duration, err error) {
+	if open, err = parseClock(sc.Open); err != nil {
+		return 0, 0, err
+	}
+	if close, err = parseClock(sc.Close); err != nil {
+		return 0, 0, err
+	}
+	return open, close, nil
+}
+
+// parseClock parses a time of day in the "15:04" form.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, want HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func validateHost(v *ValidationError, path, host string, allowEmpty bool) {
+	if host == "" && !allowEmpty {
+		v.add(path, "must not be empty")
+	}
+	if strings.ContainsAny(host, " :/") && net.ParseIP(host) == nil {
+		v.add(path, "invalid host %q", host)
+	}
+}
+
+func validatePort(v *ValidationError, path, port string) {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		v.add(path, "invalid port %q, want a number between 1 and 65535", port)
+	}
+}
+
+func containsFold(items []string, value string) bool {
+	for _, item := range items {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadStrict reads the configuration like Load, but rejects unknown YAML keys.
+// Decoding problems are reported as a *ValidationError.
+func LoadStrict(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := decode(data, &cfg, true); err != nil {
+		return nil, err
+	}
+	if err := inheritPipelines(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
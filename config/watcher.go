@@ -0,0 +1,94 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Watcher polls a configuration file and reports when its contents change.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	load     func() (*Config, error)
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher creates a watcher for the file at path. The current state of the
+// file is treated as already seen.
+func NewWatcher(path string, interval time.Duration) *Watcher {
+	w := &Watcher{path: path, interval: interval}
+	w.load = func() (*Config, error) { return Load(path) }
+	w.Sync()
+	return w
+}
+
+// NewWatcher creates a watcher for the configuration file of s. Reloaded
+// configurations have the environment and command-line overrides applied.
+func (s Sources) NewWatcher(interval time.Duration) *Watcher {
+	w := NewWatcher(s.Path, interval)
+	w.load = s.Load
+	return w
+}
+
+// Sync marks the current state of the file as seen, so a change made by the
+// application itself does not trigger a reload.
+func (w *Watcher) Sync() {
+	fi, err := os.Stat(w.path)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		w.modTime, w.size = time.Time{}, 0
+		return
+	}
+	w.modTime, w.size = fi.ModTime(), fi.Size()
+}
+
+// Run polls the file until ctx is cancelled and calls onChange with the newly
+// loaded configuration whenever the file changes. Files that fail to parse are
+// logged and skipped.
+func (w *Watcher) Run(ctx context.Context, onChange func(*Config)) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !w.changed() {
+				continue
+			}
+
+			cfg, err := w.load()
+			if err != nil {
+				slog.Error("Failed to reload configuration", "path", w.path, "error", err)
+				continue
+			}
+
+			slog.Info("Configuration file changed", "path", w.path)
+			onChange(cfg)
+		}
+	}
+}
+
+// changed reports whether the file differs from the last seen state and records the new state.
+func (w *Watcher) changed() bool {
+	fi, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if fi.ModTime().Equal(w.modTime) && fi.Size() == w.size {
+		return false
+	}
+	w.modTime, w.size = fi.ModTime(), fi.Size()
+	return true
+}
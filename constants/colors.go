@@ -72,7 +72,7 @@ var (
 var (
 	UnknownEvent  = 0
 	GuardEvent    = 1
-	DisguardEvent = 3
+	DisguardEvent = 2
 	OkEvent       = 3
 	AlarmEvent    = 4
 	OtherEvent    = 5
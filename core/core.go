@@ -1,12 +1,23 @@
 package core
 
 import (
-	"cid_retranslator_walk/client"
+	"cid_retranslator_walk/api"
+	"cid_retranslator_walk/audit"
+	"cid_retranslator_walk/bus"
 	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/deadletter"
+	"cid_retranslator_walk/eventdict"
+	"cid_retranslator_walk/forward"
+	"cid_retranslator_walk/incident"
+	"cid_retranslator_walk/logging"
 	"cid_retranslator_walk/metrics"
+	"cid_retranslator_walk/mqtt"
+	"cid_retranslator_walk/notify"
 	"cid_retranslator_walk/queue"
 	"cid_retranslator_walk/server"
+	"cid_retranslator_walk/state"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -14,12 +25,12 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
-	"github.com/getlantern/systray"
 	// "github.com/wailsapp/wails/v2/pkg/runtime"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
@@ -27,53 +38,93 @@ import (
 // App struct
 type App struct {
 	ctx context.Context // Signal context for shutdown
+	// Конвеєри працюють у власному контексті, щоб при зупинці доставити вже прийняте
+	runCtx       context.Context
+	stopRun      context.CancelFunc
+	shutdownOnce sync.Once
 	// wailsCtx   context.Context // Wails context for runtime calls
 	cfg        *config.Config
-	appQueue   *queue.Queue
-	tcpServer  *server.Server
-	tcpClient  *client.Client
+	pipelines  []*pipeline
 	logger     *slog.Logger
 	fileLogger *lumberjack.Logger // Store fileLogger for closing
 	cancelfunc context.CancelFunc
 	wg         sync.WaitGroup
-	logBuffer  []string
-	logMu      sync.RWMutex
+	logs       *logging.Ring   // Останні записи журналу для API
+	syslog     *logging.Syslog // nil, якщо відправка в syslog вимкнена
 	startTime  time.Time
+
+	// Об'єднані оновлення всіх конвеєрів для UI
+	deviceUpdates chan server.Device
+	eventUpdates  chan server.GlobalEvent
+
+	// Словник кодів подій для UI і публікації подій
+	events *eventdict.Dictionary
+	// Сховище повідомлень, які приймач відхилив після всіх спроб (nil - вимкнене)
+	deadLetters *deadletter.Store
+	// Журнал дій операторів і змін конфігурації (nil - вимкнений)
+	audit *audit.Log
+	// Сповіщення операторів про стан ретранслятора (nil - вимкнені)
+	notifier *notify.Notifier
+	// Пересилання подій HTTP-споживачам (nil - немає отримувачів)
+	forwarder *forward.Forwarder
+	// Публікація подій і стану пристроїв у MQTT (nil - вимкнена)
+	mqtt *mqtt.Publisher
+	// Публікація всіх повідомлень з відповіддю приймача в шини повідомлень (nil - немає шин)
+	bus *bus.Bus
+	// Відкриті тривоги, несправності і стан охорони розділів (nil - вимкнене)
+	state *state.Tracker
+	// Інциденти, які має підтвердити диспетчер (nil - вимкнені)
+	incidents *incident.Store
+
+	// Hot-reload state
+	cfgMu    sync.RWMutex
+	reloadMu sync.Mutex
+	sources  config.Sources
+	watcher  *config.Watcher
+	logLevel slog.LevelVar
 }
 
-// NewApp creates a new App application struct
-func NewApp(stats *metrics.Stats) *App {
-	cfg := config.New()
+const (
+	// configPollInterval is how often the config file is checked for changes
+	configPollInterval = 2 * time.Second
+	// fanInBuffer is the buffer size of the merged UI update channels
+	fanInBuffer = 100
+)
+
+// NewApp creates a new App application struct with one pipeline per configured
+// relay. sources describe where cfg was loaded from and are used again on reload.
+func NewApp(cfg *config.Config, sources config.Sources) *App {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-	sharedQueue := queue.New(cfg.Queue.BufferSize, stats)
 
+	runCtx, stopRun := context.WithCancel(context.Background())
 	app := &App{
 		ctx:        ctx,
+		runCtx:     runCtx,
+		stopRun:    stopRun,
 		cfg:        cfg,
-		tcpServer:  server.New(&cfg.Server, sharedQueue, &cfg.CIDRules),
-		tcpClient:  client.New(&cfg.Client, sharedQueue),
 		cancelfunc: cancel,
-		logBuffer:  make([]string, 0, 100),
+		logs:       logging.NewRing(cfg.Logging.BufferLines),
 		startTime:  time.Now(),
+		sources:    sources,
+		events:     eventdict.New(sources.Resolve(cfg.Events.Path), sources.Resolve(cfg.Events.Overrides), cfg.Events.Language),
 	}
-
-	// Validate log file path and create directory if needed
-	if cfg.Logging.Filename == "" {
-		cfg.Logging.Filename = "cid_retranslator.log" // Default filename
+	labelled := len(cfg.Pipelines) > 0
+	for _, pc := range cfg.EffectivePipelines() {
+		app.pipelines = append(app.pipelines, newPipeline(pc, labelled, app.events.IsAlarm))
 	}
+	app.fanIn()
+	app.watcher = sources.NewWatcher(configPollInterval)
 
-	exePath, err := os.Executable()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Cannot determine executable path: %v\n", err)
+	// Validate log file path and create directory if needed
+	logFilename := cfg.Logging.Filename
+	if logFilename == "" {
+		logFilename = "cid_retranslator.log" // Default filename
 	}
 
-	exeDir := filepath.Dir(exePath)
+	// Relative paths are resolved against the config file directory, not the working directory
+	logFilename = sources.Resolve(logFilename)
 
-	if !filepath.IsAbs(cfg.Logging.Filename) {
-		cfg.Logging.Filename = filepath.Join(exeDir, cfg.Logging.Filename)
-	}
-
-	logDir := filepath.Dir(cfg.Logging.Filename)
+	logDir := filepath.Dir(logFilename)
 	if logDir != "." && logDir != "" {
 		if err := os.MkdirAll(logDir, 0755); err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to create log directory %s: %v\n", logDir, err)
@@ -81,7 +132,7 @@ func NewApp(stats *metrics.Stats) *App {
 	}
 
 	fileLogger := &lumberjack.Logger{
-		Filename:   cfg.Logging.Filename,
+		Filename:   logFilename,
 		MaxSize:    cfg.Logging.MaxSize,
 		MaxBackups: cfg.Logging.MaxBackups,
 		MaxAge:     cfg.Logging.MaxAge,
@@ -99,83 +150,195 @@ func NewApp(stats *metrics.Stats) *App {
 	// multiWriter := io.MultiWriter(os.Stdout, fileLogger)
 
 	// Determine log level
-	var logLevel slog.Level
-	switch strings.ToUpper(cfg.Logging.Level) {
-	case "DEBUG":
-		logLevel = slog.LevelDebug
-	case "INFO":
-		logLevel = slog.LevelInfo
-	case "WARN":
-		logLevel = slog.LevelWarn
-	case "ERROR":
-		logLevel = slog.LevelError
-	default:
-		logLevel = slog.LevelInfo
-	}
+	app.logLevel.Set(parseLogLevel(cfg.Logging.Level))
 
-	// Create custom handler for collecting full messages
-	handler := &logHandler{
-		app:     app,
-		handler: slog.NewTextHandler(multiWriter, &slog.HandlerOptions{Level: logLevel}),
+	// Файл/консоль, останні записи для API і, за потреби, syslog
+	handlers := []slog.Handler{
+		logging.NewHandler(multiWriter, cfg.Logging.Format, &slog.HandlerOptions{Level: &app.logLevel}),
+		app.logs.Handler(&app.logLevel),
+	}
+	if sc := cfg.Logging.Syslog; sc.Enabled {
+		sl, err := logging.NewSyslog(logging.SyslogOptions{
+			Network:  sc.Network,
+			Address:  sc.Address,
+			Facility: sc.Facility,
+			AppName:  sc.AppName,
+			Format:   cfg.Logging.Format,
+			Level:    &app.logLevel,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to set up syslog output: %v\n", err)
+		} else {
+			app.syslog = sl
+			handlers = append(handlers, sl.Handler())
+		}
 	}
 
-	app.logger = slog.New(handler)
+	app.logger = slog.New(logging.Multi(handlers...))
 	slog.SetDefault(app.logger)
 
 	// Log initialization to verify logging setup
-	app.logger.Info("Logger initialized", "filename", cfg.Logging.Filename)
+	app.logger.Info("Logger initialized", "filename", logFilename)
 
-	return app
-}
+	if err := app.events.Reload(); err != nil {
+		app.logger.Error("Failed to load event dictionary, events are shown as unknown codes", "error", err)
+	} else {
+		app.logger.Info("Event dictionary loaded", "codes", app.events.Len(), "language", cfg.Events.Language)
+	}
 
-// logHandler and related methods
-type logHandler struct {
-	app     *App
-	handler slog.Handler
-}
+	if cfg.DeadLetter.Enabled {
+		path := sources.Resolve(cfg.DeadLetter.Path)
+		store, err := deadletter.Open(path, cfg.DeadLetter.MaxEntries)
+		if err != nil {
+			app.logger.Error("Failed to open dead letter store, dead letters are disabled", "path", path, "error", err)
+		} else {
+			app.deadLetters = store
+			app.logger.Info("Dead letter store opened", "path", path, "entries", store.Len())
+		}
+	}
+	for _, p := range app.pipelines {
+		p.setDeadLetters(app.deadLetters, cfg.DeadLetter.AckPanel)
+	}
 
-func (h *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	return h.handler.Enabled(ctx, level)
-}
+	if cfg.Audit.Enabled {
+		path := sources.Resolve(cfg.Audit.Path)
+		log, err := audit.Open(path)
+		if err != nil {
+			app.logger.Error("Failed to open audit log, auditing is disabled", "path", path, "error", err)
+		} else {
+			app.audit = log
+		}
+	}
 
-func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
-	var msgBuilder strings.Builder
-	msgBuilder.WriteString(r.Time.Format("15:04:05.000 2006-01-02"))
-	msgBuilder.WriteString("\t")
-	msgBuilder.WriteString(r.Level.String())
-	msgBuilder.WriteString("\t")
-	msgBuilder.WriteString(r.Message)
-	r.Attrs(func(a slog.Attr) bool {
-		if a.Key != slog.TimeKey && a.Key != slog.LevelKey && a.Key != slog.MessageKey {
-			msgBuilder.WriteString("\t")
-			msgBuilder.WriteString(a.Key)
-			msgBuilder.WriteString("=")
-			msgBuilder.WriteString(a.Value.String())
+	if cfg.Notify.Enabled {
+		n, err := notify.NewFromConfig(&cfg.Notify)
+		if err != nil {
+			app.logger.Error("Invalid notification settings, notifications are disabled", "error", err)
+		} else {
+			app.notifier = n
 		}
-		return true
-	})
+	}
 
-	h.app.logMu.Lock()
-	h.app.logBuffer = append(h.app.logBuffer, msgBuilder.String())
-	if len(h.app.logBuffer) > 100 {
-		h.app.logBuffer = h.app.logBuffer[1:]
+	if cfg.State.Enabled {
+		path := sources.Resolve(cfg.State.Path)
+		tracker, err := state.Open(path, cfg.State.History, app.events)
+		if err != nil {
+			app.logger.Error("Failed to open state file, state tracking is disabled", "path", path, "error", err)
+		} else {
+			app.state = tracker
+			tracker.SetSchedules(schedules(cfg.State.Schedules))
+			for _, p := range app.pipelines {
+				name := p.name
+				p.server.Observe(func(fr server.Frame) {
+					// Оновлення пристрою вже надіслано при прийомі кадру; повторюємо його з новим станом охорони
+					if tracker.Submit(name, fr) {
+						p.server.RefreshDevice(fr.DeviceID)
+					}
+				})
+			}
+			snap := tracker.Snapshot()
+			app.logger.Info("State tracking enabled", "path", path,
+				"conditions", len(snap.Conditions), "partitions", len(snap.Partitions))
+		}
 	}
-	h.app.logMu.Unlock()
 
-	err := h.handler.Handle(ctx, r)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to write log: %v\n", err)
+	if cfg.Incidents.Enabled {
+		path := sources.Resolve(cfg.Incidents.Path)
+		store, err := incident.Open(path, cfg.Incidents.MaxEntries, app.events)
+		if err != nil {
+			app.logger.Error("Failed to open incident store, incidents are disabled", "path", path, "error", err)
+		} else {
+			app.incidents = store
+			store.SetPolicy(cfg.Incidents.EscalateAfter, cfg.Incidents.Resolutions)
+			app.observe(store.Submit)
+			app.logger.Info("Incident store opened", "path", path, "open", len(store.List(incident.StatusOpen)))
+		}
+	}
+
+	if len(cfg.Forward.Destinations) > 0 || cfg.MQTT.Enabled || len(cfg.Bus.Sinks) > 0 {
+		app.setupPublishers()
 	}
 
-	return err
+	return app
 }
 
-func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return &logHandler{app: h.app, handler: h.handler.WithAttrs(attrs)}
+// setupPublishers створює пересилання подій HTTP, публікацію в MQTT і шини
+// повідомлень і підключає їх до серверів конвеєрів
+func (a *App) setupPublishers() {
+	// Назви об'єктів читаються з поточної конфігурації і змінюються без перезапуску
+	objects := func(account int) string {
+		return a.Config().Objects[account]
+	}
+
+	var observers []func(pipeline string, fr server.Frame)
+	if len(a.cfg.Forward.Destinations) > 0 {
+		f, err := forward.New(&a.cfg.Forward, a.events, objects)
+		if err != nil {
+			a.logger.Error("Invalid forwarding settings, forwarding is disabled", "error", err)
+		} else {
+			a.forwarder = f
+			observers = append(observers, f.Submit)
+		}
+	}
+	if a.cfg.MQTT.Enabled {
+		a.mqtt = mqtt.New(&a.cfg.MQTT, a.events, objects)
+		observers = append(observers, a.mqtt.Submit)
+	}
+	if len(a.cfg.Bus.Sinks) > 0 {
+		busCfg := config.BusConfig{Sinks: slices.Clone(a.cfg.Bus.Sinks)}
+		for i := range busCfg.Sinks {
+			if path := busCfg.Sinks[i].SpillPath; path != "" {
+				busCfg.Sinks[i].SpillPath = a.sources.Resolve(path)
+			}
+		}
+		b, err := bus.New(&busCfg)
+		if err != nil {
+			a.logger.Error("Invalid message bus settings, message bus is disabled", "error", err)
+		} else {
+			a.bus = b
+			observers = append(observers, b.Submit)
+		}
+	}
+	for _, observe := range observers {
+		a.observe(observe)
+	}
+}
+
+// observe передає observe кожен кадр усіх конвеєрів разом з назвою конвеєра
+func (a *App) observe(observe func(pipeline string, fr server.Frame)) {
+	for _, p := range a.pipelines {
+		name := p.name
+		p.server.Observe(func(fr server.Frame) {
+			observe(name, fr)
+		})
+	}
+}
+
+// schedules перетворює перевірені розклади об'єктів для відстеження стану
+func schedules(cfg map[int]config.ScheduleConfig) map[int]state.Schedule {
+	out := make(map[int]state.Schedule, len(cfg))
+	for account, sc := range cfg {
+		days, _ := sc.Weekdays()
+		open, close, _ := sc.Times()
+		out[account] = state.Schedule{Days: days, Open: open, Close: close, Grace: sc.Grace}
+	}
+	return out
 }
 
-func (h *logHandler) WithGroup(name string) slog.Handler {
-	return &logHandler{app: h.app, handler: h.handler.WithGroup(name)}
+// parseLogLevel converts a config level name into slog.Level (INFO by default)
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToUpper(level) {
+	case "DEBUG":
+		return slog.LevelDebug
+	case "INFO":
+		return slog.LevelInfo
+	case "WARN":
+		return slog.LevelWarn
+	case "ERROR":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
 }
 
 func (a *App) Ctx() context.Context {
@@ -198,88 +361,605 @@ func isTerminal(f *os.File) bool {
 // Startup is called when the app starts
 func (a *App) Startup() {
 
-	// Start TCP server and client
-	a.wg.Add(2)
+	// Start TCP server and client of every pipeline
+	for _, p := range a.pipelines {
+		p.run(a.runCtx, &a.wg, a.logger)
+	}
+
+	// Сигнал ОС запускає ту саму зупинку з доставкою черг, що й закриття вікна
 	go func() {
-		defer a.wg.Done()
-		defer func() {
-			if r := recover(); r != nil {
-				a.logger.Error("Panic in TCP server", "panic", r)
-			}
-		}()
-		a.tcpServer.Run(a.ctx)
+		<-a.ctx.Done()
+		a.Shutdown(context.Background())
 	}()
-	go func() {
-		defer a.wg.Done()
-		defer func() {
-			if r := recover(); r != nil {
-				a.logger.Error("Panic in TCP client", "panic", r)
-			}
+
+	go a.watcher.Run(a.ctx, func(cfg *config.Config) {
+		report, err := a.ApplyConfig(cfg, audit.Actor{Name: a.sources.Path, Source: audit.SourceFile})
+		if err != nil {
+			a.logger.Error("Configuration file rejected", "error", err)
+			return
+		}
+		a.logger.Info("Configuration reloaded from file",
+			"applied", report.Applied,
+			"restartRequired", report.RestartRequired,
+			"errors", report.Errors)
+	})
+
+	if a.notifier != nil {
+		go a.notifier.Run(a.ctx, a.cfg.Notify.Interval, a.conditions)
+	}
+
+	// Пересилання, MQTT і шини працюють до кінця доставки черг, щоб не втратити події, прийняті під час зупинки
+	if a.forwarder != nil {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.forwarder.Run(a.runCtx)
 		}()
-		a.tcpClient.Run(a.ctx)
-	}()
+	}
+	if a.mqtt != nil {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.mqtt.Run(a.runCtx, a.PPKTimeout)
+		}()
+	}
+	if a.bus != nil {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.bus.Run(a.runCtx)
+		}()
+	}
+	if a.state != nil {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.state.Run(a.runCtx)
+		}()
+	}
+	if a.incidents != nil {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.incidents.Run(a.runCtx)
+		}()
+	}
 
+	if a.cfg.API.Enabled {
+		go func() {
+			if err := api.New(a.cfg.API.Listen, a).Run(a.ctx); err != nil {
+				a.logger.Error("API server failed", "error", err)
+			}
+		}()
+	}
 }
 
-// Shutdown is called when the app is closing
+// Shutdown is called when the app is closing. Сервери припиняють приймати
+// повідомлення, клієнти доставляють уже прийняте протягом queue.draintimeout
+// (або до скасування ctx), залишок зберігається у сховищі недоставлених.
+// Повторні виклики чекають завершення першого.
 func (a *App) Shutdown(ctx context.Context) {
+	a.shutdownOnce.Do(func() {
+		a.shutdown(ctx)
+	})
+}
+
+// shutdown зупиняє конвеєри і повертає сумарний підсумок зупинки
+func (a *App) shutdown(ctx context.Context) shutdownReport {
 	a.logger.Info("Received shutdown signal, initiating graceful shutdown...")
-	a.cancelfunc()
-	a.tcpServer.Stop()
-	a.tcpClient.Stop()
+	a.cancelfunc() // Watcher і API
+
+	reports := make([]shutdownReport, len(a.pipelines))
+	baseline := make([][3]int64, len(a.pipelines))
+	for i, p := range a.pipelines {
+		baseline[i][0], baseline[i][1], baseline[i][2] = p.counters()
+	}
+
+	// 1. Припиняємо приймати повідомлення від панелей; сесії з повідомленням у черзі дочекаються відповіді
+	a.eachPipeline(func(i int, p *pipeline) {
+		p.server.Stop()
+	})
+
+	// 2. Доставляємо вже прийняте
+	a.eachPipeline(func(i int, p *pipeline) {
+		reports[i].drained = p.drain(ctx)
+	})
+
+	// 3. Зупиняємо клієнти; після wg.Wait кожна доставка завершена або перервана
+	a.stopRun()
+	for _, p := range a.pipelines {
+		p.stop()
+	}
 	a.wg.Wait()
-	a.appQueue.Close()
+
+	// 4. Зберігаємо залишок черг і підбиваємо підсумок
+	total := shutdownReport{drained: true}
+	for i, p := range a.pipelines {
+		r := &reports[i]
+		p.spool(a.deadLetters, r)
+		accepted, rejected, dropped := p.counters()
+		r.delivered, r.rejected = accepted-baseline[i][0], rejected-baseline[i][1]
+		// Перервані доставки вважаються втраченими
+		r.lost += int(dropped - baseline[i][2])
+
+		a.logger.Info("Pipeline stopped", "pipeline", p.name,
+			"drained", r.drained, "delivered", r.delivered, "rejected", r.rejected,
+			"spooled", r.spooled, "lost", r.lost)
+		total.delivered += r.delivered
+		total.rejected += r.rejected
+		total.spooled += r.spooled
+		total.lost += r.lost
+		total.drained = total.drained && r.drained
+	}
+	a.logger.Info("Shutdown accounting",
+		"delivered", total.delivered, "rejected", total.rejected,
+		"spooled", total.spooled, "lost", total.lost)
+
 	if a.fileLogger != nil {
 		if err := a.fileLogger.Close(); err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to close file logger: %v\n", err)
 		}
 	}
-	systray.Quit() // Ensure system tray is closed
+	if a.audit != nil {
+		a.audit.Close()
+	}
 	a.logger.Info("Program exited gracefully")
+	if a.syslog != nil {
+		a.syslog.Close()
+	}
+	return total
 }
 
-// GetServer повертає TCP сервер (для доступу до його методів)
-func (a *App) GetServer() *server.Server {
-	return a.tcpServer
+// eachPipeline виконує fn для всіх конвеєрів паралельно і чекає завершення
+func (a *App) eachPipeline(fn func(i int, p *pipeline)) {
+	var wg sync.WaitGroup
+	for i, p := range a.pipelines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(i, p)
+		}()
+	}
+	wg.Wait()
 }
 
-func (a *App) GetClient() *client.Client {
-	return a.tcpClient
+// Config повертає поточну конфігурацію (лише для читання)
+func (a *App) Config() *config.Config {
+	a.cfgMu.RLock()
+	defer a.cfgMu.RUnlock()
+	return a.cfg
 }
 
-// GetDeviceUpdates повертає канал оновлень пристроїв
+// PPKTimeout повертає поточний таймаут подій ППК
+func (a *App) PPKTimeout() time.Duration {
+	return a.Config().Monitoring.PPKTimeout
+}
+
+// Stats повертає сумарний знімок статистики всіх конвеєрів
+func (a *App) Stats() metrics.Snapshot {
+	snapshots := make([]metrics.Snapshot, len(a.pipelines))
+	for i, p := range a.pipelines {
+		snapshots[i] = p.stats.Snapshot()
+	}
+	return metrics.Sum(snapshots...)
+}
+
+// Pipelines повертає стан кожного конвеєра
+func (a *App) Pipelines() []api.PipelineStatus {
+	statuses := make([]api.PipelineStatus, len(a.pipelines))
+	for i, p := range a.pipelines {
+		statuses[i] = p.status()
+	}
+	return statuses
+}
+
+// Backlog повертає черги всіх приймачів усіх конвеєрів
+func (a *App) Backlog() []api.Backlog {
+	var backlog []api.Backlog
+	for _, p := range a.pipelines {
+		backlog = append(backlog, p.backlog(time.Now())...)
+	}
+	return backlog
+}
+
+// PauseUpstream призупиняє або відновлює видачу повідомлень приймачу upstream конвеєра pipeline
+func (a *App) PauseUpstream(pipeline, upstream string, paused bool) error {
+	for _, p := range a.pipelines {
+		if p.name != pipeline {
+			continue
+		}
+		c := p.upstreamClient(upstream)
+		if c == nil {
+			break
+		}
+		if paused {
+			c.Pause()
+		} else {
+			c.Resume()
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s/%s", api.ErrUnknownUpstream, pipeline, upstream)
+}
+
+// ReloadConfig перечитує файл конфігурації і застосовує зміни на льоту від імені actor
+func (a *App) ReloadConfig(actor audit.Actor) (*config.ReloadReport, error) {
+	cfg, err := a.sources.Load()
+	if err != nil {
+		return nil, err
+	}
+	a.watcher.Sync()
+	return a.ApplyConfig(cfg, actor)
+}
+
+// UpdateConfig записує у файл конфігурації лише поля paths зі значеннями з cfg
+// і застосовує результат на льоту. Решта файлу, змінні середовища і параметри
+// командного рядка не змінюються. Викликається з вікна налаштувань, тому зміни
+// записуються від імені користувача ОС.
+func (a *App) UpdateConfig(cfg *config.Config, paths []string) (*config.ReloadReport, error) {
+	if len(paths) == 0 {
+		return &config.ReloadReport{}, nil
+	}
+	if err := a.sources.SaveFields(cfg, paths); err != nil {
+		return nil, err
+	}
+	a.watcher.Sync()
+	newCfg, err := a.sources.Load()
+	if err != nil {
+		return nil, err
+	}
+	return a.ApplyConfig(newCfg, audit.LocalUser())
+}
+
+// ApplyConfig перевіряє конфігурацію, застосовує зміни там, де це безпечно,
+// і повідомляє, які поля потребують перезапуску. Невалідна конфігурація не застосовується.
+// Кожне змінене поле записується в журнал аудиту від імені actor.
+func (a *App) ApplyConfig(newCfg *config.Config, actor audit.Actor) (*config.ReloadReport, error) {
+	if err := newCfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	oldCfg := a.Config()
+	report := &config.ReloadReport{}
+	changes := config.Diff(oldCfg, newCfg)
+	if len(changes) == 0 {
+		return report, nil
+	}
+
+	for _, ch := range changes {
+		section, _, _ := strings.Cut(ch.Path, ".")
+		switch {
+		case isPipelineSection(section):
+			// Застосовується нижче для кожного конвеєра
+			continue
+		case ch.Path == "logging.level" || section == "monitoring" || ch.Path == "deadletter.ackpanel" ||
+			ch.Path == "events.language" || ch.Path == "state.schedules" ||
+			ch.Path == "incidents.escalateafter" || strings.HasPrefix(ch.Path, "incidents.resolutions"):
+		case section == "objects":
+			// Назви об'єктів для пересилання подій читаються з поточної конфігурації
+		case strings.HasPrefix(ch.Path, "api.tokens"):
+			// API читає токени з поточної конфігурації при кожному запиті
+		default:
+			report.RestartRequired = append(report.RestartRequired, ch.Path)
+			continue
+		}
+		report.Applied = append(report.Applied, ch.Path)
+	}
+
+	oldPipelines, newPipelines := oldCfg.EffectivePipelines(), newCfg.EffectivePipelines()
+	if !samePipelines(oldPipelines, newPipelines) {
+		// Додавання, видалення чи перейменування конвеєрів потребує перезапуску
+		report.RestartRequired = append(report.RestartRequired, "pipelines")
+	} else {
+		for i, p := range a.pipelines {
+			prefix := ""
+			if len(newCfg.Pipelines) > 0 {
+				prefix = "pipelines." + p.name + "."
+			}
+			p.apply(oldPipelines[i], &newPipelines[i], prefix, report)
+		}
+		if len(newCfg.Pipelines) == 0 {
+			// Неявний конвеєр - копія секцій верхнього рівня; повертаємо можливий відкат адреси
+			newCfg.Server = newPipelines[0].Server
+		}
+	}
+	a.logLevel.Set(parseLogLevel(newCfg.Logging.Level))
+	a.events.SetLanguage(newCfg.Events.Language)
+	if a.state != nil {
+		a.state.SetSchedules(schedules(newCfg.State.Schedules))
+	}
+	if a.incidents != nil {
+		a.incidents.SetPolicy(newCfg.Incidents.EscalateAfter, newCfg.Incidents.Resolutions)
+	}
+	if newCfg.DeadLetter.AckPanel != oldCfg.DeadLetter.AckPanel {
+		for _, p := range a.pipelines {
+			p.setDeadLetters(a.deadLetters, newCfg.DeadLetter.AckPanel)
+		}
+	}
+
+	a.cfgMu.Lock()
+	a.cfg = newCfg
+	a.cfgMu.Unlock()
+
+	a.auditChanges(actor, changes, report)
+	return report, nil
+}
+
+// auditChanges записує зміни конфігурації в журнал аудиту
+func (a *App) auditChanges(actor audit.Actor, changes []config.FieldChange, report *config.ReloadReport) {
+	if a.audit == nil {
+		return
+	}
+	for _, ch := range changes {
+		e := audit.Entry{Action: audit.ActionConfigChange, Target: ch.Path, Old: ch.Old, New: ch.New}
+		if slices.Contains(report.RestartRequired, ch.Path) {
+			e.Detail = "restart required"
+		}
+		if err := a.audit.Record(actor, e); err != nil {
+			a.logger.Error("Failed to write audit entry", "field", ch.Path, "error", err)
+		}
+	}
+}
+
+// Audit повертає журнал аудиту (nil, якщо вимкнений)
+func (a *App) Audit() *audit.Log {
+	return a.audit
+}
+
+// Bus повертає лічильники шин повідомлень (nil, якщо шин немає)
+func (a *App) Bus() []bus.Stats {
+	if a.bus == nil {
+		return nil
+	}
+	return a.bus.Stats()
+}
+
+// Events повертає словник кодів подій
+func (a *App) Events() *eventdict.Dictionary {
+	return a.events
+}
+
+// State повертає відкриті стани і стан охорони розділів (nil, якщо вимкнене)
+func (a *App) State() *state.Tracker {
+	return a.state
+}
+
+// Incidents повертає сховище інцидентів (nil, якщо вимкнене)
+func (a *App) Incidents() *incident.Store {
+	return a.incidents
+}
+
+// AcknowledgeIncident підтверджує інцидент з вікна програми від імені користувача ОС
+func (a *App) AcknowledgeIncident(id, resolution, comment string) (incident.Incident, error) {
+	if a.incidents == nil {
+		return incident.Incident{}, incident.ErrDisabled
+	}
+	actor := audit.LocalUser()
+	inc, err := a.incidents.Acknowledge(id, actor.Name, resolution, comment)
+	if err != nil {
+		return inc, err
+	}
+	if a.audit != nil {
+		e := audit.Entry{Action: audit.ActionIncidentAck, Target: id, New: resolution, Detail: comment}
+		if err := a.audit.Record(actor, e); err != nil {
+			a.logger.Error("Failed to write audit entry", "action", e.Action, "error", err)
+		}
+	}
+	return inc, nil
+}
+
+// PartitionStates повертає стан охорони розділів пристрою конвеєра з міткою pipeline
+func (a *App) PartitionStates(pipeline string, deviceID int) []state.Partition {
+	p := a.pipelineByLabel(pipeline)
+	if p == nil || a.state == nil {
+		return nil
+	}
+	return a.state.Account(p.name, deviceID)
+}
+
+// Logs повертає буфер останніх записів журналу
+func (a *App) Logs() *logging.Ring {
+	return a.logs
+}
+
+// DeadLetters повертає сховище недоставлених повідомлень (nil, якщо вимкнене)
+func (a *App) DeadLetters() *deadletter.Store {
+	return a.deadLetters
+}
+
+// ResubmitDeadLetter повертає недоставлене повідомлення в чергу приймача, який його
+// відхилив. Запис забирається зі сховища до постановки в чергу, тож одночасні
+// запити не відправлять його двічі; якщо поставити в чергу не вдалося, запис
+// повертається. Якщо приймач знову відхилить повідомлення, воно буде збережене
+// як новий запис.
+func (a *App) ResubmitDeadLetter(id string) error {
+	if a.deadLetters == nil {
+		return deadletter.ErrDisabled
+	}
+	entry, err := a.deadLetters.Take(id)
+	if errors.Is(err, deadletter.ErrNotFound) {
+		return err
+	}
+	if err == nil {
+		err = a.enqueueDeadLetter(entry)
+	}
+	if err != nil {
+		if rerr := a.deadLetters.Restore(entry); rerr != nil {
+			a.logger.Error("Failed to restore dead letter", "id", id, "error", rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// enqueueDeadLetter ставить повідомлення запису в чергу його приймача
+func (a *App) enqueueDeadLetter(entry deadletter.Entry) error {
+	var dest queue.MessageQueue
+	for _, p := range a.pipelines {
+		if p.name == entry.Pipeline {
+			dest = p.destination(entry.Upstream)
+		}
+	}
+	if dest == nil {
+		return fmt.Errorf("upstream %s/%s no longer exists", entry.Pipeline, entry.Upstream)
+	}
+	// Повторна відправка отримує власний ідентифікатор кореляції
+	data := queue.SharedData{ID: "dl-" + entry.ID, Payload: entry.Payload, ReplyCh: make(chan queue.DeliveryData, 1)}
+	if !dest.Enqueue(data) {
+		return fmt.Errorf("queue of %s/%s is full", entry.Pipeline, entry.Upstream)
+	}
+
+	a.logger.Info("Dead letter resubmitted", "id", entry.ID, "pipeline", entry.Pipeline, "upstream", entry.Upstream, logging.CorrelationKey, data.ID)
+	return nil
+}
+
+// isPipelineSection повідомляє, чи належить секція конфігурації до конвеєрів
+func isPipelineSection(section string) bool {
+	switch section {
+	case "server", "client", "queue", "cidrules", "routing":
+		return true
+	}
+	return strings.HasPrefix(section, "pipelines")
+}
+
+// samePipelines повідомляє, чи збігаються набори конвеєрів (за іменами і порядком)
+func samePipelines(a, b []config.PipelineConfig) bool {
+	return slices.EqualFunc(a, b, func(x, y config.PipelineConfig) bool {
+		return x.Name == y.Name
+	})
+}
+
+// conditions збирає умови для сповіщень з усіх конвеєрів
+func (a *App) conditions() []notify.Condition {
+	cfg := a.Config()
+	now := time.Now()
+	var active []notify.Condition
+	for _, p := range a.pipelines {
+		active = append(active, p.conditions(now, cfg.Notify.QueueThreshold, cfg.Monitoring.PPKTimeout)...)
+	}
+	if a.incidents != nil {
+		for _, inc := range a.incidents.List(incident.StatusOpen) {
+			if inc.Escalated.IsZero() {
+				continue
+			}
+			active = append(active, notify.Condition{
+				Kind:     notify.ConditionIncidentEscalated,
+				Pipeline: inc.Pipeline,
+				Subject:  "incident " + inc.ID,
+				Message: fmt.Sprintf("%s on account %d, partition %d, zone %d is not acknowledged since %s",
+					inc.Code, inc.Account, inc.Partition, inc.Zone, inc.Opened.Format(time.RFC3339)),
+			})
+		}
+	}
+	return active
+}
+
+// fanIn об'єднує канали оновлень усіх конвеєрів у спільні канали для UI,
+// додаючи мітку конвеєра. Спільні канали закриваються після закриття всіх джерел.
+func (a *App) fanIn() {
+	a.deviceUpdates = make(chan server.Device, fanInBuffer)
+	a.eventUpdates = make(chan server.GlobalEvent, fanInBuffer)
+
+	var devicesWG, eventsWG sync.WaitGroup
+	for _, p := range a.pipelines {
+		devicesWG.Add(1)
+		go func() {
+			defer devicesWG.Done()
+			for d := range p.server.GetDeviceUpdatesChannel() {
+				a.deviceUpdates <- p.labelDevice(d)
+			}
+		}()
+
+		eventsWG.Add(1)
+		go func() {
+			defer eventsWG.Done()
+			for e := range p.server.GetEventUpdatesChannel() {
+				a.eventUpdates <- p.labelEvent(e)
+			}
+		}()
+	}
+
+	go func() {
+		devicesWG.Wait()
+		close(a.deviceUpdates)
+	}()
+	go func() {
+		eventsWG.Wait()
+		close(a.eventUpdates)
+	}()
+}
+
+// pipelineByLabel знаходить конвеєр за міткою (порожня мітка - неявний конвеєр)
+func (a *App) pipelineByLabel(label string) *pipeline {
+	for _, p := range a.pipelines {
+		if p.label == label {
+			return p
+		}
+	}
+	return nil
+}
+
+// GetDeviceUpdates повертає об'єднаний канал оновлень пристроїв
 func (a *App) GetDeviceUpdates() <-chan server.Device {
-	return a.tcpServer.GetDeviceUpdatesChannel()
+	return a.deviceUpdates
 }
 
-// GetEventUpdates повертає канал глобальних подій
+// GetEventUpdates повертає об'єднаний канал глобальних подій
 func (a *App) GetEventUpdates() <-chan server.GlobalEvent {
-	return a.tcpServer.GetEventUpdatesChannel()
+	return a.eventUpdates
 }
 
-// GetInitialDevices повертаєSnapshot всіх пристроїв (для початкового завантаження)
+// GetInitialDevices повертає Snapshot пристроїв усіх конвеєрів (для початкового завантаження)
 func (a *App) GetInitialDevices() []server.Device {
-	return a.tcpServer.GetDevices()
+	var devices []server.Device
+	for _, p := range a.pipelines {
+		for _, d := range p.server.GetDevices() {
+			devices = append(devices, p.labelDevice(d))
+		}
+	}
+	return devices
 }
 
-// GetInitialEvents повертає Snapshot всіх подій (для початкового завантаження)
+// GetInitialEvents повертає Snapshot подій усіх конвеєрів у хронологічному порядку
 func (a *App) GetInitialEvents() []server.GlobalEvent {
-	return a.tcpServer.GetGlobalEvents()
+	var events []server.GlobalEvent
+	for _, p := range a.pipelines {
+		for _, e := range p.server.GetGlobalEvents() {
+			events = append(events, p.labelEvent(e))
+		}
+	}
+	slices.SortStableFunc(events, func(x, y server.GlobalEvent) int {
+		return x.Time.Compare(y.Time)
+	})
+	return events
 }
 
-// GetDeviceEvents повертає події конкретного пристрою
-func (a *App) GetDeviceEvents(deviceID int) []server.Event {
-	return a.tcpServer.GetDeviceEvents(deviceID)
+// GetDeviceEvents повертає події пристрою конвеєра з міткою pipeline
+func (a *App) GetDeviceEvents(pipeline string, deviceID int) []server.Event {
+	p := a.pipelineByLabel(pipeline)
+	if p == nil {
+		return []server.Event{}
+	}
+	return p.server.GetDeviceEvents(deviceID)
 }
 
 // GetDeviceEventChannel повертає канал для нових подій пристрою
-func (a *App) GetDeviceEventChannel(deviceID int) <-chan server.Event {
-	return a.tcpServer.GetDeviceEventChannel(deviceID)
+func (a *App) GetDeviceEventChannel(pipeline string, deviceID int) <-chan server.Event {
+	p := a.pipelineByLabel(pipeline)
+	if p == nil {
+		return nil
+	}
+	return p.server.GetDeviceEventChannel(deviceID)
 }
 
 // CloseDeviceEventChannel закриває канал подій пристрою
-func (a *App) CloseDeviceEventChannel(deviceID int) {
-	a.tcpServer.CloseDeviceEventChannel(deviceID)
+func (a *App) CloseDeviceEventChannel(pipeline string, deviceID int) {
+	if p := a.pipelineByLabel(pipeline); p != nil {
+		p.server.CloseDeviceEventChannel(deviceID)
+	}
 }
 
 func (a *App) Greet(name string) string {
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/deadletter"
+	"cid_retranslator_walk/queue"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// holdingReceiver підтверджує перше повідомлення, а на друге не відповідає, доки
+// з'єднання не закриють. held закривається, коли друге повідомлення отримано.
+func holdingReceiver(t *testing.T) (addr *net.TCPAddr, held <-chan struct{}) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan struct{})
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		buf := make([]byte, 1024)
+		for n := 0; ; n++ {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+			if n == 0 {
+				conn.Write([]byte{0x06})
+				continue
+			}
+			if n == 1 {
+				close(ch)
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr), ch
+}
+
+func TestApp_ShutdownAccounting(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := config.LoadOrCreate(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, held := holdingReceiver(t)
+
+	pc := cfg.EffectivePipelines()[0]
+	pc.Server.Host, pc.Server.Port = "127.0.0.1", "0"
+	pc.Client.Host, pc.Client.Port = "127.0.0.1", fmt.Sprint(addr.Port)
+	pc.Queue.DrainTimeout = 100 * time.Millisecond
+	p := newPipeline(pc, false, func(string) bool { return false })
+
+	store, err := deadletter.Open(filepath.Join(dir, "deadletters.json"), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.setDeadLetters(store, false)
+
+	runCtx, stopRun := context.WithCancel(context.Background())
+	a := &App{
+		runCtx:      runCtx,
+		stopRun:     stopRun,
+		cancelfunc:  func() {},
+		logger:      slog.New(slog.DiscardHandler),
+		pipelines:   []*pipeline{p},
+		deadLetters: store,
+	}
+	p.run(runCtx, &a.wg, a.logger)
+
+	for i := range 5 {
+		data := queue.SharedData{
+			ID:      fmt.Sprint(i),
+			Payload: fmt.Appendf(nil, "5010 18123%dE13001001\x14", i),
+			ReplyCh: make(chan queue.DeliveryData, 1),
+		}
+		if !p.queue.Enqueue(data) {
+			t.Fatalf("message %d not queued", i)
+		}
+	}
+
+	select {
+	case <-held:
+	case <-time.After(5 * time.Second):
+		t.Fatal("receiver did not get the second message")
+	}
+
+	// Перше доставлено ще до зупинки, друге перервано зупинкою, решта лишилася в черзі
+	got := a.shutdown(context.Background())
+	want := shutdownReport{drained: false, spooled: 3, lost: 1}
+	if got != want {
+		t.Errorf("shutdown() = %+v, want %+v", got, want)
+	}
+	if store.Len() != 3 {
+		t.Errorf("dead letters = %d, want 3", store.Len())
+	}
+}
+
+func TestApp_ResubmitDeadLetter(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := config.LoadOrCreate(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc := cfg.EffectivePipelines()[0]
+	pc.Queue.Mode, pc.Queue.BufferSize = "fifo", 1
+	p := newPipeline(pc, false, func(string) bool { return false })
+
+	store, err := deadletter.Open(filepath.Join(dir, "deadletters.json"), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{logger: slog.New(slog.DiscardHandler), pipelines: []*pipeline{p}, deadLetters: store}
+
+	first, _ := store.Add(p.name, config.PrimaryUpstream, []byte("5010 181234E13001001\x14"), "nack", 1)
+	second, _ := store.Add(p.name, config.PrimaryUpstream, []byte("5010 181235E13001001\x14"), "nack", 1)
+
+	// Одночасні запити ставлять повідомлення в чергу лише один раз
+	var wg sync.WaitGroup
+	var ok atomic.Int32
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.ResubmitDeadLetter(first.ID); err == nil {
+				ok.Add(1)
+			} else if !errors.Is(err, deadletter.ErrNotFound) {
+				t.Errorf("ResubmitDeadLetter() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if ok.Load() != 1 || p.queue.Depth() != 1 {
+		t.Errorf("resubmitted %d times, queue depth %d, want 1 and 1", ok.Load(), p.queue.Depth())
+	}
+
+	// Черга заповнена: запис залишається у сховищі з тим самим ID
+	if err := a.ResubmitDeadLetter(second.ID); err == nil {
+		t.Fatal("ResubmitDeadLetter() succeeded with a full queue")
+	}
+	if e, err := store.Get(second.ID); err != nil || e.Attempts != 1 {
+		t.Errorf("entry after failed resubmit = %+v, %v", e, err)
+	}
+	if store.Len() != 1 {
+		t.Errorf("dead letters = %d, want 1", store.Len())
+	}
+}
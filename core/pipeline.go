@@ -0,0 +1,494 @@
+package core
+
+import (
+	"cid_retranslator_walk/api"
+	"cid_retranslator_walk/client"
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/deadletter"
+	"cid_retranslator_walk/logging"
+	"cid_retranslator_walk/metrics"
+	"cid_retranslator_walk/notify"
+	"cid_retranslator_walk/queue"
+	"cid_retranslator_walk/router"
+	"cid_retranslator_walk/server"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pipeline - незалежний ретранслятор: сервер, черга і клієнт зі своїми правилами та метриками
+type pipeline struct {
+	name   string
+	label  string // Мітка для UI; порожня, якщо конвеєр єдиний і неявний
+	cfgMu  sync.RWMutex
+	cfg    config.PipelineConfig // Поточна конфігурація, оновлюється в apply
+	stats  *metrics.Stats
+	queue  queue.MessageQueue
+	server *server.Server
+	client *client.Client
+
+	// Маршрутизація (nil/порожні, якщо правил і додаткових приймачів немає)
+	router    *router.Router
+	upstreams []*upstream
+}
+
+// upstream - додатковий приймач маршрутизації зі своєю чергою і метриками
+type upstream struct {
+	name   string
+	stats  *metrics.Stats
+	queue  queue.MessageQueue
+	client *client.Client
+}
+
+// newUpstream створює додатковий приймач; затримки, повтори і тест зв'язку беруться з клієнта конвеєра
+func newUpstream(uc config.UpstreamConfig, cfg config.PipelineConfig) *upstream {
+	size := uc.BufferSize
+	if size == 0 {
+		size = cfg.Queue.BufferSize
+	}
+	stats := metrics.New()
+	q := queue.New(size, stats)
+	return &upstream{
+		name:  uc.Name,
+		stats: stats,
+		queue: q,
+		client: client.New(&config.ClientConfig{
+			Host:             uc.Host,
+			Port:             uc.Port,
+			ReconnectInitial: cfg.Client.ReconnectInitial,
+			ReconnectMax:     cfg.Client.ReconnectMax,
+			RetryAttempts:    cfg.Client.RetryAttempts,
+			RetryBackoff:     cfg.Client.RetryBackoff,
+
+			HeartbeatInterval: cfg.Client.HeartbeatInterval,
+			HeartbeatFrame:    cfg.Client.HeartbeatFrame,
+			ReplayRate:        cfg.Client.ReplayRate,
+		}, q),
+	}
+}
+
+// newPipeline створює конвеєр з його конфігурації. isAlarm визначає тривоги
+// для пріоритетної черги.
+func newPipeline(cfg config.PipelineConfig, labelled bool, isAlarm func(code string) bool) *pipeline {
+	stats := metrics.New()
+	q, err := queue.NewFromConfig(&cfg.Queue, stats, isAlarm)
+	if err != nil {
+		slog.Error("Invalid queue configuration, falling back to FIFO", "pipeline", cfg.Name, "error", err)
+		q = queue.New(cfg.Queue.BufferSize, stats)
+	}
+
+	p := &pipeline{
+		name:   cfg.Name,
+		cfg:    cfg,
+		stats:  stats,
+		queue:  q,
+		client: client.New(&cfg.Client, q),
+	}
+
+	var enqueuer server.MessageEnqueuer = q
+	if len(cfg.Routing.Upstreams) > 0 || len(cfg.Routing.Routes) > 0 {
+		dests := map[string]queue.MessageQueue{config.PrimaryUpstream: q}
+		for _, uc := range cfg.Routing.Upstreams {
+			u := newUpstream(uc, cfg)
+			p.upstreams = append(p.upstreams, u)
+			dests[u.name] = u.queue
+		}
+		r, err := router.New(&cfg.Routing, dests, stats)
+		if err != nil {
+			slog.Error("Invalid routing configuration, sending everything to the primary upstream", "pipeline", cfg.Name, "error", err)
+		} else {
+			p.router = r
+			enqueuer = r
+		}
+	}
+	p.server = server.New(&cfg.Server, enqueuer, &cfg.CIDRules)
+
+	if labelled {
+		p.label = cfg.Name
+	}
+	return p
+}
+
+// setDeadLetters підключає сховище недоставлених повідомлень до всіх клієнтів конвеєра
+// (nil - вимкнути)
+func (p *pipeline) setDeadLetters(store *deadletter.Store, ackPanel bool) {
+	p.client.SetDeadLetter(p.deadLetterFunc(store, config.PrimaryUpstream), ackPanel)
+	for _, u := range p.upstreams {
+		u.client.SetDeadLetter(p.deadLetterFunc(store, u.name), ackPanel)
+	}
+}
+
+func (p *pipeline) deadLetterFunc(store *deadletter.Store, upstream string) client.DeadLetterFunc {
+	if store == nil {
+		return nil
+	}
+	return func(payload []byte, reason string, attempts int) error {
+		_, err := store.Add(p.name, upstream, payload, reason, attempts)
+		return err
+	}
+}
+
+// destination повертає чергу приймача за іменем (config.PrimaryUpstream - клієнт конвеєра)
+func (p *pipeline) destination(name string) queue.MessageQueue {
+	if name == config.PrimaryUpstream {
+		return p.queue
+	}
+	for _, u := range p.upstreams {
+		if u.name == name {
+			return u.queue
+		}
+	}
+	return nil
+}
+
+// upstreamClient повертає клієнт приймача за іменем (config.PrimaryUpstream - клієнт конвеєра)
+func (p *pipeline) upstreamClient(name string) *client.Client {
+	if name == config.PrimaryUpstream {
+		return p.client
+	}
+	for _, u := range p.upstreams {
+		if u.name == name {
+			return u.client
+		}
+	}
+	return nil
+}
+
+// run запускає сервер і клієнт конвеєра
+func (p *pipeline) run(ctx context.Context, wg *sync.WaitGroup, logger *slog.Logger) {
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("Panic in TCP server", "pipeline", p.name, "panic", r)
+			}
+		}()
+		p.server.Run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Error("Panic in TCP client", "pipeline", p.name, "panic", r)
+			}
+		}()
+		p.client.Run(ctx)
+	}()
+
+	for _, u := range p.upstreams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error("Panic in upstream client", "pipeline", p.name, "upstream", u.name, "panic", r)
+				}
+			}()
+			u.client.Run(ctx)
+		}()
+	}
+}
+
+// stop зупиняє сервер і клієнти конвеєра
+func (p *pipeline) stop() {
+	p.server.Stop()
+	p.client.Stop()
+	for _, u := range p.upstreams {
+		u.client.Stop()
+	}
+}
+
+// drainPoll - як часто під час зупинки перевіряється, чи спорожніли черги
+const drainPoll = 50 * time.Millisecond
+
+// shutdownReport - підсумок зупинки конвеєра
+type shutdownReport struct {
+	delivered int64 // Підтверджено приймачами під час зупинки
+	rejected  int64 // Відхилено приймачами під час зупинки
+	drained   bool  // Черги спорожніли до таймауту
+	spooled   int   // Збережено у сховищі недоставлених
+	lost      int   // Не доставлено і не збережено (включно з перерваними доставками)
+}
+
+// counters повертає сумарні лічильники підтверджених, відхилених і перерваних
+// доставок усіх приймачів
+func (p *pipeline) counters() (accepted, rejected, dropped int64) {
+	snap := p.stats.Snapshot()
+	accepted, rejected, dropped = snap.Accepted, snap.Rejected, p.client.Dropped()
+	for _, u := range p.upstreams {
+		snap := u.stats.Snapshot()
+		accepted += snap.Accepted
+		rejected += snap.Rejected
+		dropped += u.client.Dropped()
+	}
+	return accepted, rejected, dropped
+}
+
+// idle повідомляє, чи порожні всі черги конвеєра і чи не доставляють клієнти повідомлень
+func (p *pipeline) idle() bool {
+	if p.queue.Depth() > 0 || p.client.Busy() {
+		return false
+	}
+	for _, u := range p.upstreams {
+		if u.queue.Depth() > 0 || u.client.Busy() {
+			return false
+		}
+	}
+	return true
+}
+
+// drain чекає, поки клієнти доставлять повідомлення з черг, але не довше за
+// queue.draintimeout або до скасування ctx. Повертає true, якщо черги спорожніли.
+func (p *pipeline) drain(ctx context.Context) bool {
+	p.cfgMu.RLock()
+	timeout := p.cfg.Queue.DrainTimeout
+	p.cfgMu.RUnlock()
+
+	deadline := time.After(timeout)
+	settled := 0
+	for {
+		// Двічі поспіль: повідомлення могло бути між чергою і клієнтом
+		if p.idle() {
+			settled++
+		} else {
+			settled = 0
+		}
+		if settled >= 2 {
+			return true
+		}
+
+		select {
+		case <-time.After(drainPoll):
+		case <-deadline:
+			return p.idle()
+		case <-ctx.Done():
+			return p.idle()
+		}
+	}
+}
+
+// spool закриває черги конвеєра після зупинки клієнтів і забирає невидані
+// повідомлення: зберігає їх у сховищі недоставлених (якщо воно є) або рахує як втрачені
+func (p *pipeline) spool(store *deadletter.Store, report *shutdownReport) {
+	spoolQueue := func(name string, q queue.MessageQueue) {
+		q.Close()
+		for _, data := range q.Drain() {
+			// Сесія панелі вже закрита, але роутер може чекати на відповідь копії
+			select {
+			case data.ReplyCh <- queue.DeliveryData{Status: false}:
+			default:
+			}
+
+			if store != nil {
+				_, err := store.Add(p.name, name, data.Payload, "undelivered at shutdown", 0)
+				if err == nil {
+					report.spooled++
+					continue
+				}
+				slog.Error("Failed to spool message", "pipeline", p.name, "upstream", name, "error", err, logging.CorrelationKey, data.ID)
+			}
+			report.lost++
+		}
+	}
+
+	spoolQueue(config.PrimaryUpstream, p.queue)
+	for _, u := range p.upstreams {
+		spoolQueue(u.name, u.queue)
+	}
+}
+
+// apply застосовує зміни конфігурації конвеєра на льоту. prefix додається до шляхів
+// полів у звіті. Якщо сервер не вдалося перенести на нову адресу, у newCfg
+// повертається стара адреса.
+func (p *pipeline) apply(oldCfg config.PipelineConfig, newCfg *config.PipelineConfig, prefix string, report *config.ReloadReport) {
+	var rulesChanged, targetChanged, backoffChanged, retryChanged, heartbeatChanged, replayChanged, listenChanged, routesChanged bool
+	for _, ch := range config.DiffPipeline(oldCfg, *newCfg) {
+		section, _, _ := strings.Cut(ch.Path, ".")
+		switch {
+		case section == "cidrules":
+			rulesChanged = true
+		case section == "routing" && p.router != nil && !strings.HasPrefix(ch.Path, "routing.upstreams"):
+			// Правила і політику ACK можна замінити на льоту; набір приймачів - лише з перезапуском
+			routesChanged = true
+		case ch.Path == "client.host" || ch.Path == "client.port":
+			targetChanged = true
+		case ch.Path == "client.reconnectinitial" || ch.Path == "client.reconnectmax":
+			backoffChanged = true
+		case ch.Path == "client.retryattempts" || ch.Path == "client.retrybackoff":
+			retryChanged = true
+		case ch.Path == "client.heartbeatinterval" || ch.Path == "client.heartbeatframe":
+			heartbeatChanged = true
+		case ch.Path == "client.replayrate":
+			replayChanged = true
+		case ch.Path == "server.host" || ch.Path == "server.port":
+			listenChanged = true
+		case ch.Path == "queue.draintimeout":
+			// Читається з p.cfg під час зупинки
+		default:
+			report.RestartRequired = append(report.RestartRequired, prefix+ch.Path)
+			continue
+		}
+		report.Applied = append(report.Applied, prefix+ch.Path)
+	}
+
+	if rulesChanged {
+		rules := newCfg.CIDRules
+		p.server.SetRules(&rules)
+	}
+	if backoffChanged {
+		p.client.SetBackoff(newCfg.Client.ReconnectInitial, newCfg.Client.ReconnectMax)
+		for _, u := range p.upstreams {
+			u.client.SetBackoff(newCfg.Client.ReconnectInitial, newCfg.Client.ReconnectMax)
+		}
+	}
+	if retryChanged {
+		p.client.SetRetry(newCfg.Client.RetryAttempts, newCfg.Client.RetryBackoff)
+		for _, u := range p.upstreams {
+			u.client.SetRetry(newCfg.Client.RetryAttempts, newCfg.Client.RetryBackoff)
+		}
+	}
+	if heartbeatChanged {
+		p.client.SetHeartbeat(newCfg.Client.HeartbeatInterval, newCfg.Client.HeartbeatFrame)
+		for _, u := range p.upstreams {
+			u.client.SetHeartbeat(newCfg.Client.HeartbeatInterval, newCfg.Client.HeartbeatFrame)
+		}
+	}
+	if replayChanged {
+		p.client.SetReplayRate(newCfg.Client.ReplayRate)
+		for _, u := range p.upstreams {
+			u.client.SetReplayRate(newCfg.Client.ReplayRate)
+		}
+	}
+	if routesChanged {
+		if err := p.router.SetRoutes(&newCfg.Routing); err != nil {
+			slog.Error("Failed to apply routing rules", "pipeline", p.name, "error", err)
+			report.Applied = slices.DeleteFunc(report.Applied, func(path string) bool {
+				return strings.HasPrefix(path, prefix+"routing.")
+			})
+			report.Errors = append(report.Errors, fmt.Sprintf("%srouting: %v", prefix, err))
+		}
+	}
+	if targetChanged {
+		p.client.SetTarget(newCfg.Client.Host, newCfg.Client.Port)
+	}
+	if listenChanged {
+		if err := p.server.Rebind(newCfg.Server.Host, newCfg.Server.Port); err != nil {
+			slog.Error("Failed to rebind server", "pipeline", p.name, "error", err)
+			report.Applied = slices.DeleteFunc(report.Applied, func(path string) bool {
+				return path == prefix+"server.host" || path == prefix+"server.port"
+			})
+			report.Errors = append(report.Errors, fmt.Sprintf("%sserver: %v", prefix, err))
+			// Залишаємо стару адресу, щоб наступне перезавантаження спробувало знову
+			newCfg.Server.Host, newCfg.Server.Port = oldCfg.Server.Host, oldCfg.Server.Port
+		}
+	}
+	p.cfgMu.Lock()
+	p.cfg = *newCfg
+	p.cfgMu.Unlock()
+}
+
+// labelDevice додає мітку конвеєра до оновлення пристрою
+func (p *pipeline) labelDevice(d server.Device) server.Device {
+	d.Pipeline = p.label
+	return d
+}
+
+// labelEvent додає мітку конвеєра до глобальної події
+func (p *pipeline) labelEvent(e server.GlobalEvent) server.GlobalEvent {
+	e.Pipeline = p.label
+	return e
+}
+
+// status повертає стан конвеєра для API
+func (p *pipeline) status() api.PipelineStatus {
+	p.cfgMu.RLock()
+	defer p.cfgMu.RUnlock()
+	status := api.PipelineStatus{
+		Name:     p.name,
+		Listen:   net.JoinHostPort(p.cfg.Server.Host, p.cfg.Server.Port),
+		Upstream: net.JoinHostPort(p.cfg.Client.Host, p.cfg.Client.Port),
+		Devices:  len(p.server.GetDevices()),
+		Stats:    p.stats.Snapshot(),
+	}
+	for _, u := range p.upstreams {
+		if status.Upstreams == nil {
+			status.Upstreams = make(map[string]metrics.Snapshot)
+		}
+		status.Upstreams[u.name] = u.stats.Snapshot()
+	}
+	return status
+}
+
+// backlog повертає черги основного і додаткових приймачів конвеєра
+func (p *pipeline) backlog(now time.Time) []api.Backlog {
+	p.cfgMu.RLock()
+	rate := p.cfg.Client.ReplayRate
+	p.cfgMu.RUnlock()
+
+	entry := func(name string, stats *metrics.Stats, q queue.MessageQueue, c *client.Client) api.Backlog {
+		b := api.Backlog{
+			Pipeline:   p.name,
+			Upstream:   name,
+			Backlog:    q.Backlog(),
+			Connected:  stats.IsConnected(),
+			Paused:     c.Paused(),
+			ReplayRate: rate,
+		}
+		if !b.Oldest.IsZero() {
+			b.OldestAge = now.Sub(b.Oldest)
+		}
+		return b
+	}
+	backlog := []api.Backlog{entry(config.PrimaryUpstream, p.stats, p.queue, p.client)}
+	for _, u := range p.upstreams {
+		backlog = append(backlog, entry(u.name, u.stats, u.queue, u.client))
+	}
+	return backlog
+}
+
+// conditions повертає активні умови для сповіщень: приймачі без з'єднання,
+// черги, заповнені не менше ніж на threshold, і пристрої без подій довше за silence
+func (p *pipeline) conditions(now time.Time, threshold float64, silence time.Duration) []notify.Condition {
+	p.cfgMu.RLock()
+	addrs := map[string]string{config.PrimaryUpstream: net.JoinHostPort(p.cfg.Client.Host, p.cfg.Client.Port)}
+	for _, uc := range p.cfg.Routing.Upstreams {
+		addrs[uc.Name] = net.JoinHostPort(uc.Host, uc.Port)
+	}
+	p.cfgMu.RUnlock()
+
+	var active []notify.Condition
+	check := func(name string, stats *metrics.Stats, q queue.MessageQueue) {
+		if !stats.Snapshot().Connected {
+			active = append(active, notify.Condition{
+				Kind: notify.ConditionLinkDown, Pipeline: p.name, Subject: name,
+				Message: fmt.Sprintf("upstream %s (%s) is disconnected", name, addrs[name]),
+			})
+		}
+		if depth, capacity := q.Depth(), q.Capacity(); capacity > 0 && float64(depth) >= threshold*float64(capacity) {
+			active = append(active, notify.Condition{
+				Kind: notify.ConditionQueueHigh, Pipeline: p.name, Subject: name,
+				Message: fmt.Sprintf("queue for %s holds %d of %d messages", name, depth, capacity),
+			})
+		}
+	}
+	check(config.PrimaryUpstream, p.stats, p.queue)
+	for _, u := range p.upstreams {
+		check(u.name, u.stats, u.queue)
+	}
+
+	for _, d := range p.server.GetDevices() {
+		if idle := now.Sub(d.LastEventTime); idle > silence {
+			active = append(active, notify.Condition{
+				Kind: notify.ConditionDeviceSilent, Pipeline: p.name, Subject: fmt.Sprintf("device %d", d.ID),
+				Message: fmt.Sprintf("device %d sent no events for %s (last: %s)", d.ID, idle.Round(time.Second), d.LastEvent),
+			})
+		}
+	}
+	return active
+}
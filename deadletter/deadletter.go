@@ -0,0 +1,230 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound повертається, якщо запису з таким ID немає
+	ErrNotFound = errors.New("dead letter not found")
+	// ErrDisabled повертається, якщо сховище вимкнене в конфігурації
+	ErrDisabled = errors.New("dead letter store is disabled")
+)
+
+// Entry - повідомлення, яке приймач відхилив після всіх спроб
+type Entry struct {
+	ID       string    `json:"id"`
+	Pipeline string    `json:"pipeline"`
+	Upstream string    `json:"upstream"`
+	Payload  []byte    `json:"payload"` // Сирі байти кадру (у JSON - base64)
+	Text     string    `json:"text"`    // Кадр як текст, для зручності перегляду
+	Reason   string    `json:"reason"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failedAt"`
+	EditedAt time.Time `json:"editedAt,omitzero"`
+}
+
+// Store - сховище недоставлених повідомлень з опційним збереженням у JSON файл
+type Store struct {
+	mu         sync.Mutex
+	path       string // Порожній - лише в пам'яті
+	maxEntries int
+	entries    []*Entry
+	nextID     int
+	now        func() time.Time
+}
+
+// Open створює сховище і завантажує записи з path (якщо файл існує).
+// Якщо записів більше за maxEntries, найстаріші видаляються.
+func Open(path string, maxEntries int) (*Store, error) {
+	s := &Store{path: path, maxEntries: maxEntries, nextID: 1, now: time.Now}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.entries); err != nil {
+		return nil, fmt.Errorf("dead letters %s: %w", path, err)
+	}
+	for _, e := range s.entries {
+		if id, err := strconv.Atoi(e.ID); err == nil && id >= s.nextID {
+			s.nextID = id + 1
+		}
+	}
+	return s, nil
+}
+
+// Add зберігає повідомлення і повертає запис. Панель повторює відхилений кадр,
+// тому той самий кадр для того самого приймача не додається вдруге: існуючий
+// запис переноситься в кінець, спроби додаються, час і причина оновлюються.
+func (s *Store) Add(pipeline, upstream string, payload []byte, reason string, attempts int) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.entries, func(e *Entry) bool {
+		return e.Pipeline == pipeline && e.Upstream == upstream && slices.Equal(e.Payload, payload)
+	})
+	if i >= 0 {
+		e := s.entries[i]
+		e.Reason = reason
+		e.Attempts += attempts
+		e.FailedAt = s.now()
+		s.entries = append(slices.Delete(s.entries, i, i+1), e)
+		return *e, s.save()
+	}
+
+	e := &Entry{
+		ID:       strconv.Itoa(s.nextID),
+		Pipeline: pipeline,
+		Upstream: upstream,
+		Payload:  slices.Clone(payload),
+		Text:     string(payload),
+		Reason:   reason,
+		Attempts: attempts,
+		FailedAt: s.now(),
+	}
+	s.nextID++
+	s.entries = append(s.entries, e)
+	if s.maxEntries > 0 && len(s.entries) > s.maxEntries {
+		s.entries = s.entries[len(s.entries)-s.maxEntries:]
+	}
+	return *e, s.save()
+}
+
+// List повертає копії всіх записів, від найстарішого
+func (s *Store) List() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Entry, len(s.entries))
+	for i, e := range s.entries {
+		list[i] = *e
+	}
+	return list
+}
+
+// Get повертає запис за ID
+func (s *Store) Get(id string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.find(id)
+	if e == nil {
+		return Entry{}, ErrNotFound
+	}
+	return *e, nil
+}
+
+// Update замінює сирі байти запису (наприклад, щоб виправити номер об'єкта перед повторною відправкою)
+func (s *Store) Update(id string, payload []byte) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.find(id)
+	if e == nil {
+		return Entry{}, ErrNotFound
+	}
+	e.Payload = slices.Clone(payload)
+	e.Text = string(payload)
+	e.EditedAt = s.now()
+	return *e, s.save()
+}
+
+// Take видаляє запис і повертає його (для повторної відправки)
+func (s *Store) Take(id string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.entries, func(e *Entry) bool { return e.ID == id })
+	if i < 0 {
+		return Entry{}, ErrNotFound
+	}
+	e := s.entries[i]
+	s.entries = slices.Delete(s.entries, i, i+1)
+	return *e, s.save()
+}
+
+// Restore повертає у сховище запис, забраний Take, з тим самим ID, на місце
+// за часом помилки (якщо повторна відправка не вдалася)
+func (s *Store) Restore(e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.find(e.ID) != nil {
+		return nil
+	}
+	i := slices.IndexFunc(s.entries, func(x *Entry) bool { return x.FailedAt.After(e.FailedAt) })
+	if i < 0 {
+		i = len(s.entries)
+	}
+	s.entries = slices.Insert(s.entries, i, &e)
+	return s.save()
+}
+
+// Delete видаляє запис
+func (s *Store) Delete(id string) error {
+	_, err := s.Take(id)
+	return err
+}
+
+// Purge видаляє всі записи і повертає їх кількість
+func (s *Store) Purge() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.entries)
+	s.entries = nil
+	return n, s.save()
+}
+
+// Len повертає кількість записів
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+func (s *Store) find(id string) *Entry {
+	for _, e := range s.entries {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// save атомарно записує записи у файл (виклик під s.mu)
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,129 @@
+package deadletter
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStore_Lifecycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq", "deadletters.json")
+	s, err := Open(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := s.Add("default", "primary", []byte("5010 181234E13001005\x14"), "NACK after 3 attempts", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := s.Add("north", "fire", []byte("5010 181234E11001005\x14"), "NACK after 3 attempts", 3)
+	if first.ID == second.ID {
+		t.Fatal("IDs must be unique")
+	}
+
+	edited, err := s.Update(first.ID, []byte("5010 189999E13001005\x14"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Text != "5010 189999E13001005\x14" || edited.EditedAt.IsZero() {
+		t.Errorf("unexpected edited entry: %+v", edited)
+	}
+
+	// Записи переживають перезапуск
+	reopened, err := Open(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.Get(first.ID); err != nil || string(got.Payload) != "5010 189999E13001005\x14" {
+		t.Errorf("reopened Get() = %+v, %v", got, err)
+	}
+	third, _ := reopened.Add("default", "primary", []byte("x"), "r", 1)
+	if third.ID == first.ID || third.ID == second.ID {
+		t.Errorf("ID %s reused after reopen", third.ID)
+	}
+
+	taken, err := reopened.Take(second.ID)
+	if err != nil || taken.Upstream != "fire" {
+		t.Errorf("Take() = %+v, %v", taken, err)
+	}
+	if _, err := reopened.Get(second.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("taken entry still present: %v", err)
+	}
+	if err := reopened.Delete("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete(unknown) = %v", err)
+	}
+
+	n, err := reopened.Purge()
+	if err != nil || n != 2 || reopened.Len() != 0 {
+		t.Errorf("Purge() = %d, %v; len %d", n, err, reopened.Len())
+	}
+}
+
+func TestStore_MaxEntries(t *testing.T) {
+	s, _ := Open("", 2)
+	for _, p := range []string{"a", "b", "c"} {
+		s.Add("default", "primary", []byte(p), "NACK", 1)
+	}
+
+	list := s.List()
+	if len(list) != 2 || list[0].Text != "b" || list[1].Text != "c" {
+		t.Errorf("expected the two newest entries, got %+v", list)
+	}
+}
+
+// Повтор панелі після NACK оновлює існуючий запис замість нового
+func TestStore_AddRetransmit(t *testing.T) {
+	s, _ := Open("", 10)
+	frame := []byte("5010 181234E13001005\x14")
+	first, _ := s.Add("default", "primary", frame, "NACK after 3 attempt(s)", 3)
+	again, err := s.Add("default", "primary", frame, "NACK after 2 attempt(s)", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 1 || again.ID != first.ID || again.Attempts != 5 || again.Reason != "NACK after 2 attempt(s)" {
+		t.Errorf("Add() = %+v, len %d", again, s.Len())
+	}
+
+	s.Add("default", "primary", []byte("other"), "NACK", 1)
+	s.Add("default", "primary", frame, "NACK", 1)
+	if list := s.List(); len(list) != 2 || list[1].ID != first.ID {
+		t.Errorf("retransmitted entry not moved to the end: %+v", list)
+	}
+	// Той самий кадр для іншого приймача - окремий запис
+	if other, _ := s.Add("default", "fire", frame, "NACK", 1); other.ID == first.ID {
+		t.Error("entries of different upstreams merged")
+	}
+}
+
+// Запис, повернутий після невдалої повторної відправки, зберігає ID і місце
+func TestStore_Restore(t *testing.T) {
+	s, _ := Open("", 10)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { now = now.Add(time.Second); return now }
+	for _, p := range []string{"a", "b", "c"} {
+		s.Add("default", "primary", []byte(p), "NACK", 1)
+	}
+
+	taken, err := s.Take("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Restore(taken); err != nil {
+		t.Fatal(err)
+	}
+	s.Restore(taken) // Повторне повернення нічого не змінює
+
+	var ids []string
+	for _, e := range s.List() {
+		ids = append(ids, e.ID)
+	}
+	if !slices.Equal(ids, []string{"1", "2", "3"}) {
+		t.Errorf("IDs after Restore = %v, want [1 2 3]", ids)
+	}
+	if e, _ := s.Add("default", "primary", []byte("d"), "NACK", 1); e.ID != "4" {
+		t.Errorf("next ID = %s, want 4", e.ID)
+	}
+}
@@ -0,0 +1,145 @@
+package dedup
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Cache - обмежений кеш з часом життя записів для придушення повторних кадрів.
+// Безпечний для одночасного використання з багатьох з'єднань.
+type Cache struct {
+	mu         sync.Mutex
+	window     time.Duration
+	maxEntries int
+	entries    map[string]*Entry
+	order      *list.List // від найстаршого до найновішого
+	now        func() time.Time
+}
+
+// Entry - запис про оригінальний кадр та його результат доставки
+type Entry struct {
+	key     string
+	created time.Time
+	elem    *list.Element
+	done    chan struct{}
+	status  bool
+}
+
+// New створює кеш з вікном window та максимумом maxEntries записів
+func New(window time.Duration, maxEntries int) *Cache {
+	if maxEntries <= 0 {
+		maxEntries = 1
+	}
+	return &Cache{
+		window:     window,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*Entry),
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+// Key формує ключ з номера акаунту та нормалізованого вмісту повідомлення
+func Key(account int, payload []byte) string {
+	end := len(payload)
+	for end > 0 && (payload[end-1] == 0x14 || payload[end-1] == '\r' || payload[end-1] == '\n') {
+		end--
+	}
+	return strconv.Itoa(account) + "|" + string(payload[:end])
+}
+
+// Begin реєструє кадр. Якщо такий самий кадр вже є у вікні, повертає його запис
+// і duplicate=true. Інакше створює новий запис, який треба завершити через
+// Complete або Forget.
+func (c *Cache) Begin(key string) (entry *Entry, duplicate bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.expire(now)
+
+	if e, ok := c.entries[key]; ok {
+		return e, true
+	}
+
+	e := &Entry{
+		key:     key,
+		created: now,
+		done:    make(chan struct{}),
+	}
+	e.elem = c.order.PushBack(e)
+	c.entries[key] = e
+
+	for len(c.entries) > c.maxEntries {
+		c.remove(c.order.Front().Value.(*Entry))
+	}
+
+	return e, false
+}
+
+// Complete фіксує результат доставки оригінального кадру і будить дублікати, що чекають
+func (c *Cache) Complete(e *Entry, status bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-e.done:
+		return
+	default:
+	}
+	e.status = status
+	close(e.done)
+}
+
+// Forget видаляє запис, щоб наступна ретрансляція пройшла як новий кадр.
+// Дублікати, що вже чекають, отримують NACK.
+func (c *Cache) Forget(e *Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cur, ok := c.entries[e.key]; ok && cur == e {
+		c.remove(e)
+	}
+	select {
+	case <-e.done:
+	default:
+		e.status = false
+		close(e.done)
+	}
+}
+
+// Len повертає кількість записів у кеші
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Wait чекає результат оригінального кадру. ok=false, якщо результат не надійшов за timeout.
+func (e *Entry) Wait(timeout time.Duration) (status bool, ok bool) {
+	select {
+	case <-e.done:
+		return e.status, true
+	case <-time.After(timeout):
+		return false, false
+	}
+}
+
+// expire видаляє записи, старші за вікно (викликати під локом)
+func (c *Cache) expire(now time.Time) {
+	for front := c.order.Front(); front != nil; front = c.order.Front() {
+		e := front.Value.(*Entry)
+		if now.Sub(e.created) < c.window {
+			return
+		}
+		c.remove(e)
+	}
+}
+
+// remove видаляє запис з мапи та списку (викликати під локом)
+func (c *Cache) remove(e *Entry) {
+	c.order.Remove(e.elem)
+	delete(c.entries, e.key)
+}
@@ -0,0 +1,131 @@
+package dedup
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	a := Key(2100, []byte("5010 182100R57516331\x14"))
+	b := Key(2100, []byte("5010 182100R57516331"))
+	if a != b {
+		t.Errorf("Key() should ignore terminator: %q != %q", a, b)
+	}
+
+	if Key(2100, []byte("x")) == Key(2101, []byte("x")) {
+		t.Error("Key() should differ for different accounts")
+	}
+}
+
+func TestCache_BeginDuplicate(t *testing.T) {
+	c := New(time.Minute, 10)
+
+	first, dup := c.Begin("k")
+	if dup {
+		t.Fatal("first Begin() should not be a duplicate")
+	}
+
+	second, dup := c.Begin("k")
+	if !dup {
+		t.Fatal("second Begin() should be a duplicate")
+	}
+	if second != first {
+		t.Error("duplicate should return the original entry")
+	}
+
+	c.Complete(first, true)
+	status, ok := second.Wait(time.Second)
+	if !ok || !status {
+		t.Errorf("Wait() = (%v, %v), want (true, true)", status, ok)
+	}
+}
+
+func TestCache_WaitPending(t *testing.T) {
+	c := New(time.Minute, 10)
+	first, _ := c.Begin("k")
+	dupEntry, _ := c.Begin("k")
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		c.Complete(first, false)
+	}()
+
+	status, ok := dupEntry.Wait(time.Second)
+	if !ok || status {
+		t.Errorf("Wait() = (%v, %v), want (false, true)", status, ok)
+	}
+
+	pending, _ := c.Begin("other")
+	if _, ok := pending.Wait(10 * time.Millisecond); ok {
+		t.Error("Wait() on pending entry should time out")
+	}
+}
+
+func TestCache_Expire(t *testing.T) {
+	c := New(time.Second, 10)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	e, _ := c.Begin("k")
+	c.Complete(e, true)
+
+	now = now.Add(2 * time.Second)
+	if _, dup := c.Begin("k"); dup {
+		t.Error("entry should expire after the window")
+	}
+}
+
+func TestCache_Forget(t *testing.T) {
+	c := New(time.Minute, 10)
+	e, _ := c.Begin("k")
+	c.Forget(e)
+
+	if _, ok := e.Wait(10 * time.Millisecond); !ok {
+		t.Error("Forget() should release waiters")
+	}
+	if _, dup := c.Begin("k"); dup {
+		t.Error("forgotten entry should not be treated as duplicate")
+	}
+}
+
+func TestCache_MaxEntries(t *testing.T) {
+	c := New(time.Minute, 3)
+	for i := 0; i < 5; i++ {
+		c.Begin(fmt.Sprint(i))
+	}
+
+	if c.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", c.Len())
+	}
+	if _, dup := c.Begin("0"); dup {
+		t.Error("oldest entry should have been evicted")
+	}
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	c := New(time.Minute, 100)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	originals := 0
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e, dup := c.Begin("same")
+			if !dup {
+				mu.Lock()
+				originals++
+				mu.Unlock()
+				c.Complete(e, true)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if originals != 1 {
+		t.Errorf("expected exactly one original, got %d", originals)
+	}
+}
@@ -0,0 +1,403 @@
+// Package eventdict - словник кодів Contact ID: тип і опис події кількома
+// мовами та класифікація коду (категорія, важливість, код відновлення).
+// Словник складається з файлу, що постачається з програмою (events.json), і
+// файлу користувача з тим самим форматом, записи якого накладаються поверх
+// постачених поле за полем. Зміни через API пишуться лише
+// у файл користувача, тож оновлення програми їх не затирає.
+package eventdict
+
+import (
+	"cid_retranslator_walk/cidparser"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+// Мови словника (config.EventLanguages); постачений файл містить тексти всіма мовами
+const (
+	LangUK = "uk"
+	LangEN = "en"
+	LangRU = "ru"
+)
+
+// Languages перелічує мови словника
+var Languages = []string{LangUK, LangEN, LangRU}
+
+// Походження запису
+const (
+	SourceShipped  = "shipped"  // Лише з постаченого файлу
+	SourceOverride = "override" // Постачений запис, змінений користувачем
+	SourceCustom   = "custom"   // Код, якого немає в постаченому файлі
+)
+
+// Категорії кодів
+const (
+	CategoryAlarm   = "alarm"   // Тривога
+	CategoryRestore = "restore" // Відновлення після тривоги чи несправності
+	CategoryArm     = "arm"     // Постановка під охорону
+	CategoryDisarm  = "disarm"  // Зняття з охорони
+	CategoryOther   = "other"   // Несправності, тести та інші службові події
+)
+
+// Categories перелічує категорії кодів
+var Categories = []string{CategoryAlarm, CategoryRestore, CategoryArm, CategoryDisarm, CategoryOther}
+
+// Важливість кодів від найвищої до найнижчої
+const (
+	SeverityCritical = "critical"
+	SeverityMajor    = "major"
+	SeverityMinor    = "minor"
+	SeverityInfo     = "info"
+)
+
+// Severities перелічує рівні важливості від найвищого
+var Severities = []string{SeverityCritical, SeverityMajor, SeverityMinor, SeverityInfo}
+
+// ErrNotFound повертається, якщо для коду немає запису користувача
+var ErrNotFound = errors.New("no user entry for this code")
+
+// unknownCode - тип події для кодів, яких немає у словнику
+var unknownCode = map[string]string{
+	LangUK: "Невідомий код %s",
+	LangEN: "Unknown code %s",
+	LangRU: "Неизвестный код %s",
+}
+
+var codeRe = regexp.MustCompile(`^[ER]\d{3}$`)
+
+// Class - класифікація коду
+type Class struct {
+	Category string `json:"category,omitempty"` // Одна з Categories
+	Severity string `json:"severity,omitempty"` // Одна з Severities
+	Restore  string `json:"restore,omitempty"`  // Код, що завершує стан, відкритий цим кодом (R130 для E130)
+}
+
+// Entry - запис словника з текстами всіма мовами, для яких вони є
+type Entry struct {
+	Code        string            `json:"code"`
+	Type        map[string]string `json:"type"`        // Мова → тип події
+	Description map[string]string `json:"description"` // Мова → опис коду
+	Class
+	Source string `json:"source,omitempty"`
+}
+
+// Dictionary - словник кодів. Безпечний для одночасного використання.
+type Dictionary struct {
+	path      string
+	overrides string
+
+	mu       sync.RWMutex
+	lang     string
+	version  string // Версія постаченого файлу
+	revision int    // Лічильник змін файлу користувача
+	shipped  cidparser.EventMap
+	local    cidparser.EventMap
+}
+
+// New створює порожній словник; записи з файлів path і overrides завантажує Reload.
+// lang - мова описів (одна з Languages).
+func New(path, overrides, lang string) *Dictionary {
+	return &Dictionary{
+		path:      path,
+		overrides: overrides,
+		lang:      lang,
+		shipped:   cidparser.EventMap{},
+		local:     cidparser.EventMap{},
+	}
+}
+
+// Reload перечитує обидва файли. Файл користувача може бути відсутній.
+// При помилці словник залишається без змін.
+func (d *Dictionary) Reload() error {
+	shipped, file, err := load(d.path)
+	if err != nil {
+		return err
+	}
+	local, localFile, err := load(d.overrides)
+	if errors.Is(err, os.ErrNotExist) {
+		local, err = cidparser.EventMap{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.shipped, d.local = shipped, local
+	d.version, d.revision = file.Version, localFile.Revision
+	d.mu.Unlock()
+	return nil
+}
+
+func load(path string) (cidparser.EventMap, cidparser.EventFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, cidparser.EventFile{}, err
+	}
+	events, file, err := cidparser.LoadEventFile(data)
+	if err != nil {
+		return nil, cidparser.EventFile{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return events, file, nil
+}
+
+// Len повертає кількість кодів у словнику
+func (d *Dictionary) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	n := len(d.shipped)
+	for code := range d.local {
+		if _, ok := d.shipped[code]; !ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Version повертає версію постаченого файлу ("" для файлу без версії) і
+// кількість змін файлу користувача
+func (d *Dictionary) Version() (version string, revision int) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.version, d.revision
+}
+
+// Language повертає поточну мову описів
+func (d *Dictionary) Language() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.lang
+}
+
+// SetLanguage змінює мову описів
+func (d *Dictionary) SetLanguage(lang string) {
+	d.mu.Lock()
+	d.lang = lang
+	d.mu.Unlock()
+}
+
+// Describe повертає тип і опис коду поточною мовою. Відсутній переклад
+// замінюється українським текстом, а невідомий код - типом "Невідомий код E123"
+// з порожнім описом; known показує, чи є код у словнику.
+func (d *Dictionary) Describe(code string) (typeDesc, codeDesc string, known bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	e, ok := d.entry(code)
+	if !ok {
+		format, ok := unknownCode[d.lang]
+		if !ok {
+			format = unknownCode[LangUK]
+		}
+		return fmt.Sprintf(format, code), "", false
+	}
+	return text(e.Type, d.lang), text(e.Description, d.lang), true
+}
+
+// GetEventDescriptions повертає тип і опис коду поточною мовою або found=false
+// для невідомого коду. Сумісний з cidparser.EventMap для пересилання подій.
+func (d *Dictionary) GetEventDescriptions(code string) (typeDesc, codeDesc string, found bool) {
+	typeDesc, codeDesc, found = d.Describe(code)
+	if !found {
+		return "", "", false
+	}
+	return typeDesc, codeDesc, true
+}
+
+// Classify повертає класифікацію коду; ok=false, якщо коду немає у словнику.
+// Пошук точний: категорія не виводиться з тексту чи сусідніх кодів.
+func (d *Dictionary) Classify(code string) (c Class, ok bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	e, ok := d.entry(code)
+	return e.Class, ok
+}
+
+// IsAlarm повідомляє, чи є код тривогою. Для кодів, яких немає у словнику,
+// використовується діапазон тривог Contact ID (cidparser.IsAlarm).
+func (d *Dictionary) IsAlarm(code string) bool {
+	if c, ok := d.Classify(code); ok {
+		return c.Category == CategoryAlarm
+	}
+	return cidparser.IsAlarm(code)
+}
+
+// text повертає текст мовою lang або український, якщо перекладу немає
+func text(texts map[string]string, lang string) string {
+	if s := texts[lang]; s != "" {
+		return s
+	}
+	return texts[LangUK]
+}
+
+// Entries повертає всі записи, впорядковані за кодом
+func (d *Dictionary) Entries() []Entry {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	codes := slices.Collect(maps.Keys(d.shipped))
+	for code := range d.local {
+		if _, ok := d.shipped[code]; !ok {
+			codes = append(codes, code)
+		}
+	}
+	slices.Sort(codes)
+
+	entries := make([]Entry, 0, len(codes))
+	for _, code := range codes {
+		e, _ := d.entry(code)
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Entry повертає запис коду
+func (d *Dictionary) Entry(code string) (Entry, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.entry(code)
+}
+
+// entry накладає запис користувача на постачений (виклик під d.mu)
+func (d *Dictionary) entry(code string) (Entry, bool) {
+	shipped, inShipped := d.shipped[code]
+	local, inLocal := d.local[code]
+	if !inShipped && !inLocal {
+		return Entry{}, false
+	}
+
+	e := Entry{Code: code, Type: map[string]string{}, Description: map[string]string{}, Source: SourceShipped}
+	if inShipped {
+		overlay(&e, shipped)
+	}
+	if inLocal {
+		overlay(&e, local)
+		e.Source = SourceOverride
+		if !inShipped {
+			e.Source = SourceCustom
+		}
+	}
+	return e, true
+}
+
+// overlay переносить непорожні поля ev у e
+func overlay(e *Entry, ev *cidparser.Event) {
+	for lang, s := range map[string][2]string{
+		LangUK: {ev.TypeCodeMesUK, ev.CodeMesUK},
+		LangEN: {ev.TypeCodeMesEN, ev.CodeMesEN},
+		LangRU: {ev.TypeCodeMesRU, ev.CodeMesRU},
+	} {
+		if s[0] != "" {
+			e.Type[lang] = s[0]
+		}
+		if s[1] != "" {
+			e.Description[lang] = s[1]
+		}
+	}
+	if ev.Category != "" {
+		e.Category = ev.Category
+	}
+	if ev.Severity != "" {
+		e.Severity = ev.Severity
+	}
+	if ev.Restore != "" {
+		e.Restore = ev.Restore
+	}
+}
+
+// Set замінює запис користувача для e.Code і зберігає файл користувача.
+// Поля, яких немає в e, беруться з постаченого файлу. Повертає об'єднаний запис.
+func (d *Dictionary) Set(e Entry) (Entry, error) {
+	if !codeRe.MatchString(e.Code) {
+		return Entry{}, fmt.Errorf("invalid event code %q, want E or R and three digits", e.Code)
+	}
+	if e.Category != "" && !slices.Contains(Categories, e.Category) {
+		return Entry{}, fmt.Errorf("unknown category %q, want one of %v", e.Category, Categories)
+	}
+	if e.Severity != "" && !slices.Contains(Severities, e.Severity) {
+		return Entry{}, fmt.Errorf("unknown severity %q, want one of %v", e.Severity, Severities)
+	}
+	if e.Restore != "" && !codeRe.MatchString(e.Restore) {
+		return Entry{}, fmt.Errorf("invalid restore code %q, want E or R and three digits", e.Restore)
+	}
+	empty := e.Class == Class{}
+	for _, texts := range []map[string]string{e.Type, e.Description} {
+		for lang, s := range texts {
+			if !slices.Contains(Languages, lang) {
+				return Entry{}, fmt.Errorf("unknown language %q, want one of %v", lang, Languages)
+			}
+			if s != "" {
+				empty = false
+			}
+		}
+	}
+	if empty {
+		return Entry{}, errors.New("at least one text or classification field is required")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	local := maps.Clone(d.local)
+	local[e.Code] = &cidparser.Event{
+		ContactIdCode: e.Code,
+		TypeCodeMesUK: e.Type[LangUK],
+		CodeMesUK:     e.Description[LangUK],
+		TypeCodeMesEN: e.Type[LangEN],
+		CodeMesEN:     e.Description[LangEN],
+		TypeCodeMesRU: e.Type[LangRU],
+		CodeMesRU:     e.Description[LangRU],
+		Category:      e.Category,
+		Severity:      e.Severity,
+		Restore:       e.Restore,
+	}
+	if err := d.save(local); err != nil {
+		return Entry{}, err
+	}
+	d.local = local
+	d.revision++
+	merged, _ := d.entry(e.Code)
+	return merged, nil
+}
+
+// Delete видаляє запис користувача для code; постачений запис, якщо він є, знову діє
+func (d *Dictionary) Delete(code string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.local[code]; !ok {
+		return ErrNotFound
+	}
+	local := maps.Clone(d.local)
+	delete(local, code)
+	if err := d.save(local); err != nil {
+		return err
+	}
+	d.local = local
+	d.revision++
+	return nil
+}
+
+// save атомарно записує записи користувача у файл з наступним номером
+// зміни (виклик під d.mu)
+func (d *Dictionary) save(local cidparser.EventMap) error {
+	file := cidparser.EventFile{Revision: d.revision + 1, Events: make([]cidparser.Event, 0, len(local))}
+	for _, code := range slices.Sorted(maps.Keys(local)) {
+		file.Events = append(file.Events, *local[code])
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(d.overrides); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := d.overrides + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.overrides)
+}
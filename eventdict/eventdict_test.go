@@ -0,0 +1,263 @@
+package eventdict
+
+import (
+	"cid_retranslator_walk/config"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const shippedJSON = `[
+	{"contactId_code": "E130", "TypeCodeMes_UK": "Тривога", "CodeMes_UK": "Проникнення", "TypeCodeMes_EN": "Alarm",
+	 "Category": "alarm", "Severity": "critical", "Restore": "R130", "Zoneno": 1},
+	{"contactId_code": "E602", "TypeCodeMes_UK": "Тест", "CodeMes_UK": "Періодичний тест", "Category": "other", "Severity": "info"}
+]`
+
+func newTestDictionary(t *testing.T) *Dictionary {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.json")
+	if err := os.WriteFile(path, []byte(shippedJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := New(path, filepath.Join(dir, "events.local.json"), LangUK)
+	if err := d.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestLanguagesMatchConfig(t *testing.T) {
+	if !slices.Equal(Languages, config.EventLanguages) {
+		t.Errorf("Languages = %v, config.EventLanguages = %v", Languages, config.EventLanguages)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	d := newTestDictionary(t)
+	tests := []struct {
+		lang, code     string
+		typeDesc, desc string
+		known          bool
+	}{
+		{LangUK, "E130", "Тривога", "Проникнення", true},
+		{LangEN, "E130", "Alarm", "Проникнення", true}, // Опису англійською немає
+		{LangRU, "E602", "Тест", "Періодичний тест", true},
+		{LangUK, "E123", "Невідомий код E123", "", false},
+		{LangEN, "E123", "Unknown code E123", "", false},
+	}
+	for _, tt := range tests {
+		d.SetLanguage(tt.lang)
+		typeDesc, desc, known := d.Describe(tt.code)
+		if typeDesc != tt.typeDesc || desc != tt.desc || known != tt.known {
+			t.Errorf("Describe(%s, %s) = %q, %q, %v, want %q, %q, %v",
+				tt.lang, tt.code, typeDesc, desc, known, tt.typeDesc, tt.desc, tt.known)
+		}
+	}
+
+	if typeDesc, _, found := d.GetEventDescriptions("E123"); found || typeDesc != "" {
+		t.Errorf("GetEventDescriptions(E123) = %q, %v, want not found", typeDesc, found)
+	}
+}
+
+func TestSetAndDelete(t *testing.T) {
+	d := newTestDictionary(t)
+
+	e, err := d.Set(Entry{Code: "E130", Description: map[string]string{LangEN: "Burglary"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Source != SourceOverride || e.Type[LangUK] != "Тривога" || e.Description[LangEN] != "Burglary" {
+		t.Errorf("merged entry = %+v", e)
+	}
+	if _, err := d.Set(Entry{Code: "E999", Type: map[string]string{LangUK: "Власний код"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Записи користувача переживають перезапуск і повторне завантаження
+	reopened := New(d.path, d.overrides, LangEN)
+	if err := reopened.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if typeDesc, desc, _ := reopened.Describe("E130"); typeDesc != "Alarm" || desc != "Burglary" {
+		t.Errorf("Describe(E130) after reload = %q, %q", typeDesc, desc)
+	}
+	if e, ok := reopened.Entry("E999"); !ok || e.Source != SourceCustom {
+		t.Errorf("Entry(E999) = %+v, %v", e, ok)
+	}
+	if n := reopened.Len(); n != 3 {
+		t.Errorf("Len() = %d, want 3", n)
+	}
+	// Постачений файл у старому форматі не має версії; кожен запис користувача - нова зміна
+	if version, revision := reopened.Version(); version != "" || revision != 2 {
+		t.Errorf("Version() after reload = %q, %d, want \"\", 2", version, revision)
+	}
+
+	if err := d.Delete("E130"); err != nil {
+		t.Fatal(err)
+	}
+	if _, revision := d.Version(); revision != 3 {
+		t.Errorf("revision after delete = %d, want 3", revision)
+	}
+	if e, _ := d.Entry("E130"); e.Source != SourceShipped || e.Description[LangEN] != "" {
+		t.Errorf("entry after delete = %+v", e)
+	}
+	if err := d.Delete("E130"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete error = %v, want ErrNotFound", err)
+	}
+
+	codes := []string{}
+	for _, e := range d.Entries() {
+		codes = append(codes, e.Code)
+	}
+	if !slices.Equal(codes, []string{"E130", "E602", "E999"}) {
+		t.Errorf("Entries() codes = %v", codes)
+	}
+}
+
+func TestSet_Invalid(t *testing.T) {
+	d := newTestDictionary(t)
+	for _, e := range []Entry{
+		{Code: "130", Type: map[string]string{LangUK: "Тривога"}},
+		{Code: "E130", Type: map[string]string{"de": "Alarm"}},
+		{Code: "E130"},
+	} {
+		if _, err := d.Set(e); err == nil {
+			t.Errorf("Set(%+v) succeeded", e)
+		}
+	}
+	if _, err := os.Stat(d.overrides); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("overrides file written for invalid entries: %v", err)
+	}
+}
+
+// Невдале перезавантаження залишає попередній вміст
+func TestReload_KeepsEntriesOnError(t *testing.T) {
+	d := newTestDictionary(t)
+	if err := os.WriteFile(d.path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Reload(); err == nil {
+		t.Fatal("Reload accepted a corrupt file")
+	}
+	if _, _, known := d.Describe("E130"); !known {
+		t.Error("entries lost after a failed reload")
+	}
+}
+
+// legacyCategories - набори кодів, що раніше були зашиті в cidparser/event_colors.go.
+// Постачений словник має класифікувати їх так само.
+var legacyCategories = map[string]string{
+	CategoryArm: `
+		R407 R417 R431 R470 R471 R472 R473 R474 R475 R401 R402 R403 R404 R405 R406 R408 R409 R442 R455 R454
+		R400`,
+	CategoryDisarm: `
+		E407 E404 E451 E458 E401 E403 E400 E402 E405 E406 E408 E409 E442 E455 E454`,
+	CategoryAlarm: `
+		E100 E101 E110 E111 E112 E115 E116 E118 E120 E121 E122 E123 E124 E125 E130 E131 E132 E133 E134 E135
+		E136 E137 E138 E139 E140 E141 E142 E144 E145 E146 E150 E383`,
+	CategoryRestore: `
+		R100 R101 R102 R110 R111 R112 R113 R114 R116 R117 R118 R120 R121 R122 R123 R124 R125 R126 R130 R131
+		R132 R133 R134 R135 R136 R137 R138 R139 R140 R141 R142 R143 R144 R145 R146 R147 R150 R151 R152 R153
+		R154 R155 R156 R157 R158 R159 R161 R162 R163 R200 R201 R202 R203 R204 R205 R206 R220 R300 R301 R302
+		R303 R304 R305 R306 R307 R308 R309 R310 R311 R312 R313 R314 R315 R319 R320 R321 R322 R323 R324 R325
+		R326 R327 R330 R331 R332 R333 R334 R335 R336 R337 R338 R339 R341 R342 R343 R344 R350 R351 R352 R353
+		R354 R355 R357 R358 R359 R361 R370 R371 R372 R373 R374 R375 R376 R377 R378 R380 R381 R382 R383 R384
+		R385 R386 R387 R388 R389 R391 R392 R393 R410 R411 R412 R413 R414 R415 R416 R421 R422 R423 R424 R425
+		R426 R427 R428 R429 R430 R432 R433 R434 R441 R450 R451 R452 R453 R456 R457 R458 R459 R461 R462 R463
+		R464 R465 R466 R501 R520 R521 R522 R523 R524 R525 R526 R527 R530 R531 R532 R551 R552 R553 R570 R571
+		R572 R573 R574 R575 R576 R577 R580 R581 R582 R583 R584 R585 R586 R601 R602 R603 R604 R605 R606 R607
+		R608 R609 R611 R612 R613 R614 R615 R616 R621 R622 R623 R625 R626 R627 R628 R629 R630 R631 R632 R641
+		R642 R654 R825 R826`,
+	CategoryOther: `
+		E000 E143 E147 E151 E152 E153 E154 E155 E156 E157 E158 E159 E161 E162 E163 E201 E202 E203 E204 E205
+		E206 E208 E300 E303 E305 E306 E307 E308 E309 E310 E311 E312 E313 E314 E320 E322 E323 E324 E325 E326
+		E327 E330 E331 E333 E334 E335 E336 E337 E338 E339 E341 E342 E343 E344 E351 E352 E353 E354 E355 E357
+		E358 E359 E370 E371 E372 E374 E375 E376 E377 E378 E380 E381 E382 E384 E385 E386 E387 E388 E389 E391
+		E392 E393 E410 E411 E412 E413 E414 E415 E416 E421 E422 E424 E425 E426 E427 E428 E429 E430 E432 E433
+		E434 E450 E453 E457 E461 E462 E464 E465 E501 E522 E523 E524 E525 E526 E527 E528 E530 E531 E532 E551
+		E552 E553 E571 E572 E573 E574 E575 E576 E577 E584 E600 E601 E602 E603 E604 E605 E606 E607 E608 E609
+		E610 E611 E612 E613 E614 E615 E616 E621 E622 E623 E625 E626 E627 E628 E629 E630 E631 E632 E641 E642
+		E654 E656 E800 E830`,
+}
+
+// Кожен постачений код має коректну класифікацію, а коди зі старих наборів -
+// ту саму категорію. Зокрема, зняття з охорони (E401) не є постановкою (R401).
+func TestShippedClassification(t *testing.T) {
+	d := New("../events.json", filepath.Join(t.TempDir(), "events.local.json"), LangUK)
+	if err := d.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := d.Version(); version == "" {
+		t.Error("shipped dictionary has no version")
+	}
+
+	want := map[string]string{}
+	for category, codes := range legacyCategories {
+		for _, code := range strings.Fields(codes) {
+			want[code] = category
+		}
+	}
+	for _, e := range d.Entries() {
+		for _, lang := range Languages {
+			if e.Type[lang] == "" || e.Description[lang] == "" {
+				t.Errorf("%s: no %s text", e.Code, lang)
+			}
+		}
+		if !slices.Contains(Categories, e.Category) {
+			t.Errorf("%s: category %q, want one of %v", e.Code, e.Category, Categories)
+		}
+		if !slices.Contains(Severities, e.Severity) {
+			t.Errorf("%s: severity %q, want one of %v", e.Code, e.Severity, Severities)
+		}
+		if category, ok := want[e.Code]; ok && e.Category != category {
+			t.Errorf("%s: category %q, want %q", e.Code, e.Category, category)
+		}
+		if e.Category == CategoryAlarm && e.Severity != SeverityCritical {
+			t.Errorf("%s: alarm with severity %q", e.Code, e.Severity)
+		}
+		if e.Restore != "" {
+			if r, ok := d.Classify(e.Restore); !ok || r.Category != CategoryRestore {
+				t.Errorf("%s: restore code %s is not a shipped restore (%+v)", e.Code, e.Restore, r)
+			}
+		}
+	}
+
+	for code, category := range map[string]string{
+		"E401": CategoryDisarm, "R401": CategoryArm, "E130": CategoryAlarm, "R130": CategoryRestore,
+	} {
+		if c, _ := d.Classify(code); c.Category != category {
+			t.Errorf("Classify(%s) = %q, want %q", code, c.Category, category)
+		}
+	}
+	if c, _ := d.Classify("E130"); c.Restore != "R130" {
+		t.Errorf("E130 restore = %q, want R130", c.Restore)
+	}
+}
+
+func TestClassify_Override(t *testing.T) {
+	d := newTestDictionary(t)
+	if d.IsAlarm("E602") {
+		t.Fatal("E602 is an alarm before the override")
+	}
+	e, err := d.Set(Entry{Code: "E602", Class: Class{Category: CategoryAlarm, Severity: SeverityCritical}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Type[LangUK] != "Тест" || !d.IsAlarm("E602") {
+		t.Errorf("entry after override = %+v", e)
+	}
+
+	// Коди поза словником класифікуються за діапазоном Contact ID
+	if !d.IsAlarm("E110") || d.IsAlarm("E301") {
+		t.Error("IsAlarm fallback for unknown codes")
+	}
+	for _, c := range []Class{{Category: "guard"}, {Severity: "high"}, {Restore: "130"}} {
+		if _, err := d.Set(Entry{Code: "E130", Class: c}); err == nil {
+			t.Errorf("Set accepted %+v", c)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// Package forward пересилає ретрансльовані події HTTP-споживачам як JSON.
+// Кожен отримувач має власну чергу, пакетування і повтори з наростаючою
+// затримкою, тож повільний споживач не впливає ні на інших, ні на ACK панелі.
+package forward
+
+import (
+	"bytes"
+	"cid_retranslator_walk/cidparser"
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/match"
+	"cid_retranslator_walk/ratelimiter"
+	"cid_retranslator_walk/server"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Значення за замовчуванням для нульових полів config.ForwardDestination
+const (
+	defaultQueueSize    = 1000
+	defaultBatchSize    = 1
+	defaultTimeout      = 10 * time.Second
+	defaultRetryInitial = time.Second
+	defaultRetryMax     = time.Minute
+)
+
+// Заголовки підпису запиту
+const (
+	TimestampHeader = "X-CID-Timestamp" // Час підпису, секунди Unix
+	SignatureHeader = "X-CID-Signature" // "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body))
+)
+
+// Event - подія, яку отримує споживач
+type Event struct {
+	ID          string    `json:"id"` // Ідентифікатор кореляції, унікальний у межах запуску
+	Pipeline    string    `json:"pipeline"`
+	Account     int       `json:"account"`
+	Object      string    `json:"object,omitempty"` // Назва об'єкта з секції objects
+	Code        string    `json:"code"`             // Разом з кваліфікатором, наприклад "E130"
+	Qualifier   string    `json:"qualifier"`        // "E" - подія, "R" - відновлення
+	Group       int       `json:"group"`
+	Zone        int       `json:"zone"`
+	Category    string    `json:"category"`
+	Type        string    `json:"type,omitempty"`        // Тип події з events.json
+	Description string    `json:"description,omitempty"` // Опис коду з events.json
+	Raw         string    `json:"raw"`                   // Кадр без термінатора
+	ReceivedAt  time.Time `json:"receivedAt"`            // Коли кадр прийнято від панелі
+	AckedAt     time.Time `json:"ackedAt"`               // Коли панелі надіслано ACK
+}
+
+// Descriptions дає тип і опис коду події поточною мовою
+// (eventdict.Dictionary або cidparser.EventMap)
+type Descriptions interface {
+	GetEventDescriptions(code string) (typeDesc, codeDesc string, found bool)
+}
+
+// Forwarder розбирає кадри, які отримали ACK, і ставить події в черги отримувачів
+type Forwarder struct {
+	dests   []*destination
+	events  Descriptions
+	objects func(account int) string
+}
+
+// destination - один споживач зі своєю чергою
+type destination struct {
+	config.ForwardDestination
+	expr   *match.Expr
+	queue  chan Event
+	client *http.Client
+
+	// Попередження про переповнену чергу не частіше разу на 10 секунд
+	dropLog *ratelimiter.RateLimiter
+
+	sent    atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+// New створює Forwarder. events дає описи кодів (може бути nil), objects -
+// назву об'єкта за номером (може бути nil) і викликається для кожної події.
+func New(cfg *config.ForwardConfig, events Descriptions, objects func(account int) string) (*Forwarder, error) {
+	f := &Forwarder{events: events, objects: objects}
+	for _, dc := range cfg.Destinations {
+		d := &destination{ForwardDestination: dc, dropLog: ratelimiter.NewRateLimiter(0.1, 1)}
+		expr, err := match.Compile(dc.Match)
+		if err != nil {
+			return nil, fmt.Errorf("destination %s: %w", dc.Name, err)
+		}
+		d.expr = expr
+		d.setDefaults()
+		d.queue = make(chan Event, d.QueueSize)
+		d.client = &http.Client{Timeout: d.Timeout}
+		f.dests = append(f.dests, d)
+	}
+	return f, nil
+}
+
+func (d *destination) setDefaults() {
+	if d.QueueSize <= 0 {
+		d.QueueSize = defaultQueueSize
+	}
+	if d.BatchSize <= 0 {
+		d.BatchSize = defaultBatchSize
+	}
+	if d.Timeout <= 0 {
+		d.Timeout = defaultTimeout
+	}
+	if d.RetryInitial <= 0 {
+		d.RetryInitial = defaultRetryInitial
+	}
+	if d.RetryMax < d.RetryInitial {
+		d.RetryMax = max(defaultRetryMax, d.RetryInitial)
+	}
+}
+
+// Submit ставить подію з кадру в черги отримувачів, чиї фільтри її пропускають.
+// Пересилаються лише кадри з ACK: на NACK панель повторить кадр, і подія
+// надійшла б споживачу двічі. Не блокується: якщо черга отримувача повна,
+// подія для нього відкидається. Підходить як server.Observer.
+func (f *Forwarder) Submit(pipeline string, fr server.Frame) {
+	if fr.Outcome != server.OutcomeAck || len(f.dests) == 0 {
+		return
+	}
+	m, err := cidparser.Parse(fr.Payload)
+	if err != nil {
+		slog.Debug("Cannot parse message for forwarding", "error", err, "pipeline", pipeline)
+		return
+	}
+	fields := &match.Fields{
+		Receiver:  m.Receiver,
+		Account:   m.Account,
+		Qualifier: string(m.Qualifier),
+		Code:      m.Code,
+		Event:     m.Event(),
+		Group:     m.Group,
+		Zone:      m.Zone,
+		Category:  m.Category(),
+	}
+
+	var ev *Event
+	for _, d := range f.dests {
+		if !d.expr.Match(fields) {
+			continue
+		}
+		if ev == nil {
+			e := f.event(pipeline, fr, m)
+			ev = &e
+		}
+		select {
+		case d.queue <- *ev:
+		default:
+			d.dropped.Add(1)
+			if d.dropLog.Allow() {
+				slog.Warn("Forward queue full, dropping event", "destination", d.Name,
+					"queueSize", d.QueueSize, "dropped", d.dropped.Load(), "id", fr.ID)
+			}
+		}
+	}
+}
+
+// event формує подію для споживача
+func (f *Forwarder) event(pipeline string, fr server.Frame, m cidparser.Message) Event {
+	var object string
+	if f.objects != nil {
+		object = f.objects(m.Account)
+	}
+	return NewEvent(pipeline, fr, m, f.events, object)
+}
+
+// NewEvent формує подію з кадру fr і його розібраних полів m. events дає
+// описи кодів (може бути nil), object - назва об'єкта.
+func NewEvent(pipeline string, fr server.Frame, m cidparser.Message, events Descriptions, object string) Event {
+	ev := Event{
+		ID:         fr.ID,
+		Pipeline:   pipeline,
+		Account:    m.Account,
+		Object:     object,
+		Code:       m.Code,
+		Qualifier:  string(m.Qualifier),
+		Group:      m.Group,
+		Zone:       m.Zone,
+		Category:   m.Category(),
+		Raw:        strings.TrimRight(string(fr.Payload), "\x14"),
+		ReceivedAt: fr.Received,
+		AckedAt:    fr.Replied,
+	}
+	if events != nil {
+		ev.Type, ev.Description, _ = events.GetEventDescriptions(m.Code)
+	}
+	return ev
+}
+
+// Run доставляє події до скасування ctx. Події, що лишилися в чергах
+// на момент зупинки, втрачаються (їх кількість записується в лог).
+func (f *Forwarder) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, d := range f.dests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.run(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *destination) run(ctx context.Context) {
+	for {
+		batch := d.collect(ctx)
+		if ctx.Err() != nil {
+			d.dropped.Add(int64(len(batch) + len(d.queue)))
+			slog.Info("Forwarder stopped", "destination", d.Name, "lost", len(batch)+len(d.queue),
+				"sent", d.sent.Load(), "dropped", d.dropped.Load(), "failed", d.failed.Load())
+			return
+		}
+		d.deliver(ctx, batch)
+	}
+}
+
+// collect чекає першу подію і добирає пакет до BatchSize протягом BatchWait
+func (d *destination) collect(ctx context.Context) []Event {
+	var batch []Event
+	select {
+	case <-ctx.Done():
+		return nil
+	case ev := <-d.queue:
+		batch = append(batch, ev)
+	}
+
+	var wait <-chan time.Time
+	if d.BatchWait > 0 {
+		timer := time.NewTimer(d.BatchWait)
+		defer timer.Stop()
+		wait = timer.C
+	}
+	for len(batch) < d.BatchSize {
+		select {
+		case ev := <-d.queue:
+			batch = append(batch, ev)
+			continue
+		default:
+		}
+		if wait == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return batch
+		case <-wait:
+			return batch
+		case ev := <-d.queue:
+			batch = append(batch, ev)
+		}
+	}
+	return batch
+}
+
+// deliver надсилає пакет, повторюючи спроби з подвоєнням затримки від
+// RetryInitial до RetryMax, доки не вдасться, не вичерпано MaxAttempts
+// або не скасовано ctx
+func (d *destination) deliver(ctx context.Context, batch []Event) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		slog.Error("Cannot encode events for forwarding", "destination", d.Name, "error", err)
+		d.dropped.Add(int64(len(batch)))
+		return
+	}
+
+	backoff := d.RetryInitial
+	for attempt := 1; ; attempt++ {
+		err := d.post(ctx, body)
+		if err == nil {
+			d.sent.Add(int64(len(batch)))
+			slog.Debug("Events forwarded", "destination", d.Name, "count", len(batch), "attempt", attempt)
+			return
+		}
+		if ctx.Err() != nil {
+			d.dropped.Add(int64(len(batch)))
+			return
+		}
+		d.failed.Add(1)
+
+		if d.MaxAttempts > 0 && attempt >= d.MaxAttempts {
+			d.dropped.Add(int64(len(batch)))
+			slog.Error("Forwarding failed, dropping events", "destination", d.Name,
+				"count", len(batch), "attempts", attempt, "error", err)
+			return
+		}
+		slog.Warn("Forwarding failed, retrying", "destination", d.Name, "count", len(batch),
+			"attempt", attempt, "retryIn", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			d.dropped.Add(int64(len(batch)))
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, d.RetryMax)
+	}
+}
+
+// post надсилає один запит. Відповідь не 2xx вважається помилкою.
+func (d *destination) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, ts)
+		req.Header.Set(SignatureHeader, Sign(d.Secret, ts, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign повертає значення заголовка SignatureHeader для тіла body, підписаного
+// в момент timestamp. Споживач перевіряє підпис так само (hmac.Equal).
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
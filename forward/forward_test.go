@@ -0,0 +1,272 @@
+package forward
+
+import (
+	"cid_retranslator_walk/cidparser"
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/server"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// frame повертає кадр з ACK для об'єкта account з кодом code (наприклад "E130")
+func frame(id string, account int, code string) server.Frame {
+	payload := fmt.Appendf(nil, "5010 18%04d%s01015\x14", account, code)
+	now := time.Now()
+	return server.Frame{ID: id, Payload: payload, DeviceID: account, Received: now, Replied: now, Outcome: server.OutcomeAck}
+}
+
+// consumer - тестовий споживач, що записує отримані пакети
+type consumer struct {
+	t       *testing.T
+	mu      sync.Mutex
+	batches [][]Event
+	headers []http.Header
+	fail    int // Скільки перших запитів відхилити з 500
+	got     chan struct{}
+}
+
+func newConsumer(t *testing.T) (*consumer, *httptest.Server) {
+	c := &consumer{t: t, got: make(chan struct{}, 100)}
+	srv := httptest.NewServer(c)
+	t.Cleanup(srv.Close)
+	return c, srv
+}
+
+func (c *consumer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fail > 0 {
+		c.fail--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var batch []Event
+	if err := json.Unmarshal(body, &batch); err != nil {
+		c.t.Errorf("invalid body %q: %v", body, err)
+	}
+	h := r.Header.Clone()
+	h.Set("X-Body", string(body))
+	c.batches = append(c.batches, batch)
+	c.headers = append(c.headers, h)
+	c.got <- struct{}{}
+}
+
+func (c *consumer) wait(t *testing.T, n int) {
+	t.Helper()
+	for range n {
+		select {
+		case <-c.got:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for forwarded events")
+		}
+	}
+}
+
+func start(t *testing.T, f *Forwarder) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		f.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestForwarder_Event(t *testing.T) {
+	c, srv := newConsumer(t)
+	events := cidparser.EventMap{"E130": {ContactIdCode: "E130", TypeCodeMesUK: "Тривога", CodeMesUK: "Проникнення"}}
+	f, err := New(&config.ForwardConfig{Destinations: []config.ForwardDestination{
+		{Name: "crm", URL: srv.URL, Secret: "s3cret"},
+	}}, events, func(account int) string {
+		if account == 1234 {
+			return "Магазин"
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start(t, f)
+
+	f.Submit("default", frame("1-1", 1234, "E130"))
+	c.wait(t, 1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ev := c.batches[0][0]
+	if ev.ID != "1-1" || ev.Pipeline != "default" || ev.Account != 1234 || ev.Object != "Магазин" ||
+		ev.Code != "E130" || ev.Qualifier != "E" || ev.Group != 1 || ev.Zone != 15 {
+		t.Errorf("event = %+v", ev)
+	}
+	if ev.Type != "Тривога" || ev.Description != "Проникнення" || ev.Category != "burglary" {
+		t.Errorf("descriptions = %q %q %q", ev.Type, ev.Description, ev.Category)
+	}
+	if ev.Raw != "5010 181234E13001015" {
+		t.Errorf("raw = %q", ev.Raw)
+	}
+
+	h := c.headers[0]
+	if want := Sign("s3cret", h.Get(TimestampHeader), []byte(h.Get("X-Body"))); h.Get(SignatureHeader) != want {
+		t.Errorf("signature = %q, want %q", h.Get(SignatureHeader), want)
+	}
+}
+
+func TestForwarder_SkipsNotAcked(t *testing.T) {
+	f, err := New(&config.ForwardConfig{Destinations: []config.ForwardDestination{
+		{Name: "crm", URL: "http://127.0.0.1:1"},
+	}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, outcome := range []string{server.OutcomeNack, server.OutcomeTimeout, server.OutcomeRejected} {
+		fr := frame("1-1", 1234, "E130")
+		fr.Outcome = outcome
+		f.Submit("default", fr)
+	}
+	if n := len(f.dests[0].queue); n != 0 {
+		t.Errorf("queued %d events, want 0", n)
+	}
+}
+
+func TestForwarder_Filter(t *testing.T) {
+	c, srv := newConsumer(t)
+	f, err := New(&config.ForwardConfig{Destinations: []config.ForwardDestination{
+		{Name: "alarms", URL: srv.URL, Match: "category == burglary && account >= 1000"},
+	}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start(t, f)
+
+	f.Submit("default", frame("1-1", 1234, "E602")) // Тест
+	f.Submit("default", frame("1-2", 999, "E130"))  // Не той об'єкт
+	f.Submit("default", frame("1-3", 1234, "E130"))
+	c.wait(t, 1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.batches) != 1 || c.batches[0][0].ID != "1-3" {
+		t.Errorf("batches = %+v", c.batches)
+	}
+}
+
+func TestForwarder_Batch(t *testing.T) {
+	c, srv := newConsumer(t)
+	f, err := New(&config.ForwardConfig{Destinations: []config.ForwardDestination{
+		{Name: "crm", URL: srv.URL, BatchSize: 3, BatchWait: 200 * time.Millisecond},
+	}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []string{"1-1", "1-2", "1-3", "1-4"} {
+		f.Submit("default", frame(id, 1000+i, "E130"))
+	}
+	start(t, f)
+	c.wait(t, 2)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.batches[0]) != 3 || len(c.batches[1]) != 1 {
+		t.Fatalf("batch sizes = %d, %d; want 3, 1", len(c.batches[0]), len(c.batches[1]))
+	}
+	if c.batches[1][0].ID != "1-4" {
+		t.Errorf("second batch = %+v", c.batches[1])
+	}
+}
+
+func TestForwarder_Retry(t *testing.T) {
+	c, srv := newConsumer(t)
+	c.fail = 2
+	f, err := New(&config.ForwardConfig{Destinations: []config.ForwardDestination{
+		{Name: "crm", URL: srv.URL, RetryInitial: 10 * time.Millisecond, RetryMax: 20 * time.Millisecond},
+	}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start(t, f)
+
+	f.Submit("default", frame("1-1", 1234, "E130"))
+	c.wait(t, 1)
+
+	if failed := f.dests[0].failed.Load(); failed != 2 {
+		t.Errorf("failed = %d, want 2", failed)
+	}
+}
+
+func TestForwarder_MaxAttempts(t *testing.T) {
+	c, srv := newConsumer(t)
+	c.fail = 1
+	f, err := New(&config.ForwardConfig{Destinations: []config.ForwardDestination{
+		{Name: "crm", URL: srv.URL, MaxAttempts: 1},
+	}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start(t, f)
+
+	f.Submit("default", frame("1-1", 1234, "E130"))
+	f.Submit("default", frame("1-2", 1234, "E130"))
+	c.wait(t, 1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.batches) != 1 || c.batches[0][0].ID != "1-2" {
+		t.Errorf("batches = %+v", c.batches)
+	}
+	if dropped := f.dests[0].dropped.Load(); dropped != 1 {
+		t.Errorf("dropped = %d, want 1", dropped)
+	}
+}
+
+// Повільний споживач не блокує Submit: зайві події відкидаються
+func TestForwarder_QueueFull(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	f, err := New(&config.ForwardConfig{Destinations: []config.ForwardDestination{
+		{Name: "slow", URL: srv.URL, QueueSize: 2},
+	}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := range 10 {
+			f.Submit("default", frame(fmt.Sprint("1-", i), 1234, "E130"))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Submit blocked on a full queue")
+	}
+	if dropped := f.dests[0].dropped.Load(); dropped != 8 {
+		t.Errorf("dropped = %d, want 8", dropped)
+	}
+}
+
+func TestNew_InvalidMatch(t *testing.T) {
+	_, err := New(&config.ForwardConfig{Destinations: []config.ForwardDestination{
+		{Name: "crm", URL: "http://localhost", Match: "account >"},
+	}}, nil, nil)
+	if err == nil {
+		t.Error("New accepted an invalid match expression")
+	}
+}
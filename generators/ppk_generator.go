@@ -9,7 +9,7 @@ import (
 
 // GeneratePPKData - генератор тестових даних для ППК
 func GeneratePPKData(ppkChan chan<- *models.PPKItem) {
-	statuses := []string{"Активний", "Помилка", "Попередження"}
+	statuses := []string{models.StatusArmed, models.StatusDisarmed, models.StatusPartial, models.StatusEarlyOpen, models.StatusLateClose}
 
 	ticker := time.NewTicker(50 * time.Millisecond) // 20 разів на секунду
 	defer ticker.Stop()
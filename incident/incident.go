@@ -0,0 +1,379 @@
+// Package incident перетворює тривоги на інциденти, які диспетчер має
+// підтвердити з коментарем і кодом вирішення. Непідтверджений інцидент
+// ескалюється через заданий час. Пакет не залежить від UI: вікно програми
+// і API - лише споживачі сховища.
+package incident
+
+import (
+	"cid_retranslator_walk/cidparser"
+	"cid_retranslator_walk/eventdict"
+	"cid_retranslator_walk/server"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound повертається, якщо інциденту з таким ID немає
+	ErrNotFound = errors.New("incident not found")
+	// ErrDisabled повертається, якщо інциденти вимкнені в конфігурації
+	ErrDisabled = errors.New("incidents are disabled")
+	// ErrAcknowledged повертається при повторному підтвердженні
+	ErrAcknowledged = errors.New("incident is already acknowledged")
+	// ErrInvalid повертається, якщо код вирішення невідомий або коментар порожній
+	ErrInvalid = errors.New("invalid acknowledgement")
+)
+
+// Стани інциденту
+const (
+	StatusOpen         = "open"
+	StatusAcknowledged = "acknowledged"
+)
+
+// escalateInterval - як часто перевіряються непідтверджені інциденти
+const escalateInterval = time.Second
+
+// Classifier дає класифікацію коду (eventdict.Dictionary)
+type Classifier interface {
+	Classify(code string) (eventdict.Class, bool)
+}
+
+// Incident - тривога, що чекає на реакцію диспетчера
+type Incident struct {
+	ID        string    `json:"id"`
+	Pipeline  string    `json:"pipeline"`
+	Account   int       `json:"account"`
+	Partition int       `json:"partition"`
+	Zone      int       `json:"zone"`
+	Code      string    `json:"code"`
+	Severity  string    `json:"severity"`
+	Count     int       `json:"count"` // Подій, що надійшли, поки інцидент відкритий
+	Opened    time.Time `json:"opened"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Restored  time.Time `json:"restored,omitzero"` // Надійшло відновлення; підтвердження все одно потрібне
+	Restore   string    `json:"restore,omitempty"`
+	Status    string    `json:"status"`
+	Escalated time.Time `json:"escalated,omitzero"`
+
+	Acknowledged time.Time `json:"acknowledged,omitzero"`
+	AckBy        string    `json:"ackBy,omitempty"`
+	Resolution   string    `json:"resolution,omitempty"`
+	Comment      string    `json:"comment,omitempty"`
+}
+
+// Store - сховище інцидентів з опційним збереженням у JSON файл
+type Store struct {
+	path       string // Порожній - лише в пам'яті
+	maxEntries int
+	classify   Classifier
+
+	mu            sync.Mutex
+	incidents     []*Incident // Від найстарішого
+	nextID        int
+	version       uint64
+	dirty         bool          // Є зміни, ще не записані у файл
+	escalateAfter time.Duration // 0 - без ескалації
+	resolutions   []string
+
+	saveMu sync.Mutex // Записи у файл не перетинаються
+}
+
+// Open створює сховище і завантажує інциденти з path (якщо файл існує).
+// Понад maxEntries видаляються найстаріші підтверджені інциденти.
+func Open(path string, maxEntries int, classify Classifier) (*Store, error) {
+	s := &Store{path: path, maxEntries: maxEntries, classify: classify, nextID: 1}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.incidents); err != nil {
+		return nil, fmt.Errorf("incidents %s: %w", path, err)
+	}
+	for _, inc := range s.incidents {
+		if id, err := strconv.Atoi(inc.ID); err == nil && id >= s.nextID {
+			s.nextID = id + 1
+		}
+	}
+	return s, nil
+}
+
+// SetPolicy задає час до ескалації і коди вирішення, які приймає Acknowledge
+func (s *Store) SetPolicy(escalateAfter time.Duration, resolutions []string) {
+	s.mu.Lock()
+	s.escalateAfter = escalateAfter
+	s.resolutions = slices.Clone(resolutions)
+	s.mu.Unlock()
+}
+
+// Resolutions повертає коди вирішення
+func (s *Store) Resolutions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.resolutions)
+}
+
+// Submit створює інцидент для тривоги з кадру. Як і пересилання HTTP,
+// враховуються лише кадри з ACK: панель повторює відхилений кадр, і повтор
+// не має рахуватися ще раз. Підходить як спостерігач сервера.
+func (s *Store) Submit(pipeline string, fr server.Frame) {
+	if fr.Outcome != server.OutcomeAck {
+		return
+	}
+	m, err := cidparser.Parse(fr.Payload)
+	if err != nil {
+		return
+	}
+	s.Raise(pipeline, m, fr.Received)
+}
+
+// Raise створює інцидент для тривоги m або додає подію до відкритого інциденту
+// того самого місця. Відновлення позначається на відкритому інциденті.
+// Для подій інших категорій повертає порожній Incident. Зміни записуються у файл з Run.
+func (s *Store) Raise(pipeline string, m cidparser.Message, at time.Time) Incident {
+	class, _ := s.classify.Classify(m.Code)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	same := func(inc *Incident) bool {
+		return inc.Status == StatusOpen && inc.Pipeline == pipeline && inc.Account == m.Account &&
+			inc.Partition == m.Group && inc.Zone == m.Zone
+	}
+
+	if class.Category != eventdict.CategoryAlarm {
+		changed := false
+		for _, inc := range s.incidents {
+			if same(inc) && inc.Restore == m.Code && inc.Restored.IsZero() {
+				inc.Restored = at
+				changed = true
+			}
+		}
+		if changed {
+			s.touch()
+		}
+		return Incident{}
+	}
+
+	for _, inc := range s.incidents {
+		if same(inc) && inc.Code == m.Code {
+			inc.Count++
+			inc.LastSeen = at
+			inc.Restored = time.Time{}
+			s.touch()
+			return *inc
+		}
+	}
+
+	inc := &Incident{
+		ID:        strconv.Itoa(s.nextID),
+		Pipeline:  pipeline,
+		Account:   m.Account,
+		Partition: m.Group,
+		Zone:      m.Zone,
+		Code:      m.Code,
+		Severity:  class.Severity,
+		Restore:   class.Restore,
+		Count:     1,
+		Opened:    at,
+		LastSeen:  at,
+		Status:    StatusOpen,
+	}
+	s.nextID++
+	s.incidents = append(s.incidents, inc)
+	s.trim()
+	slog.Info("Incident opened", "id", inc.ID, "pipeline", pipeline, "account", m.Account,
+		"partition", m.Group, "zone", m.Zone, "code", m.Code)
+	s.touch()
+	return *inc
+}
+
+// trim видаляє найстаріші підтверджені інциденти понад maxEntries, а якщо їх
+// немає - найстаріші відкриті
+func (s *Store) trim() {
+	for s.maxEntries > 0 && len(s.incidents) > s.maxEntries {
+		i := slices.IndexFunc(s.incidents, func(inc *Incident) bool { return inc.Status == StatusAcknowledged })
+		if i < 0 {
+			i = 0
+			slog.Warn("Too many open incidents, dropping the oldest", "id", s.incidents[0].ID, "maxEntries", s.maxEntries)
+		}
+		s.incidents = slices.Delete(s.incidents, i, i+1)
+	}
+}
+
+// Acknowledge підтверджує інцидент від імені by з кодом вирішення і коментарем
+func (s *Store) Acknowledge(id, by, resolution, comment string) (Incident, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !slices.Contains(s.resolutions, resolution) {
+		return Incident{}, fmt.Errorf("%w: unknown resolution %q, want one of %v", ErrInvalid, resolution, s.resolutions)
+	}
+	if comment == "" {
+		return Incident{}, fmt.Errorf("%w: comment is required", ErrInvalid)
+	}
+	inc := s.find(id)
+	if inc == nil {
+		return Incident{}, ErrNotFound
+	}
+	if inc.Status == StatusAcknowledged {
+		return *inc, ErrAcknowledged
+	}
+	inc.Status = StatusAcknowledged
+	inc.Acknowledged = time.Now()
+	inc.AckBy, inc.Resolution, inc.Comment = by, resolution, comment
+	s.touch()
+	return *inc, nil
+}
+
+// Escalate позначає відкриті інциденти, що чекають довше за час ескалації,
+// і повертає щойно ескальовані
+func (s *Store) Escalate(now time.Time) []Incident {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.escalateAfter <= 0 {
+		return nil
+	}
+
+	var escalated []Incident
+	for _, inc := range s.incidents {
+		if inc.Status == StatusOpen && inc.Escalated.IsZero() && now.Sub(inc.Opened) >= s.escalateAfter {
+			inc.Escalated = now
+			escalated = append(escalated, *inc)
+			slog.Warn("Incident escalated", "id", inc.ID, "pipeline", inc.Pipeline, "account", inc.Account,
+				"zone", inc.Zone, "code", inc.Code, "open", now.Sub(inc.Opened).Round(time.Second))
+		}
+	}
+	if len(escalated) > 0 {
+		s.touch()
+	}
+	return escalated
+}
+
+// Run ескалює непідтверджені інциденти і записує змінені у файл до скасування
+// ctx і востаннє перед виходом
+func (s *Store) Run(ctx context.Context) {
+	ticker := time.NewTicker(escalateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Save(); err != nil {
+				slog.Error("Cannot save incidents", "path", s.path, "error", err)
+			}
+			return
+		case now := <-ticker.C:
+			s.Escalate(now)
+			if err := s.Save(); err != nil {
+				slog.Error("Cannot save incidents", "path", s.path, "error", err)
+			}
+		}
+	}
+}
+
+// List повертає копії інцидентів зі статусом status (порожній - усі), від найновішого
+func (s *Store) List(status string) []Incident {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []Incident{}
+	for _, inc := range slices.Backward(s.incidents) {
+		if status == "" || inc.Status == status {
+			list = append(list, *inc)
+		}
+	}
+	return list
+}
+
+// Get повертає інцидент за ID
+func (s *Store) Get(id string) (Incident, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inc := s.find(id)
+	if inc == nil {
+		return Incident{}, ErrNotFound
+	}
+	return *inc, nil
+}
+
+// Version змінюється з кожною зміною сховища; UI перечитує список лише після зміни
+func (s *Store) Version() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version
+}
+
+func (s *Store) find(id string) *Incident {
+	for _, inc := range s.incidents {
+		if inc.ID == id {
+			return inc
+		}
+	}
+	return nil
+}
+
+// touch позначає зміну сховища (викликати під s.mu)
+func (s *Store) touch() {
+	s.version++
+	s.dirty = true
+}
+
+// Save атомарно записує інциденти у файл, якщо вони змінилися з останнього запису.
+// Під блокуванням робиться лише знімок, кодування і запис - поза ним.
+func (s *Store) Save() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	if !s.dirty || s.path == "" {
+		s.mu.Unlock()
+		return nil
+	}
+	snapshot := make([]Incident, len(s.incidents))
+	for i, inc := range s.incidents {
+		snapshot[i] = *inc
+	}
+	s.dirty = false
+	s.mu.Unlock()
+
+	if err := s.write(snapshot); err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// write записує інциденти у файл (тимчасовий файл + перейменування)
+func (s *Store) write(incidents []Incident) error {
+	data, err := json.MarshalIndent(incidents, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
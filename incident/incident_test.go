@@ -0,0 +1,179 @@
+package incident
+
+import (
+	"cid_retranslator_walk/cidparser"
+	"cid_retranslator_walk/eventdict"
+	"cid_retranslator_walk/server"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type classes map[string]eventdict.Class
+
+func (c classes) Classify(code string) (eventdict.Class, bool) {
+	class, ok := c[code]
+	return class, ok
+}
+
+var testClasses = classes{
+	"E130": {Category: eventdict.CategoryAlarm, Severity: eventdict.SeverityCritical, Restore: "R130"},
+	"R130": {Category: eventdict.CategoryRestore, Severity: eventdict.SeverityInfo},
+	"E110": {Category: eventdict.CategoryAlarm, Severity: eventdict.SeverityCritical, Restore: "R110"},
+	"E301": {Category: eventdict.CategoryOther, Severity: eventdict.SeverityMinor, Restore: "R301"},
+}
+
+var resolutions = []string{"false_alarm", "alarm_confirmed"}
+
+var t0 = time.Date(2026, 3, 1, 14, 2, 0, 0, time.UTC)
+
+func msg(account int, code string, group, zone int) cidparser.Message {
+	return cidparser.Message{Account: account, Qualifier: code[0], Code: code, Group: group, Zone: zone}
+}
+
+func newTestStore(t *testing.T, path string) *Store {
+	t.Helper()
+	s, err := Open(path, 10, testClasses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetPolicy(time.Minute, resolutions)
+	return s
+}
+
+func TestStore_Raise(t *testing.T) {
+	s := newTestStore(t, "")
+	first := s.Raise("default", msg(1234, "E130", 1, 5), t0)
+	again := s.Raise("default", msg(1234, "E130", 1, 5), t0.Add(time.Second))
+	s.Raise("default", msg(1234, "E110", 1, 5), t0)
+	if inc := s.Raise("default", msg(1234, "E301", 0, 0), t0); inc.ID != "" {
+		t.Errorf("trouble opened incident %+v", inc)
+	}
+
+	if first.ID != "1" || again.ID != "1" || again.Count != 2 || !again.LastSeen.Equal(t0.Add(time.Second)) {
+		t.Errorf("incidents = %+v, %+v", first, again)
+	}
+	list := s.List(StatusOpen)
+	if len(list) != 2 || list[0].Code != "E110" || list[1].Severity != eventdict.SeverityCritical {
+		t.Fatalf("open incidents = %+v", list)
+	}
+
+	// Відновлення позначається, але не закриває інцидент
+	s.Raise("default", msg(1234, "R130", 1, 5), t0.Add(time.Minute))
+	if inc, _ := s.Get("1"); inc.Status != StatusOpen || !inc.Restored.Equal(t0.Add(time.Minute)) {
+		t.Errorf("restored incident = %+v", inc)
+	}
+}
+
+func TestStore_Submit(t *testing.T) {
+	s := newTestStore(t, "")
+	v := s.Version()
+	s.Submit("default", server.Frame{Payload: []byte("5010 181234E13001015\x14"), Received: t0, Outcome: server.OutcomeNack})
+	s.Submit("default", server.Frame{Payload: []byte("5010 181234E13001015\x14"), Received: t0, Outcome: server.OutcomeTimeout})
+	if list := s.List(""); len(list) != 0 || s.Version() != v {
+		t.Fatalf("incidents from rejected frames = %+v", list)
+	}
+
+	// Повтор, який приймач підтвердив, відкриває інцидент один раз
+	s.Submit("default", server.Frame{Payload: []byte("5010 181234E13001015\x14"), Received: t0, Outcome: server.OutcomeAck})
+	s.Submit("default", server.Frame{Payload: []byte("garbage\x14"), Received: t0, Outcome: server.OutcomeAck})
+	if list := s.List(""); len(list) != 1 || list[0].Zone != 15 || list[0].Count != 1 || s.Version() == v {
+		t.Errorf("incidents = %+v", list)
+	}
+}
+
+func TestStore_Acknowledge(t *testing.T) {
+	s := newTestStore(t, "")
+	s.Raise("default", msg(1234, "E130", 1, 5), t0)
+
+	for _, tt := range []struct{ resolution, comment string }{
+		{"unknown", "checked"},
+		{"false_alarm", ""},
+	} {
+		if _, err := s.Acknowledge("1", "operator", tt.resolution, tt.comment); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Acknowledge(%q, %q) error = %v, want ErrInvalid", tt.resolution, tt.comment, err)
+		}
+	}
+	if _, err := s.Acknowledge("9", "operator", "false_alarm", "checked"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown id error = %v, want ErrNotFound", err)
+	}
+
+	inc, err := s.Acknowledge("1", "operator", "false_alarm", "Cat in the warehouse")
+	if err != nil || inc.Status != StatusAcknowledged || inc.AckBy != "operator" || inc.Acknowledged.IsZero() {
+		t.Fatalf("Acknowledge() = %+v, %v", inc, err)
+	}
+	if _, err := s.Acknowledge("1", "operator", "false_alarm", "again"); !errors.Is(err, ErrAcknowledged) {
+		t.Errorf("second Acknowledge error = %v, want ErrAcknowledged", err)
+	}
+
+	// Нова тривога після підтвердження відкриває новий інцидент
+	if inc := s.Raise("default", msg(1234, "E130", 1, 5), t0.Add(time.Hour)); inc.ID != "2" {
+		t.Errorf("incident after acknowledge = %+v", inc)
+	}
+}
+
+func TestStore_Escalate(t *testing.T) {
+	s := newTestStore(t, "")
+	s.Raise("default", msg(1234, "E130", 1, 5), t0)
+	s.Raise("default", msg(1234, "E110", 1, 6), t0.Add(30*time.Second))
+	s.Raise("default", msg(1234, "E110", 1, 7), t0)
+	s.Acknowledge("3", "operator", "alarm_confirmed", "Patrol sent")
+
+	if got := s.Escalate(t0.Add(59 * time.Second)); len(got) != 0 {
+		t.Errorf("escalated early: %+v", got)
+	}
+	got := s.Escalate(t0.Add(time.Minute))
+	if len(got) != 1 || got[0].ID != "1" {
+		t.Fatalf("escalated = %+v, want incident 1", got)
+	}
+	if got := s.Escalate(t0.Add(2 * time.Minute)); len(got) != 1 || got[0].ID != "2" {
+		t.Errorf("second escalation = %+v, want only incident 2", got)
+	}
+
+	s.SetPolicy(0, resolutions)
+	s.Raise("default", msg(4321, "E130", 1, 1), t0)
+	if got := s.Escalate(t0.Add(time.Hour)); len(got) != 0 {
+		t.Errorf("escalated with escalation disabled: %+v", got)
+	}
+}
+
+// Понад maxEntries спершу видаляються підтверджені інциденти
+func TestStore_Trim(t *testing.T) {
+	s, _ := Open("", 2, testClasses)
+	s.SetPolicy(0, resolutions)
+	s.Raise("default", msg(1, "E130", 1, 1), t0)
+	s.Raise("default", msg(2, "E130", 1, 1), t0)
+	s.Acknowledge("2", "operator", "false_alarm", "Test")
+	s.Raise("default", msg(3, "E130", 1, 1), t0)
+	list := s.List("")
+	if len(list) != 2 || list[0].ID != "3" || list[1].ID != "1" {
+		t.Errorf("incidents = %+v, want 3 and 1", list)
+	}
+}
+
+// Інциденти переживають перезапуск, нумерація продовжується
+func TestStore_Persist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "incidents.json")
+	s := newTestStore(t, path)
+	s.Raise("default", msg(1234, "E130", 1, 5), t0)
+	s.Acknowledge("1", "operator", "false_alarm", "Checked")
+	s.Raise("default", msg(1234, "E110", 1, 5), t0)
+
+	// Зміни записуються лише з Save, не в потоці сесії
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("file written before Save: %v", err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := newTestStore(t, path)
+	if list := reopened.List(""); len(list) != 2 || list[1].Comment != "Checked" || list[0].Status != StatusOpen {
+		t.Fatalf("incidents after reopen = %+v", list)
+	}
+	if inc := reopened.Raise("default", msg(4321, "E130", 1, 1), t0); inc.ID != "3" {
+		t.Errorf("new incident ID = %q, want 3", inc.ID)
+	}
+}
@@ -0,0 +1,82 @@
+// Package logging містить обробники slog ретранслятора: текстовий або JSON
+// вивід, кільцевий буфер останніх записів для API та відправку в syslog
+// (RFC 5424), а також ідентифікатори кореляції повідомлень.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// CorrelationKey - ключ атрибута з ідентифікатором кореляції. Один ідентифікатор
+// супроводжує повідомлення від сесії панелі через чергу до приймача.
+const CorrelationKey = "corrID"
+
+// CorrelationID формує ідентифікатор повідомлення: номер сесії панелі і
+// порядковий номер кадру в ній
+func CorrelationID(session, seq uint64) string {
+	return fmt.Sprintf("%d-%d", session, seq)
+}
+
+// Формати виводу
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// NewHandler створює обробник у форматі text (за замовчуванням) або json
+func NewHandler(w io.Writer, format string, opts *slog.HandlerOptions) slog.Handler {
+	if strings.EqualFold(format, FormatJSON) {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// multiHandler передає запис кожному обробнику, що його приймає
+type multiHandler []slog.Handler
+
+// Multi об'єднує обробники: запис отримують усі, для кого рівень увімкнений
+func Multi(handlers ...slog.Handler) slog.Handler {
+	return multiHandler(handlers)
+}
+
+func (m multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range m {
+		if h.Enabled(ctx, r.Level) {
+			if err := h.Handle(ctx, r.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (m multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestMulti(t *testing.T) {
+	var text, json bytes.Buffer
+	ring := NewRing(10)
+	logger := slog.New(Multi(
+		NewHandler(&text, FormatText, &slog.HandlerOptions{Level: slog.LevelWarn}),
+		NewHandler(&json, "JSON", nil),
+		ring.Handler(slog.LevelDebug),
+	)).With("pipeline", "north")
+
+	logger.Debug("dial")
+	logger.Warn("queue full")
+
+	if strings.Contains(text.String(), "dial") || !strings.Contains(text.String(), `msg="queue full" pipeline=north`) {
+		t.Errorf("text output = %q", text.String())
+	}
+	if strings.Contains(json.String(), "dial") || !strings.Contains(json.String(), `"msg":"queue full","pipeline":"north"`) {
+		t.Errorf("json output = %q", json.String())
+	}
+	if got := ring.Last(0, slog.LevelDebug); len(got) != 2 {
+		t.Errorf("ring has %d entries, want 2", len(got))
+	}
+}
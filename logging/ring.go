@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Entry - запис журналу в кільцевому буфері
+type Entry struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+
+	level slog.Level
+}
+
+// Ring зберігає останні записи журналу в пам'яті
+type Ring struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int // Позиція наступного запису
+	full    bool
+}
+
+// NewRing створює буфер на size записів
+func NewRing(size int) *Ring {
+	return &Ring{entries: make([]Entry, max(size, 1))}
+}
+
+func (r *Ring) add(e Entry) {
+	r.mu.Lock()
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+	r.mu.Unlock()
+}
+
+// Last повертає до n найновіших записів рівня minLevel і вище, від старих до
+// нових. n <= 0 - усі записи.
+func (r *Ring) Last(n int, minLevel slog.Level) []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := r.next
+	if r.full {
+		count = len(r.entries)
+	}
+
+	var result []Entry
+	// Ідемо від найновішого, потім розвертаємо
+	for i := 1; i <= count && (n <= 0 || len(result) < n); i++ {
+		e := r.entries[(r.next-i+len(r.entries))%len(r.entries)]
+		if e.level >= minLevel {
+			result = append(result, e)
+		}
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// Handler повертає обробник slog, що записує в буфер записи рівня level і вище
+func (r *Ring) Handler(level slog.Leveler) slog.Handler {
+	return &ringHandler{ring: r, level: level}
+}
+
+type ringHandler struct {
+	ring   *Ring
+	level  slog.Leveler
+	attrs  []slog.Attr
+	prefix string // Префікс груп для ключів атрибутів
+}
+
+func (h *ringHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *ringHandler) Handle(_ context.Context, r slog.Record) error {
+	e := Entry{Time: r.Time, Level: r.Level.String(), Message: r.Message, level: r.Level}
+	if len(h.attrs) > 0 || r.NumAttrs() > 0 {
+		e.Attrs = make(map[string]string, len(h.attrs)+r.NumAttrs())
+		for _, a := range h.attrs {
+			addAttr(e.Attrs, "", a)
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			addAttr(e.Attrs, h.prefix, a)
+			return true
+		})
+	}
+	h.ring.add(e)
+	return nil
+}
+
+// addAttr додає атрибут, розгортаючи групи в ключі через крапку
+func addAttr(attrs map[string]string, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			addAttr(attrs, prefix, ga)
+		}
+		return
+	}
+	if a.Key != "" {
+		attrs[prefix+a.Key] = a.Value.String()
+	}
+}
+
+func (h *ringHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	clone.attrs = append(clone.attrs, h.attrs...)
+	for _, a := range attrs {
+		if h.prefix != "" {
+			a.Key = h.prefix + a.Key
+		}
+		clone.attrs = append(clone.attrs, a)
+	}
+	return &clone
+}
+
+func (h *ringHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
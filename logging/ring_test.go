@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"log/slog"
+	"strconv"
+	"testing"
+)
+
+func TestRing_Last(t *testing.T) {
+	r := NewRing(3)
+	logger := slog.New(r.Handler(slog.LevelDebug))
+	for i := range 5 {
+		logger.Info("line " + strconv.Itoa(i))
+	}
+
+	got := r.Last(0, slog.LevelDebug)
+	if len(got) != 3 || got[0].Message != "line 2" || got[2].Message != "line 4" {
+		t.Fatalf("Last(0) = %+v, want lines 2..4", got)
+	}
+	if got := r.Last(1, slog.LevelDebug); len(got) != 1 || got[0].Message != "line 4" {
+		t.Errorf("Last(1) = %+v, want line 4", got)
+	}
+	if got := r.Last(0, slog.LevelWarn); len(got) != 0 {
+		t.Errorf("Last(WARN) = %+v, want none", got)
+	}
+}
+
+func TestRing_Handler(t *testing.T) {
+	r := NewRing(10)
+	var level slog.LevelVar
+	level.Set(slog.LevelInfo)
+	logger := slog.New(r.Handler(&level))
+
+	logger.Debug("hidden")
+	logger.With("pipeline", "north").WithGroup("conn").Warn("dropped", CorrelationKey, CorrelationID(4, 2), slog.Group("peer", "port", 5000))
+
+	got := r.Last(0, slog.LevelDebug)
+	if len(got) != 1 {
+		t.Fatalf("entries = %+v, want only the warning", got)
+	}
+	want := map[string]string{"pipeline": "north", "conn.corrID": "4-2", "conn.peer.port": "5000"}
+	for k, v := range want {
+		if got[0].Attrs[k] != v {
+			t.Errorf("attr %s = %q, want %q (all: %v)", k, got[0].Attrs[k], v, got[0].Attrs)
+		}
+	}
+	if got[0].Level != "WARN" {
+		t.Errorf("level = %s, want WARN", got[0].Level)
+	}
+}
@@ -0,0 +1,260 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// syslogQueue - скільки записів чекає відправки; при переповненні нові відкидаються
+	syslogQueue        = 1024
+	syslogDialTimeout  = 3 * time.Second
+	syslogWriteTimeout = 3 * time.Second
+	// syslogRetryDelay - пауза перед повторним підключенням після помилки
+	syslogRetryDelay = 5 * time.Second
+)
+
+// syslogFacilities - коди facility за RFC 5424
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// ParseFacility повертає код facility за назвою (наприклад, local0)
+func ParseFacility(name string) (int, error) {
+	code, ok := syslogFacilities[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog facility %q", name)
+	}
+	return code, nil
+}
+
+// severity відповідає рівню slog
+func severity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3 // err
+	case level >= slog.LevelWarn:
+		return 4 // warning
+	case level >= slog.LevelInfo:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
+
+// SyslogOptions - параметри відправки в syslog
+type SyslogOptions struct {
+	Network  string // udp або tcp
+	Address  string // host:port
+	Facility string
+	AppName  string
+	Format   string // Формат тексту повідомлення: text або json
+	Level    slog.Leveler
+}
+
+// Syslog надсилає записи журналу на віддалений syslog-сервер у форматі
+// RFC 5424. UDP - одна датаграма на запис, TCP - з префіксом довжини
+// (RFC 6587, octet counting). Відправка асинхронна: недоступний сервер не
+// гальмує ретранслятор, записи понад чергу відкидаються.
+type Syslog struct {
+	network, address string
+	facility         int
+	header           string // HOSTNAME APP-NAME PROCID
+	level            slog.Leveler
+
+	queue   chan []byte
+	done    chan struct{}
+	stopped chan struct{}
+
+	// Формування тексту запису: inner пише в buf під mu
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	inner slog.Handler
+}
+
+// NewSyslog створює відправник і запускає фонове з'єднання
+func NewSyslog(opts SyslogOptions) (*Syslog, error) {
+	network := strings.ToLower(opts.Network)
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("unknown syslog network %q, want udp or tcp", opts.Network)
+	}
+	facility, err := ParseFacility(opts.Facility)
+	if err != nil {
+		return nil, err
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	appName := opts.AppName
+	if appName == "" {
+		appName = "-"
+	}
+
+	s := &Syslog{
+		network:  network,
+		address:  opts.Address,
+		facility: facility,
+		header:   fmt.Sprintf("%s %s %d", headerField(hostname), headerField(appName), os.Getpid()),
+		level:    opts.Level,
+		queue:    make(chan []byte, syslogQueue),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if s.level == nil {
+		s.level = slog.LevelInfo
+	}
+	// Час і рівень передаються в заголовку syslog
+	s.inner = NewHandler(&s.buf, opts.Format, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	go s.run()
+	return s, nil
+}
+
+// headerField замінює в полі заголовка символи, не дозволені RFC 5424
+func headerField(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// Handler повертає обробник slog, що надсилає записи в syslog
+func (s *Syslog) Handler() slog.Handler {
+	return &syslogHandler{s: s, inner: s.inner}
+}
+
+// Close відправляє записи з черги (не довше за таймаут запису) і закриває з'єднання
+func (s *Syslog) Close() {
+	close(s.done)
+	<-s.stopped
+}
+
+// format будує повідомлення RFC 5424 для запису
+func (s *Syslog) format(ctx context.Context, inner slog.Handler, r slog.Record) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf.Reset()
+	if err := inner.Handle(ctx, r); err != nil {
+		return nil, err
+	}
+	msg := bytes.TrimRight(s.buf.Bytes(), "\n")
+
+	ts := r.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	line := fmt.Appendf(nil, "<%d>1 %s %s - - %s",
+		s.facility*8+severity(r.Level), ts.Format(time.RFC3339Nano), s.header, msg)
+	if s.network == "tcp" {
+		line = append([]byte(strconv.Itoa(len(line))+" "), line...)
+	}
+	return line, nil
+}
+
+func (s *Syslog) enqueue(line []byte) {
+	select {
+	case s.queue <- line:
+	default:
+		// Лог про втрату сам потрапив би сюди, тому лише stderr
+		fmt.Fprintln(os.Stderr, "syslog queue full, log record dropped")
+	}
+}
+
+func (s *Syslog) run() {
+	defer close(s.stopped)
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	var retryAt time.Time
+
+	send := func(line []byte) {
+		if conn == nil {
+			if time.Now().Before(retryAt) {
+				return
+			}
+			c, err := net.DialTimeout(s.network, s.address, syslogDialTimeout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "syslog: %v\n", err)
+				retryAt = time.Now().Add(syslogRetryDelay)
+				return
+			}
+			conn = c
+		}
+		conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+		if _, err := conn.Write(line); err != nil {
+			fmt.Fprintf(os.Stderr, "syslog: %v\n", err)
+			conn.Close()
+			conn = nil
+			retryAt = time.Now().Add(syslogRetryDelay)
+		}
+	}
+
+	for {
+		select {
+		case line := <-s.queue:
+			send(line)
+		case <-s.done:
+			for {
+				select {
+				case line := <-s.queue:
+					send(line)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+type syslogHandler struct {
+	s     *Syslog
+	inner slog.Handler
+}
+
+func (h *syslogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.s.level.Level()
+}
+
+func (h *syslogHandler) Handle(ctx context.Context, r slog.Record) error {
+	line, err := h.s.format(ctx, h.inner, r)
+	if err != nil {
+		return err
+	}
+	h.s.enqueue(line)
+	return nil
+}
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syslogHandler{s: h.s, inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return &syslogHandler{s: h.s, inner: h.inner.WithGroup(name)}
+}
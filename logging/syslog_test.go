@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc5424 - заголовок і текст повідомлення: PRI, версія, час, хост, застосунок, PID, без MSGID і SD
+var rfc5424 = regexp.MustCompile(`^<(\d+)>1 \S+ \S+ relay \d+ - - (.*)$`)
+
+func TestSyslog_UDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	s, err := NewSyslog(SyslogOptions{Network: "udp", Address: pc.LocalAddr().String(), Facility: "local0", AppName: "relay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(s.Handler())
+	logger.Debug("below level")
+	logger.Warn("queue full", CorrelationKey, "1-5")
+	s.Close()
+
+	buf := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := rfc5424.FindStringSubmatch(string(buf[:n]))
+	if m == nil {
+		t.Fatalf("not an RFC 5424 message: %q", buf[:n])
+	}
+	if m[1] != "132" { // local0 (16) * 8 + warning (4)
+		t.Errorf("PRI = %s, want 132", m[1])
+	}
+	if m[2] != `msg="queue full" corrID=1-5` {
+		t.Errorf("MSG = %q", m[2])
+	}
+}
+
+func TestSyslog_TCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	s, err := NewSyslog(SyslogOptions{Network: "tcp", Address: ln.Addr().String(), Facility: "daemon", AppName: "relay", Format: FormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(s.Handler())
+	logger.Info("first")
+	logger.Error("second")
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	r := bufio.NewReader(conn)
+
+	for _, want := range []struct{ pri, msg string }{
+		{"30", `{"msg":"first"}`}, // daemon (3) * 8 + info (6)
+		{"27", `{"msg":"second"}`},
+	} {
+		// Octet counting: "<довжина> <повідомлення>"
+		length, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(length))
+		if err != nil {
+			t.Fatalf("bad frame length %q", length)
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			t.Fatal(err)
+		}
+		m := rfc5424.FindStringSubmatch(string(frame))
+		if m == nil || m[1] != want.pri || m[2] != want.msg {
+			t.Errorf("frame %q, want PRI %s and MSG %s", frame, want.pri, want.msg)
+		}
+	}
+	s.Close()
+}
+
+func TestNewSyslog_Invalid(t *testing.T) {
+	if _, err := NewSyslog(SyslogOptions{Network: "http", Facility: "local0"}); err == nil {
+		t.Error("unknown network accepted")
+	}
+	if _, err := NewSyslog(SyslogOptions{Network: "udp", Facility: "local8"}); err == nil {
+		t.Error("unknown facility accepted")
+	}
+}
@@ -2,38 +2,59 @@ package main
 
 import (
 	"cid_retranslator_walk/adapters"
-	"cid_retranslator_walk/cidparser"
-	"cid_retranslator_walk/client"
 	"cid_retranslator_walk/config"
 	"cid_retranslator_walk/core"
-	"cid_retranslator_walk/metrics"
 	"cid_retranslator_walk/models"
 	"cid_retranslator_walk/ui"
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 func main() {
-	const eventFilePath = "events.json"
-	jsonData, err := os.ReadFile(eventFilePath)
-	if err != nil {
-		log.Fatalf("Failed to load %s: %v", eventFilePath, err)
+	var overrides stringList
+	configPath := flag.String("config", config.DefaultPath, "path to the configuration file")
+	flag.Var(&overrides, "set", "override a configuration field, e.g. -set server.port=20005 (repeatable)")
+	checkConfig := flag.Bool("check-config", false, "validate the configuration file and exit")
+	strictConfig := flag.Bool("strict-config", false, "reject unknown keys in the configuration file")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	flag.Parse()
+
+	// Пріоритет: значення за замовчуванням < файл < змінні CIDR_* < прапорці -set
+	sources := config.Sources{
+		Path:      *configPath,
+		Environ:   os.Environ(),
+		Overrides: overrides,
+		Strict:    *strictConfig,
 	}
 
-	eventMap, err := cidparser.LoadEvents(jsonData)
-	if err != nil {
-		log.Fatal(err)
+	if *checkConfig {
+		sources.Strict = true
+		os.Exit(runConfigCheck(sources))
+	}
+	if *printConfig {
+		os.Exit(runPrintConfig(sources))
 	}
 
-	// 0. Завантажуємо конфігурацію
-	cfg := config.New()
+	// 0. Завантажуємо та перевіряємо конфігурацію
+	cfg, err := sources.Load()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration %s: %v", sources.Path, err)
+	}
 
 	// 1. Ініціалізація Core (TCP сервер/клієнт)
-	stats := metrics.New()
-	retranslator := core.NewApp(stats)
+	retranslator := core.NewApp(cfg, sources)
 
 	// 2. Створюємо моделі UI
 	ppkModel := models.NewPPKModel()
@@ -55,7 +76,7 @@ func main() {
 	}()
 
 	// 6. Ініціалізуємо адаптер
-	adapter := adapters.NewAdapter(eventMap)
+	adapter := adapters.NewAdapter(retranslator.Events(), retranslator.PartitionStates)
 
 	// 7. Завантажуємо початковий стан (якщо є збережені дані)
 	go func() {
@@ -96,7 +117,7 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go startStatsUpdater(ctx, retranslator.GetClient(), statsData, mw)
+	go startStatsUpdater(ctx, retranslator, statsData, mw)
 
 	// 11. Run блокує виконання до закриття вікна
 	slog.Info("Starting UI...")
@@ -104,7 +125,7 @@ func main() {
 
 	// 12. Graceful shutdown після закриття UI
 	slog.Info("UI closed, initiating shutdown...")
-	retranslator.Shutdown(retranslator.Ctx())
+	retranslator.Shutdown(context.Background())
 
 	// Закриваємо UI канали
 	close(ppkChan)
@@ -113,9 +134,64 @@ func main() {
 	slog.Info("Application shutdown complete")
 }
 
+// runConfigCheck перевіряє ефективну конфігурацію (суворо, з відхиленням невідомих ключів)
+// і повертає код виходу процесу
+func runConfigCheck(sources config.Sources) int {
+	path := sources.Path
+	cfg, err := sources.Load()
+	if err == nil {
+		err = cfg.Validate()
+	}
+
+	var validationErr *config.ValidationError
+	switch {
+	case err == nil:
+		fmt.Printf("%s: OK\n", path)
+		return 0
+	case errors.As(err, &validationErr):
+		fmt.Printf("%s: %d problem(s)\n", path, len(validationErr.Errors))
+		for _, fe := range validationErr.Errors {
+			fmt.Printf("  %s\n", fe.Error())
+		}
+	default:
+		fmt.Printf("%s: %v\n", path, err)
+	}
+	return 1
+}
+
+// runPrintConfig виводить ефективну конфігурацію з прихованими секретами
+func runPrintConfig(sources config.Sources) int {
+	cfg, err := sources.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", sources.Path, err)
+		return 1
+	}
+
+	data, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("# Effective configuration: defaults < %s < %s* environment < -set flags\n", sources.Path, config.EnvPrefix)
+	os.Stdout.Write(data)
+	return 0
+}
+
+// stringList - значення прапорця, що може повторюватися
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func startStatsUpdater(
 	ctx context.Context,
-	tcpClient *client.Client,
+	retranslator *core.App,
 	statsData *models.StatsData,
 	mainWindow *ui.MainWindowWithStats,
 ) {
@@ -129,23 +205,16 @@ func startStatsUpdater(
 			slog.Info("Stats updater stopped")
 			return
 		case <-ticker.C:
-			// Отримуємо статистику з клієнта (неблокуюча операція через канал)
-			statsChan := tcpClient.GetQueueStats()
-
-			select {
-			case stats := <-statsChan:
-				// Оновлюємо модель даних
-				statsData.Update(stats)
-
-				// Оновлюємо UI в головному потоці Walk
-				mainWindow.Window.Synchronize(func() {
-					mainWindow.UpdateStats(stats)
-				})
-
-			case <-time.After(500 * time.Millisecond):
-				// Якщо клієнт не відповідає за 500мс, логуємо попередження
-				slog.Warn("Stats channel timeout")
-			}
+			// Отримуємо сумарну статистику всіх конвеєрів
+			stats := retranslator.Stats()
+
+			// Оновлюємо модель даних
+			statsData.Update(stats)
+
+			// Оновлюємо UI в головному потоці Walk
+			mainWindow.Window.Synchronize(func() {
+				mainWindow.UpdateStats(stats)
+			})
 		}
 	}
 }
@@ -0,0 +1,404 @@
+// Package match implements the expression language used by routing rules to
+// select Contact ID messages.
+//
+// Examples:
+//
+//	category == fire
+//	account in 1000..1999 && qualifier == E
+//	code in [E130, E131] || (group == 2 and not zone < 10)
+//
+// Numeric fields: account, event (code without qualifier, e.g. 130), group
+// (alias partition) and zone. Text fields: receiver, qualifier, code and
+// category. Numeric fields support ==, !=, <, <=, >, >=, ranges (a..b) and
+// lists; text fields support ==, != and lists. Comparison of text is case-insensitive.
+package match
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Fields are the parsed message fields an expression is evaluated against.
+type Fields struct {
+	Receiver  string
+	Account   int
+	Qualifier string // "E" or "R"
+	Code      string // Event code with qualifier, e.g. "E130"
+	Event     int    // Event code without qualifier, e.g. 130
+	Group     int
+	Zone      int
+	Category  string // e.g. "fire", "burglary", "technical"
+}
+
+// Expr is a compiled match expression.
+type Expr struct {
+	src  string
+	eval func(*Fields) bool
+}
+
+// Compile parses an expression. An empty expression matches every message.
+func Compile(src string) (*Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return &Expr{src: src, eval: func(*Fields) bool { return true }}, nil
+	}
+
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	eval, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+	return &Expr{src: src, eval: eval}, nil
+}
+
+// Match reports whether the message fields satisfy the expression.
+func (e *Expr) Match(f *Fields) bool {
+	return e.eval(f)
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+type fieldKind int
+
+const (
+	numeric fieldKind = iota
+	text
+)
+
+type field struct {
+	kind fieldKind
+	num  func(*Fields) int
+	str  func(*Fields) string
+}
+
+var fields = map[string]field{
+	"account":   {kind: numeric, num: func(f *Fields) int { return f.Account }},
+	"event":     {kind: numeric, num: func(f *Fields) int { return f.Event }},
+	"group":     {kind: numeric, num: func(f *Fields) int { return f.Group }},
+	"partition": {kind: numeric, num: func(f *Fields) int { return f.Group }},
+	"zone":      {kind: numeric, num: func(f *Fields) int { return f.Zone }},
+	"receiver":  {kind: text, str: func(f *Fields) string { return f.Receiver }},
+	"qualifier": {kind: text, str: func(f *Fields) string { return f.Qualifier }},
+	"code":      {kind: text, str: func(f *Fields) string { return f.Code }},
+	"category":  {kind: text, str: func(f *Fields) string { return f.Category }},
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp     // == != < <= > >=
+	tokAnd    // && and
+	tokOr     // || or
+	tokNot    // ! not
+	tokIn     // in
+	tokRange  // ..
+	tokLParen // (
+	tokRParen // )
+	tokLBrack // [
+	tokRBrack // ]
+	tokComma  // ,
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "&&"):
+			tokens = append(tokens, token{tokAnd, "&&", i})
+			i += 2
+		case strings.HasPrefix(src[i:], "||"):
+			tokens = append(tokens, token{tokOr, "||", i})
+			i += 2
+		case strings.HasPrefix(src[i:], ".."):
+			tokens = append(tokens, token{tokRange, "..", i})
+			i += 2
+		case strings.HasPrefix(src[i:], "=="), strings.HasPrefix(src[i:], "!="),
+			strings.HasPrefix(src[i:], "<="), strings.HasPrefix(src[i:], ">="):
+			tokens = append(tokens, token{tokOp, src[i : i+2], i})
+			i += 2
+		case c == '<' || c == '>':
+			tokens = append(tokens, token{tokOp, string(c), i})
+			i++
+		case c == '!':
+			tokens = append(tokens, token{tokNot, "!", i})
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']' || c == ',':
+			kind := map[byte]tokenKind{'(': tokLParen, ')': tokRParen, '[': tokLBrack, ']': tokRBrack, ',': tokComma}[c]
+			tokens = append(tokens, token{kind, string(c), i})
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(src[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, token{tokString, src[i+1 : i+1+end], i})
+			i += end + 2
+		case isDigit(c):
+			start := i
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, src[start:i], start})
+		case isIdentChar(c):
+			start := i
+			for i < len(src) && (isIdentChar(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			word := src[start:i]
+			kind := tokIdent
+			switch strings.ToLower(word) {
+			case "and":
+				kind = tokAnd
+			case "or":
+				kind = tokOr
+			case "not":
+				kind = tokNot
+			case "in":
+				kind = tokIn
+			}
+			tokens = append(tokens, token{kind, word, start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+		}
+	}
+	return append(tokens, token{tokEOF, "end of expression", len(src)}), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, fmt.Errorf("expected %s at position %d, got %q", what, tok.pos, tok.text)
+	}
+	return tok, nil
+}
+
+// parseOr: and ("||" and)*
+func (p *parser) parseOr() (func(*Fields) bool, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(f *Fields) bool { return l(f) || right(f) }
+	}
+	return left, nil
+}
+
+// parseAnd: unary ("&&" unary)*
+func (p *parser) parseAnd() (func(*Fields) bool, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(f *Fields) bool { return l(f) && right(f) }
+	}
+	return left, nil
+}
+
+// parseUnary: "!" unary | "(" or ")" | comparison
+func (p *parser) parseUnary() (func(*Fields) bool, error) {
+	switch p.peek().kind {
+	case tokNot:
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(f *Fields) bool { return !inner(f) }, nil
+	case tokLParen:
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison: field op value | field "in" (range | list)
+func (p *parser) parseComparison() (func(*Fields) bool, error) {
+	name, err := p.expect(tokIdent, "field name")
+	if err != nil {
+		return nil, err
+	}
+	fld, ok := fields[strings.ToLower(name.text)]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q at position %d", name.text, name.pos)
+	}
+
+	tok := p.next()
+	switch tok.kind {
+	case tokIn:
+		return p.parseIn(fld)
+	case tokOp:
+	default:
+		return nil, fmt.Errorf("expected operator after %q at position %d, got %q", name.text, tok.pos, tok.text)
+	}
+
+	if fld.kind == text {
+		if tok.text != "==" && tok.text != "!=" {
+			return nil, fmt.Errorf("operator %s is not supported for text field %q", tok.text, name.text)
+		}
+		value, err := p.textValue()
+		if err != nil {
+			return nil, err
+		}
+		equal := func(f *Fields) bool { return strings.EqualFold(fld.str(f), value) }
+		if tok.text == "!=" {
+			return func(f *Fields) bool { return !equal(f) }, nil
+		}
+		return equal, nil
+	}
+
+	value, err := p.numberValue()
+	if err != nil {
+		return nil, err
+	}
+	cmp := map[string]func(a, b int) bool{
+		"==": func(a, b int) bool { return a == b },
+		"!=": func(a, b int) bool { return a != b },
+		"<":  func(a, b int) bool { return a < b },
+		"<=": func(a, b int) bool { return a <= b },
+		">":  func(a, b int) bool { return a > b },
+		">=": func(a, b int) bool { return a >= b },
+	}[tok.text]
+	return func(f *Fields) bool { return cmp(fld.num(f), value) }, nil
+}
+
+// parseIn: number ".." number | "[" value ("," value)* "]"
+func (p *parser) parseIn(fld field) (func(*Fields) bool, error) {
+	if p.peek().kind != tokLBrack {
+		if fld.kind != numeric {
+			return nil, fmt.Errorf("ranges are only supported for numeric fields (position %d)", p.peek().pos)
+		}
+		low, err := p.numberValue()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRange, "'..'"); err != nil {
+			return nil, err
+		}
+		high, err := p.numberValue()
+		if err != nil {
+			return nil, err
+		}
+		if low > high {
+			return nil, fmt.Errorf("empty range %d..%d", low, high)
+		}
+		return func(f *Fields) bool { v := fld.num(f); return v >= low && v <= high }, nil
+	}
+
+	p.next()
+	var nums []int
+	var strs []string
+	for {
+		if fld.kind == numeric {
+			v, err := p.numberValue()
+			if err != nil {
+				return nil, err
+			}
+			nums = append(nums, v)
+		} else {
+			v, err := p.textValue()
+			if err != nil {
+				return nil, err
+			}
+			strs = append(strs, strings.ToLower(v))
+		}
+		tok := p.next()
+		if tok.kind == tokRBrack {
+			break
+		}
+		if tok.kind != tokComma {
+			return nil, fmt.Errorf("expected ',' or ']' at position %d, got %q", tok.pos, tok.text)
+		}
+	}
+
+	if fld.kind == numeric {
+		return func(f *Fields) bool { return slices.Contains(nums, fld.num(f)) }, nil
+	}
+	return func(f *Fields) bool { return slices.Contains(strs, strings.ToLower(fld.str(f))) }, nil
+}
+
+func (p *parser) numberValue() (int, error) {
+	tok, err := p.expect(tokNumber, "number")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(tok.text)
+}
+
+func (p *parser) textValue() (string, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokIdent, tokString, tokNumber:
+		return tok.text, nil
+	}
+	return "", fmt.Errorf("expected value at position %d, got %q", tok.pos, tok.text)
+}
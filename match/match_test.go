@@ -0,0 +1,65 @@
+package match
+
+import "testing"
+
+func TestCompile_Match(t *testing.T) {
+	fire := &Fields{Receiver: "5010", Account: 1234, Qualifier: "E", Code: "E110", Event: 110, Group: 1, Zone: 5, Category: "fire"}
+	burglary := &Fields{Receiver: "5011", Account: 2500, Qualifier: "R", Code: "R130", Event: 130, Group: 2, Zone: 12, Category: "burglary"}
+
+	tests := []struct {
+		expr     string
+		fire     bool
+		burglary bool
+	}{
+		{"", true, true},
+		{"category == fire", true, false},
+		{"category == 'FIRE'", true, false},
+		{"category != fire", false, true},
+		{"account in 1000..1999", true, false},
+		{"account >= 2000 && qualifier == R", false, true},
+		{"code in [E110, R130]", true, true},
+		{"event in [130, 131]", false, true},
+		{"partition == 2", false, true},
+		{"receiver == 5010", true, false},
+		{"not zone < 10", false, true},
+		{"!(zone < 10) or category == fire", true, true},
+		{"category == fire and (account < 1000 || group == 1)", true, false},
+		{"zone > 4 && zone <= 12 && group != 3", true, true},
+	}
+
+	for _, tt := range tests {
+		expr, err := Compile(tt.expr)
+		if err != nil {
+			t.Errorf("Compile(%q) error: %v", tt.expr, err)
+			continue
+		}
+		if got := expr.Match(fire); got != tt.fire {
+			t.Errorf("%q on fire = %v, want %v", tt.expr, got, tt.fire)
+		}
+		if got := expr.Match(burglary); got != tt.burglary {
+			t.Errorf("%q on burglary = %v, want %v", tt.expr, got, tt.burglary)
+		}
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []string{
+		"colour == red",
+		"account == ",
+		"account == abc",
+		"category < fire",
+		"category in 1..3",
+		"account in 10..1",
+		"account in [1, 2",
+		"(account == 1",
+		"account == 1 zone == 2",
+		"account = 1",
+		"code == 'E130",
+		"account == 1 &&",
+	}
+	for _, src := range tests {
+		if _, err := Compile(src); err == nil {
+			t.Errorf("Compile(%q) should fail", src)
+		}
+	}
+}
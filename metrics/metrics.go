@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -11,8 +12,31 @@ type Stats struct {
 	accepted   atomic.Int64
 	rejected   atomic.Int64
 	reconnects atomic.Int64
+	duplicates atomic.Int64
+	retries    atomic.Int64
+	deadLetter atomic.Int64
+	linkRTT    atomic.Int64 // Наносекунди
+	linkDown   atomic.Int64
 	startTime  time.Time
 	connected  atomic.Bool
+
+	classMu sync.RWMutex
+	classes map[string]*classCounters
+}
+
+// classCounters - лічильники одного класу пріоритетної черги
+type classCounters struct {
+	enqueued atomic.Int64
+	dequeued atomic.Int64
+	dropped  atomic.Int64
+}
+
+// ClassSnapshot - знімок лічильників класу черги
+type ClassSnapshot struct {
+	Enqueued int64 `json:"enqueued"`
+	Dequeued int64 `json:"dequeued"`
+	Dropped  int64 `json:"dropped"`
+	Depth    int64 `json:"depth"`
 }
 
 // Snapshot - знімок статистики на момент часу
@@ -20,8 +44,15 @@ type Snapshot struct {
 	Accepted   int64         `json:"accepted"`
 	Rejected   int64         `json:"rejected"`
 	Reconnects int64         `json:"reconnects"`
+	Duplicates int64         `json:"duplicates"`
+	Retries    int64         `json:"retries"`      // Повторні відправки після NACK
+	DeadLetter int64         `json:"deadLettered"` // Повідомлення, переміщені в сховище недоставлених
+	LinkRTT    time.Duration `json:"linkRtt"`      // Час відповіді на останній тест зв'язку
+	LinkDown   int64         `json:"linkDown"`     // Тести зв'язку без відповіді
 	Uptime     time.Duration `json:"uptime"`
 	Connected  bool          `json:"connected"`
+
+	Classes map[string]ClassSnapshot `json:"classes,omitempty"`
 }
 
 // New створює новий екземпляр статистики
@@ -46,6 +77,67 @@ func (s *Stats) IncrementReconnects() {
 	s.reconnects.Add(1)
 }
 
+// IncrementDuplicates збільшує лічильник придушених повторних кадрів
+func (s *Stats) IncrementDuplicates() {
+	s.duplicates.Add(1)
+}
+
+// IncrementRetries збільшує лічильник повторних відправок після NACK
+func (s *Stats) IncrementRetries() {
+	s.retries.Add(1)
+}
+
+// IncrementDeadLettered збільшує лічильник повідомлень, переміщених у сховище недоставлених
+func (s *Stats) IncrementDeadLettered() {
+	s.deadLetter.Add(1)
+}
+
+// RecordLinkRTT зберігає час відповіді приймача на тест зв'язку
+func (s *Stats) RecordLinkRTT(rtt time.Duration) {
+	s.linkRTT.Store(int64(rtt))
+}
+
+// IncrementLinkDown збільшує лічильник тестів зв'язку, що залишилися без відповіді
+func (s *Stats) IncrementLinkDown() {
+	s.linkDown.Add(1)
+}
+
+// IncrementClassEnqueued збільшує лічильник повідомлень, прийнятих у клас черги
+func (s *Stats) IncrementClassEnqueued(class string) {
+	s.class(class).enqueued.Add(1)
+}
+
+// IncrementClassDequeued збільшує лічильник повідомлень, виданих з класу черги
+func (s *Stats) IncrementClassDequeued(class string) {
+	s.class(class).dequeued.Add(1)
+}
+
+// IncrementClassDropped збільшує лічильник повідомлень, відхилених через переповнення класу
+func (s *Stats) IncrementClassDropped(class string) {
+	s.class(class).dropped.Add(1)
+}
+
+// class повертає (створюючи за потреби) лічильники класу
+func (s *Stats) class(name string) *classCounters {
+	s.classMu.RLock()
+	c, ok := s.classes[name]
+	s.classMu.RUnlock()
+	if ok {
+		return c
+	}
+
+	s.classMu.Lock()
+	defer s.classMu.Unlock()
+	if s.classes == nil {
+		s.classes = make(map[string]*classCounters)
+	}
+	if c, ok = s.classes[name]; !ok {
+		c = &classCounters{}
+		s.classes[name] = c
+	}
+	return c
+}
+
 // SetConnected встановлює статус підключення
 func (s *Stats) SetConnected(status bool) {
 	s.connected.Store(status)
@@ -61,18 +153,80 @@ func (s *Stats) Reset() {
 	s.accepted.Store(0)
 	s.rejected.Store(0)
 	s.reconnects.Store(0)
+	s.duplicates.Store(0)
+	s.retries.Store(0)
+	s.deadLetter.Store(0)
+	s.linkRTT.Store(0)
+	s.linkDown.Store(0)
+	s.classMu.Lock()
+	s.classes = nil
+	s.classMu.Unlock()
 	s.startTime = time.Now()
 }
 
 // Snapshot повертає знімок поточної статистики
 func (s *Stats) Snapshot() Snapshot {
+	var classes map[string]ClassSnapshot
+	s.classMu.RLock()
+	if len(s.classes) > 0 {
+		classes = make(map[string]ClassSnapshot, len(s.classes))
+		for name, c := range s.classes {
+			enq, deq := c.enqueued.Load(), c.dequeued.Load()
+			classes[name] = ClassSnapshot{
+				Enqueued: enq,
+				Dequeued: deq,
+				Dropped:  c.dropped.Load(),
+				Depth:    enq - deq,
+			}
+		}
+	}
+	s.classMu.RUnlock()
+
 	return Snapshot{
 		Accepted:   s.accepted.Load(),
 		Rejected:   s.rejected.Load(),
 		Reconnects: s.reconnects.Load(),
+		Duplicates: s.duplicates.Load(),
+		Retries:    s.retries.Load(),
+		DeadLetter: s.deadLetter.Load(),
+		LinkRTT:    time.Duration(s.linkRTT.Load()),
+		LinkDown:   s.linkDown.Load(),
 		Uptime:     time.Since(s.startTime),
 		Connected:  s.connected.Load(),
+		Classes:    classes,
+	}
+}
+
+// Sum об'єднує знімки кількох конвеєрів: лічильники додаються, uptime і RTT беруться
+// найбільші, Connected істинний лише якщо підключені всі
+func Sum(snapshots ...Snapshot) Snapshot {
+	var total Snapshot
+	total.Connected = len(snapshots) > 0
+	for _, snap := range snapshots {
+		total.Accepted += snap.Accepted
+		total.Rejected += snap.Rejected
+		total.Reconnects += snap.Reconnects
+		total.Duplicates += snap.Duplicates
+		total.Retries += snap.Retries
+		total.DeadLetter += snap.DeadLetter
+		total.LinkRTT = max(total.LinkRTT, snap.LinkRTT)
+		total.LinkDown += snap.LinkDown
+		total.Uptime = max(total.Uptime, snap.Uptime)
+		total.Connected = total.Connected && snap.Connected
+
+		for name, c := range snap.Classes {
+			if total.Classes == nil {
+				total.Classes = make(map[string]ClassSnapshot)
+			}
+			sum := total.Classes[name]
+			sum.Enqueued += c.Enqueued
+			sum.Dequeued += c.Dequeued
+			sum.Dropped += c.Dropped
+			sum.Depth += c.Depth
+			total.Classes[name] = sum
+		}
 	}
+	return total
 }
 
 // UptimeString повертає uptime у форматі HH:MM:SS
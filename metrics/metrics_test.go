@@ -132,6 +132,37 @@ func TestSnapshot(t *testing.T) {
 	}
 }
 
+func TestSum(t *testing.T) {
+	a := Snapshot{Accepted: 2, Rejected: 1, Retries: 3, LinkRTT: 20 * time.Millisecond, Uptime: time.Minute, Connected: true,
+		Classes: map[string]ClassSnapshot{"alarm": {Enqueued: 3, Depth: 1}}}
+	b := Snapshot{Accepted: 5, Duplicates: 4, DeadLetter: 1, LinkRTT: 5 * time.Millisecond, LinkDown: 2, Uptime: time.Hour, Connected: false,
+		Classes: map[string]ClassSnapshot{"alarm": {Enqueued: 2, Depth: 2}, "test": {Dropped: 1}}}
+
+	total := Sum(a, b)
+	if total.Accepted != 7 || total.Rejected != 1 || total.Duplicates != 4 || total.Retries != 3 || total.DeadLetter != 1 {
+		t.Errorf("unexpected counters: %+v", total)
+	}
+	if total.LinkRTT != 20*time.Millisecond || total.LinkDown != 2 {
+		t.Errorf("link = %s/%d, want the largest RTT and summed failures", total.LinkRTT, total.LinkDown)
+	}
+	if total.Uptime != time.Hour {
+		t.Errorf("Uptime = %s, want the largest", total.Uptime)
+	}
+	if total.Connected {
+		t.Error("Connected should be false when any pipeline is disconnected")
+	}
+	if got := total.Classes["alarm"]; got.Enqueued != 5 || got.Depth != 3 {
+		t.Errorf("alarm class = %+v", got)
+	}
+	if total.Classes["test"].Dropped != 1 {
+		t.Errorf("test class = %+v", total.Classes["test"])
+	}
+
+	if Sum().Connected {
+		t.Error("empty sum should not be connected")
+	}
+}
+
 func TestSnapshotUptimeString(t *testing.T) {
 	snap := Snapshot{
 		Uptime: 3665 * time.Second, // 1h 1m 5s
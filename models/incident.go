@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lxn/walk"
+)
+
+// Стани інциденту для колонки "Стан"
+const (
+	IncidentOpen         = "Очікує"
+	IncidentEscalated    = "Ескальовано"
+	IncidentAcknowledged = "Підтверджено"
+)
+
+type IncidentItem struct {
+	ID         string
+	Opened     time.Time
+	Device     string
+	Zone       string
+	Code       string
+	Desc       string
+	Count      int
+	Status     string
+	Resolution string
+	Comment    string
+}
+
+// IncidentModel - таблиця інцидентів; список повністю замінюється через SetItems
+type IncidentModel struct {
+	walk.TableModelBase
+	items []*IncidentItem
+}
+
+func NewIncidentModel() *IncidentModel {
+	return &IncidentModel{}
+}
+
+func (m *IncidentModel) RowCount() int {
+	return len(m.items)
+}
+
+func (m *IncidentModel) Value(row, col int) interface{} {
+	if row < 0 || row >= len(m.items) {
+		return nil
+	}
+	item := m.items[row]
+	switch col {
+	case 0:
+		return item.ID
+	case 1:
+		return item.Opened.Format("15:04:05 2006-01-02")
+	case 2:
+		return item.Device
+	case 3:
+		return item.Zone
+	case 4:
+		return item.Code
+	case 5:
+		return item.Desc
+	case 6:
+		return item.Count
+	case 7:
+		return item.Status
+	case 8:
+		if item.Comment == "" {
+			return item.Resolution
+		}
+		return item.Resolution + ": " + item.Comment
+	}
+	return nil
+}
+
+func (m *IncidentModel) GetItem(row int) *IncidentItem {
+	if row < 0 || row >= len(m.items) {
+		return nil
+	}
+	return m.items[row]
+}
+
+// SetItems замінює список (викликати в UI потоці)
+func (m *IncidentModel) SetItems(items []*IncidentItem) {
+	m.items = items
+	m.PublishRowsReset()
+}
@@ -9,12 +9,22 @@ import (
 	"github.com/lxn/walk"
 )
 
+// Стан охорони ППК; порожній, якщо постановок і знять ще не було
+const (
+	StatusArmed     = "Під охороною"
+	StatusDisarmed  = "Знято з охорони"
+	StatusPartial   = "Частково під охороною"
+	StatusEarlyOpen = "Раннє зняття"
+	StatusLateClose = "Не поставлено вчасно"
+)
+
 type PPKItem struct {
-	Number int
-	Name   string
-	Event  string
-	Date   time.Time
-	Status string
+	Pipeline string // Мітка конвеєра (порожня для єдиного конвеєра)
+	Number   int
+	Name     string
+	Event    string
+	Date     time.Time
+	Status   string // Стан охорони, див. Status*
 }
 
 type PPKModel struct {
@@ -50,8 +60,10 @@ func (m *PPKModel) Value(row, col int) interface{} {
 	case 1:
 		return item.Name
 	case 2:
-		return item.Event
+		return item.Status
 	case 3:
+		return item.Event
+	case 4:
 		return item.Date.Format("15:04:05 2006-01-02")
 	}
 	return nil
@@ -76,8 +88,10 @@ func (m *PPKModel) Sort(col int, order walk.SortOrder) error {
 		case 1:
 			return c(a.Name < b.Name)
 		case 2:
-			return c(a.Event < b.Event)
+			return c(a.Status < b.Status)
 		case 3:
+			return c(a.Event < b.Event)
+		case 4:
 			return c(a.Date.Before(b.Date))
 		}
 		return false
@@ -155,7 +169,7 @@ func (m *PPKModel) StartListening(dataChan <-chan *PPKItem) {
 					found := false
 					// 1. Шукаємо в існуючих елементах
 					for i, existing := range m.items {
-						if existing.Number == item.Number {
+						if existing.Number == item.Number && existing.Pipeline == item.Pipeline {
 							m.items[i] = item
 							updatedRows[i] = true
 							found = true
@@ -166,7 +180,7 @@ func (m *PPKModel) StartListening(dataChan <-chan *PPKItem) {
 					// 2. Якщо не знайшли, шукаємо в доданих у цій пачці
 					if !found {
 						for i, added := range addedRows {
-							if added.Number == item.Number {
+							if added.Number == item.Number && added.Pipeline == item.Pipeline {
 								addedRows[i] = item
 								found = true
 								break
@@ -0,0 +1,287 @@
+// Package mqtt публікує ретрансльовані події і стан пристроїв у брокер MQTT
+// для систем автоматизації будівлі:
+//
+//	<prefix>/<pipeline>/<account>/event - кожна подія з ACK (forward.Event)
+//	<prefix>/<account>/state            - збережений (retained) стан пристрою (State)
+//	<prefix>/relay/status               - збережений стан ретранслятора: online або
+//	                                      offline (last will при обриві зв'язку)
+//
+// Підтримується лише MQTT 3.1.1: клієнт paho v3 не реалізує MQTT 5, тож
+// властивості повідомлень, коди причин і псевдоніми тем не використовуються.
+// Брокери MQTT 5 приймають такі з'єднання без змін налаштувань.
+package mqtt
+
+import (
+	"cid_retranslator_walk/cidparser"
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/forward"
+	"cid_retranslator_walk/ratelimiter"
+	"cid_retranslator_walk/server"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// Стан ретранслятора в StatusTopic
+	StatusOnline  = "online"
+	StatusOffline = "offline"
+
+	// publishTimeout - скільки чекати підтвердження брокера для одного повідомлення
+	publishTimeout = 5 * time.Second
+	// superviseInterval - як часто пристрої без подій позначаються offline
+	superviseInterval = 10 * time.Second
+)
+
+// State - стан пристрою, що публікується з прапорцем retained
+type State struct {
+	Account   int            `json:"account"`
+	Object    string         `json:"object,omitempty"`
+	Pipeline  string         `json:"pipeline"`
+	Online    bool           `json:"online"` // false - немає подій довше за monitoring.ppktimeout
+	LastSeen  time.Time      `json:"lastSeen"`
+	LastEvent *forward.Event `json:"lastEvent,omitempty"`
+}
+
+// message - повідомлення, що чекає на відправку брокеру
+type message struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  []byte
+}
+
+// Publisher публікує події і стан пристроїв. Повідомлення ставляться в чергу
+// і відправляються окремою горутиною, тож недоступний брокер не затримує ACK панелі.
+type Publisher struct {
+	client   paho.Client
+	prefix   string
+	eventQoS byte
+	stateQoS byte
+	events   forward.Descriptions
+	objects  func(account int) string
+
+	queue     chan message
+	connected chan struct{} // Сигнал про (пере)підключення до брокера
+	dropped   atomic.Int64
+	dropLog   *ratelimiter.RateLimiter
+
+	mu     sync.Mutex
+	states map[int]*State
+}
+
+// New створює Publisher. events дає описи кодів (може бути nil), objects -
+// назву об'єкта за номером (може бути nil). З'єднання встановлює Run.
+func New(cfg *config.MQTTConfig, events forward.Descriptions, objects func(account int) string) *Publisher {
+	p := &Publisher{
+		prefix:    cfg.TopicPrefix,
+		eventQoS:  byte(cfg.EventQoS),
+		stateQoS:  byte(cfg.StateQoS),
+		events:    events,
+		objects:   objects,
+		queue:     make(chan message, cfg.QueueSize),
+		connected: make(chan struct{}, 1),
+		dropLog:   ratelimiter.NewRateLimiter(0.1, 1),
+		states:    make(map[int]*State),
+	}
+
+	clientID := cfg.ClientID
+	if clientID == "" {
+		host, _ := os.Hostname()
+		clientID = "cid-relay-" + host
+	}
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(clientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetProtocolVersion(4). // MQTT 3.1.1, без відкату до 3.1
+		SetKeepAlive(cfg.KeepAlive).
+		SetWill(p.StatusTopic(), StatusOffline, p.stateQoS, true).
+		SetConnectRetry(true).
+		SetAutoReconnect(true).
+		SetMaxReconnectInterval(time.Minute).
+		SetOnConnectHandler(func(paho.Client) {
+			slog.Info("Connected to MQTT broker", "broker", cfg.Broker, "clientID", clientID)
+			select {
+			case p.connected <- struct{}{}:
+			default:
+			}
+		}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			slog.Warn("MQTT connection lost, reconnecting", "broker", cfg.Broker, "error", err)
+		})
+	p.client = paho.NewClient(opts)
+	return p
+}
+
+// EventTopic повертає тему подій об'єкта account конвеєра pipeline
+func (p *Publisher) EventTopic(pipeline string, account int) string {
+	return fmt.Sprintf("%s/%s/%d/event", p.prefix, pipeline, account)
+}
+
+// StateTopic повертає тему стану об'єкта account
+func (p *Publisher) StateTopic(account int) string {
+	return fmt.Sprintf("%s/%d/state", p.prefix, account)
+}
+
+// StatusTopic повертає тему стану ретранслятора
+func (p *Publisher) StatusTopic() string {
+	return p.prefix + "/relay/status"
+}
+
+// Submit публікує подію з кадру і оновлює стан пристрою. Як і пересилання
+// HTTP, враховуються лише кадри з ACK. Не блокується: якщо черга повна,
+// повідомлення відкидається. Підходить як server.Observer.
+func (p *Publisher) Submit(pipeline string, fr server.Frame) {
+	if fr.Outcome != server.OutcomeAck {
+		return
+	}
+	m, err := cidparser.Parse(fr.Payload)
+	if err != nil {
+		slog.Debug("Cannot parse message for MQTT", "error", err, "pipeline", pipeline)
+		return
+	}
+	var object string
+	if p.objects != nil {
+		object = p.objects(m.Account)
+	}
+	ev := forward.NewEvent(pipeline, fr, m, p.events, object)
+	p.enqueue(p.EventTopic(pipeline, m.Account), p.eventQoS, false, ev)
+
+	st := State{
+		Account:   m.Account,
+		Object:    object,
+		Pipeline:  pipeline,
+		Online:    true,
+		LastSeen:  fr.Received,
+		LastEvent: &ev,
+	}
+	tracked := st // Supervise змінює збережену копію
+	p.mu.Lock()
+	p.states[m.Account] = &tracked
+	p.mu.Unlock()
+	p.enqueue(p.StateTopic(m.Account), p.stateQoS, true, st)
+}
+
+// enqueue кодує v і ставить повідомлення в чергу без блокування
+func (p *Publisher) enqueue(topic string, qos byte, retained bool, v any) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("Cannot encode MQTT message", "topic", topic, "error", err)
+		return
+	}
+	select {
+	case p.queue <- message{topic: topic, qos: qos, retained: retained, payload: payload}:
+	default:
+		p.drop(topic, "queue full")
+	}
+}
+
+func (p *Publisher) drop(topic, reason string) {
+	p.dropped.Add(1)
+	if p.dropLog.Allow() {
+		slog.Warn("MQTT message dropped", "topic", topic, "reason", reason, "dropped", p.dropped.Load())
+	}
+}
+
+// Run підключається до брокера і публікує повідомлення до скасування ctx.
+// timeout повертає поточний monitoring.ppktimeout: пристрій без подій довше
+// за нього отримує стан offline. Перед виходом публікується стан offline
+// ретранслятора і з'єднання закривається.
+func (p *Publisher) Run(ctx context.Context, timeout func() time.Duration) {
+	// З SetConnectRetry підключення повторюється у фоні; результат приходить в OnConnect
+	p.client.Connect()
+
+	ticker := time.NewTicker(superviseInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			p.stop()
+			return
+		case <-p.connected:
+			p.resync()
+		case msg := <-p.queue:
+			p.publish(msg)
+		case now := <-ticker.C:
+			p.Supervise(now, timeout())
+		}
+	}
+}
+
+// resync після (пере)підключення публікує стан ретранслятора і всіх пристроїв,
+// бо повідомлення, відкинуті без з'єднання, могли його змінити
+func (p *Publisher) resync() {
+	p.publish(message{topic: p.StatusTopic(), qos: p.stateQoS, retained: true, payload: []byte(StatusOnline)})
+
+	p.mu.Lock()
+	states := make([]State, 0, len(p.states))
+	for _, st := range p.states {
+		states = append(states, *st)
+	}
+	p.mu.Unlock()
+	for _, st := range states {
+		payload, err := json.Marshal(st)
+		if err != nil {
+			continue
+		}
+		p.publish(message{topic: p.StateTopic(st.Account), qos: p.stateQoS, retained: true, payload: payload})
+	}
+}
+
+// Supervise позначає offline пристрої, від яких немає подій довше за timeout
+func (p *Publisher) Supervise(now time.Time, timeout time.Duration) {
+	p.mu.Lock()
+	var offline []State
+	for _, st := range p.states {
+		if st.Online && now.Sub(st.LastSeen) > timeout {
+			st.Online = false
+			offline = append(offline, *st)
+		}
+	}
+	p.mu.Unlock()
+	for _, st := range offline {
+		slog.Info("Device is offline", "account", st.Account, "pipeline", st.Pipeline, "lastSeen", st.LastSeen)
+		p.enqueue(p.StateTopic(st.Account), p.stateQoS, true, st)
+	}
+}
+
+// publish відправляє повідомлення брокеру. Без з'єднання повідомлення
+// відкидається: стан пристроїв буде повторно опубліковано після підключення.
+func (p *Publisher) publish(msg message) {
+	if !p.client.IsConnectionOpen() {
+		p.drop(msg.topic, "not connected")
+		return
+	}
+	token := p.client.Publish(msg.topic, msg.qos, msg.retained, msg.payload)
+	if !token.WaitTimeout(publishTimeout) {
+		p.drop(msg.topic, "broker did not acknowledge in time")
+		return
+	}
+	if err := token.Error(); err != nil {
+		p.drop(msg.topic, err.Error())
+	}
+}
+
+// stop публікує решту черги, стан offline ретранслятора і закриває з'єднання
+// (last will брокер надсилає лише при обриві, а не при штатному відключенні)
+func (p *Publisher) stop() {
+	if p.client.IsConnectionOpen() {
+		for len(p.queue) > 0 {
+			p.publish(<-p.queue)
+		}
+		p.publish(message{topic: p.StatusTopic(), qos: p.stateQoS, retained: true, payload: []byte(StatusOffline)})
+	}
+	p.client.Disconnect(250)
+	if n := p.dropped.Load(); n > 0 {
+		slog.Info("MQTT publisher stopped", "dropped", n)
+	}
+}
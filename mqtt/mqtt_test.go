@@ -0,0 +1,215 @@
+package mqtt
+
+import (
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/forward"
+	"cid_retranslator_walk/server"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// broker - вбудований брокер MQTT для тестів
+type broker struct {
+	*mochi.Server
+	addr string
+
+	mu       sync.Mutex
+	messages chan packets.Packet
+	will     packets.ConnectParams
+}
+
+func newBroker(t *testing.T) *broker {
+	t.Helper()
+	b := &broker{
+		Server:   mochi.New(&mochi.Options{InlineClient: true}),
+		messages: make(chan packets.Packet, 100),
+	}
+	if err := b.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddHook(&connectHook{b: b}, nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	if err := b.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	b.addr = "tcp://" + tcp.Address()
+
+	err := b.Subscribe("#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		b.messages <- pk
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// connectHook запам'ятовує last will клієнта
+type connectHook struct {
+	mochi.HookBase
+	b *broker
+}
+
+func (h *connectHook) ID() string { return "connect" }
+
+func (h *connectHook) Provides(b byte) bool { return b == mochi.OnConnect }
+
+func (h *connectHook) OnConnect(_ *mochi.Client, pk packets.Packet) error {
+	h.b.mu.Lock()
+	h.b.will = pk.Connect
+	h.b.mu.Unlock()
+	return nil
+}
+
+// next чекає наступне повідомлення в темі topic
+func (b *broker) next(t *testing.T, topic string) packets.Packet {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case pk := <-b.messages:
+			if pk.TopicName == topic {
+				return pk
+			}
+		case <-deadline:
+			t.Fatalf("timeout waiting for a message in %s", topic)
+		}
+	}
+}
+
+func newPublisher(t *testing.T, b *broker) (*Publisher, context.CancelFunc) {
+	t.Helper()
+	cfg := &config.MQTTConfig{
+		Broker:      b.addr,
+		ClientID:    "test-relay",
+		TopicPrefix: "cid",
+		EventQoS:    1,
+		StateQoS:    1,
+		KeepAlive:   30 * time.Second,
+		QueueSize:   100,
+	}
+	p := New(cfg, nil, func(account int) string { return fmt.Sprintf("Об'єкт %d", account) })
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx, func() time.Duration { return time.Hour })
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return p, cancel
+}
+
+func frame(account int, code string, outcome string) server.Frame {
+	now := time.Now()
+	return server.Frame{
+		ID:       "1-1",
+		Payload:  fmt.Appendf(nil, "5010 18%04d%s01015\x14", account, code),
+		DeviceID: account,
+		Received: now,
+		Replied:  now,
+		Outcome:  outcome,
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	b := newBroker(t)
+	p, _ := newPublisher(t, b)
+
+	status := b.next(t, "cid/relay/status")
+	if string(status.Payload) != StatusOnline || !status.FixedHeader.Retain {
+		t.Errorf("status = %q (retain %v), want retained %q", status.Payload, status.FixedHeader.Retain, StatusOnline)
+	}
+	b.mu.Lock()
+	will := b.will
+	b.mu.Unlock()
+	if !will.WillFlag || will.WillTopic != "cid/relay/status" || string(will.WillPayload) != StatusOffline || !will.WillRetain {
+		t.Errorf("last will = %q %q (retain %v)", will.WillTopic, will.WillPayload, will.WillRetain)
+	}
+
+	p.Submit("default", frame(1234, "E130", server.OutcomeNack))
+	p.Submit("default", frame(1234, "E130", server.OutcomeAck))
+
+	pk := b.next(t, "cid/default/1234/event")
+	var ev forward.Event
+	if err := json.Unmarshal(pk.Payload, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Account != 1234 || ev.Code != "E130" || ev.Object != "Об'єкт 1234" || pk.FixedHeader.Retain {
+		t.Errorf("event = %+v (retain %v)", ev, pk.FixedHeader.Retain)
+	}
+
+	pk = b.next(t, "cid/1234/state")
+	var st State
+	if err := json.Unmarshal(pk.Payload, &st); err != nil {
+		t.Fatal(err)
+	}
+	if !st.Online || st.LastEvent == nil || st.LastEvent.Code != "E130" || !pk.FixedHeader.Retain {
+		t.Errorf("state = %+v (retain %v)", st, pk.FixedHeader.Retain)
+	}
+
+	// Пристрій без подій довше за таймаут стає offline
+	p.Supervise(time.Now().Add(2*time.Minute), time.Minute)
+	pk = b.next(t, "cid/1234/state")
+	if err := json.Unmarshal(pk.Payload, &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Online {
+		t.Error("device still online after supervision timeout")
+	}
+
+	// NACK-кадр не публікується
+	select {
+	case pk := <-b.messages:
+		t.Errorf("unexpected message in %s", pk.TopicName)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPublisher_StopPublishesOffline(t *testing.T) {
+	b := newBroker(t)
+	_, cancel := newPublisher(t, b)
+	b.next(t, "cid/relay/status")
+
+	cancel()
+	if pk := b.next(t, "cid/relay/status"); string(pk.Payload) != StatusOffline || !pk.FixedHeader.Retain {
+		t.Errorf("status = %q (retain %v), want retained %q", pk.Payload, pk.FixedHeader.Retain, StatusOffline)
+	}
+}
+
+func TestPublisher_QueueFull(t *testing.T) {
+	// Без Run черга не розбирається: Submit не має блокуватися
+	p := New(&config.MQTTConfig{Broker: "tcp://127.0.0.1:1", TopicPrefix: "cid", QueueSize: 2}, nil, nil)
+	done := make(chan struct{})
+	go func() {
+		for range 5 {
+			p.Submit("default", frame(1234, "E130", server.OutcomeAck))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Submit blocked on a full queue")
+	}
+	// По два повідомлення (подія і стан) на кадр, у черзі вміщуються два
+	if n := p.dropped.Load(); n != 8 {
+		t.Errorf("dropped = %d, want 8", n)
+	}
+}
@@ -0,0 +1,200 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTelegramAPI - адреса Telegram Bot API
+const DefaultTelegramAPI = "https://api.telegram.org"
+
+// Webhook надсилає сповіщення як JSON (Alert) методом POST
+type Webhook struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+// NewWebhook створює канал, що надсилає сповіщення на url
+func NewWebhook(name, url string) *Webhook {
+	return &Webhook{name: name, url: url, client: &http.Client{}}
+}
+
+func (w *Webhook) Name() string { return w.name }
+
+func (w *Webhook) Send(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, w.client, w.url, body, nil)
+}
+
+// Telegram надсилає сповіщення в чат через Bot API
+type Telegram struct {
+	name   string
+	apiURL string
+	token  string
+	chatID string
+	client *http.Client
+}
+
+// NewTelegram створює канал бота token для чату chatID. Порожній apiURL - DefaultTelegramAPI.
+func NewTelegram(name, token, chatID, apiURL string) *Telegram {
+	if apiURL == "" {
+		apiURL = DefaultTelegramAPI
+	}
+	return &Telegram{
+		name:   name,
+		apiURL: strings.TrimRight(apiURL, "/"),
+		token:  token,
+		chatID: chatID,
+		client: &http.Client{},
+	}
+}
+
+func (t *Telegram) Name() string { return t.name }
+
+func (t *Telegram) Send(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(map[string]string{"chat_id": t.chatID, "text": a.Text()})
+	if err != nil {
+		return err
+	}
+	var reply struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	err = postJSON(ctx, t.client, t.apiURL+"/bot"+t.token+"/sendMessage", body, &reply)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		// Адреса містить токен бота - не показуємо її в лозі
+		err = urlErr.Err
+	}
+	if err == nil && !reply.OK {
+		err = fmt.Errorf("telegram: %s", reply.Description)
+	}
+	return err
+}
+
+// postJSON надсилає body і, якщо reply не nil, розбирає JSON відповіді.
+// Відповідь не 2xx вважається помилкою.
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, reply any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if reply != nil && json.Unmarshal(data, reply) == nil {
+		// Результат (і причину помилки при статусі не 2xx) перевіряє викликач
+		return nil
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// SMTPOptions - параметри поштового каналу
+type SMTPOptions struct {
+	Host     string
+	Port     string
+	Username string // Порожній - без автентифікації
+	Password string
+	From     string
+	To       []string
+}
+
+// SMTP надсилає сповіщення електронною поштою. STARTTLS використовується, якщо
+// сервер його пропонує; пароль без TLS надсилається лише на localhost.
+type SMTP struct {
+	name string
+	opts SMTPOptions
+}
+
+// NewSMTP створює поштовий канал
+func NewSMTP(name string, opts SMTPOptions) *SMTP {
+	return &SMTP{name: name, opts: opts}
+}
+
+func (s *SMTP) Name() string { return s.name }
+
+func (s *SMTP) Send(ctx context.Context, a Alert) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(s.opts.Host, s.opts.Port))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, s.opts.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.opts.Host}); err != nil {
+			return err
+		}
+	}
+	if s.opts.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.opts.Username, s.opts.Password, s.opts.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.opts.From); err != nil {
+		return err
+	}
+	for _, to := range s.opts.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.message(a)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message формує лист: заголовки і текст сповіщення з переведеннями рядків CRLF
+func (s *SMTP) message(a Alert) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.opts.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.opts.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", a.Title()))
+	fmt.Fprintf(&b, "Date: %s\r\n", a.Time.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(a.Text(), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
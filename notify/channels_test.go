@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testAlert = Alert{
+	Condition: Condition{Kind: ConditionLinkDown, Pipeline: "north", Subject: "primary", Message: "upstream 10.0.0.1:20004 disconnected"},
+	Rule:      "link",
+	State:     StateFiring,
+	Since:     time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	Time:      time.Date(2026, 3, 1, 12, 1, 0, 0, time.UTC),
+}
+
+func TestWebhook_Send(t *testing.T) {
+	var got Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("content type = %s", r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhook("hook", srv.URL).Send(context.Background(), testAlert); err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != ConditionLinkDown || got.Subject != "primary" || got.State != StateFiring || !got.Since.Equal(testAlert.Since) {
+		t.Errorf("webhook payload = %+v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	if err := NewWebhook("hook", failing.URL).Send(context.Background(), testAlert); err == nil {
+		t.Error("502 reported as success")
+	}
+}
+
+func TestTelegram_Send(t *testing.T) {
+	var path string
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&got)
+		if got["chat_id"] == "-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok":false,"description":"Bad Request: chat not found"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	if err := NewTelegram("duty", "123:secret", "-100", srv.URL).Send(context.Background(), testAlert); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/bot123:secret/sendMessage" || got["chat_id"] != "-100" || !strings.HasPrefix(got["text"], "[FIRING] link.down north/primary\n") {
+		t.Errorf("request %s %v", path, got)
+	}
+
+	err := NewTelegram("duty", "123:secret", "-1", srv.URL).Send(context.Background(), testAlert)
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("error = %v, want the API description", err)
+	}
+	srv.Close()
+	err = NewTelegram("duty", "123:secret", "-100", srv.URL).Send(context.Background(), testAlert)
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("error = %v, want a failure without the bot token", err)
+	}
+}
+
+// smtpServer - мінімальний SMTP сервер для тестів; повертає отриманий лист у канал
+func smtpServer(t *testing.T) (addr string, mail <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		var envelope []string
+		reply("220 localhost ESMTP test")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				envelope = append(envelope, cmd)
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				reply("250 queued")
+				received <- strings.Join(envelope, "\n") + "\n\n" + data.String()
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestSMTP_Send(t *testing.T) {
+	addr, mail := smtpServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	ch := NewSMTP("ops", SMTPOptions{Host: host, Port: port, From: "relay@example.com", To: []string{"ops@example.com", "duty@example.com"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ch.Send(ctx, testAlert); err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-mail
+	for _, want := range []string{
+		"MAIL FROM:<relay@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"RCPT TO:<duty@example.com>",
+		"Subject: [FIRING] link.down north/primary\r\n",
+		"\r\n\r\n[FIRING] link.down north/primary\r\nupstream 10.0.0.1:20004 disconnected\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("mail is missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Package notify сповіщає операторів про стан самого ретранслятора: обрив
+// зв'язку з приймачем, майже повну чергу, пристрій без подій. Правила
+// визначають, які умови і куди надсилати, із затримкою, повторами,
+// ескалацією та обмеженням частоти.
+package notify
+
+import (
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/ratelimiter"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Умови, які перевіряє ядро (config.NotifyConditions)
+const (
+	ConditionLinkDown     = "link.down"     // Немає з'єднання з приймачем
+	ConditionQueueHigh    = "queue.high"    // Черга заповнена понад notify.queuethreshold
+	ConditionDeviceSilent = "device.silent" // Пристрій мовчить довше за monitoring.ppktimeout
+
+	ConditionIncidentEscalated = "incident.escalated" // Інцидент не підтверджено за incidents.escalateafter
+)
+
+// Стани сповіщення
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// sendTimeout - скільки чекати на один канал
+const sendTimeout = 10 * time.Second
+
+// Condition - активна умова, наприклад обрив зв'язку з конкретним приймачем
+type Condition struct {
+	Kind     string `json:"condition"`
+	Pipeline string `json:"pipeline"`
+	Subject  string `json:"subject"` // Приймач або пристрій
+	Message  string `json:"message"`
+}
+
+func (c Condition) key() string {
+	return c.Kind + "|" + c.Pipeline + "|" + c.Subject
+}
+
+// Alert - сповіщення, що надсилається каналам
+type Alert struct {
+	Condition
+	Rule      string    `json:"rule"`
+	State     string    `json:"state"` // StateFiring, StateResolved
+	Escalated bool      `json:"escalated"`
+	Since     time.Time `json:"since"` // Коли умову помічено вперше
+	Time      time.Time `json:"time"`
+}
+
+// Title - короткий заголовок, наприклад "[FIRING] link.down default/primary"
+func (a Alert) Title() string {
+	state := strings.ToUpper(a.State)
+	if a.Escalated && a.State == StateFiring {
+		state = "ESCALATED"
+	}
+	return fmt.Sprintf("[%s] %s %s/%s", state, a.Kind, a.Pipeline, a.Subject)
+}
+
+// Text - повний текст сповіщення
+func (a Alert) Text() string {
+	return fmt.Sprintf("%s\n%s\nsince %s (%s)",
+		a.Title(), a.Message, a.Since.Format(time.RFC3339), a.Time.Sub(a.Since).Round(time.Second))
+}
+
+// Channel - спосіб доставки сповіщень
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, a Alert) error
+}
+
+// Notifier відстежує умови і надсилає сповіщення за правилами
+type Notifier struct {
+	rules    []*rule
+	channels map[string]Channel
+}
+
+// rule - правило зі станом активних умов
+type rule struct {
+	config.NotifyRule
+	limiter *ratelimiter.RateLimiter // nil - без обмеження
+	active  map[string]*alertState
+}
+
+// alertState - стан однієї умови в межах правила
+type alertState struct {
+	cond       Condition
+	since      time.Time
+	sent       time.Time // Останнє сповіщення; нуль - ще не надсилалося
+	escalated  bool
+	suppressed bool // Про придушення вже записано в лог
+}
+
+// New створює Notifier з правил і каналів. Канали, на які посилаються правила, мають існувати.
+func New(rules []config.NotifyRule, channels []Channel) (*Notifier, error) {
+	n := &Notifier{channels: make(map[string]Channel, len(channels))}
+	for _, ch := range channels {
+		n.channels[ch.Name()] = ch
+	}
+	for _, rc := range rules {
+		for _, name := range slices.Concat(rc.Channels, rc.EscalateTo) {
+			if n.channels[name] == nil {
+				return nil, fmt.Errorf("rule %s: unknown channel %q", rc.Name, name)
+			}
+		}
+		r := &rule{NotifyRule: rc, active: make(map[string]*alertState)}
+		if rc.MaxPerHour > 0 {
+			r.limiter = ratelimiter.NewRateLimiter(float64(rc.MaxPerHour)/3600, rc.MaxPerHour)
+		}
+		n.rules = append(n.rules, r)
+	}
+	return n, nil
+}
+
+// NewFromConfig створює Notifier і канали з секції notify
+func NewFromConfig(cfg *config.NotifyConfig) (*Notifier, error) {
+	channels := make([]Channel, 0, len(cfg.Channels))
+	for _, cc := range cfg.Channels {
+		switch cc.Type {
+		case "smtp":
+			channels = append(channels, NewSMTP(cc.Name, SMTPOptions{
+				Host:     cc.Host,
+				Port:     cc.Port,
+				Username: cc.Username,
+				Password: cc.Password,
+				From:     cc.From,
+				To:       cc.To,
+			}))
+		case "webhook":
+			channels = append(channels, NewWebhook(cc.Name, cc.URL))
+		case "telegram":
+			channels = append(channels, NewTelegram(cc.Name, cc.BotToken, cc.ChatID, cc.APIURL))
+		default:
+			return nil, fmt.Errorf("channel %s: unknown type %q", cc.Name, cc.Type)
+		}
+	}
+	return New(cfg.Rules, channels)
+}
+
+// Run перевіряє умови, які повертає probe, кожні interval до скасування ctx
+func (n *Notifier) Run(ctx context.Context, interval time.Duration, probe func() []Condition) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n.Check(ctx, now, probe())
+		}
+	}
+}
+
+// Check порівнює активні умови з попередньою перевіркою і надсилає сповіщення:
+// після затримки правила, повторно через repeat, на канали ескалації після
+// escalate і, якщо правило цього просить, коли умова зникла.
+func (n *Notifier) Check(ctx context.Context, now time.Time, active []Condition) {
+	for _, r := range n.rules {
+		seen := make(map[string]bool)
+		for _, c := range active {
+			if !slices.Contains(r.Conditions, c.Kind) {
+				continue
+			}
+			key := c.key()
+			seen[key] = true
+			st := r.active[key]
+			if st == nil {
+				st = &alertState{since: now}
+				r.active[key] = st
+			}
+			st.cond = c
+
+			switch {
+			case st.sent.IsZero():
+				if now.Sub(st.since) >= r.Delay && n.notify(ctx, r, st, now, StateFiring, r.Channels) {
+					st.sent = now
+				}
+			case r.Escalate > 0 && !st.escalated && now.Sub(st.since) >= r.Escalate:
+				st.escalated = true
+				if n.notify(ctx, r, st, now, StateFiring, r.EscalateTo) {
+					st.sent = now
+				} else {
+					st.escalated = false
+				}
+			case r.Repeat > 0 && now.Sub(st.sent) >= r.Repeat:
+				if n.notify(ctx, r, st, now, StateFiring, r.recipients(st)) {
+					st.sent = now
+				}
+			}
+		}
+
+		for key, st := range r.active {
+			if seen[key] {
+				continue
+			}
+			delete(r.active, key)
+			if r.Resolved && !st.sent.IsZero() {
+				n.notify(ctx, r, st, now, StateResolved, r.recipients(st))
+			}
+		}
+	}
+}
+
+// recipients повертає канали правила і, після ескалації, канали ескалації
+func (r *rule) recipients(st *alertState) []string {
+	if !st.escalated {
+		return r.Channels
+	}
+	names := slices.Clone(r.Channels)
+	for _, name := range r.EscalateTo {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// notify надсилає сповіщення каналам. Повертає false, якщо його придушило
+// обмеження частоти правила; помилки окремих каналів лише записуються в лог.
+func (n *Notifier) notify(ctx context.Context, r *rule, st *alertState, now time.Time, state string, channels []string) bool {
+	if r.limiter != nil && !r.limiter.Allow() {
+		if !st.suppressed {
+			st.suppressed = true
+			slog.Warn("Notification suppressed by rate limit", "rule", r.Name, "condition", st.cond.Kind,
+				"pipeline", st.cond.Pipeline, "subject", st.cond.Subject, "maxPerHour", r.MaxPerHour)
+		}
+		return false
+	}
+	st.suppressed = false
+
+	alert := Alert{
+		Condition: st.cond,
+		Rule:      r.Name,
+		State:     state,
+		Escalated: st.escalated,
+		Since:     st.since,
+		Time:      now,
+	}
+	for _, name := range channels {
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := n.channels[name].Send(sendCtx, alert)
+		cancel()
+		if err != nil {
+			slog.Error("Failed to send notification", "channel", name, "alert", alert.Title(), "error", err)
+			continue
+		}
+		slog.Info("Notification sent", "channel", name, "alert", alert.Title())
+	}
+	return true
+}
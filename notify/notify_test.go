@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"cid_retranslator_walk/config"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder - канал, що запам'ятовує надіслані сповіщення
+type recorder struct {
+	name   string
+	alerts []Alert
+	err    error
+}
+
+func (r *recorder) Name() string { return r.name }
+
+func (r *recorder) Send(_ context.Context, a Alert) error {
+	r.alerts = append(r.alerts, a)
+	return r.err
+}
+
+func (r *recorder) titles() string {
+	var titles []string
+	for _, a := range r.alerts {
+		titles = append(titles, a.Title())
+	}
+	return strings.Join(titles, "; ")
+}
+
+func TestConditionsMatchConfig(t *testing.T) {
+	for _, c := range []string{ConditionLinkDown, ConditionQueueHigh, ConditionDeviceSilent, ConditionIncidentEscalated} {
+		if !slices.Contains(config.NotifyConditions, c) {
+			t.Errorf("config.NotifyConditions is missing %s", c)
+		}
+	}
+}
+
+func TestNotifier_Lifecycle(t *testing.T) {
+	ops, duty := &recorder{name: "ops"}, &recorder{name: "duty"}
+	n, err := New([]config.NotifyRule{{
+		Name:       "link",
+		Conditions: []string{ConditionLinkDown},
+		Channels:   []string{"ops"},
+		Delay:      30 * time.Second,
+		Repeat:     10 * time.Minute,
+		Escalate:   time.Hour,
+		EscalateTo: []string{"duty"},
+		Resolved:   true,
+	}}, []Channel{ops, duty})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	down := []Condition{
+		{Kind: ConditionLinkDown, Pipeline: "default", Subject: "primary", Message: "upstream disconnected"},
+		{Kind: ConditionQueueHigh, Pipeline: "default", Subject: "primary"}, // Не відповідає правилу
+	}
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+	ctx := context.Background()
+
+	n.Check(ctx, at(0), down)
+	n.Check(ctx, at(20*time.Second), down)
+	if len(ops.alerts) != 0 {
+		t.Fatalf("alert before the delay: %s", ops.titles())
+	}
+
+	// Короткочасний обрив, що зник до затримки, не сповіщається
+	n.Check(ctx, at(25*time.Second), nil)
+	n.Check(ctx, at(30*time.Second), down)
+	n.Check(ctx, at(50*time.Second), down)
+	if len(ops.alerts) != 0 {
+		t.Fatalf("flapping condition alerted: %s", ops.titles())
+	}
+
+	n.Check(ctx, at(time.Minute), down)
+	n.Check(ctx, at(2*time.Minute), down)
+	n.Check(ctx, at(11*time.Minute), down)
+	if ops.titles() != "[FIRING] link.down default/primary; [FIRING] link.down default/primary" {
+		t.Errorf("ops after repeat: %s", ops.titles())
+	}
+	if got := ops.alerts[0].Since; !got.Equal(at(30 * time.Second)) {
+		t.Errorf("since = %s, want the start of the current outage", got)
+	}
+
+	n.Check(ctx, at(time.Hour+30*time.Second), down)
+	if duty.titles() != "[ESCALATED] link.down default/primary" || len(ops.alerts) != 2 {
+		t.Errorf("escalation: duty %q, ops %q", duty.titles(), ops.titles())
+	}
+
+	n.Check(ctx, at(2*time.Hour), nil)
+	if !strings.HasPrefix(ops.alerts[len(ops.alerts)-1].Title(), "[RESOLVED]") || !strings.HasPrefix(duty.alerts[len(duty.alerts)-1].Title(), "[RESOLVED]") {
+		t.Errorf("resolved notice missing: ops %q, duty %q", ops.titles(), duty.titles())
+	}
+}
+
+func TestNotifier_RateLimit(t *testing.T) {
+	ops := &recorder{name: "ops", err: errors.New("mail server down")}
+	n, err := New([]config.NotifyRule{{
+		Name:       "devices",
+		Conditions: []string{ConditionDeviceSilent},
+		Channels:   []string{"ops"},
+		MaxPerHour: 2,
+	}}, []Channel{ops})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var silent []Condition
+	for _, id := range []string{"1001", "1002", "1003"} {
+		silent = append(silent, Condition{Kind: ConditionDeviceSilent, Pipeline: "default", Subject: "device " + id})
+	}
+	now := time.Now()
+	n.Check(context.Background(), now, silent)
+	n.Check(context.Background(), now.Add(time.Second), silent)
+	if len(ops.alerts) != 2 {
+		t.Errorf("sent %d alerts, want 2 (rate limit): %s", len(ops.alerts), ops.titles())
+	}
+}
+
+func TestNew_UnknownChannel(t *testing.T) {
+	_, err := New([]config.NotifyRule{{Name: "r", Conditions: []string{ConditionLinkDown}, Channels: []string{"pager"}}}, nil)
+	if err == nil {
+		t.Error("rule with an unknown channel accepted")
+	}
+}
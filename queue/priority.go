@@ -0,0 +1,459 @@
+package queue
+
+import (
+	"cid_retranslator_walk/cidparser"
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/logging"
+	"cid_retranslator_walk/metrics"
+	"cmp"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Class - клас пріоритету повідомлення (менше значення - вищий пріоритет)
+type Class int
+
+const (
+	ClassAlarm Class = iota
+	ClassTrouble
+	ClassOpenClose
+	ClassTest
+	numClasses
+)
+
+// classNames збігаються з назвами класів у конфігурації (перетворення панікує при розбіжності)
+var classNames = [numClasses]string(config.QueueClassNames)
+
+// String повертає назву класу
+func (c Class) String() string {
+	if c < 0 || c >= numClasses {
+		return "unknown"
+	}
+	return classNames[c]
+}
+
+// ParseClass перетворює назву класу на Class
+func ParseClass(name string) (Class, error) {
+	for i, n := range classNames {
+		if strings.EqualFold(n, name) {
+			return Class(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown queue class %q", name)
+}
+
+// Classify визначає клас повідомлення за кодом події Contact ID; тривоги
+// визначаються за діапазоном кодів (cidparser.IsAlarm).
+// Нерозпізнані кадри потрапляють до класу тестів.
+func Classify(payload []byte) Class {
+	return classify(payload, cidparser.IsAlarm)
+}
+
+func classify(payload []byte, isAlarm func(code string) bool) Class {
+	msg, err := cidparser.Parse(payload)
+	if err != nil {
+		return ClassTest
+	}
+
+	if isAlarm(msg.Code) {
+		return ClassAlarm
+	}
+
+	switch msg.Code[1] {
+	case '1', '2', '3':
+		return ClassTrouble
+	case '4':
+		return ClassOpenClose
+	default:
+		return ClassTest
+	}
+}
+
+// DequeueStrategy - спосіб вибору класу при видачі повідомлень
+type DequeueStrategy int
+
+const (
+	// DequeueStrict завжди видає повідомлення з найвищого непорожнього класу
+	DequeueStrict DequeueStrategy = iota
+	// DequeueWeighted розподіляє видачу між класами пропорційно вагам
+	DequeueWeighted
+)
+
+// PriorityOptions - налаштування пріоритетної черги
+type PriorityOptions struct {
+	Strategy             DequeueStrategy
+	Weights              [numClasses]int
+	Capacity             [numClasses]int
+	PreserveAccountOrder bool
+	IsAlarm              func(code string) bool // Чи є код тривогою; nil - cidparser.IsAlarm
+}
+
+type priorityItem struct {
+	data    SharedData
+	class   Class
+	account int
+	seq     uint64
+	queued  time.Time
+}
+
+// PriorityQueue - черга з класами пріоритету, сумісна з інтерфейсами Queue.
+// Тривоги обганяють рутинні повідомлення; за PreserveAccountOrder повідомлення
+// одного акаунту ніколи не переставляються (старше повідомлення успадковує пріоритет нового).
+type PriorityQueue struct {
+	mu       sync.Mutex
+	opts     PriorityOptions
+	classes  [numClasses][]*priorityItem
+	accounts map[int][]uint64 // черга seq по акаунтах (для PreserveAccountOrder)
+	credits  [numClasses]int
+	seq      uint64
+	closed   bool
+	held     *priorityItem // Повідомлення, вибране диспетчером, але ще не отримане споживачем
+
+	notify    chan struct{}
+	done      chan struct{}
+	stopped   chan struct{} // Закривається, коли диспетчер завершився
+	out       chan SharedData
+	closeOnce sync.Once
+	metrics   *metrics.Stats
+}
+
+// NewPriority створює пріоритетну чергу та запускає диспетчер видачі
+func NewPriority(opts PriorityOptions, stats *metrics.Stats) *PriorityQueue {
+	if stats == nil {
+		stats = metrics.New()
+	}
+	if opts.IsAlarm == nil {
+		opts.IsAlarm = cidparser.IsAlarm
+	}
+	for i := range opts.Weights {
+		if opts.Weights[i] <= 0 {
+			opts.Weights[i] = 1
+		}
+	}
+
+	q := &PriorityQueue{
+		opts:     opts,
+		accounts: make(map[int][]uint64),
+		notify:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		out:      make(chan SharedData),
+		metrics:  stats,
+	}
+	q.credits = opts.Weights
+
+	go q.dispatch()
+	return q
+}
+
+// NewFromConfig створює FIFO або пріоритетну чергу згідно конфігурації. isAlarm
+// визначає тривоги для пріоритетної черги (nil - cidparser.IsAlarm).
+func NewFromConfig(cfg *config.QueueConfig, stats *metrics.Stats, isAlarm func(code string) bool) (MessageQueue, error) {
+	switch strings.ToLower(cfg.Mode) {
+	case "", "fifo":
+		q := New(cfg.BufferSize, stats)
+		if isAlarm != nil {
+			q.isAlarm = isAlarm
+		}
+		return q, nil
+	case "priority":
+	default:
+		return nil, fmt.Errorf("unknown queue mode %q", cfg.Mode)
+	}
+
+	var opts PriorityOptions
+	switch strings.ToLower(cfg.Dequeue) {
+	case "", "strict":
+		opts.Strategy = DequeueStrict
+	case "weighted":
+		opts.Strategy = DequeueWeighted
+	default:
+		return nil, fmt.Errorf("unknown dequeue strategy %q", cfg.Dequeue)
+	}
+
+	for i := range opts.Capacity {
+		opts.Capacity[i] = cfg.BufferSize
+	}
+	for name, capacity := range cfg.ClassCapacity {
+		class, err := ParseClass(name)
+		if err != nil {
+			return nil, err
+		}
+		opts.Capacity[class] = capacity
+	}
+	for name, weight := range cfg.Weights {
+		class, err := ParseClass(name)
+		if err != nil {
+			return nil, err
+		}
+		opts.Weights[class] = weight
+	}
+	opts.PreserveAccountOrder = cfg.PreserveAccountOrder
+	opts.IsAlarm = isAlarm
+
+	return NewPriority(opts, stats), nil
+}
+
+// Enqueue додає дані у відповідний клас (non-blocking). Повертає false, якщо клас переповнений.
+func (q *PriorityQueue) Enqueue(data SharedData) bool {
+	class := classify(data.Payload, q.opts.IsAlarm)
+	account := -1
+	if msg, err := cidparser.Parse(data.Payload); err == nil {
+		account = msg.Account
+	}
+
+	q.mu.Lock()
+	if q.closed || len(q.classes[class]) >= q.opts.Capacity[class] {
+		q.mu.Unlock()
+		q.metrics.IncrementClassDropped(class.String())
+		slog.Debug("Priority class full, message dropped", "class", class.String(), logging.CorrelationKey, data.ID)
+		return false
+	}
+
+	q.seq++
+	item := &priorityItem{data: data, class: class, account: account, seq: q.seq, queued: time.Now()}
+	q.classes[class] = append(q.classes[class], item)
+	if q.opts.PreserveAccountOrder {
+		q.accounts[account] = append(q.accounts[account], item.seq)
+	}
+	q.mu.Unlock()
+
+	q.metrics.IncrementClassEnqueued(class.String())
+	slog.Debug("Message queued", "class", class.String(), logging.CorrelationKey, data.ID)
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Events повертає канал для читання повідомлень у порядку пріоритету
+func (q *PriorityQueue) Events() <-chan SharedData {
+	return q.out
+}
+
+// GetMetrics повертає посилання на метрики
+func (q *PriorityQueue) GetMetrics() *metrics.Stats {
+	return q.metrics
+}
+
+// Close зупиняє диспетчер і закриває канал Events (можна викликати кілька разів)
+func (q *PriorityQueue) Close() {
+	q.closeOnce.Do(func() {
+		q.mu.Lock()
+		q.closed = true
+		q.mu.Unlock()
+		close(q.done)
+	})
+}
+
+// Len повертає кількість повідомлень у черзі по класах
+func (q *PriorityQueue) Len() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	lens := make(map[string]int, numClasses)
+	for c := Class(0); c < numClasses; c++ {
+		lens[c.String()] = len(q.classes[c])
+	}
+	return lens
+}
+
+// Depth повертає кількість повідомлень у черзі, включно з тим, що чекає на споживача
+func (q *PriorityQueue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	depth := 0
+	if q.held != nil {
+		depth++
+	}
+	for c := Class(0); c < numClasses; c++ {
+		depth += len(q.classes[c])
+	}
+	return depth
+}
+
+// Backlog повертає кількість, класи і час постановки найстарішого повідомлення,
+// включно з тим, що чекає на споживача
+func (q *PriorityQueue) Backlog() Backlog {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	b := newBacklog()
+	if q.held != nil {
+		b.add(q.held.class, q.held.queued)
+	}
+	for c := Class(0); c < numClasses; c++ {
+		for _, item := range q.classes[c] {
+			b.add(item.class, item.queued)
+		}
+	}
+	return b
+}
+
+// Capacity повертає сумарну місткість усіх класів
+func (q *PriorityQueue) Capacity() int {
+	total := 0
+	for _, c := range q.opts.Capacity {
+		total += c
+	}
+	return total
+}
+
+// Drain забирає всі невидані повідомлення в порядку надходження. Викликається
+// після Close, коли споживачі зупинені.
+func (q *PriorityQueue) Drain() []SharedData {
+	<-q.stopped
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var items []*priorityItem
+	for c := Class(0); c < numClasses; c++ {
+		items = append(items, q.classes[c]...)
+		q.classes[c] = nil
+	}
+	q.accounts = make(map[int][]uint64)
+	slices.SortFunc(items, func(a, b *priorityItem) int { return cmp.Compare(a.seq, b.seq) })
+	left := make([]SharedData, len(items))
+	for i, item := range items {
+		left[i] = item.data
+	}
+	return left
+}
+
+// dispatch передає повідомлення в канал out. Якщо під час очікування споживача
+// надходить нове повідомлення, вибір переглядається, щоб тривога не чекала за рутиною.
+func (q *PriorityQueue) dispatch() {
+	defer close(q.stopped)
+	defer close(q.out)
+
+	for {
+		item := q.pop()
+		if item == nil {
+			select {
+			case <-q.notify:
+				continue
+			case <-q.done:
+				return
+			}
+		}
+
+		select {
+		case q.out <- item.data:
+			q.mu.Lock()
+			q.held = nil
+			q.mu.Unlock()
+			q.metrics.IncrementClassDequeued(item.class.String())
+		case <-q.notify:
+			q.pushFront(item)
+		case <-q.done:
+			// Повертаємо в чергу, щоб Drain не загубив повідомлення
+			q.pushFront(item)
+			return
+		}
+	}
+}
+
+// pop вибирає і видаляє наступне повідомлення згідно стратегії
+func (q *PriorityQueue) pop() *priorityItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	class, ok := q.pickClass()
+	if !ok {
+		return nil
+	}
+
+	item := q.classes[class][0]
+	if q.opts.PreserveAccountOrder {
+		if head := q.accounts[item.account][0]; head != item.seq {
+			item = q.findBySeq(head)
+		}
+		q.accounts[item.account] = q.accounts[item.account][1:]
+		if len(q.accounts[item.account]) == 0 {
+			delete(q.accounts, item.account)
+		}
+	}
+
+	q.remove(item)
+	q.held = item
+	return item
+}
+
+// pickClass повертає клас, з якого видавати наступне повідомлення (викликати під локом)
+func (q *PriorityQueue) pickClass() (Class, bool) {
+	if q.opts.Strategy == DequeueStrict {
+		for c := Class(0); c < numClasses; c++ {
+			if len(q.classes[c]) > 0 {
+				return c, true
+			}
+		}
+		return 0, false
+	}
+
+	// Зважений round-robin: клас витрачає кредит на кожне повідомлення,
+	// коли у всіх непорожніх класів кредит вичерпано - кредити поновлюються
+	for round := 0; round < 2; round++ {
+		for c := Class(0); c < numClasses; c++ {
+			if len(q.classes[c]) > 0 && q.credits[c] > 0 {
+				q.credits[c]--
+				return c, true
+			}
+		}
+		q.credits = q.opts.Weights
+	}
+	return 0, false
+}
+
+// findBySeq шукає повідомлення за порядковим номером (викликати під локом)
+func (q *PriorityQueue) findBySeq(seq uint64) *priorityItem {
+	for c := Class(0); c < numClasses; c++ {
+		for _, item := range q.classes[c] {
+			if item.seq == seq {
+				return item
+			}
+		}
+	}
+	return nil
+}
+
+// remove видаляє повідомлення з його класу (викликати під локом)
+func (q *PriorityQueue) remove(item *priorityItem) {
+	items := q.classes[item.class]
+	for i, it := range items {
+		if it == item {
+			q.classes[item.class] = append(items[:i], items[i+1:]...)
+			return
+		}
+	}
+}
+
+// pushFront повертає невидане повідомлення на початок черги
+func (q *PriorityQueue) pushFront(item *priorityItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.held = nil
+	q.classes[item.class] = insertBySeq(q.classes[item.class], item)
+	if q.opts.PreserveAccountOrder {
+		q.accounts[item.account] = append([]uint64{item.seq}, q.accounts[item.account]...)
+	}
+}
+
+// insertBySeq вставляє повідомлення, зберігаючи порядок надходження в класі
+func insertBySeq(items []*priorityItem, item *priorityItem) []*priorityItem {
+	i := 0
+	for i < len(items) && items[i].seq < item.seq {
+		i++
+	}
+	items = append(items, nil)
+	copy(items[i+1:], items[i:])
+	items[i] = item
+	return items
+}
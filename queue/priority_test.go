@@ -0,0 +1,313 @@
+package queue
+
+import (
+	"cid_retranslator_walk/cidparser"
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/metrics"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// frame формує кадр Contact ID для акаунту та коду
+func frame(account int, code string) []byte {
+	return []byte(fmt.Sprintf("5010 18%04d%s01001\x14", account, code))
+}
+
+// newStoppedPriority створює чергу без диспетчера, щоб перевіряти порядок через pop()
+func newStoppedPriority(opts PriorityOptions) *PriorityQueue {
+	if opts.IsAlarm == nil {
+		opts.IsAlarm = cidparser.IsAlarm
+	}
+	for i := range opts.Capacity {
+		if opts.Capacity[i] == 0 {
+			opts.Capacity[i] = 10
+		}
+		if opts.Weights[i] == 0 {
+			opts.Weights[i] = 1
+		}
+	}
+	q := &PriorityQueue{
+		opts:     opts,
+		accounts: make(map[int][]uint64),
+		notify:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		out:      make(chan SharedData),
+		metrics:  metrics.New(),
+	}
+	q.credits = opts.Weights
+	return q
+}
+
+func popAll(q *PriorityQueue) []string {
+	var got []string
+	for item := q.pop(); item != nil; item = q.pop() {
+		got = append(got, string(item.data.Payload))
+	}
+	return got
+}
+
+func receive(t *testing.T, q *PriorityQueue) SharedData {
+	t.Helper()
+	select {
+	case data := <-q.Events():
+		return data
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+		return SharedData{}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		code string
+		want Class
+	}{
+		{"E130", ClassAlarm},
+		{"E110", ClassAlarm},
+		{"R130", ClassTrouble},
+		{"E301", ClassTrouble},
+		{"E401", ClassOpenClose},
+		{"R401", ClassOpenClose},
+		{"E602", ClassTest},
+	}
+
+	for _, tt := range tests {
+		if got := Classify(frame(1234, tt.code)); got != tt.want {
+			t.Errorf("Classify(%s) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+
+	if got := Classify([]byte("garbage")); got != ClassTest {
+		t.Errorf("Classify(garbage) = %v, want %v", got, ClassTest)
+	}
+}
+
+// Тривоги визначає словник подій: код поза діапазоном тривог обганяє інші
+func TestPriorityQueue_IsAlarm(t *testing.T) {
+	q := newStoppedPriority(PriorityOptions{IsAlarm: func(code string) bool { return code == "E383" }})
+	q.Enqueue(SharedData{Payload: frame(1234, "E130")})
+	q.Enqueue(SharedData{Payload: frame(5678, "E383")})
+	got := popAll(q)
+	if len(got) != 2 || got[0] != string(frame(5678, "E383")) {
+		t.Errorf("order = %q, want the E383 alarm first", got)
+	}
+}
+
+func TestPriorityQueue_StrictOrder(t *testing.T) {
+	q := newStoppedPriority(PriorityOptions{Strategy: DequeueStrict})
+
+	q.Enqueue(SharedData{Payload: frame(1001, "E602")})
+	q.Enqueue(SharedData{Payload: frame(1002, "R301")})
+	q.Enqueue(SharedData{Payload: frame(1003, "E401")})
+	q.Enqueue(SharedData{Payload: frame(1004, "E130")})
+	q.Enqueue(SharedData{Payload: frame(1005, "E602")})
+
+	want := []string{
+		string(frame(1004, "E130")),
+		string(frame(1002, "R301")),
+		string(frame(1003, "E401")),
+		string(frame(1001, "E602")),
+		string(frame(1005, "E602")),
+	}
+	assertOrder(t, popAll(q), want)
+}
+
+func TestPriorityQueue_PreserveAccountOrder(t *testing.T) {
+	q := newStoppedPriority(PriorityOptions{Strategy: DequeueStrict, PreserveAccountOrder: true})
+
+	q.Enqueue(SharedData{Payload: frame(1001, "E602")})
+	q.Enqueue(SharedData{Payload: frame(1002, "E602")})
+	q.Enqueue(SharedData{Payload: frame(1001, "E130")})
+
+	// Тест акаунту 1001 успадковує пріоритет тривоги і йде першим
+	want := []string{
+		string(frame(1001, "E602")),
+		string(frame(1001, "E130")),
+		string(frame(1002, "E602")),
+	}
+	assertOrder(t, popAll(q), want)
+}
+
+func TestPriorityQueue_Weighted(t *testing.T) {
+	opts := PriorityOptions{Strategy: DequeueWeighted}
+	opts.Weights[ClassAlarm] = 2
+	opts.Weights[ClassTest] = 1
+	q := newStoppedPriority(opts)
+
+	for i := 0; i < 3; i++ {
+		q.Enqueue(SharedData{Payload: frame(1000+i, "E602")})
+		q.Enqueue(SharedData{Payload: frame(2000+i, "E130")})
+	}
+
+	var got []Class
+	for _, payload := range popAll(q) {
+		got = append(got, Classify([]byte(payload)))
+	}
+
+	want := []Class{ClassAlarm, ClassAlarm, ClassTest, ClassAlarm, ClassTest, ClassTest}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("weighted order = %v, want %v", got, want)
+	}
+}
+
+func TestPriorityQueue_AlarmOvertakes(t *testing.T) {
+	opts := PriorityOptions{Strategy: DequeueStrict}
+	for i := range opts.Capacity {
+		opts.Capacity[i] = 10
+	}
+	q := NewPriority(opts, nil)
+	defer q.Close()
+
+	q.Enqueue(SharedData{Payload: frame(1001, "E602")})
+	q.Enqueue(SharedData{Payload: frame(1002, "E602")})
+	q.Enqueue(SharedData{Payload: frame(1003, "E130")})
+
+	// Диспетчер може вже тримати перше повідомлення, але тривога має обігнати решту
+	time.Sleep(20 * time.Millisecond)
+	if got := Classify(receive(t, q).Payload); got != ClassAlarm {
+		t.Errorf("first message class = %v, want %v", got, ClassAlarm)
+	}
+	receive(t, q)
+	receive(t, q)
+}
+
+func assertOrder(t *testing.T, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d: %q", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPriorityQueue_ClassCapacity(t *testing.T) {
+	stats := metrics.New()
+	opts := PriorityOptions{}
+	opts.Capacity[ClassAlarm] = 10
+	opts.Capacity[ClassTest] = 1
+	q := NewPriority(opts, stats)
+	defer q.Close()
+
+	// Диспетчер може одразу забрати перше повідомлення, тому заповнюємо із запасом
+	for i := 0; i < 3; i++ {
+		q.Enqueue(SharedData{Payload: frame(1001, "E602")})
+	}
+	if !q.Enqueue(SharedData{Payload: frame(1001, "E130")}) {
+		t.Error("alarm should be accepted while test class is full")
+	}
+
+	snap := stats.Snapshot()
+	if snap.Classes["test"].Dropped == 0 {
+		t.Error("expected dropped messages in test class")
+	}
+	if snap.Classes["alarm"].Enqueued != 1 {
+		t.Errorf("expected 1 enqueued alarm, got %d", snap.Classes["alarm"].Enqueued)
+	}
+}
+
+func TestPriorityQueue_Close(t *testing.T) {
+	q := NewPriority(PriorityOptions{}, nil)
+	q.Close()
+	q.Close()
+
+	if _, ok := <-q.Events(); ok {
+		t.Error("Events channel should be closed")
+	}
+	if q.Enqueue(SharedData{Payload: frame(1001, "E130")}) {
+		t.Error("Enqueue after Close should fail")
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	q, err := NewFromConfig(&config.QueueConfig{BufferSize: 5}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := q.(*Queue); !ok {
+		t.Errorf("default mode should be FIFO, got %T", q)
+	}
+
+	q, err = NewFromConfig(&config.QueueConfig{
+		BufferSize:    5,
+		Mode:          "priority",
+		Dequeue:       "weighted",
+		ClassCapacity: map[string]int{"alarm": 50},
+		Weights:       map[string]int{"test": 3},
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pq, ok := q.(*PriorityQueue)
+	if !ok {
+		t.Fatalf("expected *PriorityQueue, got %T", q)
+	}
+	defer pq.Close()
+	if pq.opts.Capacity[ClassAlarm] != 50 || pq.opts.Capacity[ClassTest] != 5 {
+		t.Errorf("unexpected capacities: %v", pq.opts.Capacity)
+	}
+	if pq.opts.Weights[ClassTest] != 3 {
+		t.Errorf("unexpected weights: %v", pq.opts.Weights)
+	}
+
+	if _, err := NewFromConfig(&config.QueueConfig{Mode: "lifo"}, nil, nil); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+	if _, err := NewFromConfig(&config.QueueConfig{Mode: "priority", Weights: map[string]int{"bogus": 1}}, nil, nil); err == nil {
+		t.Error("expected error for unknown class")
+	}
+}
+
+func TestPriorityQueue_Drain(t *testing.T) {
+	q := NewPriority(PriorityOptions{Capacity: [numClasses]int{10, 10, 10, 10}}, nil)
+	q.Enqueue(SharedData{Payload: frame(1001, "E602")})
+	q.Enqueue(SharedData{Payload: frame(1002, "E130")})
+	q.Enqueue(SharedData{Payload: frame(1003, "E401")})
+
+	// Диспетчер тримає тривогу, чекаючи на споживача - вона теж у глибині черги
+	time.Sleep(10 * time.Millisecond)
+	if got := q.Depth(); got != 3 {
+		t.Errorf("Depth() = %d, want 3", got)
+	}
+	receive(t, q)
+	time.Sleep(10 * time.Millisecond)
+	if got := q.Depth(); got != 2 {
+		t.Errorf("Depth() after receive = %d, want 2", got)
+	}
+
+	q.Close()
+	var got []string
+	for _, data := range q.Drain() {
+		got = append(got, string(data.Payload))
+	}
+	assertOrder(t, got, []string{string(frame(1001, "E602")), string(frame(1003, "E401"))})
+	if q.Depth() != 0 {
+		t.Errorf("Depth() after Drain = %d, want 0", q.Depth())
+	}
+}
+
+func TestPriorityQueue_Backlog(t *testing.T) {
+	q := NewPriority(PriorityOptions{Capacity: [numClasses]int{10, 10, 10, 10}}, nil)
+	defer q.Close()
+	start := time.Now()
+	q.Enqueue(SharedData{Payload: frame(1001, "E602")})
+	q.Enqueue(SharedData{Payload: frame(1002, "E130")})
+	q.Enqueue(SharedData{Payload: frame(1003, "E130")})
+
+	// Тривогу, яку диспетчер уже тримає для споживача, теж враховано
+	time.Sleep(10 * time.Millisecond)
+	b := q.Backlog()
+	if b.Count != 3 || b.Classes["alarm"] != 2 || b.Classes["test"] != 1 || b.Oldest.Before(start) {
+		t.Errorf("Backlog() = %+v", b)
+	}
+
+	receive(t, q)
+	receive(t, q)
+	time.Sleep(10 * time.Millisecond)
+	if b := q.Backlog(); b.Count != 1 || b.Classes["alarm"] != 0 || b.Classes["test"] != 1 {
+		t.Errorf("Backlog() after receive = %+v", b)
+	}
+}
@@ -1,7 +1,10 @@
 package queue
 
 import (
+	"cid_retranslator_walk/cidparser"
+	"cid_retranslator_walk/logging"
 	"cid_retranslator_walk/metrics"
+	"log/slog"
 	"sync"
 	"time"
 )
@@ -11,10 +14,58 @@ type Queue struct {
 	DataChannel chan SharedData
 	closeOnce   sync.Once
 	metrics     *metrics.Stats
+	isAlarm     func(code string) bool // Для класів у Backlog
+
+	mu     sync.Mutex
+	queued []queuedAt // Час і клас повідомлень у каналі, від найстарішого
+}
+
+// queuedAt - час постановки і клас повідомлення FIFO черги
+type queuedAt struct {
+	at    time.Time
+	class Class
+}
+
+// MessageQueue - спільний інтерфейс FIFO та пріоритетної черги
+type MessageQueue interface {
+	Enqueue(data SharedData) bool
+	Events() <-chan SharedData
+	GetMetrics() *metrics.Stats
+	Close()
+	Depth() int          // Кількість повідомлень, ще не отриманих споживачем
+	Capacity() int       // Скільки повідомлень вміщує черга
+	Drain() []SharedData // Забирає невидані повідомлення; викликати після Close
+	Backlog() Backlog    // Повідомлення, що чекають на доставку
+}
+
+// Backlog - знімок повідомлень, що чекають на доставку
+type Backlog struct {
+	Count   int            `json:"count"`
+	Oldest  time.Time      `json:"oldest,omitzero"` // Коли поставлено найстаріше; нульовий, якщо черга порожня
+	Classes map[string]int `json:"classes"`         // Кількість за класами пріоритету
+}
+
+// newBacklog повертає порожній знімок з усіма класами
+func newBacklog() Backlog {
+	b := Backlog{Classes: make(map[string]int, numClasses)}
+	for c := Class(0); c < numClasses; c++ {
+		b.Classes[c.String()] = 0
+	}
+	return b
+}
+
+// add враховує повідомлення класу class, поставлене в чергу at
+func (b *Backlog) add(class Class, at time.Time) {
+	b.Count++
+	b.Classes[class.String()]++
+	if b.Oldest.IsZero() || at.Before(b.Oldest) {
+		b.Oldest = at
+	}
 }
 
 // SharedData - структура даних від сервера до клієнта
 type SharedData struct {
+	ID      string // Ідентифікатор кореляції для логів (logging.CorrelationID)
 	Payload []byte
 	ReplyCh chan DeliveryData
 }
@@ -33,6 +84,7 @@ func New(bufferSize int, stats *metrics.Stats) *Queue {
 	return &Queue{
 		DataChannel: make(chan SharedData, bufferSize),
 		metrics:     stats,
+		isAlarm:     cidparser.IsAlarm,
 	}
 }
 
@@ -43,6 +95,50 @@ func (q *Queue) Close() {
 	})
 }
 
+// Depth повертає кількість повідомлень у черзі
+func (q *Queue) Depth() int {
+	return len(q.DataChannel)
+}
+
+// Capacity повертає розмір буфера черги
+func (q *Queue) Capacity() int {
+	return cap(q.DataChannel)
+}
+
+// Drain забирає всі невидані повідомлення. Викликається після Close, коли споживачі зупинені.
+func (q *Queue) Drain() []SharedData {
+	var left []SharedData
+	for data := range q.DataChannel {
+		left = append(left, data)
+	}
+	q.mu.Lock()
+	q.queued = nil
+	q.mu.Unlock()
+	return left
+}
+
+// Backlog повертає кількість, класи і час постановки найстарішого повідомлення в каналі
+func (q *Queue) Backlog() Backlog {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.forgetConsumed()
+	b := newBacklog()
+	for _, item := range q.queued {
+		b.add(item.class, item.at)
+	}
+	return b
+}
+
+// forgetConsumed відкидає записи повідомлень, уже забраних споживачем. Канал
+// видає повідомлення по порядку, тож у ньому лишаються останні len(канал)
+// (викликати під локом).
+func (q *Queue) forgetConsumed() {
+	if n := len(q.queued) - len(q.DataChannel); n > 0 {
+		q.queued = q.queued[n:]
+	}
+}
+
 // UpdateStartTime оновлює час старту (для обчислення uptime)
 func (q *Queue) UpdateStartTime() {
 	q.metrics.Reset()
@@ -86,8 +182,15 @@ func (q *Queue) GetMetrics() *metrics.Stats {
 
 // Enqueue додає дані в чергу (non-blocking). Повертає true, якщо успішно, false якщо черга повна.
 func (q *Queue) Enqueue(data SharedData) bool {
+	class := classify(data.Payload, q.isAlarm)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.forgetConsumed()
 	select {
 	case q.DataChannel <- data:
+		q.queued = append(q.queued, queuedAt{at: time.Now(), class: class})
+		slog.Debug("Message queued", "depth", len(q.DataChannel), logging.CorrelationKey, data.ID)
 		return true
 	default:
 		return false
@@ -228,4 +228,44 @@ func BenchmarkQueue_ConcurrentAccess(b *testing.B) {
 			q.Stats()
 		}
 	})
-}
\ No newline at end of file
+}
+func TestQueue_Drain(t *testing.T) {
+	q := New(5, nil)
+	q.Enqueue(SharedData{Payload: []byte("a")})
+	q.Enqueue(SharedData{Payload: []byte("b")})
+	if q.Depth() != 2 {
+		t.Errorf("Depth() = %d, want 2", q.Depth())
+	}
+
+	q.Close()
+	left := q.Drain()
+	if len(left) != 2 || string(left[0].Payload) != "a" || string(left[1].Payload) != "b" {
+		t.Errorf("Drain() = %+v", left)
+	}
+}
+
+func TestQueue_Backlog(t *testing.T) {
+	q := New(5, nil)
+	if b := q.Backlog(); b.Count != 0 || !b.Oldest.IsZero() || len(b.Classes) != 4 {
+		t.Errorf("empty Backlog() = %+v", b)
+	}
+
+	start := time.Now()
+	q.Enqueue(SharedData{Payload: []byte("5010 181001E13001001\x14")})
+	time.Sleep(5 * time.Millisecond)
+	second := time.Now()
+	q.Enqueue(SharedData{Payload: []byte("5010 181002E40101001\x14")})
+	q.Enqueue(SharedData{Payload: []byte("5010 181003E13001001\x14")})
+
+	b := q.Backlog()
+	if b.Count != 3 || b.Classes["alarm"] != 2 || b.Classes["openclose"] != 1 || b.Oldest.Before(start) || !b.Oldest.Before(second) {
+		t.Errorf("Backlog() = %+v", b)
+	}
+
+	// Найстаріше повідомлення забрано - найстарішим стає друге
+	<-q.Events()
+	b = q.Backlog()
+	if b.Count != 2 || b.Classes["alarm"] != 1 || b.Oldest.Before(second) {
+		t.Errorf("Backlog() after receive = %+v", b)
+	}
+}
@@ -52,6 +52,18 @@ func (rl *RateLimiter) Allow() bool {
 	return false
 }
 
+// Delay returns how long until a token is available, without taking it
+func (rl *RateLimiter) Delay() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	tokens := min(rl.tokens+time.Since(rl.lastUpdate).Seconds()*rl.rate, float64(rl.burst))
+	if tokens >= 1.0 {
+		return 0
+	}
+	return time.Duration((1.0 - tokens) / rl.rate * float64(time.Second))
+}
+
 // RecordSuppressed increments the suppressed message counter
 func (rl *RateLimiter) RecordSuppressed() {
 	rl.mu.Lock()
@@ -0,0 +1,259 @@
+package router
+
+import (
+	"cid_retranslator_walk/cidparser"
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/logging"
+	"cid_retranslator_walk/match"
+	"cid_retranslator_walk/metrics"
+	"cid_retranslator_walk/queue"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// deliveryTimeout - скільки роутер чекає відповіді приймачів перед NACK
+const deliveryTimeout = 10 * time.Second
+
+// AckPolicy визначає, коли панелі відповідати ACK при доставці кільком приймачам
+type AckPolicy string
+
+const (
+	AckPrimary AckPolicy = "primary" // ACK/NACK основного приймача; решта - без гарантій
+	AckAny     AckPolicy = "any"     // ACK, якщо хоча б один приймач відповів ACK
+	AckAll     AckPolicy = "all"     // ACK, лише якщо всі приймачі відповіли ACK
+)
+
+// ParseAckPolicy перетворює назву політики з конфігурації (порожня - primary)
+func ParseAckPolicy(s string) (AckPolicy, error) {
+	switch p := AckPolicy(strings.ToLower(s)); p {
+	case "":
+		return AckPrimary, nil
+	case AckPrimary, AckAny, AckAll:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown ack policy %q", s)
+}
+
+// route - скомпільоване правило маршрутизації
+type route struct {
+	name string
+	expr *match.Expr
+	to   []string
+}
+
+// Router розподіляє повідомлення між чергами приймачів за правилами маршрутизації.
+// Реалізує server.MessageEnqueuer, тому вбудовується між сервером і клієнтами.
+type Router struct {
+	metrics *metrics.Stats
+	dests   map[string]queue.MessageQueue
+
+	mu     sync.RWMutex
+	policy AckPolicy
+	routes []route
+}
+
+// New створює роутер. dests містить черги приймачів за іменами, включно з
+// config.PrimaryUpstream; stats - метрики конвеєра.
+func New(cfg *config.RoutingConfig, dests map[string]queue.MessageQueue, stats *metrics.Stats) (*Router, error) {
+	if _, ok := dests[config.PrimaryUpstream]; !ok {
+		return nil, fmt.Errorf("router: missing %q destination", config.PrimaryUpstream)
+	}
+	r := &Router{metrics: stats, dests: dests}
+	if err := r.SetRoutes(cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetRoutes замінює правила та політику ACK на льоту
+func (r *Router) SetRoutes(cfg *config.RoutingConfig) error {
+	policy, err := ParseAckPolicy(cfg.AckPolicy)
+	if err != nil {
+		return err
+	}
+
+	routes := make([]route, 0, len(cfg.Routes))
+	for i, rc := range cfg.Routes {
+		expr, err := match.Compile(rc.Match)
+		if err != nil {
+			return fmt.Errorf("route %d (%s): %w", i, rc.Name, err)
+		}
+		for _, to := range rc.To {
+			if _, ok := r.dests[to]; !ok {
+				return fmt.Errorf("route %d (%s): unknown upstream %q", i, rc.Name, to)
+			}
+		}
+		routes = append(routes, route{name: rc.Name, expr: expr, to: rc.To})
+	}
+
+	r.mu.Lock()
+	r.policy, r.routes = policy, routes
+	r.mu.Unlock()
+	return nil
+}
+
+// GetMetrics повертає метрики конвеєра
+func (r *Router) GetMetrics() *metrics.Stats {
+	return r.metrics
+}
+
+// Destinations повертає імена приймачів для повідомлення: об'єднання всіх
+// правил, що спрацювали, або основний приймач, якщо не спрацювало жодне
+func (r *Router) Destinations(payload []byte) []string {
+	r.mu.RLock()
+	routes := r.routes
+	r.mu.RUnlock()
+
+	var fields *match.Fields
+	var names []string
+	seen := make(map[string]bool)
+	for _, rt := range routes {
+		if fields == nil {
+			f, err := parseFields(payload)
+			if err != nil {
+				slog.Debug("Cannot parse message for routing, using primary", "error", err)
+				break
+			}
+			fields = f
+		}
+		if !rt.expr.Match(fields) {
+			continue
+		}
+		for _, to := range rt.to {
+			if !seen[to] {
+				seen[to] = true
+				names = append(names, to)
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		return []string{config.PrimaryUpstream}
+	}
+	return names
+}
+
+// delivery - копія повідомлення, поставлена в чергу одного приймача
+type delivery struct {
+	name  string
+	reply chan queue.DeliveryData
+}
+
+// Enqueue ставить повідомлення в черги всіх приймачів маршруту. Повертає false,
+// якщо результат за політикою ACK вже відомий як негативний (черги переповнені).
+// Підсумковий статус надсилається в data.ReplyCh.
+func (r *Router) Enqueue(data queue.SharedData) bool {
+	names := r.Destinations(data.Payload)
+	r.mu.RLock()
+	policy := r.policy
+	r.mu.RUnlock()
+
+	// Основний приймач маршруту: клієнт конвеєра, якщо він серед адресатів, інакше перший
+	primary := names[0]
+	if slices.Contains(names, config.PrimaryUpstream) {
+		primary = config.PrimaryUpstream
+	}
+
+	var pending []delivery
+	var primaryQueued bool
+	for _, name := range names {
+		reply := make(chan queue.DeliveryData, 1)
+		if !r.dests[name].Enqueue(queue.SharedData{ID: data.ID, Payload: data.Payload, ReplyCh: reply}) {
+			slog.Warn("Upstream queue full, message not routed", "upstream", name, logging.CorrelationKey, data.ID)
+			continue
+		}
+		pending = append(pending, delivery{name: name, reply: reply})
+		if name == primary {
+			primaryQueued = true
+		}
+	}
+
+	switch {
+	case len(pending) == 0:
+		return false
+	case policy == AckAll && len(pending) < len(names):
+		return false
+	case policy == AckPrimary && !primaryQueued:
+		return false
+	}
+
+	go r.collect(data.ID, policy, primary, pending, data.ReplyCh)
+	return true
+}
+
+// collect чекає відповідей приймачів і надсилає підсумковий статус за політикою
+func (r *Router) collect(id string, policy AckPolicy, primary string, pending []delivery, replyCh chan queue.DeliveryData) {
+	type result struct {
+		name   string
+		status bool
+	}
+	results := make(chan result, len(pending))
+	for _, d := range pending {
+		go func() {
+			select {
+			case reply, ok := <-d.reply:
+				results <- result{d.name, ok && reply.Status}
+			case <-time.After(deliveryTimeout):
+				results <- result{d.name, false}
+			}
+		}()
+	}
+
+	status, acks := false, 0
+	for range pending {
+		res := <-results
+		if !res.status {
+			slog.Debug("Upstream did not acknowledge", "upstream", res.name, logging.CorrelationKey, id)
+		}
+
+		decided := false
+		switch policy {
+		case AckPrimary:
+			if res.name == primary {
+				status, decided = res.status, true
+			}
+		case AckAny:
+			if res.status {
+				status, decided = true, true
+			}
+		case AckAll:
+			if !res.status {
+				decided = true
+			} else if acks++; acks == len(pending) {
+				status, decided = true, true
+			}
+		}
+		if decided {
+			break
+		}
+	}
+
+	select {
+	case replyCh <- queue.DeliveryData{Status: status}:
+		close(replyCh)
+	default:
+		slog.Warn("Reply channel busy, routed status dropped", logging.CorrelationKey, id)
+	}
+}
+
+// parseFields розбирає повідомлення у поля для виразів маршрутизації
+func parseFields(payload []byte) (*match.Fields, error) {
+	m, err := cidparser.Parse(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &match.Fields{
+		Receiver:  m.Receiver,
+		Account:   m.Account,
+		Qualifier: string(m.Qualifier),
+		Code:      m.Code,
+		Event:     m.Event(),
+		Group:     m.Group,
+		Zone:      m.Zone,
+		Category:  m.Category(),
+	}, nil
+}
@@ -0,0 +1,155 @@
+package router
+
+import (
+	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/metrics"
+	"cid_retranslator_walk/queue"
+	"slices"
+	"testing"
+	"time"
+)
+
+const (
+	fireAlarm     = "5010 181234E11001005\x14"
+	burglaryAlarm = "5010 182500E13002012\x14"
+)
+
+// newTestRouter створює роутер з чергами primary, fire і backup
+func newTestRouter(t *testing.T, cfg config.RoutingConfig) (*Router, map[string]*queue.Queue) {
+	t.Helper()
+	queues := map[string]*queue.Queue{
+		config.PrimaryUpstream: queue.New(10, nil),
+		"fire":                 queue.New(10, nil),
+		"backup":               queue.New(10, nil),
+	}
+	dests := make(map[string]queue.MessageQueue, len(queues))
+	for name, q := range queues {
+		dests[name] = q
+	}
+	r, err := New(&cfg, dests, metrics.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, queues
+}
+
+// reply відповідає на наступне повідомлення з черги
+func reply(t *testing.T, q *queue.Queue, status bool) {
+	t.Helper()
+	select {
+	case data := <-q.Events():
+		data.ReplyCh <- queue.DeliveryData{Status: status}
+		close(data.ReplyCh)
+	case <-time.After(time.Second):
+		t.Fatal("no message delivered to upstream")
+	}
+}
+
+func waitStatus(t *testing.T, ch chan queue.DeliveryData) bool {
+	t.Helper()
+	select {
+	case d := <-ch:
+		return d.Status
+	case <-time.After(time.Second):
+		t.Fatal("no routed status")
+		return false
+	}
+}
+
+var fireRoutes = []config.RouteConfig{
+	{Name: "fire", Match: "category == fire", To: []string{config.PrimaryUpstream, "fire"}},
+	{Name: "big accounts", Match: "account >= 2000", To: []string{"backup"}},
+}
+
+func TestRouter_Destinations(t *testing.T) {
+	r, _ := newTestRouter(t, config.RoutingConfig{Routes: fireRoutes})
+
+	tests := []struct {
+		payload string
+		want    []string
+	}{
+		{fireAlarm, []string{config.PrimaryUpstream, "fire"}},
+		{burglaryAlarm, []string{"backup"}},
+		{"5010 181234E30101005\x14", []string{config.PrimaryUpstream}},
+		{"garbage", []string{config.PrimaryUpstream}},
+	}
+	for _, tt := range tests {
+		if got := r.Destinations([]byte(tt.payload)); !slices.Equal(got, tt.want) {
+			t.Errorf("Destinations(%q) = %v, want %v", tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestRouter_AckPolicies(t *testing.T) {
+	tests := []struct {
+		policy  string
+		primary bool
+		fire    bool
+		want    bool
+	}{
+		{"primary", true, false, true},
+		{"primary", false, true, false},
+		{"any", false, true, true},
+		{"any", false, false, false},
+		{"all", true, true, true},
+		{"all", true, false, false},
+	}
+
+	for _, tt := range tests {
+		r, queues := newTestRouter(t, config.RoutingConfig{AckPolicy: tt.policy, Routes: fireRoutes})
+		replyCh := make(chan queue.DeliveryData, 1)
+		if !r.Enqueue(queue.SharedData{Payload: []byte(fireAlarm), ReplyCh: replyCh}) {
+			t.Fatalf("%s: Enqueue failed", tt.policy)
+		}
+		reply(t, queues[config.PrimaryUpstream], tt.primary)
+		reply(t, queues["fire"], tt.fire)
+
+		if got := waitStatus(t, replyCh); got != tt.want {
+			t.Errorf("policy %s with primary=%v fire=%v: got %v, want %v", tt.policy, tt.primary, tt.fire, got, tt.want)
+		}
+	}
+}
+
+func TestRouter_QueueFull(t *testing.T) {
+	cfg := config.RoutingConfig{AckPolicy: "all", Routes: fireRoutes}
+	r, queues := newTestRouter(t, cfg)
+	queues["fire"].Close()
+	full := queue.New(0, nil)
+	r.dests["fire"] = full
+
+	if r.Enqueue(queue.SharedData{Payload: []byte(fireAlarm), ReplyCh: make(chan queue.DeliveryData, 1)}) {
+		t.Error("policy all should reject when one upstream queue is full")
+	}
+
+	cfg.AckPolicy = "primary"
+	if err := r.SetRoutes(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	replyCh := make(chan queue.DeliveryData, 1)
+	if !r.Enqueue(queue.SharedData{Payload: []byte(fireAlarm), ReplyCh: replyCh}) {
+		t.Fatal("policy primary should accept while primary has room")
+	}
+	reply(t, queues[config.PrimaryUpstream], true) // копія з першої спроби
+	reply(t, queues[config.PrimaryUpstream], true)
+	if !waitStatus(t, replyCh) {
+		t.Error("expected ACK from primary")
+	}
+}
+
+func TestRouter_SetRoutesErrors(t *testing.T) {
+	r, _ := newTestRouter(t, config.RoutingConfig{})
+	bad := []config.RoutingConfig{
+		{AckPolicy: "most"},
+		{Routes: []config.RouteConfig{{Match: "colour == red", To: []string{"fire"}}}},
+		{Routes: []config.RouteConfig{{Match: "category == fire", To: []string{"police"}}}},
+	}
+	for _, cfg := range bad {
+		if err := r.SetRoutes(&cfg); err == nil {
+			t.Errorf("SetRoutes(%+v) should fail", cfg)
+		}
+	}
+
+	if _, err := New(&config.RoutingConfig{}, map[string]queue.MessageQueue{}, nil); err == nil {
+		t.Error("New() without a primary destination should fail")
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"bytes"
 	"cid_retranslator_walk/cidparser"
 	"cid_retranslator_walk/config"
+	"cid_retranslator_walk/dedup"
+	"cid_retranslator_walk/logging"
+	"cid_retranslator_walk/metrics"
 	"cid_retranslator_walk/queue"
 	"container/ring"
 	"context"
@@ -14,6 +17,7 @@ import (
 	"slices"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,11 +30,10 @@ const (
 	detailChanBuffer = 200
 
 	// Таймаути
-	readTimeout      = 60 * time.Second
-	writeTimeout     = 10 * time.Second
-	replyTimeout     = 10 * time.Second
-	inactiveTimeout  = time.Hour
-	cleanupInterval  = 5 * time.Minute
+	readTimeout     = 60 * time.Second
+	writeTimeout    = 10 * time.Second
+	inactiveTimeout = time.Hour
+	cleanupInterval = 5 * time.Minute
 
 	// Протокол
 	terminatorByte = 0x14
@@ -39,20 +42,37 @@ const (
 	maxBufferSize  = 8192
 )
 
+// Змінні, щоб тести могли їх скоротити
+var (
+	// replyTimeout - скільки панель чекає на відповідь приймача
+	replyTimeout = 10 * time.Second
+	// duplicateWait - скільки повтор кадру чекає на результат оригіналу, що ще доставляється.
+	// Має бути значно меншим за таймаут повтору панелі, щоб не тримати її з'єднання.
+	duplicateWait = 2 * time.Second
+)
+
 // MessageEnqueuer defines the interface for enqueuing messages
 type MessageEnqueuer interface {
 	Enqueue(data queue.SharedData) bool
+	GetMetrics() *metrics.Stats
 }
 
 type Server struct {
 	host             string
 	port             string
 	queue            MessageEnqueuer
-	rules            *config.CIDRules
 	cancel           context.CancelFunc
 	stopOnce         sync.Once
-	listener         net.Listener
 	isRunning        bool
+	metrics          *metrics.Stats
+	dedup            *dedup.Cache // nil якщо придушення дублікатів вимкнено
+
+	// Параметри, що змінюються на льоту (hot-reload)
+	rulesMu  sync.RWMutex
+	rules    *config.CIDRules
+	listenMu sync.Mutex
+	listener net.Listener
+	ctx      context.Context
 
 	// Захищені даними
 	deviceMu         sync.RWMutex
@@ -69,14 +89,48 @@ type Server struct {
 	eventUpdates  chan GlobalEvent
 	closeOnce     sync.Once
 	wg            sync.WaitGroup
+
+	// Відкриті сесії панелей (щоб розбудити їх при зупинці)
+	connMu sync.Mutex
+	conns  map[net.Conn]struct{}
+
+	// Лічильник сесій панелей для ідентифікаторів кореляції
+	sessions atomic.Uint64
+
+	// Спостерігачі оброблених кадрів (форвардери подій)
+	observerMu sync.RWMutex
+	observers  []Observer
+}
+
+// Результати обробки кадру для спостерігачів
+const (
+	OutcomeAck      = "ack"      // Панель отримала ACK
+	OutcomeNack     = "nack"     // Приймач відхилив кадр, панель отримала NACK
+	OutcomeTimeout  = "timeout"  // Приймач не відповів вчасно, панель отримала NACK
+	OutcomeRejected = "rejected" // Черга переповнена, панель отримала NACK
+)
+
+// Frame - кадр від панелі після відповіді їй
+type Frame struct {
+	ID       string    // Ідентифікатор кореляції
+	Payload  []byte    // Кадр після заміни номера об'єкта
+	DeviceID int
+	Received time.Time // Коли кадр прийнято від панелі
+	Replied  time.Time // Коли панелі надіслано відповідь
+	Outcome  string    // OutcomeAck, ...
 }
 
+// Observer отримує кожен кадр, переданий у чергу, після відповіді панелі.
+// Викликається в горутині сесії, тому має лише передати кадр далі без блокування.
+type Observer func(Frame)
+
 type Event struct {
 	Time time.Time `json:"time"`
 	Data string    `json:"data"`
 }
 
 type Device struct {
+	Pipeline      string    `json:"pipeline,omitempty"` // Мітка конвеєра, заповнюється ядром
 	ID            int       `json:"id"`
 	LastEventTime time.Time `json:"lastEventTime"`
 	LastEvent     string    `json:"lastEvent"`
@@ -84,6 +138,7 @@ type Device struct {
 }
 
 type GlobalEvent struct {
+	Pipeline string    `json:"pipeline,omitempty"` // Мітка конвеєра, заповнюється ядром
 	Time     time.Time `json:"time"`
 	DeviceID int       `json:"deviceID"`
 	Data     string    `json:"data"`
@@ -92,23 +147,34 @@ type GlobalEvent struct {
 type connection struct {
 	conn   net.Conn
 	queue  MessageEnqueuer
-	rules  *config.CIDRules
 	server *Server
+
+	session uint64 // Номер сесії панелі
+	seq     uint64 // Номер останнього кадру в сесії
 }
 
 func New(cfg *config.ServerConfig, q MessageEnqueuer, rules *config.CIDRules) *Server {
-	return &Server{
+	s := &Server{
 		host:             cfg.Host,
 		port:             cfg.Port,
 		queue:            q,
 		rules:            rules,
+		metrics:          metrics.New(),
 		devices:          make(map[int]*Device),
 		globalEventsRing: ring.New(maxGlobalEvents),
 		lastActive:       make(map[int]time.Time),
 		deviceUpdates:    make(chan Device, deviceChanBuffer),
 		eventUpdates:     make(chan GlobalEvent, eventChanBuffer),
 		deviceEventChans: make(map[int]chan Event),
+		conns:            make(map[net.Conn]struct{}),
+	}
+	if q != nil {
+		s.metrics = q.GetMetrics()
 	}
+	if cfg.DedupWindow > 0 {
+		s.dedup = dedup.New(cfg.DedupWindow, cfg.DedupMaxEntries)
+	}
+	return s
 }
 
 func (s *Server) Run(ctx context.Context) {
@@ -116,18 +182,21 @@ func (s *Server) Run(ctx context.Context) {
 	s.cancel = cancel
 	// s.queue.UpdateStartTime() // Removed as interface doesn't have it, or we need to add it to interface
 
+	s.listenMu.Lock()
 	listener, err := net.Listen("tcp", s.host+":"+s.port)
 	if err != nil {
+		s.listenMu.Unlock()
 		slog.Error("Failed to start server", "error", err)
 		return
 	}
 	s.listener = listener
-	s.isRunning = true
-
+	s.ctx = ctx
 	slog.Info("Server started", "host", s.host, "port", s.port)
+	s.listenMu.Unlock()
+	s.isRunning = true
 
 	// Горутина прийому з'єднань
-	go s.acceptConnections(ctx)
+	go s.acceptConnections(ctx, listener)
 
 	// Горутина очищення неактивних пристроїв
 	// go s.cleanupLoop(ctx)
@@ -138,40 +207,95 @@ func (s *Server) Run(ctx context.Context) {
 	s.closeChannels()
 }
 
-func (s *Server) acceptConnections(ctx context.Context) {
+func (s *Server) acceptConnections(ctx context.Context, listener net.Listener) {
 	defer func() {
 		if r := recover(); r != nil {
 			slog.Error("Panic in acceptConnections", "panic", r)
 		}
-		s.listener.Close()
+		listener.Close()
 	}()
 
 	for {
-		conn, err := s.listener.Accept()
+		conn, err := listener.Accept()
 		if err != nil {
 			select {
 			case <-ctx.Done():
 				slog.Info("Server listener stopped")
 				return
 			default:
-				slog.Error("Accept error", "error", err)
-				continue
 			}
+			if !s.isCurrentListener(listener) {
+				slog.Info("Previous listener closed after rebind", "addr", listener.Addr())
+				return
+			}
+			slog.Error("Accept error", "error", err)
+			continue
 		}
 
-		slog.Info("Accepted connection", "from", conn.RemoteAddr())
+		session := s.sessions.Add(1)
+		slog.Info("Accepted connection", "from", conn.RemoteAddr(), "session", session)
 		s.wg.Add(1)
-		
+		s.trackConn(conn, true)
+
 		connHandler := &connection{
-			conn:   conn,
-			queue:  s.queue,
-			rules:  s.rules,
-			server: s,
+			conn:    conn,
+			queue:   s.queue,
+			server:  s,
+			session: session,
 		}
 		go connHandler.handleRequest(ctx)
 	}
 }
 
+// isCurrentListener перевіряє, чи listener досі активний (не замінений через Rebind)
+func (s *Server) isCurrentListener(listener net.Listener) bool {
+	s.listenMu.Lock()
+	defer s.listenMu.Unlock()
+	return s.listener == listener
+}
+
+// Rebind переносить сервер на нову адресу без зупинки. Нова адреса відкривається
+// до закриття старої, тож при помилці сервер продовжує слухати попередню.
+// Встановлені з'єднання не розриваються.
+func (s *Server) Rebind(host, port string) error {
+	s.listenMu.Lock()
+	defer s.listenMu.Unlock()
+
+	if s.listener == nil {
+		s.host, s.port = host, port
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", host+":"+port)
+	if err != nil {
+		return err
+	}
+
+	old := s.listener
+	s.listener = listener
+	s.host, s.port = host, port
+	old.Close()
+
+	go s.acceptConnections(s.ctx, listener)
+	slog.Info("Server rebound", "host", host, "port", port)
+	return nil
+}
+
+// Rules повертає поточні правила обробки CID
+func (s *Server) Rules() *config.CIDRules {
+	s.rulesMu.RLock()
+	defer s.rulesMu.RUnlock()
+	return s.rules
+}
+
+// SetRules замінює правила обробки CID; нові правила діють з наступного повідомлення
+func (s *Server) SetRules(rules *config.CIDRules) {
+	s.rulesMu.Lock()
+	s.rules = rules
+	s.rulesMu.Unlock()
+	slog.Info("CID rules updated")
+}
+
 func (s *Server) cleanupLoop(ctx context.Context) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -192,14 +316,37 @@ func (s *Server) cleanupLoop(ctx context.Context) {
 	}
 }
 
+// trackConn додає (add) або прибирає сесію панелі зі списку відкритих
+func (s *Server) trackConn(conn net.Conn, add bool) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if add {
+		s.conns[conn] = struct{}{}
+	} else {
+		delete(s.conns, conn)
+	}
+}
+
+// Stop припиняє приймати з'єднання і завершує сесії панелей: сесії, що чекають
+// на дані, закриваються одразу, а повідомлення, вже передані в чергу, отримують
+// відповідь приймача (поки клієнт ще працює). Нові кадри отримують NACK.
 func (s *Server) Stop() {
 	s.stopOnce.Do(func() {
 		if s.cancel != nil {
 			slog.Info("Stopping server...")
 			s.cancel()
+			s.listenMu.Lock()
 			if s.listener != nil {
 				s.listener.Close()
 			}
+			s.listenMu.Unlock()
+
+			// Будимо сесії, заблоковані на читанні
+			s.connMu.Lock()
+			for conn := range s.conns {
+				conn.SetReadDeadline(time.Now())
+			}
+			s.connMu.Unlock()
 
 			done := make(chan struct{})
 			go func() {
@@ -328,6 +475,45 @@ func (s *Server) UpdateDevice(id int, eventData string) {
 	}
 }
 
+// RefreshDevice повторно надсилає пристрій у канал оновлень без нової події,
+// щоб UI перечитав похідний стан (наприклад, стан охорони після обробки кадру)
+func (s *Server) RefreshDevice(id int) {
+	s.deviceMu.RLock()
+	dev, exists := s.devices[id]
+	if !exists {
+		s.deviceMu.RUnlock()
+		return
+	}
+	deviceCopy := Device{
+		ID:            dev.ID,
+		LastEventTime: dev.LastEventTime,
+		LastEvent:     dev.LastEvent,
+	}
+	s.deviceMu.RUnlock()
+
+	select {
+	case s.deviceUpdates <- deviceCopy:
+	default:
+		slog.Warn("Device channel full, dropping refresh", "deviceID", id)
+	}
+}
+
+// Observe додає спостерігача оброблених кадрів
+func (s *Server) Observe(fn Observer) {
+	s.observerMu.Lock()
+	s.observers = append(s.observers, fn)
+	s.observerMu.Unlock()
+}
+
+// observe передає кадр спостерігачам
+func (s *Server) observe(f Frame) {
+	s.observerMu.RLock()
+	defer s.observerMu.RUnlock()
+	for _, fn := range s.observers {
+		fn(f)
+	}
+}
+
 func (s *Server) GetDeviceUpdatesChannel() <-chan Device {
 	return s.deviceUpdates
 }
@@ -425,6 +611,7 @@ func (c *connection) handleRequest(ctx context.Context) {
 		if r := recover(); r != nil {
 			slog.Error("Panic in handleRequest", "panic", r, "from", c.conn.RemoteAddr())
 		}
+		c.server.trackConn(c.conn, false)
 		c.conn.Close()
 	}()
 
@@ -451,6 +638,10 @@ func (c *connection) handleRequest(ctx context.Context) {
 		n, err := reader.Read(chunk)
 		if err != nil {
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				if ctx.Err() != nil {
+					slog.Info("Closing connection due to shutdown", "client", remoteAddr)
+					return
+				}
 				slog.Debug("Read timeout", "from", remoteAddr)
 				if _, err := c.conn.Write([]byte{nackByte}); err != nil {
 					slog.Error("Error sending NACK on timeout", "error", err)
@@ -486,48 +677,97 @@ func (c *connection) handleRequest(ctx context.Context) {
 				continue
 			}
 
-			slog.Debug("Received message", "from", remoteAddr, "length", len(msg))
+			// Ідентифікатор супроводжує кадр через чергу до приймача
+			c.seq++
+			corrID := logging.CorrelationID(c.session, c.seq)
+			received := time.Now()
+			slog.Debug("Received message", "from", remoteAddr, "length", len(msg), logging.CorrelationKey, corrID)
 
 			if cidparser.IsHeartBeat(string(msg)) {
 				if _, err := c.conn.Write([]byte{ackByte}); err != nil {
-					slog.Error("Error sending ACK for heartbeat", "error", err)
+					slog.Error("Error sending ACK for heartbeat", "error", err, logging.CorrelationKey, corrID)
 				}
 				continue
 			}
 
-			if !cidparser.IsMessageValid(string(msg), c.rules) {
-				slog.Debug("Invalid message format", "from", remoteAddr)
+			rules := c.server.Rules()
+			if !cidparser.IsMessageValid(string(msg), rules) {
+				slog.Debug("Invalid message format", "from", remoteAddr, logging.CorrelationKey, corrID)
 				if _, err := c.conn.Write([]byte{nackByte}); err != nil {
-					slog.Error("Error sending NACK", "error", err)
+					slog.Error("Error sending NACK", "error", err, logging.CorrelationKey, corrID)
 				}
 				continue
 			}
 
-			newMessage, err := cidparser.ChangeAccountNumber(msg, c.rules)
+			newMessage, err := cidparser.ChangeAccountNumber(msg, rules)
 			if err != nil {
-				slog.Error("Error processing message", "from", remoteAddr, "error", err)
+				slog.Error("Error processing message", "from", remoteAddr, "error", err, logging.CorrelationKey, corrID)
 				if _, err := c.conn.Write([]byte{nackByte}); err != nil {
-					slog.Error("Error sending NACK", "error", err)
+					slog.Error("Error sending NACK", "error", err, logging.CorrelationKey, corrID)
 				}
 				continue
 			}
 
+			deviceID := extractDeviceID(newMessage)
+
+			// Під час зупинки нові повідомлення не приймаємо: панель повторить їх пізніше
+			if ctx.Err() != nil {
+				slog.Info("Rejecting message during shutdown", "from", remoteAddr, "deviceID", deviceID, logging.CorrelationKey, corrID)
+				if _, err := c.conn.Write([]byte{nackByte}); err != nil {
+					slog.Error("Error sending NACK", "error", err, logging.CorrelationKey, corrID)
+				}
+				continue
+			}
+
+			// Повторний кадр у вікні дедуплікації: відповідаємо результатом оригіналу
+			var dupEntry *dedup.Entry
+			if c.server.dedup != nil {
+				entry, duplicate := c.server.dedup.Begin(dedup.Key(deviceID, newMessage))
+				if duplicate {
+					c.server.metrics.IncrementDuplicates()
+					status, ok := entry.Wait(duplicateWait)
+					slog.Info("Duplicate frame suppressed", "from", remoteAddr, "deviceID", deviceID, "ack", status && ok, logging.CorrelationKey, corrID)
+
+					response := []byte{nackByte}
+					if status && ok {
+						response = []byte{ackByte}
+					}
+					if err := c.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+						slog.Error("Failed to set write deadline", "error", err, logging.CorrelationKey, corrID)
+						return
+					}
+					if _, err := c.conn.Write(response); err != nil {
+						slog.Error("Error sending response for duplicate", "error", err, logging.CorrelationKey, corrID)
+						return
+					}
+					continue
+				}
+				dupEntry = entry
+			}
+
 			replyCh := make(chan queue.DeliveryData, 1)
 			sharedData := queue.SharedData{
+				ID:      corrID,
 				Payload: newMessage,
 				ReplyCh: replyCh,
 			}
+			frame := Frame{ID: corrID, Payload: newMessage, DeviceID: deviceID, Received: received}
+			replied := func(outcome string) {
+				frame.Replied, frame.Outcome = time.Now(), outcome
+				c.server.observe(frame)
+			}
 
 			if c.queue.Enqueue(sharedData) {
-				deviceID := extractDeviceID(newMessage)
 				c.server.UpdateDevice(deviceID, string(newMessage))
 
 				select {
 				case clientReply, ok := <-replyCh:
 					if !ok {
-						slog.Warn("Reply channel closed unexpectedly", "from", remoteAddr)
+						slog.Warn("Reply channel closed unexpectedly", "from", remoteAddr, logging.CorrelationKey, corrID)
+						c.server.forgetDuplicate(dupEntry)
 						return
 					}
+					c.server.completeDuplicate(dupEntry, clientReply.Status)
 					
 					response := []byte{nackByte}
 					if clientReply.Status {
@@ -535,28 +775,38 @@ func (c *connection) handleRequest(ctx context.Context) {
 					}
 					
 					if err := c.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
-						slog.Error("Failed to set write deadline", "error", err)
+						slog.Error("Failed to set write deadline", "error", err, logging.CorrelationKey, corrID)
 						return
 					}
 					
 					if _, err := c.conn.Write(response); err != nil {
-						slog.Error("Error sending response", "error", err)
+						slog.Error("Error sending response", "error", err, logging.CorrelationKey, corrID)
 						return
 					}
 					
-					slog.Debug("Message relayed", "from", remoteAddr, "ack", clientReply.Status)
+					slog.Debug("Message relayed", "from", remoteAddr, "ack", clientReply.Status, logging.CorrelationKey, corrID)
+					if clientReply.Status {
+						replied(OutcomeAck)
+					} else {
+						replied(OutcomeNack)
+					}
 
 				case <-time.After(replyTimeout):
-					slog.Error("Timeout waiting for client reply", "from", remoteAddr)
+					slog.Error("Timeout waiting for client reply", "from", remoteAddr, logging.CorrelationKey, corrID)
+					// Кадр ще в черзі: повтор панелі отримає результат цієї доставки, а не піде до приймача вдруге
+					c.server.settleLate(dupEntry, replyCh)
 					if _, err := c.conn.Write([]byte{nackByte}); err != nil {
-						slog.Error("Error sending NACK after timeout", "error", err)
+						slog.Error("Error sending NACK after timeout", "error", err, logging.CorrelationKey, corrID)
 					}
+					replied(OutcomeTimeout)
 				}
 			} else {
-				slog.Warn("Queue buffer full, rejecting message", "from", remoteAddr)
+				slog.Warn("Queue buffer full, rejecting message", "from", remoteAddr, logging.CorrelationKey, corrID)
+				c.server.forgetDuplicate(dupEntry)
 				if _, err := c.conn.Write([]byte{nackByte}); err != nil {
-					slog.Error("Error sending NACK", "error", err)
+					slog.Error("Error sending NACK", "error", err, logging.CorrelationKey, corrID)
 				}
+				replied(OutcomeRejected)
 			}
 		}
 
@@ -567,6 +817,38 @@ func (c *connection) handleRequest(ctx context.Context) {
 	}
 }
 
+// completeDuplicate фіксує ACK для дедуплікації (якщо вона увімкнена). Після NACK
+// запис прибирається, щоб повтор панелі знову пішов до приймача.
+func (s *Server) completeDuplicate(e *dedup.Entry, status bool) {
+	if e == nil {
+		return
+	}
+	if status {
+		s.dedup.Complete(e, true)
+	} else {
+		s.dedup.Forget(e)
+	}
+}
+
+// settleLate чекає відповідь приймача на кадр, для якого панель уже отримала NACK
+// за таймаутом, і лише тоді завершує запис дедуплікації
+func (s *Server) settleLate(e *dedup.Entry, replyCh <-chan queue.DeliveryData) {
+	if e == nil {
+		return
+	}
+	go func() {
+		reply, ok := <-replyCh
+		s.completeDuplicate(e, ok && reply.Status)
+	}()
+}
+
+// forgetDuplicate прибирає кадр з кешу дедуплікації, щоб ретрансляція пройшла заново
+func (s *Server) forgetDuplicate(e *dedup.Entry) {
+	if e != nil {
+		s.dedup.Forget(e)
+	}
+}
+
 func extractDeviceID(message []byte) int {
 	const (
 		minMessageLength = 11
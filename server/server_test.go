@@ -6,6 +6,7 @@ import (
 	"cid_retranslator_walk/queue"
 	"context"
 	"net"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -76,6 +77,28 @@ func TestServer_UpdateDevice(t *testing.T) {
 	}
 }
 
+func TestServer_RefreshDevice(t *testing.T) {
+	s := New(&config.ServerConfig{}, nil, nil)
+	s.RefreshDevice(1234) // Невідомий пристрій
+	s.UpdateDevice(1234, "event1")
+	<-s.GetDeviceUpdatesChannel()
+
+	s.RefreshDevice(1234)
+	select {
+	case d := <-s.GetDeviceUpdatesChannel():
+		if d.ID != 1234 || d.LastEvent != "event1" {
+			t.Errorf("refreshed device = %+v", d)
+		}
+	default:
+		t.Fatal("expected device update in channel")
+	}
+	select {
+	case d := <-s.GetDeviceUpdatesChannel():
+		t.Errorf("unexpected update %+v", d)
+	default:
+	}
+}
+
 func TestServer_GetDevices(t *testing.T) {
 	s := New(&config.ServerConfig{}, nil, nil)
 
@@ -105,6 +128,9 @@ func TestServer_handleRequest(t *testing.T) {
 		if string(data.Payload) != expectedPayload {
 			t.Errorf("unexpected payload: %q, want %q", string(data.Payload), expectedPayload)
 		}
+		if data.ID != "7-1" {
+			t.Errorf("correlation ID = %q, want session 7, frame 1", data.ID)
+		}
 		// Simulate successful processing
 		go func() {
 			data.ReplyCh <- queue.DeliveryData{Status: true}
@@ -120,15 +146,17 @@ func TestServer_handleRequest(t *testing.T) {
 
 	s := New(&config.ServerConfig{}, mockQ, rules)
 	s.wg.Add(1) // handleRequest calls Done()
+	observed := make(chan Frame, 1)
+	s.Observe(func(f Frame) { observed <- f })
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	connHandler := &connection{
-		conn:   serverConn,
-		queue:  mockQ,
-		rules:  rules,
-		server: s,
+		conn:    serverConn,
+		queue:   mockQ,
+		server:  s,
+		session: 7,
 	}
 
 	go connHandler.handleRequest(ctx)
@@ -157,6 +185,15 @@ func TestServer_handleRequest(t *testing.T) {
 	if buf[0] != ackByte {
 		t.Errorf("expected ACK, got %x", buf[0])
 	}
+
+	select {
+	case f := <-observed:
+		if f.ID != "7-1" || f.Outcome != OutcomeAck || f.DeviceID != 2100 || f.Replied.Before(f.Received) {
+			t.Errorf("observed frame = %+v", f)
+		}
+	case <-time.After(time.Second):
+		t.Error("observer was not called")
+	}
 }
 
 func TestExtractDeviceID(t *testing.T) {
@@ -178,3 +215,262 @@ func TestExtractDeviceID(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_handleRequestDuplicate(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	mockQ := queue.NewMockQueue()
+	enqueued := 0
+	mockQ.EnqueueFunc = func(data queue.SharedData) bool {
+		enqueued++
+		data.ReplyCh <- queue.DeliveryData{Status: true}
+		return true
+	}
+
+	rules := &config.CIDRules{
+		RequiredPrefix: "5",
+		ValidLength:    20,
+	}
+
+	s := New(&config.ServerConfig{DedupWindow: time.Minute, DedupMaxEntries: 10}, mockQ, rules)
+	s.wg.Add(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	connHandler := &connection{
+		conn:   serverConn,
+		queue:  mockQ,
+		server: s,
+	}
+	go connHandler.handleRequest(ctx)
+
+	msg := "5010 182100R57516331" + "\x14"
+	buf := make([]byte, 1)
+	for i := 0; i < 2; i++ {
+		go clientConn.Write([]byte(msg))
+
+		if _, err := clientConn.Read(buf); err != nil {
+			t.Fatalf("failed to read reply %d: %v", i, err)
+		}
+		if buf[0] != ackByte {
+			t.Errorf("reply %d: expected ACK, got %x", i, buf[0])
+		}
+	}
+
+	if enqueued != 1 {
+		t.Errorf("expected duplicate not to be enqueued, got %d enqueues", enqueued)
+	}
+	if got := mockQ.Stats.Snapshot().Duplicates; got != 1 {
+		t.Errorf("expected 1 duplicate in metrics, got %d", got)
+	}
+}
+
+// dedupSession запускає сесію з дедуплікацією; enqueue отримує кожен поставлений у чергу кадр
+func dedupSession(t *testing.T, enqueue func(data queue.SharedData)) net.Conn {
+	t.Helper()
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() { clientConn.Close(); serverConn.Close() })
+
+	mockQ := queue.NewMockQueue()
+	mockQ.EnqueueFunc = func(data queue.SharedData) bool {
+		enqueue(data)
+		return true
+	}
+	rules := &config.CIDRules{RequiredPrefix: "5", ValidLength: 20}
+	s := New(&config.ServerConfig{DedupWindow: time.Minute, DedupMaxEntries: 10}, mockQ, rules)
+	s.wg.Add(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	connHandler := &connection{conn: serverConn, queue: mockQ, server: s}
+	go connHandler.handleRequest(ctx)
+	return clientConn
+}
+
+// send надсилає кадр від панелі і повертає відповідь
+func send(t *testing.T, conn net.Conn, msg string) byte {
+	t.Helper()
+	go conn.Write([]byte(msg))
+	buf := make([]byte, 1)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(buf); err != nil {
+		t.Fatalf("failed to read reply: %v", err)
+	}
+	return buf[0]
+}
+
+// Приймач відповів пізніше за таймаут панелі: повтор кадру не йде до приймача вдруге,
+// а отримує результат першої доставки
+func TestServer_handleRequestLateReply(t *testing.T) {
+	defer func(reply, wait time.Duration) { replyTimeout, duplicateWait = reply, wait }(replyTimeout, duplicateWait)
+	replyTimeout, duplicateWait = 50*time.Millisecond, 20*time.Millisecond
+
+	var enqueued atomic.Int32
+	pending := make(chan chan queue.DeliveryData, 2)
+	conn := dedupSession(t, func(data queue.SharedData) {
+		enqueued.Add(1)
+		pending <- data.ReplyCh
+	})
+
+	const msg = "5010 182100E13001001\x14"
+	if got := send(t, conn, msg); got != nackByte {
+		t.Fatalf("reply after timeout = %x, want NACK", got)
+	}
+	// Оригінал ще доставляється: повтор отримує NACK, не чекаючи повного таймауту
+	start := time.Now()
+	if got := send(t, conn, msg); got != nackByte {
+		t.Errorf("reply while pending = %x, want NACK", got)
+	}
+	if elapsed := time.Since(start); elapsed >= replyTimeout {
+		t.Errorf("duplicate waited %s, want less than %s", elapsed, replyTimeout)
+	}
+
+	(<-pending) <- queue.DeliveryData{Status: true}
+	time.Sleep(10 * time.Millisecond)
+	if got := send(t, conn, msg); got != ackByte {
+		t.Errorf("reply after late delivery = %x, want ACK", got)
+	}
+	if n := enqueued.Load(); n != 1 {
+		t.Errorf("frame delivered upstream %d times, want 1", n)
+	}
+}
+
+// NACK приймача не кешується: повтор панелі знову йде до приймача
+func TestServer_handleRequestRetryAfterNack(t *testing.T) {
+	var enqueued atomic.Int32
+	conn := dedupSession(t, func(data queue.SharedData) {
+		data.ReplyCh <- queue.DeliveryData{Status: enqueued.Add(1) > 1}
+	})
+
+	const msg = "5010 182100E13001001\x14"
+	if got := send(t, conn, msg); got != nackByte {
+		t.Fatalf("first reply = %x, want NACK", got)
+	}
+	if got := send(t, conn, msg); got != ackByte {
+		t.Errorf("reply to retransmission = %x, want ACK", got)
+	}
+	if n := enqueued.Load(); n != 2 {
+		t.Errorf("enqueued %d times, want 2", n)
+	}
+}
+
+func TestServer_RebindAndSetRules(t *testing.T) {
+	s := New(&config.ServerConfig{Host: "127.0.0.1", Port: "0"}, queue.NewMockQueue(), &config.CIDRules{ValidLength: 20})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+	defer s.Stop()
+
+	// Чекаємо старту слухача
+	deadline := time.Now().Add(time.Second)
+	for s.currentAddr() == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	oldAddr := s.currentAddr()
+	if oldAddr == nil {
+		t.Fatal("server did not start")
+	}
+
+	if err := s.Rebind("127.0.0.1", "0"); err != nil {
+		t.Fatalf("Rebind() error = %v", err)
+	}
+	newAddr := s.currentAddr()
+	if newAddr.String() == oldAddr.String() {
+		t.Fatal("expected a new listener address")
+	}
+
+	conn, err := net.Dial("tcp", newAddr.String())
+	if err != nil {
+		t.Fatalf("failed to connect to new address: %v", err)
+	}
+	conn.Close()
+
+	if _, err := net.Dial("tcp", oldAddr.String()); err == nil {
+		t.Error("old address should be closed after rebind")
+	}
+
+	s.SetRules(&config.CIDRules{ValidLength: 21})
+	if s.Rules().ValidLength != 21 {
+		t.Errorf("SetRules() not applied, got %+v", s.Rules())
+	}
+}
+
+// currentAddr повертає адресу активного слухача (nil, якщо сервер не запущено)
+func (s *Server) currentAddr() net.Addr {
+	s.listenMu.Lock()
+	defer s.listenMu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+func TestServer_StopWakesIdleSessions(t *testing.T) {
+	s := New(&config.ServerConfig{Host: "127.0.0.1", Port: "0"}, queue.NewMockQueue(), &config.CIDRules{ValidLength: 20})
+	go s.Run(context.Background())
+
+	deadline := time.Now().Add(time.Second)
+	for s.currentAddr() == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	conn, err := net.Dial("tcp", s.currentAddr().String())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+	time.Sleep(20 * time.Millisecond) // Сесія чекає на дані
+
+	start := time.Now()
+	s.Stop()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Stop() took %s waiting for an idle session", elapsed)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Errorf("expected the session to be closed, read %d bytes", n)
+	}
+}
+
+func TestServer_handleRequestNacksDuringShutdown(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	enqueued := 0
+	mockQ := queue.NewMockQueue()
+	mockQ.EnqueueFunc = func(data queue.SharedData) bool {
+		enqueued++
+		cancel() // Зупинка почалася, поки перше повідомлення в дорозі
+		data.ReplyCh <- queue.DeliveryData{Status: true}
+		return true
+	}
+
+	s := New(&config.ServerConfig{}, mockQ, &config.CIDRules{RequiredPrefix: "5", ValidLength: 20})
+	s.wg.Add(1)
+	go (&connection{conn: serverConn, queue: mockQ, server: s}).handleRequest(ctx)
+
+	// Два повідомлення в одному сегменті
+	go clientConn.Write([]byte("5010 182100R57516331\x145010 182100E13016331\x14"))
+
+	replies := make([]byte, 2)
+	clientConn.SetReadDeadline(time.Now().Add(time.Second))
+	for i := range replies {
+		if _, err := clientConn.Read(replies[i : i+1]); err != nil {
+			t.Fatalf("reply %d: %v", i, err)
+		}
+	}
+	if replies[0] != ackByte || replies[1] != nackByte {
+		t.Errorf("replies = %x, want ACK for the in-flight message and NACK after shutdown", replies)
+	}
+	if enqueued != 1 {
+		t.Errorf("enqueued %d messages, want 1", enqueued)
+	}
+}
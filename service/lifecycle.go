@@ -0,0 +1,90 @@
+// Package service запускає ядро ретранслятора без UI: як служба Windows або
+// як юніт systemd. Керування службою (встановлення, запуск, зупинка) винесене
+// за інтерфейс Manager, повідомлення менеджеру служб - за інтерфейс Notifier.
+package service
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// State - стан життєвого циклу служби
+type State int
+
+const (
+	Stopped State = iota
+	Starting
+	Running
+	Stopping
+)
+
+func (s State) String() string {
+	switch s {
+	case Stopped:
+		return "stopped"
+	case Starting:
+		return "starting"
+	case Running:
+		return "running"
+	case Stopping:
+		return "stopping"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// transitions - дозволені переходи між станами. Зупинка можлива і під час
+// запуску (наприклад, сигнал прийшов до готовності).
+var transitions = map[State][]State{
+	Stopped:  {Starting},
+	Starting: {Running, Stopping},
+	Running:  {Stopping},
+	Stopping: {Stopped},
+}
+
+// TransitionError повертається при спробі недозволеного переходу
+type TransitionError struct {
+	From, To State
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("invalid service state transition %s -> %s", e.From, e.To)
+}
+
+// Lifecycle - машина станів служби, безпечна для використання з кількох горутин
+type Lifecycle struct {
+	mu       sync.Mutex
+	state    State
+	onChange func(from, to State)
+}
+
+// NewLifecycle створює машину станів у стані Stopped. onChange (може бути nil)
+// викликається після кожного переходу.
+func NewLifecycle(onChange func(from, to State)) *Lifecycle {
+	return &Lifecycle{onChange: onChange}
+}
+
+// State повертає поточний стан
+func (l *Lifecycle) State() State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state
+}
+
+// Transition переводить службу у стан to або повертає *TransitionError
+func (l *Lifecycle) Transition(to State) error {
+	l.mu.Lock()
+	from := l.state
+	if !slices.Contains(transitions[from], to) {
+		l.mu.Unlock()
+		return &TransitionError{From: from, To: to}
+	}
+	l.state = to
+	l.mu.Unlock()
+
+	if l.onChange != nil {
+		l.onChange(from, to)
+	}
+	return nil
+}
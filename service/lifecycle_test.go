@@ -0,0 +1,59 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLifecycle_Transition(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    []State
+		wantErr bool
+	}{
+		{"full cycle", []State{Starting, Running, Stopping, Stopped}, false},
+		{"stop while starting", []State{Starting, Stopping, Stopped}, false},
+		{"restart after stop", []State{Starting, Running, Stopping, Stopped, Starting}, false},
+		{"run without start", []State{Running}, true},
+		{"double start", []State{Starting, Starting}, true},
+		{"stopped without stopping", []State{Starting, Running, Stopped}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLifecycle(nil)
+			var err error
+			for _, s := range tt.path {
+				if err = l.Transition(s); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var te *TransitionError
+			if err != nil && !errors.As(err, &te) {
+				t.Errorf("error %T is not *TransitionError", err)
+			}
+		})
+	}
+}
+
+func TestLifecycle_OnChange(t *testing.T) {
+	var got [][2]State
+	l := NewLifecycle(func(from, to State) {
+		got = append(got, [2]State{from, to})
+	})
+
+	l.Transition(Starting)
+	l.Transition(Stopped) // Недозволений перехід не повідомляється
+	l.Transition(Running)
+
+	want := [][2]State{{Stopped, Starting}, {Starting, Running}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("onChange calls = %v, want %v", got, want)
+	}
+	if l.State() != Running {
+		t.Errorf("State() = %s, want running", l.State())
+	}
+}
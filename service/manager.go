@@ -0,0 +1,26 @@
+package service
+
+import "errors"
+
+// DefaultName - ім'я служби за замовчуванням
+const DefaultName = "cid-retranslator"
+
+// ErrNotInstalled повертається, якщо служби з таким ім'ям немає
+var ErrNotInstalled = errors.New("service is not installed")
+
+// Config - параметри встановлення служби
+type Config struct {
+	Name        string
+	DisplayName string
+	Description string
+	Executable  string   // Абсолютний шлях до виконуваного файлу
+	Args        []string // Аргументи запуску, наприклад -config
+}
+
+// Manager встановлює службу в менеджері служб ОС і керує нею
+type Manager interface {
+	Install(cfg Config) error
+	Uninstall(name string) error
+	Start(name string) error
+	Stop(name string) error
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Стани протоколу sd_notify
+const (
+	NotifyReady    = "READY=1"
+	NotifyStopping = "STOPPING=1"
+	NotifyWatchdog = "WATCHDOG=1"
+)
+
+// Notifier повідомляє менеджеру служб про стан процесу
+type Notifier interface {
+	// Notify надсилає рядок стану (наприклад, NotifyReady)
+	Notify(state string) error
+	// WatchdogInterval - як часто надсилати NotifyWatchdog; 0 - watchdog вимкнений
+	WatchdogInterval() time.Duration
+}
+
+// NopNotifier нічого не надсилає: процес запущений не під systemd
+type NopNotifier struct{}
+
+func (NopNotifier) Notify(string) error             { return nil }
+func (NopNotifier) WatchdogInterval() time.Duration { return 0 }
+
+// SystemdNotifier реалізує протокол sd_notify: датаграми на сокет NOTIFY_SOCKET
+type SystemdNotifier struct {
+	socket   string
+	watchdog time.Duration
+}
+
+// NewNotifier повертає SystemdNotifier, якщо процес запущений systemd
+// (задано NOTIFY_SOCKET), інакше NopNotifier
+func NewNotifier() Notifier {
+	return notifierFromEnv(os.Getenv, os.Getpid())
+}
+
+func notifierFromEnv(getenv func(string) string, pid int) Notifier {
+	socket := getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return NopNotifier{}
+	}
+	return &SystemdNotifier{socket: socket, watchdog: watchdogInterval(getenv, pid)}
+}
+
+// watchdogInterval повертає половину WATCHDOG_USEC, як радить sd_watchdog_enabled(3).
+// WATCHDOG_PID, якщо задано, має збігатися з нашим процесом.
+func watchdogInterval(getenv func(string) string, pid int) time.Duration {
+	usec, err := strconv.ParseInt(getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if p := getenv("WATCHDOG_PID"); p != "" && p != strconv.Itoa(pid) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// Notify надсилає стан на сокет systemd. Ім'я, що починається з '@', -
+// абстрактний сокет Linux; net обробляє такі адреси сам.
+func (n *SystemdNotifier) Notify(state string) error {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: n.socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+func (n *SystemdNotifier) WatchdogInterval() time.Duration {
+	return n.watchdog
+}
@@ -0,0 +1,67 @@
+//go:build linux
+
+package service
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNotifierFromEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantSystemd  bool
+		wantWatchdog time.Duration
+	}{
+		{"not under systemd", nil, false, 0},
+		{"notify only", map[string]string{"NOTIFY_SOCKET": "/run/notify"}, true, 0},
+		{"watchdog", map[string]string{"NOTIFY_SOCKET": "/run/notify", "WATCHDOG_USEC": "30000000"}, true, 15 * time.Second},
+		{"watchdog for our pid", map[string]string{"NOTIFY_SOCKET": "/run/notify", "WATCHDOG_USEC": "2000000", "WATCHDOG_PID": "42"}, true, time.Second},
+		{"watchdog for another pid", map[string]string{"NOTIFY_SOCKET": "/run/notify", "WATCHDOG_USEC": "2000000", "WATCHDOG_PID": "7"}, true, 0},
+		{"invalid watchdog", map[string]string{"NOTIFY_SOCKET": "/run/notify", "WATCHDOG_USEC": "soon"}, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := notifierFromEnv(func(key string) string { return tt.env[key] }, 42)
+			if _, ok := n.(*SystemdNotifier); ok != tt.wantSystemd {
+				t.Fatalf("notifier = %T, want systemd %v", n, tt.wantSystemd)
+			}
+			if got := n.WatchdogInterval(); got != tt.wantWatchdog {
+				t.Errorf("WatchdogInterval() = %s, want %s", got, tt.wantWatchdog)
+			}
+		})
+	}
+}
+
+func TestSystemdNotifier_Notify(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	n := notifierFromEnv(func(key string) string {
+		return map[string]string{"NOTIFY_SOCKET": socket, "WATCHDOG_USEC": strconv.Itoa(1000)}[key]
+	}, 1)
+
+	for _, state := range []string{NotifyReady, NotifyWatchdog, NotifyStopping} {
+		if err := n.Notify(state); err != nil {
+			t.Fatalf("Notify(%q): %v", state, err)
+		}
+		buf := make([]byte, 64)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		k, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:k]); got != state {
+			t.Errorf("received %q, want %q", got, state)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// App - ядро ретранслятора, яким керує служба (реалізується *core.App)
+type App interface {
+	Startup()
+	// Shutdown доставляє прийняте і зупиняє ядро
+	Shutdown(ctx context.Context)
+	// Ctx скасовується сигналом ОС (SIGINT, SIGTERM)
+	Ctx() context.Context
+}
+
+// Service проводить ядро через життєвий цикл і повідомляє менеджеру служб
+// про готовність, зупинку та живучість (watchdog)
+type Service struct {
+	app       App
+	notifier  Notifier
+	lifecycle *Lifecycle
+}
+
+// New створює службу для ядра. onChange (може бути nil) викликається при
+// кожній зміні стану - через нього служба Windows звітує SCM.
+func New(app App, notifier Notifier, onChange func(from, to State)) *Service {
+	if notifier == nil {
+		notifier = NopNotifier{}
+	}
+	return &Service{
+		app:       app,
+		notifier:  notifier,
+		lifecycle: NewLifecycle(onChange),
+	}
+}
+
+// State повертає поточний стан служби
+func (s *Service) State() State {
+	return s.lifecycle.State()
+}
+
+// Run запускає ядро і блокує, доки не закриється stop або ядро не отримає
+// сигнал ОС, після чого зупиняє ядро. Повторний запуск до завершення
+// попереднього повертає *TransitionError.
+func (s *Service) Run(stop <-chan struct{}) error {
+	if err := s.lifecycle.Transition(Starting); err != nil {
+		return err
+	}
+	s.app.Startup()
+	if err := s.lifecycle.Transition(Running); err != nil {
+		return err
+	}
+	s.notify(NotifyReady)
+	slog.Info("Service is running")
+
+	var watchdog <-chan time.Time
+	if interval := s.notifier.WatchdogInterval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		watchdog = ticker.C
+		slog.Info("Service watchdog enabled", "interval", interval)
+	}
+
+	for running := true; running; {
+		select {
+		case <-watchdog:
+			s.notify(NotifyWatchdog)
+		case <-stop:
+			running = false
+		case <-s.app.Ctx().Done():
+			running = false
+		}
+	}
+
+	if err := s.lifecycle.Transition(Stopping); err != nil {
+		return err
+	}
+	s.notify(NotifyStopping)
+	slog.Info("Service is stopping")
+	s.app.Shutdown(context.Background())
+	return s.lifecycle.Transition(Stopped)
+}
+
+func (s *Service) notify(state string) {
+	if err := s.notifier.Notify(state); err != nil {
+		slog.Warn("Failed to notify service manager", "state", state, "error", err)
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeApp записує виклики ядра
+type fakeApp struct {
+	ctx      context.Context
+	mu       sync.Mutex
+	calls    []string
+	shutdown chan struct{}
+}
+
+func newFakeApp(ctx context.Context) *fakeApp {
+	return &fakeApp{ctx: ctx, shutdown: make(chan struct{})}
+}
+
+func (a *fakeApp) record(call string) {
+	a.mu.Lock()
+	a.calls = append(a.calls, call)
+	a.mu.Unlock()
+}
+
+func (a *fakeApp) Startup()                 { a.record("startup") }
+func (a *fakeApp) Shutdown(context.Context) { a.record("shutdown"); close(a.shutdown) }
+func (a *fakeApp) Ctx() context.Context     { return a.ctx }
+
+// fakeNotifier записує надіслані стани
+type fakeNotifier struct {
+	mu       sync.Mutex
+	states   []string
+	watchdog time.Duration
+}
+
+func (n *fakeNotifier) Notify(state string) error {
+	n.mu.Lock()
+	n.states = append(n.states, state)
+	n.mu.Unlock()
+	return nil
+}
+
+func (n *fakeNotifier) WatchdogInterval() time.Duration { return n.watchdog }
+
+func (n *fakeNotifier) sent() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return slices.Clone(n.states)
+}
+
+func TestService_Run(t *testing.T) {
+	app := newFakeApp(context.Background())
+	notifier := &fakeNotifier{watchdog: 10 * time.Millisecond}
+	var changes []State
+	s := New(app, notifier, func(_, to State) { changes = append(changes, to) })
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- s.Run(stop) }()
+
+	// Чекаємо кілька сигналів watchdog
+	deadline := time.Now().Add(time.Second)
+	for slices.Index(notifier.sent(), NotifyWatchdog) < 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no watchdog notification")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s.State() != Running {
+		t.Errorf("State() = %s, want running", s.State())
+	}
+	close(stop)
+
+	if err := <-done; err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if s.State() != Stopped {
+		t.Errorf("State() = %s, want stopped", s.State())
+	}
+	if want := []State{Starting, Running, Stopping, Stopped}; !slices.Equal(changes, want) {
+		t.Errorf("state changes = %v, want %v", changes, want)
+	}
+	if !slices.Equal(app.calls, []string{"startup", "shutdown"}) {
+		t.Errorf("app calls = %v", app.calls)
+	}
+
+	sent := notifier.sent()
+	if sent[0] != NotifyReady || sent[len(sent)-1] != NotifyStopping {
+		t.Errorf("notifications = %v, want READY first and STOPPING last", sent)
+	}
+}
+
+func TestService_RunStopsOnSignal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	app := newFakeApp(ctx)
+	s := New(app, nil, nil)
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run(nil) }()
+
+	// Сигнал ОС скасовує контекст ядра
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run() = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return after the signal")
+	}
+	<-app.shutdown
+}
+
+func TestService_RunTwice(t *testing.T) {
+	app := newFakeApp(context.Background())
+	s := New(app, nil, nil)
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- s.Run(stop) }()
+	for s.State() != Running {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := s.Run(nil); err == nil {
+		t.Error("second Run() while running succeeded")
+	}
+	close(stop)
+	<-done
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// SystemdUnitDir - каталог юнітів, які встановлює адміністратор
+const SystemdUnitDir = "/etc/systemd/system"
+
+// Systemd керує службою як юнітом systemd (Type=notify з watchdog)
+type Systemd struct {
+	UnitDir string
+	// Command виконує systemctl; підміняється в тестах
+	Command func(name string, args ...string) error
+}
+
+// NewSystemd повертає менеджер для системного екземпляра systemd
+func NewSystemd() *Systemd {
+	return &Systemd{UnitDir: SystemdUnitDir, Command: runCommand}
+}
+
+func runCommand(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (s *Systemd) unitPath(name string) string {
+	return filepath.Join(s.UnitDir, name+".service")
+}
+
+// Install записує файл юніта, перечитує конфігурацію systemd і вмикає
+// автозапуск служби
+func (s *Systemd) Install(cfg Config) error {
+	if err := os.WriteFile(s.unitPath(cfg.Name), []byte(UnitFile(cfg)), 0o644); err != nil {
+		return err
+	}
+	if err := s.Command("systemctl", "daemon-reload"); err != nil {
+		return err
+	}
+	return s.Command("systemctl", "enable", cfg.Name+".service")
+}
+
+// Uninstall зупиняє і вимикає службу та видаляє файл юніта
+func (s *Systemd) Uninstall(name string) error {
+	path := s.unitPath(name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotInstalled
+	}
+	if err := s.Command("systemctl", "disable", "--now", name+".service"); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return s.Command("systemctl", "daemon-reload")
+}
+
+func (s *Systemd) Start(name string) error {
+	return s.Command("systemctl", "start", name+".service")
+}
+
+func (s *Systemd) Stop(name string) error {
+	return s.Command("systemctl", "stop", name+".service")
+}
+
+// UnitFile формує юніт systemd для служби. Зупинка чекає доставки черг
+// (queue.draintimeout), тому TimeoutStopSec має запас.
+func UnitFile(cfg Config) string {
+	description := cfg.Description
+	if description == "" {
+		description = cfg.DisplayName
+	}
+
+	command := make([]string, 0, len(cfg.Args)+1)
+	for _, arg := range append([]string{cfg.Executable}, cfg.Args...) {
+		command = append(command, quoteUnitArg(arg))
+	}
+
+	return fmt.Sprintf(unitTemplate, description, strings.Join(command, " "), filepath.Dir(cfg.Executable))
+}
+
+const unitTemplate = `[Unit]
+Description=%s
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=notify
+ExecStart=%s
+WorkingDirectory=%s
+Restart=on-failure
+RestartSec=5
+WatchdogSec=30
+TimeoutStopSec=90
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// quoteUnitArg екранує аргумент ExecStart: '%' - специфікатор systemd,
+// пробіли і лапки вимагають подвійних лапок
+func quoteUnitArg(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\") {
+		return arg
+	}
+	arg = strings.ReplaceAll(arg, `\`, `\\`)
+	arg = strings.ReplaceAll(arg, `"`, `\"`)
+	return `"` + arg + `"`
+}
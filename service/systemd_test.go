@@ -0,0 +1,93 @@
+//go:build !windows
+
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUnitFile(t *testing.T) {
+	unit := UnitFile(Config{
+		Name:        "cid",
+		DisplayName: "CID Retranslator",
+		Executable:  "/opt/cid relay/cidrelayd",
+		Args:        []string{"-config", "/etc/cid/config.yaml", "-set", "client.host=50%"},
+	})
+
+	for _, want := range []string{
+		"Description=CID Retranslator\n",
+		"Type=notify\n",
+		`ExecStart="/opt/cid relay/cidrelayd" -config /etc/cid/config.yaml -set client.host=50%%` + "\n",
+		"WorkingDirectory=/opt/cid relay\n",
+		"WatchdogSec=30\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit file has no %q:\n%s", want, unit)
+		}
+	}
+}
+
+func TestQuoteUnitArg(t *testing.T) {
+	tests := map[string]string{
+		"run":      "run",
+		"":         `""`,
+		"a b":      `"a b"`,
+		`say "hi"`: `"say \"hi\""`,
+		`C:\cid`:   `"C:\\cid"`,
+		"100%":     "100%%",
+	}
+	for arg, want := range tests {
+		if got := quoteUnitArg(arg); got != want {
+			t.Errorf("quoteUnitArg(%q) = %s, want %s", arg, got, want)
+		}
+	}
+}
+
+func TestSystemd_InstallUninstall(t *testing.T) {
+	var commands []string
+	s := &Systemd{
+		UnitDir: t.TempDir(),
+		Command: func(name string, args ...string) error {
+			commands = append(commands, name+" "+strings.Join(args, " "))
+			return nil
+		},
+	}
+	unitPath := filepath.Join(s.UnitDir, "cid.service")
+
+	if err := s.Install(Config{Name: "cid", Executable: "/usr/bin/cidrelayd", Args: []string{"run"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(unitPath); err != nil {
+		t.Fatalf("unit file not written: %v", err)
+	}
+	s.Start("cid")
+	s.Stop("cid")
+	if err := s.Uninstall("cid"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(unitPath); !os.IsNotExist(err) {
+		t.Errorf("unit file still exists after Uninstall")
+	}
+
+	want := []string{
+		"systemctl daemon-reload",
+		"systemctl enable cid.service",
+		"systemctl start cid.service",
+		"systemctl stop cid.service",
+		"systemctl disable --now cid.service",
+		"systemctl daemon-reload",
+	}
+	if !slices.Equal(commands, want) {
+		t.Errorf("commands = %q, want %q", commands, want)
+	}
+
+	if err := s.Uninstall("cid"); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("second Uninstall() = %v, want ErrNotInstalled", err)
+	}
+}
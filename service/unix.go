@@ -0,0 +1,14 @@
+//go:build !windows
+
+package service
+
+// NewManager повертає менеджер юнітів systemd
+func NewManager() Manager {
+	return NewSystemd()
+}
+
+// Run запускає ядро і повідомляє systemd про готовність та живучість, якщо
+// процес запущений як юніт Type=notify. Зупиняється сигналом ОС.
+func Run(name string, app App) error {
+	return New(app, NewNotifier(), nil).Run(nil)
+}
@@ -0,0 +1,173 @@
+//go:build windows
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// stopWaitHint - скільки SCM чекати зупинки (доставка черг перед виходом)
+const stopWaitHint = 90 * time.Second
+
+// NewManager повертає менеджер служб Windows (SCM)
+func NewManager() Manager {
+	return windowsManager{}
+}
+
+type windowsManager struct{}
+
+func (windowsManager) open(name string, fn func(s *mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+		return ErrNotInstalled
+	}
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return fn(s)
+}
+
+// Install реєструє службу з автозапуском і перезапуском після збою
+func (windowsManager) Install(cfg Config) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.CreateService(cfg.Name, cfg.Executable, mgr.Config{
+		DisplayName: cfg.DisplayName,
+		Description: cfg.Description,
+		StartType:   mgr.StartAutomatic,
+	}, cfg.Args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	restart := mgr.RecoveryAction{Type: mgr.ServiceRestart, Delay: 5 * time.Second}
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{restart, restart, restart}, 86400); err != nil {
+		slog.Warn("Failed to set service recovery actions", "service", cfg.Name, "error", err)
+	}
+	return nil
+}
+
+func (w windowsManager) Uninstall(name string) error {
+	return w.open(name, func(s *mgr.Service) error {
+		if status, err := s.Query(); err == nil && status.State != svc.Stopped {
+			if err := stopAndWait(s); err != nil {
+				return err
+			}
+		}
+		return s.Delete()
+	})
+}
+
+func (w windowsManager) Start(name string) error {
+	return w.open(name, func(s *mgr.Service) error {
+		return s.Start()
+	})
+}
+
+func (w windowsManager) Stop(name string) error {
+	return w.open(name, stopAndWait)
+}
+
+// stopAndWait надсилає службі Stop і чекає, доки вона зупиниться
+func stopAndWait(s *mgr.Service) error {
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(stopWaitHint)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service did not stop within %s", stopWaitHint)
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run запускає ядро: під SCM - як службу Windows name, з консолі - як
+// звичайний процес, що зупиняється сигналом
+func Run(name string, app App) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		return New(app, NopNotifier{}, nil).Run(nil)
+	}
+	return svc.Run(name, &handler{app: app})
+}
+
+// handler перекладає запити SCM на життєвий цикл служби
+type handler struct {
+	app App
+}
+
+func (h *handler) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	s := New(h.app, NopNotifier{}, func(_, to State) {
+		if status, ok := windowsStatus(to); ok {
+			changes <- status
+		}
+	})
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- s.Run(stop) }()
+
+	for {
+		select {
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				changes <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				if stop != nil {
+					close(stop)
+					stop = nil
+				}
+			}
+		case err := <-done:
+			if err != nil {
+				slog.Error("Service failed", "error", err)
+				return false, 1
+			}
+			return false, 0
+		}
+	}
+}
+
+// windowsStatus відповідає стану життєвого циклу; Stopped SCM отримує сам
+// після виходу з Execute
+func windowsStatus(state State) (svc.Status, bool) {
+	switch state {
+	case Starting:
+		return svc.Status{State: svc.StartPending}, true
+	case Running:
+		return svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}, true
+	case Stopping:
+		return svc.Status{State: svc.StopPending, WaitHint: uint32(stopWaitHint / time.Millisecond)}, true
+	default:
+		return svc.Status{}, false
+	}
+}
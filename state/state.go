@@ -0,0 +1,474 @@
+// Package state відстежує, що залишається відкритим на об'єктах: тривоги і
+// несправності без відновлення (E130 у зоні 5 з 14:02 без R130, E301 без R301)
+// і стан охорони кожного розділу. Події пари визначає словник кодів: код з
+// полем Restore відкриває стан, а код відновлення його закриває. Для розділів
+// ведеться історія постановок і знять, а за розкладом об'єкта виявляються раннє
+// зняття і невчасна постановка. Стан зберігається у файл і переживає перезапуск.
+package state
+
+import (
+	"cid_retranslator_walk/cidparser"
+	"cid_retranslator_walk/eventdict"
+	"cid_retranslator_walk/server"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound повертається, якщо відкритого стану з таким ID немає
+	ErrNotFound = errors.New("condition not found")
+	// ErrDisabled повертається, якщо відстеження вимкнене в конфігурації
+	ErrDisabled = errors.New("state tracking is disabled")
+)
+
+// checkInterval - як часто змінений стан записується у файл і перевіряється розклад
+const checkInterval = 2 * time.Second
+
+// Відхилення від розкладу
+const (
+	ExceptionEarlyOpen = "early_open" // Знято з охорони раніше за відкриття
+	ExceptionLateClose = "late_close" // Не поставлено під охорону до закриття
+)
+
+// Classifier дає класифікацію коду (eventdict.Dictionary)
+type Classifier interface {
+	Classify(code string) (eventdict.Class, bool)
+}
+
+// Condition - відкритий стан: подія без відповідного відновлення
+type Condition struct {
+	ID        string    `json:"id"` // pipeline:account:partition:zone:code
+	Pipeline  string    `json:"pipeline"`
+	Account   int       `json:"account"`
+	Partition int       `json:"partition"` // Група з кадру
+	Zone      int       `json:"zone"`
+	Code      string    `json:"code"`    // Код, що відкрив стан
+	Restore   string    `json:"restore"` // Код, що його закриє
+	Category  string    `json:"category"`
+	Severity  string    `json:"severity"`
+	Since     time.Time `json:"since"`    // Перша подія
+	LastSeen  time.Time `json:"lastSeen"` // Остання повторна подія
+}
+
+// Partition - стан охорони розділу за останньою подією постановки чи зняття
+type Partition struct {
+	ID        string    `json:"id"` // pipeline:account:partition
+	Pipeline  string    `json:"pipeline"`
+	Account   int       `json:"account"`
+	Partition int       `json:"partition"`
+	Armed     bool      `json:"armed"`
+	User      int       `json:"user"` // Номер користувача з поля зони
+	Code      string    `json:"code"` // Код останньої постановки чи зняття
+	Changed   time.Time `json:"changed"`
+	Exception string    `json:"exception,omitempty"` // Поточне відхилення від розкладу
+}
+
+// Transition - постановка чи зняття розділу в історії об'єкта
+type Transition struct {
+	Pipeline  string    `json:"pipeline"`
+	Account   int       `json:"account"`
+	Partition int       `json:"partition"`
+	Armed     bool      `json:"armed"`
+	User      int       `json:"user"`
+	Code      string    `json:"code"`
+	At        time.Time `json:"at"`
+	Exception string    `json:"exception,omitempty"` // Раннє зняття або запізніла постановка
+}
+
+// Schedule - години, коли об'єкт очікувано знятий з охорони
+type Schedule struct {
+	Days  []time.Weekday // Порожній - щодня
+	Open  time.Duration  // Від початку доби
+	Close time.Duration
+	Grace time.Duration // Допустиме відхилення
+}
+
+// on повертає початок доби at, якщо цього дня діє розклад
+func (s Schedule) on(at time.Time) (time.Time, bool) {
+	at = at.Local()
+	if len(s.Days) > 0 && !slices.Contains(s.Days, at.Weekday()) {
+		return time.Time{}, false
+	}
+	return time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.Local), true
+}
+
+// Snapshot - копія всього стану, впорядкована за об'єктом
+type Snapshot struct {
+	Conditions []Condition `json:"conditions"`
+	Partitions []Partition `json:"partitions"`
+}
+
+// stored - вміст файлу стану
+type stored struct {
+	Snapshot
+	History []Transition `json:"history"`
+}
+
+// Tracker веде стан об'єктів. Безпечний для одночасного використання.
+type Tracker struct {
+	path     string // Порожній - лише в пам'яті
+	history  int    // Записів історії на розділ
+	classify Classifier
+
+	mu         sync.Mutex
+	conditions map[string]*Condition
+	partitions map[string]*Partition
+	timeline   map[string][]Transition // За ID розділу, від старих до нових
+	schedules  map[int]Schedule        // За номером об'єкта
+	dirty      bool
+
+	saveMu sync.Mutex // Записи у файл не перетинаються
+}
+
+// Open створює Tracker, що зберігає history постановок і знять на розділ,
+// і завантажує стан з path (якщо файл існує)
+func Open(path string, history int, classify Classifier) (*Tracker, error) {
+	t := &Tracker{
+		path:       path,
+		history:    history,
+		classify:   classify,
+		conditions: make(map[string]*Condition),
+		partitions: make(map[string]*Partition),
+		timeline:   make(map[string][]Transition),
+	}
+	if path == "" {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st stored
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("state %s: %w", path, err)
+	}
+	for _, c := range st.Conditions {
+		t.conditions[c.ID] = &c
+	}
+	for _, p := range st.Partitions {
+		t.partitions[p.ID] = &p
+	}
+	for _, tr := range st.History {
+		t.record(tr)
+	}
+	return t, nil
+}
+
+// SetSchedules замінює розклади об'єктів
+func (t *Tracker) SetSchedules(schedules map[int]Schedule) {
+	t.mu.Lock()
+	t.schedules = schedules
+	t.mu.Unlock()
+}
+
+// Submit оновлює стан за кадром. Як і інциденти, враховуються лише кадри з ACK:
+// панель повторює відхилений кадр, і повтор не має потрапити в історію ще раз.
+// Повертає true, якщо змінився стан охорони розділу.
+func (t *Tracker) Submit(pipeline string, fr server.Frame) bool {
+	if fr.Outcome != server.OutcomeAck {
+		return false
+	}
+	m, err := cidparser.Parse(fr.Payload)
+	if err != nil {
+		return false
+	}
+	return t.Apply(pipeline, m, fr.Received)
+}
+
+// Apply оновлює стан за подією m, що надійшла в момент at.
+// Повертає true, якщо змінився стан охорони розділу.
+func (t *Tracker) Apply(pipeline string, m cidparser.Message, at time.Time) bool {
+	class, _ := t.classify.Classify(m.Code)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Відновлення закриває всі стани цього місця, які на нього чекають
+	for id, c := range t.conditions {
+		if c.Restore == m.Code && c.Pipeline == pipeline && c.Account == m.Account &&
+			c.Partition == m.Group && c.Zone == m.Zone {
+			delete(t.conditions, id)
+			t.dirty = true
+			slog.Info("Condition restored", "pipeline", pipeline, "account", m.Account,
+				"partition", m.Group, "zone", m.Zone, "code", c.Code, "restore", m.Code, "open", at.Sub(c.Since).Round(time.Second))
+		}
+	}
+
+	switch {
+	case class.Restore != "":
+		id := fmt.Sprintf("%s:%d:%d:%d:%s", pipeline, m.Account, m.Group, m.Zone, m.Code)
+		if c, ok := t.conditions[id]; ok {
+			c.LastSeen = at
+		} else {
+			t.conditions[id] = &Condition{
+				ID:        id,
+				Pipeline:  pipeline,
+				Account:   m.Account,
+				Partition: m.Group,
+				Zone:      m.Zone,
+				Code:      m.Code,
+				Restore:   class.Restore,
+				Category:  class.Category,
+				Severity:  class.Severity,
+				Since:     at,
+				LastSeen:  at,
+			}
+		}
+		t.dirty = true
+	case class.Category == eventdict.CategoryArm || class.Category == eventdict.CategoryDisarm:
+		return t.setArmed(pipeline, m, class.Category == eventdict.CategoryArm, at)
+	}
+	return false
+}
+
+// setArmed записує постановку чи зняття розділу і перевіряє раннє зняття за розкладом.
+// Повтор тієї самої події (стан, користувач і код не змінилися) не записується;
+// тоді повертає false.
+func (t *Tracker) setArmed(pipeline string, m cidparser.Message, armed bool, at time.Time) bool {
+	id := fmt.Sprintf("%s:%d:%d", pipeline, m.Account, m.Group)
+	p, ok := t.partitions[id]
+	if !ok {
+		p = &Partition{ID: id, Pipeline: pipeline, Account: m.Account, Partition: m.Group}
+		t.partitions[id] = p
+	} else if !p.Changed.IsZero() && p.Armed == armed && p.User == m.Zone && p.Code == m.Code {
+		return false
+	}
+	tr := Transition{Pipeline: pipeline, Account: m.Account, Partition: m.Group, Armed: armed, User: m.Zone, Code: m.Code, At: at}
+
+	switch {
+	case armed:
+		// Постановка після дедлайну закриття завершує запізнення
+		if p.Exception == ExceptionLateClose {
+			tr.Exception = ExceptionLateClose
+		}
+		p.Exception = ""
+	case p.Armed || p.Changed.IsZero():
+		if s, ok := t.schedules[m.Account]; ok {
+			if day, ok := s.on(at); ok && at.Before(day.Add(s.Open-s.Grace)) {
+				tr.Exception, p.Exception = ExceptionEarlyOpen, ExceptionEarlyOpen
+				slog.Warn("Partition disarmed before schedule", "pipeline", pipeline, "account", m.Account,
+					"partition", m.Group, "user", m.Zone, "open", day.Add(s.Open).Format("15:04"))
+			}
+		}
+	}
+
+	p.Armed, p.User, p.Code, p.Changed = armed, m.Zone, m.Code, at
+	t.record(tr)
+	t.dirty = true
+	return true
+}
+
+// record додає запис в історію розділу, відкидаючи найстаріші понад ліміт
+func (t *Tracker) record(tr Transition) {
+	id := fmt.Sprintf("%s:%d:%d", tr.Pipeline, tr.Account, tr.Partition)
+	list := append(t.timeline[id], tr)
+	if t.history > 0 && len(list) > t.history {
+		list = slices.Delete(list, 0, len(list)-t.history)
+	}
+	t.timeline[id] = list
+}
+
+// Check позначає розділи, які за розкладом мали бути поставлені під охорону до now
+func (t *Tracker) Check(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, p := range t.partitions {
+		s, ok := t.schedules[p.Account]
+		if !ok || p.Armed || p.Exception == ExceptionLateClose {
+			continue
+		}
+		day, ok := s.on(now)
+		deadline := day.Add(s.Close + s.Grace)
+		if !ok || now.Before(deadline) || !p.Changed.Before(deadline) {
+			continue
+		}
+		p.Exception = ExceptionLateClose
+		t.dirty = true
+		slog.Warn("Partition not armed by schedule", "pipeline", p.Pipeline, "account", p.Account,
+			"partition", p.Partition, "close", day.Add(s.Close).Format("15:04"))
+	}
+}
+
+// History повертає постановки і зняття розділів об'єкта від старих до нових
+func (t *Tracker) History(account int) []Transition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := []Transition{}
+	for _, transitions := range t.timeline {
+		if len(transitions) > 0 && transitions[0].Account == account {
+			list = append(list, transitions...)
+		}
+	}
+	sortTransitions(list)
+	return list
+}
+
+// Account повертає стан охорони розділів об'єкта в конвеєрі
+func (t *Tracker) Account(pipeline string, account int) []Partition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var list []Partition
+	for _, p := range t.sortedPartitions() {
+		if p.Pipeline == pipeline && p.Account == account {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// Conditions повертає відкриті стани, впорядковані за об'єктом, розділом і зоною
+func (t *Tracker) Conditions() []Condition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sortedConditions()
+}
+
+// Partitions повертає стан охорони розділів, впорядкований за об'єктом і розділом
+func (t *Tracker) Partitions() []Partition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sortedPartitions()
+}
+
+// Snapshot повертає копію всього стану (для UI)
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Snapshot{Conditions: t.sortedConditions(), Partitions: t.sortedPartitions()}
+}
+
+// Clear закриває стан вручну (відновлення не надійде, наприклад, після заміни обладнання)
+func (t *Tracker) Clear(id string) (Condition, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.conditions[id]
+	if !ok {
+		return Condition{}, ErrNotFound
+	}
+	delete(t.conditions, id)
+	t.dirty = true
+	return *c, nil
+}
+
+func (t *Tracker) sortedConditions() []Condition {
+	list := make([]Condition, 0, len(t.conditions))
+	for _, c := range t.conditions {
+		list = append(list, *c)
+	}
+	slices.SortFunc(list, func(a, b Condition) int {
+		return cmp.Or(
+			strings.Compare(a.Pipeline, b.Pipeline),
+			cmp.Compare(a.Account, b.Account),
+			cmp.Compare(a.Partition, b.Partition),
+			cmp.Compare(a.Zone, b.Zone),
+			strings.Compare(a.Code, b.Code),
+		)
+	})
+	return list
+}
+
+func (t *Tracker) sortedPartitions() []Partition {
+	list := make([]Partition, 0, len(t.partitions))
+	for _, p := range t.partitions {
+		list = append(list, *p)
+	}
+	slices.SortFunc(list, func(a, b Partition) int {
+		return cmp.Or(
+			strings.Compare(a.Pipeline, b.Pipeline),
+			cmp.Compare(a.Account, b.Account),
+			cmp.Compare(a.Partition, b.Partition),
+		)
+	})
+	return list
+}
+
+func sortTransitions(list []Transition) {
+	slices.SortStableFunc(list, func(a, b Transition) int {
+		return cmp.Or(
+			a.At.Compare(b.At),
+			strings.Compare(a.Pipeline, b.Pipeline),
+			cmp.Compare(a.Partition, b.Partition),
+		)
+	})
+}
+
+// Run перевіряє розклад і записує змінений стан у файл до скасування ctx
+// і востаннє перед виходом
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := t.Save(); err != nil {
+				slog.Error("Cannot save state", "path", t.path, "error", err)
+			}
+			return
+		case now := <-ticker.C:
+			t.Check(now)
+			if err := t.Save(); err != nil {
+				slog.Error("Cannot save state", "path", t.path, "error", err)
+			}
+		}
+	}
+}
+
+// Save атомарно записує стан у файл, якщо він змінився з останнього запису.
+// Під блокуванням робиться лише знімок, кодування і запис - поза ним.
+func (t *Tracker) Save() error {
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+
+	t.mu.Lock()
+	if !t.dirty || t.path == "" {
+		t.mu.Unlock()
+		return nil
+	}
+	st := stored{Snapshot: Snapshot{Conditions: t.sortedConditions(), Partitions: t.sortedPartitions()}, History: []Transition{}}
+	for _, transitions := range t.timeline {
+		st.History = append(st.History, transitions...)
+	}
+	t.dirty = false
+	t.mu.Unlock()
+
+	if err := t.write(st); err != nil {
+		t.mu.Lock()
+		t.dirty = true
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// write записує стан у файл (тимчасовий файл + перейменування)
+func (t *Tracker) write(st stored) error {
+	sortTransitions(st.History)
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(t.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
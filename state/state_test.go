@@ -0,0 +1,261 @@
+package state
+
+import (
+	"cid_retranslator_walk/cidparser"
+	"cid_retranslator_walk/eventdict"
+	"cid_retranslator_walk/server"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// classes - невеликий словник: тривога і несправність з відновленням, постановка і зняття
+type classes map[string]eventdict.Class
+
+func (c classes) Classify(code string) (eventdict.Class, bool) {
+	class, ok := c[code]
+	return class, ok
+}
+
+var testClasses = classes{
+	"E130": {Category: eventdict.CategoryAlarm, Severity: eventdict.SeverityCritical, Restore: "R130"},
+	"R130": {Category: eventdict.CategoryRestore, Severity: eventdict.SeverityInfo},
+	"E301": {Category: eventdict.CategoryOther, Severity: eventdict.SeverityMinor, Restore: "R301"},
+	"R301": {Category: eventdict.CategoryRestore, Severity: eventdict.SeverityInfo},
+	"E401": {Category: eventdict.CategoryDisarm, Severity: eventdict.SeverityInfo},
+	"R401": {Category: eventdict.CategoryArm, Severity: eventdict.SeverityInfo},
+	"E602": {Category: eventdict.CategoryOther, Severity: eventdict.SeverityInfo},
+}
+
+var t0 = time.Date(2026, 3, 1, 14, 2, 0, 0, time.UTC)
+
+func msg(account int, code string, group, zone int) cidparser.Message {
+	return cidparser.Message{Account: account, Qualifier: code[0], Code: code, Group: group, Zone: zone}
+}
+
+func TestTracker_Pairs(t *testing.T) {
+	tr, err := Open("", 100, testClasses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Apply("default", msg(1234, "E130", 1, 5), t0)
+	tr.Apply("default", msg(1234, "E130", 1, 5), t0.Add(time.Minute)) // Повтор не відкриває новий стан
+	tr.Apply("default", msg(1234, "E130", 1, 6), t0)
+	tr.Apply("default", msg(1234, "E301", 0, 0), t0)
+	tr.Apply("default", msg(1234, "E602", 0, 0), t0) // Без відновлення - не стан
+
+	conds := tr.Conditions()
+	if len(conds) != 3 {
+		t.Fatalf("conditions = %+v, want 3", conds)
+	}
+	if c := conds[1]; c.ID != "default:1234:1:5:E130" || !c.Since.Equal(t0) || !c.LastSeen.Equal(t0.Add(time.Minute)) ||
+		c.Restore != "R130" || c.Severity != eventdict.SeverityCritical {
+		t.Errorf("condition = %+v", c)
+	}
+
+	// Відновлення закриває лише свою зону; відновлення іншого конвеєра чи об'єкта нічого не змінює
+	tr.Apply("default", msg(1234, "R130", 1, 5), t0.Add(2*time.Minute))
+	tr.Apply("backup", msg(1234, "R301", 0, 0), t0)
+	tr.Apply("default", msg(4321, "R301", 0, 0), t0)
+	var ids []string
+	for _, c := range tr.Conditions() {
+		ids = append(ids, c.ID)
+	}
+	want := []string{"default:1234:0:0:E301", "default:1234:1:6:E130"}
+	if len(ids) != len(want) || ids[0] != want[0] || ids[1] != want[1] {
+		t.Errorf("conditions after restore = %v, want %v", ids, want)
+	}
+}
+
+func TestTracker_Partitions(t *testing.T) {
+	tr, _ := Open("", 100, testClasses)
+	tr.Apply("default", msg(1234, "R401", 1, 7), t0)
+	tr.Apply("default", msg(1234, "R401", 2, 7), t0)
+	tr.Apply("default", msg(1234, "E401", 1, 3), t0.Add(time.Hour))
+
+	parts := tr.Partitions()
+	if len(parts) != 2 {
+		t.Fatalf("partitions = %+v, want 2", parts)
+	}
+	if p := parts[0]; p.Partition != 1 || p.Armed || p.User != 3 || p.Code != "E401" || !p.Changed.Equal(t0.Add(time.Hour)) {
+		t.Errorf("partition 1 = %+v, want disarmed", p)
+	}
+	if p := parts[1]; p.Partition != 2 || !p.Armed {
+		t.Errorf("partition 2 = %+v, want armed", p)
+	}
+	if parts := tr.Account("default", 1234); len(parts) != 2 {
+		t.Errorf("Account() = %+v", parts)
+	}
+
+	var users []int
+	for _, h := range tr.History(1234) {
+		users = append(users, h.User)
+	}
+	if len(users) != 3 || users[2] != 3 {
+		t.Errorf("history users = %v", users)
+	}
+	if h := tr.History(4321); len(h) != 0 {
+		t.Errorf("history of another object = %+v", h)
+	}
+}
+
+func TestTracker_HistoryLimit(t *testing.T) {
+	tr, _ := Open("", 3, testClasses)
+	for i := range 5 {
+		tr.Apply("default", msg(1234, "R401", 1, i), t0.Add(time.Duration(i)*time.Minute))
+	}
+	h := tr.History(1234)
+	if len(h) != 3 || h[0].User != 2 || h[2].User != 4 {
+		t.Errorf("history = %+v, want the last 3", h)
+	}
+}
+
+func TestTracker_Schedule(t *testing.T) {
+	// Понеділок; розклад 08:00-20:00 з відхиленням 15 хвилин лише в робочі дні
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local)
+	tr, _ := Open("", 100, testClasses)
+	tr.SetSchedules(map[int]Schedule{1234: {
+		Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Open:  8 * time.Hour,
+		Close: 20 * time.Hour,
+		Grace: 15 * time.Minute,
+	}})
+
+	tr.Apply("default", msg(1234, "R401", 1, 1), day.Add(-4*time.Hour)) // Неділя, без розкладу
+	tr.Apply("default", msg(1234, "R401", 2, 1), day.Add(-4*time.Hour))
+	tr.Apply("default", msg(1234, "E401", 1, 7), day.Add(7*time.Hour+50*time.Minute)) // У межах відхилення
+	tr.Apply("default", msg(1234, "E401", 2, 8), day.Add(7*time.Hour))                // Раннє зняття
+	parts := tr.Account("default", 1234)
+	if parts[0].Exception != "" || parts[1].Exception != ExceptionEarlyOpen {
+		t.Fatalf("partitions after opening = %+v", parts)
+	}
+
+	tr.Apply("default", msg(1234, "R401", 1, 7), day.Add(20*time.Hour+10*time.Minute))
+	tr.Check(day.Add(20*time.Hour + 14*time.Minute))
+	if parts := tr.Account("default", 1234); parts[1].Exception != ExceptionEarlyOpen {
+		t.Fatalf("partition 2 flagged before the deadline: %+v", parts[1])
+	}
+	tr.Check(day.Add(20*time.Hour + 16*time.Minute))
+	parts = tr.Account("default", 1234)
+	if parts[0].Exception != "" || parts[1].Exception != ExceptionLateClose {
+		t.Fatalf("partitions after closing = %+v", parts)
+	}
+
+	// Запізніла постановка позначається в історії і знімає відхилення
+	tr.Apply("default", msg(1234, "R401", 2, 8), day.Add(21*time.Hour))
+	h := tr.History(1234)
+	if last := h[len(h)-1]; last.Exception != ExceptionLateClose || !last.Armed {
+		t.Errorf("last transition = %+v", last)
+	}
+	if h[2].Exception != ExceptionEarlyOpen || h[2].Partition != 2 {
+		t.Errorf("early open transition = %+v", h[2])
+	}
+	if parts := tr.Account("default", 1234); parts[1].Exception != "" {
+		t.Errorf("partition 2 after arming = %+v", parts[1])
+	}
+
+	// У вихідний розклад не діє
+	tr.Apply("default", msg(1234, "E401", 1, 7), day.Add(5*24*time.Hour+6*time.Hour))
+	tr.Check(day.Add(5*24*time.Hour + 23*time.Hour))
+	if parts := tr.Account("default", 1234); parts[0].Exception != "" {
+		t.Errorf("partition 1 on Saturday = %+v", parts[0])
+	}
+}
+
+func TestTracker_Submit(t *testing.T) {
+	tr, _ := Open("", 100, testClasses)
+	tr.Submit("default", server.Frame{Payload: []byte("5010 181234E13001015\x14"), Received: t0, Outcome: server.OutcomeTimeout})
+	tr.Submit("default", server.Frame{Payload: []byte("5010 181234R40101007\x14"), Received: t0, Outcome: server.OutcomeNack})
+	if conds, parts := tr.Conditions(), tr.Partitions(); len(conds) != 0 || len(parts) != 0 {
+		t.Fatalf("state from rejected frames: %+v, %+v", conds, parts)
+	}
+
+	tr.Submit("default", server.Frame{Payload: []byte("5010 181234E13001015\x14"), Received: t0, Outcome: server.OutcomeAck})
+	tr.Submit("default", server.Frame{Payload: []byte("garbage\x14"), Received: t0, Outcome: server.OutcomeAck})
+	if conds := tr.Conditions(); len(conds) != 1 || conds[0].ID != "default:1234:1:15:E130" {
+		t.Errorf("conditions = %+v", conds)
+	}
+
+	// Повтор постановки не змінює стан і не потрапляє в історію
+	arm := server.Frame{Payload: []byte("5010 181234R40101007\x14"), Received: t0, Outcome: server.OutcomeAck}
+	if !tr.Submit("default", arm) {
+		t.Error("first arm reported no change")
+	}
+	arm.Received = t0.Add(time.Minute)
+	if tr.Submit("default", arm) {
+		t.Error("repeated arm reported a change")
+	}
+	if h := tr.History(1234); len(h) != 1 || !h[0].At.Equal(t0) {
+		t.Errorf("history = %+v, want one arm", h)
+	}
+}
+
+// Стан переживає перезапуск
+func TestTracker_Persist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "state.json")
+	tr, err := Open(path, 100, testClasses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Apply("default", msg(1234, "E130", 1, 5), t0)
+	tr.Apply("default", msg(1234, "R401", 1, 7), t0)
+	tr.Apply("default", msg(1234, "E401", 1, 3), t0.Add(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tr.Run(ctx) // Фінальний запис
+
+	reopened, err := Open(path, 100, testClasses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := reopened.Snapshot()
+	if len(snap.Conditions) != 1 || !snap.Conditions[0].Since.Equal(t0) || len(snap.Partitions) != 1 || snap.Partitions[0].User != 3 {
+		t.Fatalf("snapshot after reopen = %+v", snap)
+	}
+	if h := reopened.History(1234); len(h) != 2 || !h[0].Armed || h[1].Armed {
+		t.Errorf("history after reopen = %+v", h)
+	}
+	reopened.Apply("default", msg(1234, "R130", 1, 5), t0.Add(time.Minute))
+	if conds := reopened.Conditions(); len(conds) != 0 {
+		t.Errorf("conditions after restore = %+v", conds)
+	}
+}
+
+// Невдалий запис не губить зміни: наступний Save повторює його
+func TestTracker_SaveRetry(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tr, _ := Open("", 100, testClasses)
+	tr.path = filepath.Join(blocker, "state.json")
+	tr.Apply("default", msg(1234, "E130", 1, 5), t0)
+
+	if err := tr.Save(); err == nil {
+		t.Fatal("Save() into a file path succeeded")
+	}
+	tr.path = filepath.Join(dir, "state.json")
+	if err := tr.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tr.path); err != nil {
+		t.Errorf("state not written on retry: %v", err)
+	}
+}
+
+func TestTracker_Clear(t *testing.T) {
+	tr, _ := Open("", 100, testClasses)
+	tr.Apply("default", msg(1234, "E301", 0, 0), t0)
+	c, err := tr.Clear("default:1234:0:0:E301")
+	if err != nil || c.Code != "E301" {
+		t.Fatalf("Clear() = %+v, %v", c, err)
+	}
+	if _, err := tr.Clear("default:1234:0:0:E301"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Clear error = %v, want ErrNotFound", err)
+	}
+}
@@ -93,7 +93,7 @@ func ShowPPKDetails(
 						Text: "Закрити",
 						OnClicked: func() {
 							model.Stop()
-							appCtx.Retranslator.CloseDeviceEventChannel(ppkItem.Number)
+							appCtx.Retranslator.CloseDeviceEventChannel(ppkItem.Pipeline, ppkItem.Number)
 							dlg.Accept()
 						},
 					},
@@ -110,7 +110,7 @@ func ShowPPKDetails(
 
 	// Завантажуємо початкові події
 	go func() {
-		events := appCtx.Retranslator.GetDeviceEvents(ppkItem.Number)
+		events := appCtx.Retranslator.GetDeviceEvents(ppkItem.Pipeline, ppkItem.Number)
 		for _, ev := range events {
 			if len(ev.Data) < 20 {
 				continue
@@ -121,10 +121,7 @@ func ShowPPKDetails(
 			group := ev.Data[15:17]
 			zone := ev.Data[17:20]
 
-			eventType, desc, found := appCtx.Adapter.EventMap.GetEventDescriptions(code)
-			if !found {
-				continue
-			}
+			eventType, desc, _ := appCtx.Adapter.Events.Describe(code)
 
 			priority, eventType := appCtx.Adapter.DetermineEventPriority(code, eventType)
 
@@ -148,7 +145,7 @@ func ShowPPKDetails(
 	}()
 
 	// Отримуємо канал для нових подій
-	deviceEventChan := appCtx.Retranslator.GetDeviceEventChannel(ppkItem.Number)
+	deviceEventChan := appCtx.Retranslator.GetDeviceEventChannel(ppkItem.Pipeline, ppkItem.Number)
 
 	// Запускаємо стрімінг нових подій
 	go appCtx.Adapter.StreamDeviceEventsToUI(
@@ -0,0 +1,191 @@
+package ui
+
+import (
+	"cid_retranslator_walk/constants"
+	"cid_retranslator_walk/incident"
+	"cid_retranslator_walk/models"
+	"fmt"
+	"time"
+
+	"github.com/lxn/walk"
+	. "github.com/lxn/walk/declarative"
+)
+
+func CreateIncidentsTab(
+	incidentModel *models.IncidentModel,
+	incidentTableView **walk.TableView,
+	mw **walk.MainWindow,
+	appCtx *AppContext,
+) TabPage {
+	acknowledge := func() {
+		item := incidentModel.GetItem((*incidentTableView).CurrentIndex())
+		if item == nil || item.Status == models.IncidentAcknowledged {
+			return
+		}
+		ShowIncidentAck(*mw, item, appCtx)
+	}
+
+	return TabPage{
+		Title:  "Інциденти",
+		Layout: VBox{},
+		Children: []Widget{
+			TableView{
+				AssignTo:            incidentTableView,
+				AlternatingRowBG:    true,
+				ColumnsOrderable:    true,
+				LastColumnStretched: true,
+				Model:               incidentModel,
+				Columns: []TableViewColumn{
+					{Title: "№", Width: 50},
+					{Title: "Час", Width: 130},
+					{Title: "ППК", Width: 70},
+					{Title: "Зона/Група", Width: 100},
+					{Title: "Код", Width: 60},
+					{Title: "Опис", Width: 180},
+					{Title: "Подій", Width: 50},
+					{Title: "Стан", Width: 100},
+					{Title: "Вирішення", Width: 200}, // Ця колонка розтягується
+				},
+				OnItemActivated: acknowledge,
+				StyleCell: func(style *walk.CellStyle) {
+					item := incidentModel.GetItem(style.Row())
+					if item == nil {
+						return
+					}
+
+					switch item.Status {
+					case models.IncidentEscalated:
+						style.BackgroundColor = constants.ColorRed
+						style.TextColor = constants.ColorWhite
+					case models.IncidentOpen:
+						style.BackgroundColor = constants.ColorOrange
+						style.TextColor = constants.ColorBlack
+					}
+				},
+			},
+			Composite{
+				Layout: HBox{MarginsZero: true},
+				Children: []Widget{
+					HSpacer{},
+					PushButton{
+						Text:      "Підтвердити",
+						OnClicked: acknowledge,
+					},
+				},
+			},
+		},
+	}
+}
+
+// ShowIncidentAck питає код вирішення і коментар і підтверджує інцидент
+func ShowIncidentAck(owner walk.Form, item *models.IncidentItem, appCtx *AppContext) {
+	var dlg *walk.Dialog
+	var resolutionBox *walk.ComboBox
+	var commentEdit *walk.LineEdit
+	var acceptPB, cancelPB *walk.PushButton
+
+	resolutions := appCtx.Retranslator.Incidents().Resolutions()
+
+	Dialog{
+		AssignTo:      &dlg,
+		Title:         fmt.Sprintf("Підтвердження інциденту %s", item.ID),
+		DefaultButton: &acceptPB,
+		CancelButton:  &cancelPB,
+		MinSize:       Size{Width: 400, Height: 200},
+		Layout:        VBox{},
+		Children: []Widget{
+			Composite{
+				Layout: Grid{Columns: 2},
+				Children: []Widget{
+					Label{Text: "ППК:", Font: Font{Bold: true}},
+					Label{Text: fmt.Sprintf("%s, %s", item.Device, item.Zone)},
+					Label{Text: "Подія:", Font: Font{Bold: true}},
+					Label{Text: fmt.Sprintf("%s %s", item.Code, item.Desc)},
+					Label{Text: "Вирішення:", Font: Font{Bold: true}},
+					ComboBox{AssignTo: &resolutionBox, Model: resolutions, CurrentIndex: 0},
+					Label{Text: "Коментар:", Font: Font{Bold: true}},
+					LineEdit{AssignTo: &commentEdit},
+				},
+			},
+			Composite{
+				Layout: HBox{},
+				Children: []Widget{
+					HSpacer{},
+					PushButton{
+						AssignTo: &acceptPB,
+						Text:     "Підтвердити",
+						OnClicked: func() {
+							_, err := appCtx.Retranslator.AcknowledgeIncident(item.ID, resolutionBox.Text(), commentEdit.Text())
+							if err != nil {
+								walk.MsgBox(dlg, "Помилка", err.Error(), walk.MsgBoxIconError)
+								return
+							}
+							dlg.Accept()
+						},
+					},
+					PushButton{
+						AssignTo:  &cancelPB,
+						Text:      "Скасувати",
+						OnClicked: func() { dlg.Cancel() },
+					},
+				},
+			},
+		},
+	}.Create(owner)
+
+	dlg.Run()
+}
+
+// StartIncidentRefresh перечитує інциденти, коли змінюється сховище
+func StartIncidentRefresh(tv *walk.TableView, incidentModel *models.IncidentModel, appCtx *AppContext) {
+	store := appCtx.Retranslator.Incidents()
+	if store == nil || tv == nil {
+		return
+	}
+
+	go func() {
+		var seen uint64
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			version := store.Version()
+			if version == seen {
+				continue
+			}
+			seen = version
+
+			list := store.List("")
+			items := make([]*models.IncidentItem, 0, len(list))
+			for _, inc := range list {
+				items = append(items, incidentItem(inc, appCtx))
+			}
+			tv.Synchronize(func() {
+				incidentModel.SetItems(items)
+			})
+		}
+	}()
+}
+
+func incidentItem(inc incident.Incident, appCtx *AppContext) *models.IncidentItem {
+	_, desc, _ := appCtx.Adapter.Events.Describe(inc.Code)
+	status := models.IncidentOpen
+	switch {
+	case inc.Status == incident.StatusAcknowledged:
+		status = models.IncidentAcknowledged
+	case !inc.Escalated.IsZero():
+		status = models.IncidentEscalated
+	}
+	return &models.IncidentItem{
+		ID:         inc.ID,
+		Opened:     inc.Opened,
+		Device:     fmt.Sprintf("%04d", inc.Account),
+		Zone:       fmt.Sprintf("Зона %03d|Група %02d", inc.Zone, inc.Partition),
+		Code:       inc.Code,
+		Desc:       desc,
+		Count:      inc.Count,
+		Status:     status,
+		Resolution: inc.Resolution,
+		Comment:    inc.Comment,
+	}
+}
@@ -31,10 +31,13 @@ func CreateMainWindow(ppkModel *models.PPKModel, eventModel *models.EventModel,
 	var tabWidget *walk.TabWidget
 	var ppkTableView *walk.TableView
 	var eventTableView *walk.TableView
+	var incidentTableView *walk.TableView
 	var notifyIcon *walk.NotifyIcon
 
 	// Створюємо індикатори зі зв'язком з моделлю статистики
 	statsIndicators := NewStatsIndicators()
+	incidentModel := models.NewIncidentModel()
+	settingsTab := NewSettingsTab(appCtx.Retranslator.Config, appCtx.Retranslator.UpdateConfig)
 
 	err := MainWindow{
 		AssignTo: &mw,
@@ -50,7 +53,8 @@ func CreateMainWindow(ppkModel *models.PPKModel, eventModel *models.EventModel,
 				Pages: []TabPage{
 					CreatePPKTab(ppkModel, &ppkTableView, &mw, appCtx, cfg),
 					CreateEventsTab(eventModel, &eventTableView),
-					CreateSettingsTab(cfg),
+					CreateIncidentsTab(incidentModel, &incidentTableView, &mw, appCtx),
+					settingsTab.CreateSettingsTab(),
 				},
 			},
 			statsIndicators.CreateIndicators(),
@@ -158,6 +162,8 @@ func CreateMainWindow(ppkModel *models.PPKModel, eventModel *models.EventModel,
 
 	// Запускаємо автооновлення таблиці ППК для відображення таймаутів
 	StartPPKRefresh(ppkTableView)
+	StartIncidentRefresh(incidentTableView, incidentModel, appCtx)
+	settingsTab.StartRefresh()
 
 	slog.Info("MainWindow created",
 		"ppkTableView", ppkTableView != nil,
@@ -30,6 +30,7 @@ func CreatePPKTab(
 				Columns: []TableViewColumn{
 					{Title: "№", Width: 60},
 					{Title: "ППК", Width: 80},
+					{Title: "Охорона", Width: 150},
 					{Title: "Остання подія", Width: 160}, // Ця колонка розтягується
 					{Title: "Дата/Час", Width: 160},
 				},
@@ -53,18 +54,18 @@ func CreatePPKTab(
 					}
 
 					// Перевірка на таймаут
-					if time.Since(item.Date) > cfg.Monitoring.PPKTimeout {
+					if time.Since(item.Date) > appCtx.Retranslator.PPKTimeout() {
 						style.BackgroundColor = constants.ColorRed
 						style.TextColor = constants.ColorWhite
 					}
 
 					if style.Col() == 2 {
 						switch item.Status {
-						case "Помилка":
+						case models.StatusEarlyOpen, models.StatusLateClose:
 							style.TextColor = constants.ColorRed
-						case "Попередження":
+						case models.StatusPartial:
 							style.TextColor = constants.ColorOrange
-						case "Активний":
+						case models.StatusArmed:
 							style.TextColor = constants.ColorGreen
 						}
 					}
@@ -2,7 +2,9 @@ package ui
 
 import (
 	"cid_retranslator_walk/config"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/lxn/walk"
@@ -11,11 +13,15 @@ import (
 
 // SettingsTab holds the configuration form widgets
 type SettingsTab struct {
-	cfg *config.Config
+	cfg     *config.Config // Copy of the configuration the form was filled from
+	current func() *config.Config
+	apply   ApplyConfigFunc
 
 	// Server fields
-	serverHost *walk.LineEdit
-	serverPort *walk.LineEdit
+	serverHost      *walk.LineEdit
+	serverPort      *walk.LineEdit
+	dedupWindow     *walk.LineEdit
+	dedupMaxEntries *walk.NumberEdit
 
 	// Client fields
 	clientHost       *walk.LineEdit
@@ -24,7 +30,10 @@ type SettingsTab struct {
 	reconnectMax     *walk.LineEdit
 
 	// Queue fields
-	bufferSize *walk.NumberEdit
+	bufferSize           *walk.NumberEdit
+	queueMode            *walk.ComboBox
+	queueDequeue         *walk.ComboBox
+	preserveAccountOrder *walk.CheckBox
 
 	// Logging fields
 	logFilename   *walk.LineEdit
@@ -47,11 +56,47 @@ type SettingsTab struct {
 	startMinimized *walk.CheckBox
 	minimizeToTray *walk.CheckBox
 	closeToTray    *walk.CheckBox
+
+	// API fields
+	apiEnabled *walk.CheckBox
+	apiListen  *walk.LineEdit
 }
 
-// NewSettingsTab creates a new settings tab with the given configuration
-func NewSettingsTab(cfg *config.Config) *SettingsTab {
-	return &SettingsTab{cfg: cfg}
+var (
+	queueModes        = []string{"fifo", "priority"}
+	queueDequeueModes = []string{"strict", "weighted"}
+)
+
+// ApplyConfigFunc saves the fields at paths, taken from cfg, to the
+// configuration file and applies the result to the running application
+type ApplyConfigFunc func(cfg *config.Config, paths []string) (*config.ReloadReport, error)
+
+// NewSettingsTab creates a new settings tab editing a copy of the configuration
+// returned by current
+func NewSettingsTab(current func() *config.Config, apply ApplyConfigFunc) *SettingsTab {
+	return &SettingsTab{cfg: current().Clone(), current: current, apply: apply}
+}
+
+// StartRefresh refills the form whenever the running configuration changes,
+// e.g. after the file is reloaded, so saving never writes back stale values
+func (st *SettingsTab) StartRefresh() {
+	go func() {
+		seen := st.current()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			cfg := st.current()
+			if cfg == seen {
+				continue
+			}
+			seen = cfg
+			st.serverHost.Synchronize(func() {
+				st.cfg = cfg.Clone()
+				st.resetSettings()
+			})
+		}
+	}()
 }
 
 // CreateSettingsTab creates the settings tab page
@@ -83,6 +128,22 @@ func (st *SettingsTab) CreateSettingsTab() TabPage {
 
 									Label{Text: "Порт:"},
 									LineEdit{AssignTo: &st.serverPort, Text: st.cfg.Server.Port},
+
+									Label{Text: "Вікно дедуплікації:"},
+									LineEdit{
+										AssignTo:    &st.dedupWindow,
+										Text:        st.cfg.Server.DedupWindow.String(),
+										ToolTipText: "Формат: 30s, 1m тощо. 0s вимикає придушення повторів",
+									},
+
+									Label{Text: "Максимум записів дедуплікації:"},
+									NumberEdit{
+										AssignTo: &st.dedupMaxEntries,
+										Value:    float64(st.cfg.Server.DedupMaxEntries),
+										Decimals: 0,
+										MinValue: 1,
+										MaxValue: 1000000,
+									},
 								},
 							},
 
@@ -126,6 +187,24 @@ func (st *SettingsTab) CreateSettingsTab() TabPage {
 										MinValue: 1,
 										MaxValue: 10000,
 									},
+
+									Label{Text: "Режим черги:"},
+									ComboBox{
+										AssignTo:     &st.queueMode,
+										Model:        queueModes,
+										CurrentIndex: indexOf(queueModes, st.cfg.Queue.Mode, 0),
+										ToolTipText:  "priority - тривоги обганяють рутинні повідомлення",
+									},
+
+									Label{Text: "Видача з черги:"},
+									ComboBox{
+										AssignTo:     &st.queueDequeue,
+										Model:        queueDequeueModes,
+										CurrentIndex: indexOf(queueDequeueModes, st.cfg.Queue.Dequeue, 0),
+									},
+
+									Label{Text: "Зберігати порядок акаунту:"},
+									CheckBox{AssignTo: &st.preserveAccountOrder, Checked: st.cfg.Queue.PreserveAccountOrder},
 								},
 							},
 
@@ -253,6 +332,23 @@ func (st *SettingsTab) CreateSettingsTab() TabPage {
 								},
 							},
 
+							// API Configuration
+							GroupBox{
+								Title:  "HTTP API",
+								Layout: Grid{Columns: 2},
+								Children: []Widget{
+									Label{Text: "Увімкнено:"},
+									CheckBox{AssignTo: &st.apiEnabled, Checked: st.cfg.API.Enabled},
+
+									Label{Text: "Адреса:"},
+									LineEdit{
+										AssignTo:    &st.apiListen,
+										Text:        st.cfg.API.Listen,
+										ToolTipText: "Формат: 127.0.0.1:8080",
+									},
+								},
+							},
+
 							// Save and Reset buttons
 							Composite{
 								Layout: HBox{},
@@ -280,17 +376,29 @@ func (st *SettingsTab) CreateSettingsTab() TabPage {
 
 // saveSettings saves the current form values to the configuration file
 func (st *SettingsTab) saveSettings() {
+	cfg := st.cfg.Clone()
+
 	// Update Server config
-	st.cfg.Server.Host = st.serverHost.Text()
-	st.cfg.Server.Port = st.serverPort.Text()
+	cfg.Server.Host = st.serverHost.Text()
+	cfg.Server.Port = st.serverPort.Text()
+
+	if dedupWindow, err := time.ParseDuration(st.dedupWindow.Text()); err == nil {
+		cfg.Server.DedupWindow = dedupWindow
+	} else {
+		walk.MsgBox(nil, "Помилка",
+			fmt.Sprintf("Невірний формат вікна дедуплікації: %v", err),
+			walk.MsgBoxIconError)
+		return
+	}
+	cfg.Server.DedupMaxEntries = int(st.dedupMaxEntries.Value())
 
 	// Update Client config
-	st.cfg.Client.Host = st.clientHost.Text()
-	st.cfg.Client.Port = st.clientPort.Text()
+	cfg.Client.Host = st.clientHost.Text()
+	cfg.Client.Port = st.clientPort.Text()
 
 	// Parse reconnect durations
 	if reconnectInitial, err := time.ParseDuration(st.reconnectInitial.Text()); err == nil {
-		st.cfg.Client.ReconnectInitial = reconnectInitial
+		cfg.Client.ReconnectInitial = reconnectInitial
 	} else {
 		walk.MsgBox(nil, "Помилка",
 			fmt.Sprintf("Невірний формат початкової затримки перепідключення: %v", err),
@@ -299,7 +407,7 @@ func (st *SettingsTab) saveSettings() {
 	}
 
 	if reconnectMax, err := time.ParseDuration(st.reconnectMax.Text()); err == nil {
-		st.cfg.Client.ReconnectMax = reconnectMax
+		cfg.Client.ReconnectMax = reconnectMax
 	} else {
 		walk.MsgBox(nil, "Помилка",
 			fmt.Sprintf("Невірний формат максимальної затримки перепідключення: %v", err),
@@ -308,25 +416,28 @@ func (st *SettingsTab) saveSettings() {
 	}
 
 	// Update Queue config
-	st.cfg.Queue.BufferSize = int(st.bufferSize.Value())
+	cfg.Queue.BufferSize = int(st.bufferSize.Value())
+	cfg.Queue.Mode = st.queueMode.Text()
+	cfg.Queue.Dequeue = st.queueDequeue.Text()
+	cfg.Queue.PreserveAccountOrder = st.preserveAccountOrder.Checked()
 
 	// Update Logging config
-	st.cfg.Logging.Filename = st.logFilename.Text()
-	st.cfg.Logging.MaxSize = int(st.logMaxSize.Value())
-	st.cfg.Logging.MaxBackups = int(st.logMaxBackups.Value())
-	st.cfg.Logging.MaxAge = int(st.logMaxAge.Value())
-	st.cfg.Logging.Compress = st.logCompress.Checked()
-	st.cfg.Logging.Level = st.logLevel.Text()
+	cfg.Logging.Filename = st.logFilename.Text()
+	cfg.Logging.MaxSize = int(st.logMaxSize.Value())
+	cfg.Logging.MaxBackups = int(st.logMaxBackups.Value())
+	cfg.Logging.MaxAge = int(st.logMaxAge.Value())
+	cfg.Logging.Compress = st.logCompress.Checked()
+	cfg.Logging.Level = st.logLevel.Text()
 
 	// Update CID Rules config
-	st.cfg.CIDRules.RequiredPrefix = st.requiredPrefix.Text()
-	st.cfg.CIDRules.ValidLength = int(st.validLength.Value())
-	st.cfg.CIDRules.AccNumOffset = int(st.accNumOffset.Value())
-	st.cfg.CIDRules.AccNumAdd = int(st.accNumAdd.Value())
+	cfg.CIDRules.RequiredPrefix = st.requiredPrefix.Text()
+	cfg.CIDRules.ValidLength = int(st.validLength.Value())
+	cfg.CIDRules.AccNumOffset = int(st.accNumOffset.Value())
+	cfg.CIDRules.AccNumAdd = int(st.accNumAdd.Value())
 
 	// Update Monitoring config
 	if ppkTimeout, err := time.ParseDuration(st.ppkTimeout.Text()); err == nil {
-		st.cfg.Monitoring.PPKTimeout = ppkTimeout
+		cfg.Monitoring.PPKTimeout = ppkTimeout
 	} else {
 		walk.MsgBox(nil, "Помилка",
 			fmt.Sprintf("Невірний формат таймауту ППК: %v", err),
@@ -335,27 +446,76 @@ func (st *SettingsTab) saveSettings() {
 	}
 
 	// Update UI config
-	st.cfg.UI.StartMinimized = st.startMinimized.Checked()
-	st.cfg.UI.MinimizeToTray = st.minimizeToTray.Checked()
-	st.cfg.UI.CloseToTray = st.closeToTray.Checked()
+	cfg.UI.StartMinimized = st.startMinimized.Checked()
+	cfg.UI.MinimizeToTray = st.minimizeToTray.Checked()
+	cfg.UI.CloseToTray = st.closeToTray.Checked()
+
+	// Update API config
+	cfg.API.Enabled = st.apiEnabled.Checked()
+	cfg.API.Listen = st.apiListen.Text()
+
+	// Save only the fields changed in the form, so values from the environment
+	// and the command line are not written to the file
+	var paths []string
+	for _, ch := range config.Diff(st.cfg, cfg) {
+		paths = append(paths, ch.Path)
+	}
 
-	// Save to file
-	if err := st.cfg.Save("config.yaml"); err != nil {
+	// Validate, save to file and apply to the running application
+	report, err := st.apply(cfg, paths)
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		walk.MsgBox(nil, "Помилка", formatValidationError(validationErr), walk.MsgBoxIconError)
+		return
+	}
+	if err != nil {
 		walk.MsgBox(nil, "Помилка",
 			fmt.Sprintf("Не вдалося зберегти налаштування: %v", err),
 			walk.MsgBoxIconError)
 		return
 	}
 
-	walk.MsgBox(nil, "Успіх",
-		"Налаштування успішно збережено!\n\nПерезапустіть додаток для застосування змін.",
-		walk.MsgBoxIconInformation)
+	st.cfg = st.current().Clone()
+	st.resetSettings()
+	walk.MsgBox(nil, "Успіх", formatReloadReport(report), walk.MsgBoxIconInformation)
+}
+
+// formatValidationError lists every invalid field, one per line
+func formatValidationError(verr *config.ValidationError) string {
+	var b strings.Builder
+	b.WriteString("Налаштування містять помилки і не були збережені:\n")
+	for _, fe := range verr.Errors {
+		b.WriteString("\n  ")
+		b.WriteString(fe.Error())
+	}
+	return b.String()
+}
+
+// formatReloadReport describes which settings were applied and which need a restart
+func formatReloadReport(report *config.ReloadReport) string {
+	var b strings.Builder
+	b.WriteString("Налаштування успішно збережено!")
+	if len(report.Applied) > 0 {
+		b.WriteString("\n\nЗастосовано без перезапуску:\n  ")
+		b.WriteString(strings.Join(report.Applied, "\n  "))
+	}
+	if len(report.Errors) > 0 {
+		b.WriteString("\n\nНе вдалося застосувати:\n  ")
+		b.WriteString(strings.Join(report.Errors, "\n  "))
+	}
+	if len(report.RestartRequired) > 0 {
+		b.WriteString("\n\nПерезапустіть додаток для застосування:\n  ")
+		b.WriteString(strings.Join(report.RestartRequired, "\n  "))
+	}
+	return b.String()
 }
 
 // resetSettings resets the form to the current configuration values
 func (st *SettingsTab) resetSettings() {
 	st.serverHost.SetText(st.cfg.Server.Host)
 	st.serverPort.SetText(st.cfg.Server.Port)
+	st.dedupWindow.SetText(st.cfg.Server.DedupWindow.String())
+	st.dedupMaxEntries.SetValue(float64(st.cfg.Server.DedupMaxEntries))
 
 	st.clientHost.SetText(st.cfg.Client.Host)
 	st.clientPort.SetText(st.cfg.Client.Port)
@@ -363,6 +523,9 @@ func (st *SettingsTab) resetSettings() {
 	st.reconnectMax.SetText(st.cfg.Client.ReconnectMax.String())
 
 	st.bufferSize.SetValue(float64(st.cfg.Queue.BufferSize))
+	st.queueMode.SetCurrentIndex(indexOf(queueModes, st.cfg.Queue.Mode, 0))
+	st.queueDequeue.SetCurrentIndex(indexOf(queueDequeueModes, st.cfg.Queue.Dequeue, 0))
+	st.preserveAccountOrder.SetChecked(st.cfg.Queue.PreserveAccountOrder)
 
 	st.logFilename.SetText(st.cfg.Logging.Filename)
 	st.logMaxSize.SetValue(float64(st.cfg.Logging.MaxSize))
@@ -399,10 +562,23 @@ func (st *SettingsTab) resetSettings() {
 	st.startMinimized.SetChecked(st.cfg.UI.StartMinimized)
 	st.minimizeToTray.SetChecked(st.cfg.UI.MinimizeToTray)
 	st.closeToTray.SetChecked(st.cfg.UI.CloseToTray)
+
+	st.apiEnabled.SetChecked(st.cfg.API.Enabled)
+	st.apiListen.SetText(st.cfg.API.Listen)
+}
+
+// indexOf returns the index of value in items, or def if it is absent
+func indexOf(items []string, value string, def int) int {
+	for i, item := range items {
+		if item == value {
+			return i
+		}
+	}
+	return def
 }
 
 // CreateSettingsTab is a helper function for backward compatibility
-func CreateSettingsTab(cfg *config.Config) TabPage {
-	st := NewSettingsTab(cfg)
+func CreateSettingsTab(current func() *config.Config, apply ApplyConfigFunc) TabPage {
+	st := NewSettingsTab(current, apply)
 	return st.CreateSettingsTab()
 }